@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"literedis/pkg/client"
 	"sync"
 )
@@ -18,15 +17,17 @@ type ClientPool struct {
 	pool chan *Client
 	addr string
 	size int
+	opts []client.Option
 	mtx  sync.Mutex
 }
 
-func NewClientPool(host string, port int, size int) *ClientPool {
-	addr := fmt.Sprintf("%s:%d", host, port)
+// NewClientPool 创建连接池，addr 为 host:port 或 unix:///path
+func NewClientPool(addr string, size int, opts ...client.Option) *ClientPool {
 	return &ClientPool{
 		pool: make(chan *Client, size),
 		addr: addr,
 		size: size,
+		opts: opts,
 	}
 }
 
@@ -38,7 +39,7 @@ func (p *ClientPool) Get() (*Client, error) {
 	case client := <-p.pool:
 		return client, nil
 	default:
-		c, err := client.NewClient(p.addr)
+		c, err := client.NewClient(p.addr, p.opts...)
 		if err != nil {
 			return nil, err
 		}
@@ -71,7 +72,7 @@ func (c *Client) Monitor(callback MonitorCallback) error {
 
 	// 持续读取并处理监控数据
 	for {
-		reply, err := c.Do("") // 使用空命令来接收数据
+		reply, err := c.Receive()
 		if err != nil {
 			return err
 		}
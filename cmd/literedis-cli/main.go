@@ -5,20 +5,54 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+	"literedis/pkg/client"
 )
 
 var (
-	host string
-	port int
-	pool *ClientPool
+	host    string
+	port    int
+	socket  string
+	pool    *ClientPool
+	tlsOpts tlsFlags
 )
 
+// tlsFlags TLS 相关的命令行参数
+type tlsFlags struct {
+	enabled  bool
+	caCert   string
+	cert     string
+	key      string
+	sni      string
+	insecure bool
+}
+
+// dialOptions 根据命令行参数生成连接选项
+func dialOptions() ([]client.Option, error) {
+	if !tlsOpts.enabled {
+		return nil, nil
+	}
+	conf, err := client.LoadTLSConfig(client.TLSFiles{
+		CACertFile: tlsOpts.caCert,
+		CertFile:   tlsOpts.cert,
+		KeyFile:    tlsOpts.key,
+		ServerName: tlsOpts.sni,
+		Insecure:   tlsOpts.insecure,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []client.Option{client.WithTLS(conf)}, nil
+}
+
 func main() {
 	rootCmd := newRootCommand()
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	if pool == nil {
+		return
+	}
 
 	// 添加一些测试数据
 	client, err := pool.Get()
@@ -46,16 +80,33 @@ func newRootCommand() *cobra.Command {
 		Use:   "literedis-cli",
 		Short: "LiteRedis CLI - A command line interface for LiteRedis",
 		Run:   runInteractiveMode,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := dialOptions()
+			if err != nil {
+				return err
+			}
+			addr := fmt.Sprintf("%s:%d", host, port)
+			if socket != "" {
+				addr = "unix://" + socket
+			}
+			pool = NewClientPool(addr, 10, opts...) // 创建一个大小为10的连接池
+			return nil
+		},
 	}
 
 	rootCmd.PersistentFlags().StringVarP(&host, "host", "h", "localhost", "Server hostname")
 	rootCmd.PersistentFlags().IntVarP(&port, "port", "p", 6379, "Server port")
+	rootCmd.PersistentFlags().StringVarP(&socket, "socket", "s", "", "Server socket (overrides hostname and port)")
+	rootCmd.PersistentFlags().BoolVar(&tlsOpts.enabled, "tls", false, "Establish a secure TLS connection")
+	rootCmd.PersistentFlags().StringVar(&tlsOpts.caCert, "cacert", "", "CA certificate file to verify the server with")
+	rootCmd.PersistentFlags().StringVar(&tlsOpts.cert, "cert", "", "Client certificate to authenticate with")
+	rootCmd.PersistentFlags().StringVar(&tlsOpts.key, "key", "", "Private key file to authenticate with")
+	rootCmd.PersistentFlags().StringVar(&tlsOpts.sni, "sni", "", "Server name indication for TLS")
+	rootCmd.PersistentFlags().BoolVar(&tlsOpts.insecure, "insecure", false, "Allow insecure TLS connection by skipping cert validation")
 
 	rootCmd.AddCommand(CreateCommands(&host, &port)...)
 	rootCmd.AddCommand(newCompletionCommand())
 
-	pool = NewClientPool(host, port, 10) // 创建一个大小为10的连接池
-
 	return rootCmd
 }
 
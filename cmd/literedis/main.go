@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 
 	"github.com/jessevdk/go-flags"
@@ -24,12 +25,38 @@ func main() {
 	}
 
 	log.Init("redis", currentFlags.LogLevel)
+	if config.Conf.LogFile != "" {
+		if err := log.SetFile(config.Conf.LogFile); err != nil {
+			log.Fatalf("open log file failed: %v", err)
+		}
+	}
+
+	// 命令行参数优先于配置文件
+	if currentFlags.Host != "" {
+		config.Set("bind", currentFlags.Host)
+	}
+	if currentFlags.Port != "" {
+		port, err := strconv.Atoi(currentFlags.Port)
+		if err != nil {
+			log.Fatalf("invalid port: %q", currentFlags.Port)
+		}
+		config.Set("port", port)
+	}
 
 	nodeID := currentFlags.Node
 	clusterNodes := currentFlags.Cluster
 
+	execMode, err := app.ParseExecMode(config.Conf.ExecMode)
+	if err != nil {
+		panic(err)
+	}
+
 	opts := []app.OptionFunc{
-		app.WithNodeID(nodeID),
+		app.WithExecMode(execMode),
+	}
+
+	if nodeID != "" {
+		opts = append(opts, app.WithNodeID(nodeID))
 	}
 
 	if clusterNodes != "" {
@@ -37,7 +64,9 @@ func main() {
 	}
 
 	a := app.NewApp(opts...)
-	a.Start()
+	if err := a.Start(); err != nil {
+		log.Fatalf("start server failed: %v", err)
+	}
 
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
@@ -50,6 +79,9 @@ func main() {
 			return
 		case syscall.SIGHUP:
 			config.LoadConfig()
+			if err := a.ReloadTLS(); err != nil {
+				log.Errorf("reload tls certificates failed: %v", err)
+			}
 		default:
 			return
 		}
@@ -57,10 +89,10 @@ func main() {
 }
 
 type Flags struct {
-	Host     string `short:"h" long:"host" description:"Specific server host" default:""`
-	Port     string `short:"p" long:"port" description:"Specific server port" default:""`
+	Host     string `short:"h" long:"host" description:"Bind addresses, separated by spaces" default:""`
+	Port     string `short:"p" long:"port" description:"Server port" default:""`
 	Node     string `short:"n" long:"node" description:"Node ID" default:""`
-	Cluster  string `short:"c" long:"cluster" description:""Comma-separated list of cluster nodes" default:""`
+	Cluster  string `short:"c" long:"cluster" description:"Comma-separated list of cluster nodes" default:""`
 	Setup    bool   `short:"S" long:"setup" description:"Run setup"`
 	Stream   bool   `short:"s" long:"stream" description:"Stream"`
 	Model    string `short:"m" long:"model" description:"Choose model"`
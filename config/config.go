@@ -1,6 +1,8 @@
 package config
 
 import (
+	"errors"
+
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 	"log"
@@ -12,27 +14,68 @@ var Conf = new(config)
 
 type config struct {
 	Mode            string
+	ExecMode        string `mapstructure:"exec_mode"` // threaded 或 eventloop
 	Name            string
 	AOFFile         string
 	IOBufferLength  int
 	LogLevel        string `mapstructure:"log_level"`
 	LogPath         string `mapstructure:"log_path"`
+	LogFile         string `mapstructure:"log_file"` // 不为空时日志只写到该文件
 	LogLevelAddr    string `mapstructure:"log_level_addr"`
 	LogLevelPattern string `mapstructure:"log_level_pattern"`
 
-	Bind           string
+	Bind           string // 空格分隔的多个地址，"-" 前缀表示地址不可用时跳过
 	Port           int
+	Timeout        int    // 客户端空闲多少秒后关闭，0 表示不关闭
+	TCPKeepAlive   int    `mapstructure:"tcp_keepalive"` // 秒，0 表示关闭
 	AppendOnly     bool   `mapstructure:"append_only"`
 	AppendFilename string `mapstructure:"append_filename"`
 	MaxClients     int    `mapstructure:"max_clients"`
+	MaxMemory      string `mapstructure:"maxmemory"` // 如 100mb，0 表示不限制
 	RequirePass    string `mapstructure:"require_pass"`
+	ACLFile        string `mapstructure:"acl_file"`
+	ACLLogMaxLen   int    `mapstructure:"acllog_max_len"`
 	Databases      int
 
+	SlowlogLogSlowerThan int `mapstructure:"slowlog_log_slower_than"` // 微秒，负数表示关闭，0 表示记录所有命令
+	SlowlogMaxLen        int `mapstructure:"slowlog_max_len"`
+
+	LatencyMonitorThreshold int `mapstructure:"latency_monitor_threshold"` // 毫秒，0 表示关闭
+
+	BusyReplyThreshold int `mapstructure:"busy_reply_threshold"` // 毫秒，脚本执行超过该时间之后其它命令返回 BUSY
+
+	NotifyKeyspaceEvents string `mapstructure:"notify_keyspace_events"` // 如 KEA，空字符串表示关闭
+
+	ListMaxListpackSize int `mapstructure:"list_max_listpack_size"` // 正数为每个节点的元素个数，-1 到 -5 为 4KB 到 64KB
+	ListCompressDepth   int `mapstructure:"list_compress_depth"`    // 列表两端不压缩的节点数，0 表示不压缩
+
+	HashMaxListpackEntries int `mapstructure:"hash_max_listpack_entries"` // 超过后哈希转换为 hashtable 编码
+	HashMaxListpackValue   int `mapstructure:"hash_max_listpack_value"`   // field 或 value 超过该长度后转换为 hashtable 编码
+
+	// 如 "pubsub 32mb 8mb 60"：类别、硬限制、软限制和允许超过软限制的秒数
+	ClientOutputBufferLimit string `mapstructure:"client_output_buffer_limit"`
+
+	UnixSocket     string `mapstructure:"unix_socket"`
+	UnixSocketPerm string `mapstructure:"unix_socket_perm"` // 八进制，如 700
+
+	TLSPort        int    `mapstructure:"tls_port"`
+	TLSCertFile    string `mapstructure:"tls_cert_file"`
+	TLSKeyFile     string `mapstructure:"tls_key_file"`
+	TLSCACertFile  string `mapstructure:"tls_ca_cert_file"`
+	TLSAuthClients string `mapstructure:"tls_auth_clients"` // yes、no 或 optional
+
 	Peers []string `cfg:"peers"`
 	Self  string   `cfg:"self"`
 
 	// 新增 RDB 相关配置
 	RDB RDBConfig `mapstructure:"rdb"`
+
+	HTTP HTTPConfig `mapstructure:"http"`
+}
+
+// HTTPConfig 提供 /metrics、/healthz 和 /readyz 的 HTTP 服务
+type HTTPConfig struct {
+	Addr string `mapstructure:"addr"` // 为空时不启动
 }
 
 type RDBConfig struct {
@@ -60,12 +103,25 @@ func LoadConfig(paths ...string) {
 	viper.AutomaticEnv()
 
 	viper.SetDefault("mode", "debug")
+	viper.SetDefault("exec_mode", "threaded")
 	viper.SetDefault("log_level", "info")
 	viper.SetDefault("log_path", "log")
 	viper.SetDefault("log_level_pattern", "/log/level")
 	viper.SetDefault("atomic_level_addr", "4240")
 
 	viper.SetDefault("http.addr", ":8090")
+	viper.SetDefault("port", 8080)
+	viper.SetDefault("max_clients", 10000)
+	viper.SetDefault("tcp_keepalive", 300)
+	viper.SetDefault("acllog_max_len", 128)
+	viper.SetDefault("slowlog_log_slower_than", 10000)
+	viper.SetDefault("slowlog_max_len", 128)
+	viper.SetDefault("busy_reply_threshold", 5000)
+	viper.SetDefault("list_max_listpack_size", -2)
+	viper.SetDefault("hash_max_listpack_entries", 128)
+	viper.SetDefault("hash_max_listpack_value", 64)
+	viper.SetDefault("client_output_buffer_limit", "normal 0 0 0 replica 256mb 64mb 60 pubsub 32mb 8mb 60")
+	viper.SetDefault("tls_auth_clients", "yes")
 
 	// 添加 RDB 相关的默认值
 	viper.SetDefault("rdb.filename", "dump.rdb")
@@ -74,22 +130,42 @@ func LoadConfig(paths ...string) {
 	viper.SetDefault("rdb.auto_save_changes", 1000)
 
 	if err := viper.ReadInConfig(); err != nil {
-		log.Panicf("read config error: %v", err)
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			log.Panicf("read config error: %v", err)
+		}
+		log.Printf("no config file found, using defaults")
 	}
-	if err := viper.Unmarshal(Conf); err != nil {
+	if err := unmarshal(); err != nil {
 		log.Panicf("unmarshal config err: %v", err)
 	}
 
 	viper.WatchConfig()
 	viper.OnConfigChange(func(e fsnotify.Event) {
 		log.Printf("config change: %s, %s, %s\n", e.Op.String(), e.Name, e.String())
-		if err := viper.Unmarshal(Conf); err != nil {
+		if err := unmarshal(); err != nil {
 			log.Printf("config change unmarshal err: %v", err)
 		}
 	})
 	log.Println("load config successfully")
 }
 
+// unmarshal 解码到新的结构体再整体替换，避免已删除的配置项保留旧值
+func unmarshal() error {
+	c := new(config)
+	if err := viper.Unmarshal(c); err != nil {
+		return err
+	}
+	*Conf = *c
+	return nil
+}
+
+// Set 在运行时覆盖配置项，优先级高于配置文件，如命令行参数
+func Set(key string, value interface{}) error {
+	viper.Set(key, value)
+	return unmarshal()
+}
+
 // 新增方法，用于获取 RDB 配置
 func GetRDBConfig() RDBConfig {
 	return Conf.RDB
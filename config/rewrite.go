@@ -0,0 +1,113 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNoConfigFile 没有使用配置文件启动时无法写回
+var ErrNoConfigFile = errors.New("The server is running without a config file")
+
+// Entry 写回配置文件的一项
+type Entry struct {
+	Key    string // 点分隔的路径，如 rdb.save_interval
+	Value  string
+	Tag    string // YAML 类型标签，如 !!str、!!int
+	Append bool   // 文件中没有该项时追加到末尾
+}
+
+// File 返回启动时使用的配置文件，没有时返回空字符串
+func File() string {
+	return viper.ConfigFileUsed()
+}
+
+// Rewrite 把配置项写回配置文件，保留原有的注释和顺序。
+// 文件中已有的项总是更新，没有的项只在 Append 为 true 时追加
+func Rewrite(file string, entries []Entry) error {
+	if file == "" {
+		return ErrNoConfigFile
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return errors.New("config file root is not a mapping")
+	}
+
+	for _, e := range entries {
+		setNode(root, strings.Split(e.Key, "."), e)
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	enc.Close()
+	// 先写临时文件再重命名，避免写到一半时配置文件损坏
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".literedis-config-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(file); err == nil {
+		os.Chmod(tmp.Name(), fi.Mode())
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// setNode 在 mapping 中按路径设置标量值，返回是否设置成功
+func setNode(m *yaml.Node, path []string, e Entry) bool {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value != path[0] {
+			continue
+		}
+		v := m.Content[i+1]
+		if len(path) == 1 {
+			v.Kind, v.Tag, v.Value, v.Style, v.Content = yaml.ScalarNode, e.Tag, e.Value, 0, nil
+			return true
+		}
+		if v.Kind != yaml.MappingNode {
+			return false
+		}
+		return setNode(v, path[1:], e)
+	}
+	if !e.Append {
+		return false
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}
+	var v *yaml.Node
+	if len(path) == 1 {
+		v = &yaml.Node{Kind: yaml.ScalarNode, Tag: e.Tag, Value: e.Value}
+	} else {
+		v = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		setNode(v, path[1:], e)
+	}
+	m.Content = append(m.Content, key, v)
+	return true
+}
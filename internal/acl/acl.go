@@ -0,0 +1,225 @@
+package acl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"literedis/internal/consts"
+)
+
+// DefaultUser 新连接默认使用的用户
+const DefaultUser = "default"
+
+var (
+	ErrNoACLFile     = errors.New("This instance is not configured to use an ACL file. You may want to specify users via the ACL SETUSER command and then issue a CONFIG REWRITE in order to store users in the configuration.")
+	ErrDeleteDefault = errors.New("The 'default' user cannot be removed")
+)
+
+// ACL 管理所有用户、ACL 文件和 ACL LOG
+type ACL struct {
+	mu    sync.RWMutex
+	users map[string]*User
+	file  string
+	Log   *Log
+}
+
+// New 创建只包含 default 用户的 ACL，file 为空时不支持 ACL SAVE/LOAD
+func New(file string, logMaxLen int) *ACL {
+	return &ACL{
+		users: map[string]*User{DefaultUser: newDefaultUser()},
+		file:  file,
+		Log:   NewLog(logMaxLen),
+	}
+}
+
+func newDefaultUser() *User {
+	u := NewUser(DefaultUser)
+	u.SetRules("on", "nopass", "~*", "&*", "+@all")
+	return u
+}
+
+// File 返回 ACL 文件路径
+func (a *ACL) File() string {
+	return a.file
+}
+
+// SetRequirePass 按照 requirepass 设置 default 用户的密码，空字符串表示无需密码
+func (a *ACL) SetRequirePass(password string) {
+	if password == "" {
+		a.SetUser(DefaultUser, "nopass")
+		return
+	}
+	a.SetUser(DefaultUser, "resetpass", ">"+password)
+}
+
+// User 返回用户，不存在时返回 nil。返回的用户不会再被修改
+func (a *ACL) User(name string) *User {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.users[name]
+}
+
+// SetUser 创建或修改用户。规则全部成功才会生效
+func (a *ACL) SetUser(name string, rules ...string) error {
+	if name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return errors.New("Usernames can't contain spaces or null characters")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var u *User
+	if old, ok := a.users[name]; ok {
+		u = old.Clone()
+	} else {
+		u = NewUser(name)
+	}
+	if err := u.SetRules(rules...); err != nil {
+		return err
+	}
+	a.users[name] = u
+	return nil
+}
+
+// DelUser 删除用户并返回实际删除的数量
+func (a *ACL) DelUser(names ...string) (int, error) {
+	for _, name := range names {
+		if name == DefaultUser {
+			return 0, ErrDeleteDefault
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	deleted := 0
+	for _, name := range names {
+		if _, ok := a.users[name]; ok {
+			delete(a.users, name)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Users 返回所有用户名（已排序）
+func (a *ACL) Users() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	names := make([]string, 0, len(a.users))
+	for name := range a.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List 返回所有用户的规则描述，格式同 ACL 文件
+func (a *ACL) List() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	lines := make([]string, 0, len(a.users))
+	for _, u := range a.users {
+		lines = append(lines, u.Describe())
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// Authenticate 校验用户名和密码
+func (a *ACL) Authenticate(username, password string) (*User, error) {
+	u := a.User(username)
+	if u == nil || !u.Enabled || !u.CheckPassword(password) {
+		return nil, consts.ErrAuthFailed
+	}
+	return u, nil
+}
+
+// Save 将所有用户写入 ACL 文件
+func (a *ACL) Save() error {
+	if a.file == "" {
+		return ErrNoACLFile
+	}
+
+	tmp := a.file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, line := range a.List() {
+		w.WriteString(line)
+		w.WriteString("\n")
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, a.file)
+}
+
+// Load 从 ACL 文件重新加载所有用户。文件有任何错误时保持原有用户不变
+func (a *ACL) Load() error {
+	if a.file == "" {
+		return ErrNoACLFile
+	}
+
+	users, err := parseFile(a.file)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.users = users
+	return nil
+}
+
+func parseFile(path string) (map[string]*User, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	users := make(map[string]*User)
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] != "user" || len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: line should start with user keyword", path, lineNum)
+		}
+		name := fields[1]
+		if _, ok := users[name]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate user '%s' found", path, lineNum, name)
+		}
+		u := NewUser(name)
+		if err := u.SetRules(fields[2:]...); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNum, err)
+		}
+		users[name] = u
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := users[DefaultUser]; !ok {
+		users[DefaultUser] = newDefaultUser()
+	}
+	return users, nil
+}
@@ -0,0 +1,247 @@
+package acl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"literedis/internal/commands"
+)
+
+func TestDefaultUser(t *testing.T) {
+	a := New("", 128)
+	u := a.User(DefaultUser)
+	if u == nil || !u.Enabled || !u.NoPass {
+		t.Fatalf("unexpected default user: %+v", u)
+	}
+	if got := u.Describe(); got != "user default on nopass ~* &* +@all" {
+		t.Errorf("Describe() = %q", got)
+	}
+
+	a.SetRequirePass("secret")
+	if _, err := a.Authenticate(DefaultUser, "wrong"); err == nil {
+		t.Error("expected wrong password to fail")
+	}
+	if _, err := a.Authenticate(DefaultUser, "secret"); err != nil {
+		t.Errorf("Authenticate failed: %v", err)
+	}
+}
+
+func TestCommandRules(t *testing.T) {
+	u := NewUser("alice")
+	if err := u.SetRules("on", "+@read", "-get", "+flushdb", "+cluster|info"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		cmd, sub string
+		want     bool
+	}{
+		{"GET", "", false},
+		{"LRANGE", "", true},
+		{"hget", "", true},
+		{"SET", "", false},
+		{"FLUSHDB", "", true},
+		{"FLUSHALL", "", false},
+		{"CLUSTER", "info", true},
+		{"CLUSTER", "join", false},
+	}
+	for _, tt := range tests {
+		if got := u.CanRun(tt.cmd, tt.sub); got != tt.want {
+			t.Errorf("CanRun(%s %s) = %v, want %v", tt.cmd, tt.sub, got, tt.want)
+		}
+	}
+
+	if err := u.SetRule("+@all"); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.SetRule("-flushall"); err != nil {
+		t.Fatal(err)
+	}
+	if !u.CanRun("SET", "") || u.CanRun("FLUSHALL", "") {
+		t.Error("+@all -flushall not applied")
+	}
+	if got := u.CommandRules(); got != "+@all -flushall" {
+		t.Errorf("CommandRules() = %q", got)
+	}
+
+	for _, rule := range []string{"+nosuchcmd", "+@nosuchcat", "bogus", "#abc"} {
+		if err := u.SetRule(rule); err == nil {
+			t.Errorf("rule %q should be rejected", rule)
+		}
+	}
+}
+
+func TestKeyRules(t *testing.T) {
+	u := NewUser("bob")
+	if err := u.SetRules("~cache:*", "%R~ro:*", "%W~wo:*"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key         string
+		read, write bool
+	}{
+		{"cache:1", true, true},
+		{"ro:1", true, false},
+		{"wo:1", false, true},
+		{"other", false, false},
+		{"cache:a/b", true, true},
+	}
+	for _, tt := range tests {
+		if got := u.CanAccessKey(tt.key, false); got != tt.read {
+			t.Errorf("read %s = %v, want %v", tt.key, got, tt.read)
+		}
+		if got := u.CanAccessKey(tt.key, true); got != tt.write {
+			t.Errorf("write %s = %v, want %v", tt.key, got, tt.write)
+		}
+	}
+	if got := u.KeyRules(); got != "~cache:* %R~ro:* %W~wo:*" {
+		t.Errorf("KeyRules() = %q", got)
+	}
+
+	u.SetRules("+@all")
+	get, _ := commands.LookupSpec("GET")
+	set, _ := commands.LookupSpec("SET")
+	if _, _, ok := u.Check("GET", get, []string{"ro:1"}); !ok {
+		t.Error("GET ro:1 should be allowed")
+	}
+	if reason, object, ok := u.Check("SET", set, []string{"ro:1", "v"}); ok || reason != "key" || object != "ro:1" {
+		t.Errorf("SET ro:1 = %s %s %v", reason, object, ok)
+	}
+
+	// 未闭合的 [ 延伸到模式结尾，和 Redis 一样不算语法错误
+	if err := u.SetRule("~tmp[ab"); err != nil || !u.CanAccessKey("tmpb", true) {
+		t.Errorf("~tmp[ab = %v", err)
+	}
+}
+
+func TestChannelRules(t *testing.T) {
+	u := NewUser("carol")
+	u.SetRules("&news.*")
+	if !u.CanAccessChannel("news.tech", false) {
+		t.Error("news.tech should be allowed")
+	}
+	if !u.CanAccessChannel("news.world/eu", false) {
+		t.Error("news.world/eu should be allowed")
+	}
+	if u.CanAccessChannel("sports", false) {
+		t.Error("sports should be denied")
+	}
+	if !u.CanAccessChannel("news.*", true) || u.CanAccessChannel("news.t*", true) {
+		t.Error("patterns must match a rule literally")
+	}
+
+	u.SetRule("resetchannels")
+	if u.CanAccessChannel("news.tech", false) {
+		t.Error("resetchannels not applied")
+	}
+	u.SetRule("allchannels")
+	if !u.CanAccessChannel("anything", true) {
+		t.Error("allchannels not applied")
+	}
+}
+
+func TestPasswords(t *testing.T) {
+	u := NewUser("dave")
+	u.SetRules("on", ">p1", ">p2")
+	if !u.CheckPassword("p1") || !u.CheckPassword("p2") || u.CheckPassword("p3") {
+		t.Error("password check failed")
+	}
+	u.SetRule("<p1")
+	if u.CheckPassword("p1") {
+		t.Error("p1 should have been removed")
+	}
+	if err := u.SetRule("<p1"); err == nil {
+		t.Error("removing a missing password should fail")
+	}
+	u.SetRule("#" + hashPassword("p4"))
+	if !u.CheckPassword("p4") {
+		t.Error("hashed password not accepted")
+	}
+	u.SetRule("nopass")
+	if !u.CheckPassword("anything") {
+		t.Error("nopass should accept any password")
+	}
+}
+
+func TestSetUserIsAtomic(t *testing.T) {
+	a := New("", 128)
+	if err := a.SetUser("eve", "on", ">pw", "+get"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetUser("eve", "+set", "+nosuchcmd"); err == nil {
+		t.Fatal("expected error")
+	}
+	if a.User("eve").CanRun("SET", "") {
+		t.Error("failed SETUSER must not change the user")
+	}
+}
+
+func TestDelUser(t *testing.T) {
+	a := New("", 128)
+	a.SetUser("frank")
+	if _, err := a.DelUser(DefaultUser); err == nil {
+		t.Error("default user must not be deletable")
+	}
+	n, err := a.DelUser("frank", "nobody")
+	if err != nil || n != 1 {
+		t.Errorf("DelUser = %d, %v", n, err)
+	}
+	if got := strings.Join(a.Users(), ","); got != "default" {
+		t.Errorf("Users() = %s", got)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "users.acl")
+	a := New(file, 128)
+	a.SetUser("grace", "on", ">secret", "~cache:*", "%R~ro:*", "&news.*", "+@read", "-lrange")
+	a.SetRequirePass("root")
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	b := New(file, 128)
+	if err := b.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(a.List(), "\n") != strings.Join(b.List(), "\n") {
+		t.Errorf("round trip mismatch:\n%v\n%v", a.List(), b.List())
+	}
+	u := b.User("grace")
+	if !u.CheckPassword("secret") || !u.CanRun("GET", "") || u.CanRun("LRANGE", "") {
+		t.Error("loaded user lost its rules")
+	}
+
+	os.WriteFile(file, []byte("user broken on +nosuchcmd\n"), 0644)
+	if err := b.Load(); err == nil {
+		t.Error("expected load error")
+	}
+	if b.User("grace") == nil {
+		t.Error("failed load must keep existing users")
+	}
+}
+
+func TestLog(t *testing.T) {
+	l := NewLog(2)
+	l.Add("command", "toplevel", "get", "alice", "id=1")
+	l.Add("command", "toplevel", "get", "alice", "id=2")
+	entries := l.Entries(-1)
+	if len(entries) != 1 || entries[0].Count != 2 || entries[0].ClientInfo != "id=2" {
+		t.Fatalf("entries not grouped: %+v", entries)
+	}
+
+	l.Add("key", "toplevel", "k", "alice", "id=1")
+	l.Add("auth", "toplevel", "AUTH", "bob", "id=3")
+	entries = l.Entries(-1)
+	if len(entries) != 2 || entries[0].Reason != "auth" || entries[1].Reason != "key" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	l.Reset()
+	if len(l.Entries(-1)) != 0 {
+		t.Error("Reset did not clear the log")
+	}
+}
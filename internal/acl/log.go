@@ -0,0 +1,103 @@
+package acl
+
+import (
+	"sync"
+	"time"
+)
+
+// 同一用户对同一对象的相同拒绝在该时间内合并为一条记录
+const logGroupingWindow = 60 * time.Second
+
+// LogEntry ACL LOG 中的一条记录
+type LogEntry struct {
+	Count      int
+	Reason     string // command、key、channel 或 auth
+	Context    string // toplevel、multi、lua
+	Object     string
+	Username   string
+	ClientInfo string
+	EntryID    int64
+	Created    time.Time
+	Updated    time.Time
+}
+
+// Log 记录被拒绝的命令和认证失败，最新的记录排在最前
+type Log struct {
+	mu      sync.Mutex
+	entries []*LogEntry
+	maxLen  int
+	nextID  int64
+}
+
+func NewLog(maxLen int) *Log {
+	return &Log{maxLen: maxLen}
+}
+
+// SetMaxLen 修改最大记录数，多余的旧记录会被丢弃
+func (l *Log) SetMaxLen(maxLen int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxLen = maxLen
+	l.trim()
+}
+
+// Add 添加一条记录，与最近的相同记录合并
+func (l *Log) Add(reason, context, object, username, clientInfo string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for i, e := range l.entries {
+		if e.Reason == reason && e.Context == context && e.Object == object &&
+			e.Username == username && now.Sub(e.Created) < logGroupingWindow {
+			e.Count++
+			e.Updated = now
+			e.ClientInfo = clientInfo
+			copy(l.entries[1:i+1], l.entries[:i])
+			l.entries[0] = e
+			return
+		}
+	}
+
+	e := &LogEntry{
+		Count:      1,
+		Reason:     reason,
+		Context:    context,
+		Object:     object,
+		Username:   username,
+		ClientInfo: clientInfo,
+		EntryID:    l.nextID,
+		Created:    now,
+		Updated:    now,
+	}
+	l.nextID++
+	l.entries = append([]*LogEntry{e}, l.entries...)
+	l.trim()
+}
+
+func (l *Log) trim() {
+	if l.maxLen >= 0 && len(l.entries) > l.maxLen {
+		l.entries = l.entries[:l.maxLen]
+	}
+}
+
+// Entries 返回最新的 count 条记录，count 小于 0 返回全部
+func (l *Log) Entries(count int) []LogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if count < 0 || count > len(l.entries) {
+		count = len(l.entries)
+	}
+	entries := make([]LogEntry, count)
+	for i := 0; i < count; i++ {
+		entries[i] = *l.entries[i]
+	}
+	return entries
+}
+
+// Reset 清空所有记录
+func (l *Log) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = nil
+}
@@ -0,0 +1,483 @@
+package acl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"literedis/internal/commands"
+	"literedis/pkg/glob"
+)
+
+// keyPattern 键权限规则，对应 ~pattern、%R~pattern、%W~pattern
+type keyPattern struct {
+	pattern string
+	read    bool
+	write   bool
+}
+
+func (k keyPattern) String() string {
+	switch {
+	case k.read && k.write:
+		return "~" + k.pattern
+	case k.read:
+		return "%R~" + k.pattern
+	default:
+		return "%W~" + k.pattern
+	}
+}
+
+// User 一个 ACL 用户。发布到 ACL 之后不再修改，修改时先 Clone
+type User struct {
+	Name      string
+	Enabled   bool
+	NoPass    bool
+	passwords []string // sha256 十六进制摘要，保持添加顺序
+	keys      []keyPattern
+	channels  []string
+	commands  map[string]bool            // 小写命令名 -> 是否允许
+	subs      map[string]map[string]bool // 子命令例外，如 config|get
+	cmdRules  []string                   // 用于描述的命令规则
+}
+
+// NewUser 创建一个新用户，默认禁用且没有任何权限
+func NewUser(name string) *User {
+	return &User{
+		Name:     name,
+		commands: make(map[string]bool),
+		subs:     make(map[string]map[string]bool),
+		cmdRules: []string{"-@all"},
+	}
+}
+
+// Clone 返回用户的深拷贝
+func (u *User) Clone() *User {
+	c := &User{
+		Name:      u.Name,
+		Enabled:   u.Enabled,
+		NoPass:    u.NoPass,
+		passwords: append([]string(nil), u.passwords...),
+		keys:      append([]keyPattern(nil), u.keys...),
+		channels:  append([]string(nil), u.channels...),
+		commands:  make(map[string]bool, len(u.commands)),
+		subs:      make(map[string]map[string]bool, len(u.subs)),
+		cmdRules:  append([]string(nil), u.cmdRules...),
+	}
+	for k, v := range u.commands {
+		c.commands[k] = v
+	}
+	for cmd, subs := range u.subs {
+		m := make(map[string]bool, len(subs))
+		for k, v := range subs {
+			m[k] = v
+		}
+		c.subs[cmd] = m
+	}
+	return c
+}
+
+// SetRules 依次应用规则，遇到错误时返回，之前的规则已生效
+func (u *User) SetRules(rules ...string) error {
+	for _, rule := range rules {
+		if err := u.SetRule(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetRule 应用一条 ACL 规则
+func (u *User) SetRule(rule string) error {
+	if rule == "" {
+		return fmt.Errorf("Error in ACL SETUSER modifier '': Syntax error")
+	}
+	lower := strings.ToLower(rule)
+	switch lower {
+	case "on":
+		u.Enabled = true
+		return nil
+	case "off":
+		u.Enabled = false
+		return nil
+	case "nopass":
+		u.NoPass = true
+		u.passwords = nil
+		return nil
+	case "resetpass":
+		u.NoPass = false
+		u.passwords = nil
+		return nil
+	case "allkeys":
+		u.keys = []keyPattern{{pattern: "*", read: true, write: true}}
+		return nil
+	case "resetkeys":
+		u.keys = nil
+		return nil
+	case "allchannels":
+		u.channels = []string{"*"}
+		return nil
+	case "resetchannels":
+		u.channels = nil
+		return nil
+	case "allcommands":
+		u.applyCommandRule("+@all")
+		return nil
+	case "nocommands":
+		u.applyCommandRule("-@all")
+		return nil
+	case "reset":
+		u.Enabled = false
+		u.NoPass = false
+		u.passwords = nil
+		u.keys = nil
+		u.channels = nil
+		u.applyCommandRule("-@all")
+		return nil
+	}
+
+	switch rule[0] {
+	case '>':
+		u.addPassword(hashPassword(rule[1:]))
+		return nil
+	case '<':
+		return u.removePassword(hashPassword(rule[1:]), rule)
+	case '#':
+		hash := strings.ToLower(rule[1:])
+		if !isValidHash(hash) {
+			return ruleError(rule, "The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters")
+		}
+		u.addPassword(hash)
+		return nil
+	case '!':
+		hash := strings.ToLower(rule[1:])
+		if !isValidHash(hash) {
+			return ruleError(rule, "The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters")
+		}
+		return u.removePassword(hash, rule)
+	case '~':
+		u.addKeyPattern(keyPattern{pattern: rule[1:], read: true, write: true})
+		return nil
+	case '%':
+		return u.parseKeyPermission(rule)
+	case '&':
+		pattern := rule[1:]
+		if pattern == "*" {
+			u.channels = []string{"*"}
+		} else if !u.hasChannel("*") && !u.hasChannel(pattern) {
+			u.channels = append(u.channels, pattern)
+		}
+		return nil
+	case '+', '-':
+		return u.parseCommandRule(rule)
+	}
+	return ruleError(rule, "Syntax error")
+}
+
+func ruleError(rule, reason string) error {
+	return fmt.Errorf("Error in ACL SETUSER modifier '%s': %s", rule, reason)
+}
+
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func isValidHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+func (u *User) addPassword(hash string) {
+	u.NoPass = false
+	for _, p := range u.passwords {
+		if p == hash {
+			return
+		}
+	}
+	u.passwords = append(u.passwords, hash)
+}
+
+func (u *User) removePassword(hash, rule string) error {
+	for i, p := range u.passwords {
+		if p == hash {
+			u.passwords = append(u.passwords[:i], u.passwords[i+1:]...)
+			return nil
+		}
+	}
+	return ruleError(rule, "no such password")
+}
+
+func (u *User) parseKeyPermission(rule string) error {
+	perm, pattern, found := strings.Cut(rule[1:], "~")
+	if !found || perm == "" {
+		return ruleError(rule, "Syntax error")
+	}
+	kp := keyPattern{pattern: pattern}
+	for _, c := range strings.ToUpper(perm) {
+		switch c {
+		case 'R':
+			kp.read = true
+		case 'W':
+			kp.write = true
+		default:
+			return ruleError(rule, "Syntax error")
+		}
+	}
+	u.addKeyPattern(kp)
+	return nil
+}
+
+// addKeyPattern 添加键模式，相同的模式合并权限。glob 模式没有非法写法，不需要校验
+func (u *User) addKeyPattern(kp keyPattern) {
+	for i, k := range u.keys {
+		if k.pattern == kp.pattern {
+			u.keys[i].read = k.read || kp.read
+			u.keys[i].write = k.write || kp.write
+			return
+		}
+	}
+	u.keys = append(u.keys, kp)
+}
+
+func (u *User) hasChannel(pattern string) bool {
+	for _, c := range u.channels {
+		if c == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *User) parseCommandRule(rule string) error {
+	allow := rule[0] == '+'
+	name := strings.ToLower(rule[1:])
+
+	if strings.HasPrefix(name, "@") {
+		category := name[1:]
+		if category != "all" && !isCategory(category) {
+			return ruleError(rule, "Unknown command or category name in ACL")
+		}
+		u.applyCommandRule(string(rule[0]) + "@" + category)
+		return nil
+	}
+
+	cmd, sub, isSub := strings.Cut(name, "|")
+	if _, ok := commands.LookupSpec(cmd); !ok {
+		return ruleError(rule, "Unknown command or category name in ACL")
+	}
+	if isSub {
+		if sub == "" || strings.Contains(sub, "|") {
+			return ruleError(rule, "Syntax error")
+		}
+		if u.subs[cmd] == nil {
+			u.subs[cmd] = make(map[string]bool)
+		}
+		u.subs[cmd][sub] = allow
+		u.cmdRules = append(u.cmdRules, string(rule[0])+name)
+		return nil
+	}
+	u.applyCommandRule(string(rule[0]) + cmd)
+	return nil
+}
+
+// applyCommandRule 应用已校验过的 +cmd/-cmd/+@cat/-@cat 规则并记录描述
+func (u *User) applyCommandRule(rule string) {
+	allow := rule[0] == '+'
+	name := rule[1:]
+
+	if name == "@all" {
+		for _, cmd := range commands.CommandNames() {
+			u.commands[strings.ToLower(cmd)] = allow
+		}
+		u.subs = make(map[string]map[string]bool)
+		u.cmdRules = []string{rule}
+		return
+	}
+
+	if strings.HasPrefix(name, "@") {
+		for _, cmd := range commands.CommandNames() {
+			spec, _ := commands.LookupSpec(cmd)
+			if spec.InCategory(name[1:]) {
+				u.commands[strings.ToLower(cmd)] = allow
+				delete(u.subs, strings.ToLower(cmd))
+			}
+		}
+	} else {
+		u.commands[name] = allow
+		delete(u.subs, name)
+	}
+	u.cmdRules = append(u.cmdRules, rule)
+}
+
+func isCategory(name string) bool {
+	for _, c := range commands.Categories {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPassword 校验密码，nopass 用户接受任意密码
+func (u *User) CheckPassword(password string) bool {
+	if u.NoPass {
+		return true
+	}
+	hash := hashPassword(password)
+	for _, p := range u.passwords {
+		if p == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// CanRun 判断用户能否执行命令，sub 为第一个参数（可能是子命令）
+func (u *User) CanRun(cmd, sub string) bool {
+	cmd = strings.ToLower(cmd)
+	if sub != "" {
+		if allowed, ok := u.subs[cmd][strings.ToLower(sub)]; ok {
+			return allowed
+		}
+	}
+	return u.commands[cmd]
+}
+
+// CanAccessKey 判断用户能否读（write 为 false）或写该键
+func (u *User) CanAccessKey(key string, write bool) bool {
+	for _, k := range u.keys {
+		if write && !k.write || !write && !k.read {
+			continue
+		}
+		if k.pattern == "*" {
+			return true
+		}
+		if glob.Match(k.pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessChannel 判断用户能否访问频道。对于 PSUBSCRIBE 的模式，
+// 只有与某条规则完全相同（或允许所有频道）时才放行
+func (u *User) CanAccessChannel(channel string, isPattern bool) bool {
+	for _, c := range u.channels {
+		if c == "*" {
+			return true
+		}
+		if isPattern {
+			if c == channel {
+				return true
+			}
+			continue
+		}
+		if glob.Match(c, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check 检查用户能否执行命令，失败时返回拒绝原因（command/key/channel）和对象
+func (u *User) Check(cmd string, spec commands.CommandSpec, args []string) (reason, object string, ok bool) {
+	sub := ""
+	if len(args) > 0 {
+		sub = args[0]
+	}
+	if !u.CanRun(cmd, sub) {
+		name := strings.ToLower(cmd)
+		if _, isSub := u.subs[name][strings.ToLower(sub)]; isSub {
+			name += "|" + strings.ToLower(sub)
+		}
+		return "command", name, false
+	}
+	write := spec.Flags&commands.FlagWrite != 0
+	for _, key := range spec.Keys(args) {
+		if !u.CanAccessKey(key, write) {
+			return "key", key, false
+		}
+	}
+	return "", "", true
+}
+
+// Flags 返回 ACL GETUSER 中的 flags
+func (u *User) Flags() []string {
+	flags := []string{"off"}
+	if u.Enabled {
+		flags[0] = "on"
+	}
+	if u.NoPass {
+		flags = append(flags, "nopass")
+	}
+	return flags
+}
+
+// Passwords 返回密码摘要列表
+func (u *User) Passwords() []string {
+	return append([]string(nil), u.passwords...)
+}
+
+// CommandRules 返回命令规则的描述
+func (u *User) CommandRules() string {
+	return strings.Join(u.cmdRules, " ")
+}
+
+// KeyRules 返回键规则的描述
+func (u *User) KeyRules() string {
+	rules := make([]string, len(u.keys))
+	for i, k := range u.keys {
+		rules[i] = k.String()
+	}
+	return strings.Join(rules, " ")
+}
+
+// ChannelRules 返回频道规则的描述
+func (u *User) ChannelRules() string {
+	rules := make([]string, len(u.channels))
+	for i, c := range u.channels {
+		rules[i] = "&" + c
+	}
+	return strings.Join(rules, " ")
+}
+
+// Describe 返回可以重新加载的完整规则，格式同 ACL LIST
+func (u *User) Describe() string {
+	parts := []string{"user", u.Name}
+	parts = append(parts, u.Flags()...)
+	for _, p := range u.passwords {
+		parts = append(parts, "#"+p)
+	}
+	channels := u.ChannelRules()
+	if channels == "" {
+		channels = "resetchannels"
+	}
+	for _, rules := range []string{u.KeyRules(), channels, u.CommandRules()} {
+		if rules != "" {
+			parts = append(parts, rules)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// CategoryCommands 返回属于某个分类的命令名（小写，已排序）
+func CategoryCommands(category string) ([]string, bool) {
+	category = strings.ToLower(category)
+	if !isCategory(category) {
+		return nil, false
+	}
+	var names []string
+	for _, cmd := range commands.CommandNames() {
+		spec, _ := commands.LookupSpec(cmd)
+		if spec.InCategory(category) {
+			names = append(names, strings.ToLower(cmd))
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
@@ -0,0 +1,236 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"literedis/internal/acl"
+	"literedis/internal/commands"
+	"literedis/internal/consts"
+	"literedis/pkg/protocol"
+)
+
+// checkPermission 按照客户端当前用户的 ACL 规则检查命令、键的权限，拒绝时写入 ACL LOG
+func (a *App) checkPermission(c *client, cmdName string, spec commands.CommandSpec, args []string) error {
+	if spec.Flags&commands.FlagNoAuth != 0 {
+		return nil
+	}
+	u := a.acl.User(c.user)
+	if u == nil {
+		return fmt.Errorf("NOPERM User %s has no permissions to run the '%s' command", c.user, strings.ToLower(cmdName))
+	}
+	reason, object, ok := u.Check(cmdName, spec, args)
+	if ok {
+		return nil
+	}
+	a.acl.Log.Add(reason, "toplevel", object, c.user, c.info())
+	return permissionError(c.user, reason, object)
+}
+
+func permissionError(user, reason, object string) error {
+	switch reason {
+	case "key":
+		return errors.New("NOPERM No permissions to access a key")
+	case "channel":
+		return errors.New("NOPERM No permissions to access a channel")
+	}
+	return fmt.Errorf("NOPERM User %s has no permissions to run the '%s' command", user, object)
+}
+
+func (a *App) handleAuth(c *client, args []string) (*protocol.Message, error) {
+	var username, password string
+	switch len(args) {
+	case 1:
+		username, password = acl.DefaultUser, args[0]
+		if u := a.acl.User(acl.DefaultUser); u != nil && u.NoPass {
+			return nil, errors.New("AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+		}
+	case 2:
+		username, password = args[0], args[1]
+	default:
+		return nil, consts.ErrSyntaxError
+	}
+
+	if _, err := a.acl.Authenticate(username, password); err != nil {
+		a.acl.Log.Add("auth", "toplevel", "AUTH", username, c.info())
+		return nil, err
+	}
+	c.user = username
+	c.authenticated = true
+	return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+}
+
+func (a *App) handleACL(c *client, args []string) (*protocol.Message, error) {
+	name := args[0]
+	sub := strings.ToLower(name)
+	args = args[1:]
+	wrongArgs := fmt.Errorf("wrong number of arguments for 'acl|%s' command", sub)
+
+	switch sub {
+	case "setuser":
+		if len(args) < 1 {
+			return nil, wrongArgs
+		}
+		if err := a.acl.SetUser(args[0], args[1:]...); err != nil {
+			return nil, err
+		}
+		return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+
+	case "getuser":
+		if len(args) != 1 {
+			return nil, wrongArgs
+		}
+		u := a.acl.User(args[0])
+		if u == nil {
+			return &protocol.Message{Type: "Null"}, nil
+		}
+		return &protocol.Message{Type: "Array", Content: []*protocol.Message{
+			bulk("flags"), {Type: "Array", Content: u.Flags()},
+			bulk("passwords"), {Type: "Array", Content: u.Passwords()},
+			bulk("commands"), bulk(u.CommandRules()),
+			bulk("keys"), bulk(u.KeyRules()),
+			bulk("channels"), bulk(u.ChannelRules()),
+			bulk("selectors"), {Type: "Array", Content: []*protocol.Message{}},
+		}}, nil
+
+	case "deluser":
+		if len(args) < 1 {
+			return nil, wrongArgs
+		}
+		deleted, err := a.acl.DelUser(args...)
+		if err != nil {
+			return nil, err
+		}
+		a.disconnectStaleUsers(c)
+		return &protocol.Message{Type: "Integer", Content: int64(deleted)}, nil
+
+	case "list":
+		if len(args) != 0 {
+			return nil, wrongArgs
+		}
+		return &protocol.Message{Type: "Array", Content: a.acl.List()}, nil
+
+	case "users":
+		if len(args) != 0 {
+			return nil, wrongArgs
+		}
+		return &protocol.Message{Type: "Array", Content: a.acl.Users()}, nil
+
+	case "whoami":
+		if len(args) != 0 {
+			return nil, wrongArgs
+		}
+		return bulk(c.user), nil
+
+	case "cat":
+		if len(args) > 1 {
+			return nil, wrongArgs
+		}
+		if len(args) == 0 {
+			return &protocol.Message{Type: "Array", Content: commands.Categories}, nil
+		}
+		names, ok := acl.CategoryCommands(args[0])
+		if !ok {
+			return nil, fmt.Errorf("Unknown category '%s'", args[0])
+		}
+		return &protocol.Message{Type: "Array", Content: names}, nil
+
+	case "log":
+		return a.aclLog(args)
+
+	case "save":
+		if len(args) != 0 {
+			return nil, wrongArgs
+		}
+		if err := a.acl.Save(); err != nil {
+			if errors.Is(err, acl.ErrNoACLFile) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("There was an error trying to save the ACLs. Please check the server logs for more information")
+		}
+		return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+
+	case "load":
+		if len(args) != 0 {
+			return nil, wrongArgs
+		}
+		if err := a.acl.Load(); err != nil {
+			return nil, err
+		}
+		a.disconnectStaleUsers(c)
+		return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+	}
+	return nil, fmt.Errorf("unknown subcommand '%s'. Try ACL HELP.", name)
+}
+
+func (a *App) aclLog(args []string) (*protocol.Message, error) {
+	count := 10
+	if len(args) > 1 {
+		return nil, errors.New("wrong number of arguments for 'acl|log' command")
+	}
+	if len(args) == 1 {
+		if strings.EqualFold(args[0], "RESET") {
+			a.acl.Log.Reset()
+			return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			return nil, errors.New("value is out of range, must be positive")
+		}
+		count = n
+	}
+
+	now := time.Now()
+	entries := a.acl.Log.Entries(count)
+	replies := make([]*protocol.Message, len(entries))
+	for i, e := range entries {
+		replies[i] = &protocol.Message{Type: "Array", Content: []*protocol.Message{
+			bulk("count"), integer(int64(e.Count)),
+			bulk("reason"), bulk(e.Reason),
+			bulk("context"), bulk(e.Context),
+			bulk("object"), bulk(e.Object),
+			bulk("username"), bulk(e.Username),
+			bulk("age-seconds"), bulk(strconv.FormatFloat(now.Sub(e.Created).Seconds(), 'f', 3, 64)),
+			bulk("client-info"), bulk(e.ClientInfo),
+			bulk("entry-id"), integer(e.EntryID),
+			bulk("timestamp-created"), integer(e.Created.UnixMilli()),
+			bulk("timestamp-last-updated"), integer(e.Updated.UnixMilli()),
+		}}
+	}
+	return &protocol.Message{Type: "Array", Content: replies}, nil
+}
+
+// disconnectStaleUsers 断开所有用户已经不存在的连接，当前连接在回复之后关闭
+func (a *App) disconnectStaleUsers(current *client) {
+	a.clients.Range(func(_, v interface{}) bool {
+		c := v.(*client)
+		if a.acl.User(c.user) != nil {
+			return true
+		}
+		if c == current {
+			c.closeAfterReply = true
+		} else {
+			c.conn.Close()
+		}
+		return true
+	})
+}
+
+func bulk(s string) *protocol.Message {
+	return &protocol.Message{Type: "BulkString", Content: s}
+}
+
+func integer(n int64) *protocol.Message {
+	return &protocol.Message{Type: "Integer", Content: n}
+}
+
+// array 生成数组回复，没有元素时返回空数组而不是空值
+func array(items ...*protocol.Message) *protocol.Message {
+	if items == nil {
+		items = []*protocol.Message{}
+	}
+	return &protocol.Message{Type: "Array", Content: items}
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRequirePass(t *testing.T) {
+	setConfig(t, "require_pass", "secret")
+
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	if got := do(a, conn, "GET", "k"); got != "-NOAUTH Authentication required.\r\n" {
+		t.Errorf("GET before AUTH = %q", got)
+	}
+	if got := do(a, conn, "AUTH", "wrong"); !strings.HasPrefix(got, "-WRONGPASS") {
+		t.Errorf("AUTH wrong = %q", got)
+	}
+	if got := do(a, conn, "AUTH", "secret"); got != "+OK\r\n" {
+		t.Errorf("AUTH = %q", got)
+	}
+	if got := do(a, conn, "GET", "k"); got != "$-1\r\n" {
+		t.Errorf("GET after AUTH = %q", got)
+	}
+	if got := do(a, conn, "ACL", "LOG", "1"); !strings.Contains(got, "$4\r\nauth\r\n") {
+		t.Errorf("failed AUTH not logged: %q", got)
+	}
+}
+
+func TestACLPermissions(t *testing.T) {
+	a := newTestApp(t, ExecModeEventLoop)
+	admin := newFakeConn()
+	a.handleConnect(admin)
+
+	if got := do(a, admin, "ACL", "SETUSER", "alice", "on", ">pw", "~cache:*", "+@read", "+set", "-flushall"); got != "+OK\r\n" {
+		t.Fatalf("SETUSER = %q", got)
+	}
+	if got := do(a, admin, "ACL", "WHOAMI"); got != "$7\r\ndefault\r\n" {
+		t.Errorf("WHOAMI = %q", got)
+	}
+
+	conn := newFakeConn()
+	a.handleConnect(conn)
+	if got := do(a, conn, "AUTH", "alice", "pw"); got != "+OK\r\n" {
+		t.Fatalf("AUTH = %q", got)
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"SET", "cache:1", "v"}, "+OK\r\n"},
+		{[]string{"GET", "cache:1"}, "$1\r\nv\r\n"},
+		{[]string{"GET", "other"}, "-NOPERM No permissions to access a key\r\n"},
+		{[]string{"DEL", "cache:1"}, "-NOPERM User alice has no permissions to run the 'del' command\r\n"},
+		{[]string{"FLUSHALL"}, "-NOPERM User alice has no permissions to run the 'flushall' command\r\n"},
+		{[]string{"ACL", "WHOAMI"}, "-NOPERM User alice has no permissions to run the 'acl' command\r\n"},
+		{[]string{"GET"}, "-ERR wrong number of arguments for 'get' command\r\n"},
+	}
+	for _, tt := range tests {
+		if got := do(a, conn, tt.args...); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+
+	log := do(a, admin, "ACL", "LOG")
+	for _, want := range []string{"$3\r\nkey\r\n", "$5\r\nother\r\n", "$8\r\nflushall\r\n", "$5\r\nalice\r\n"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("ACL LOG missing %q: %q", want, log)
+		}
+	}
+	if got := do(a, admin, "ACL", "LOG", "RESET"); got != "+OK\r\n" {
+		t.Errorf("ACL LOG RESET = %q", got)
+	}
+
+	if got := do(a, admin, "ACL", "DELUSER", "alice"); got != ":1\r\n" {
+		t.Errorf("DELUSER = %q", got)
+	}
+	if got := do(a, admin, "ACL", "USERS"); got != "*1\r\n$7\r\ndefault\r\n" {
+		t.Errorf("USERS = %q", got)
+	}
+}
+
+func TestACLSaveLoad(t *testing.T) {
+	setConfig(t, "acl_file", filepath.Join(t.TempDir(), "users.acl"))
+
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	do(a, conn, "ACL", "SETUSER", "bob", "on", "nopass", "%R~*", "&news.*", "+@read")
+	if got := do(a, conn, "ACL", "SAVE"); got != "+OK\r\n" {
+		t.Fatalf("SAVE = %q", got)
+	}
+	do(a, conn, "ACL", "DELUSER", "bob")
+	if got := do(a, conn, "ACL", "LOAD"); got != "+OK\r\n" {
+		t.Fatalf("LOAD = %q", got)
+	}
+	if got := do(a, conn, "ACL", "LIST"); !strings.Contains(got, "user bob on nopass %R~* &news.* -@all +@read") {
+		t.Errorf("LIST = %q", got)
+	}
+	if got := do(a, conn, "ACL", "CAT", "nosuch"); got != "-ERR Unknown category 'nosuch'\r\n" {
+		t.Errorf("CAT = %q", got)
+	}
+}
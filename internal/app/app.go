@@ -6,21 +6,29 @@ import (
 	"errors"
 	"fmt"
 	"literedis/config"
+	"literedis/internal/acl"
 	"literedis/internal/cluster"
 	"literedis/internal/commands"
 	"literedis/internal/consts"
+	"literedis/internal/latency"
 	"literedis/internal/storage"
 	"literedis/pkg/log"
 	"literedis/pkg/network"
 	"literedis/pkg/network/tcp"
 	"literedis/pkg/protocol"
+	"net/http"
+	"os"
+	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type App struct {
-	srv           network.Server
+	servers       []network.Server
+	tls           *tcp.TLSReloader
 	opts          *options
 	storage       storage.Storage
 	protocol      protocol.Protocol
@@ -28,8 +36,54 @@ type App struct {
 	handlers      map[string]commands.CommandHandler
 	rdbSaveTicker *time.Ticker
 	rdbConfig     storage.RDBConfig
+	clients       sync.Map // cid -> *client
+	loop          *eventLoop
+	acl           *acl.ACL
+
+	listenMu sync.Mutex
+	bound    []network.Server // bind 地址上的明文和 TLS 监听，CONFIG SET bind/port 时重新创建
+	bind     string
+	port     int
+	started  bool
+
+	maxClients        atomic.Int64
+	idleTimeout       atomic.Int64 // 秒，0 表示不关闭空闲连接
+	tcpKeepAlive      atomic.Int64 // 秒
+	numClients        atomic.Int64
+	clientsCronTicker *time.Ticker
+	maxMemory         atomic.Int64 // 字节，0 表示不限制
+	memory            memoryUsage
+	stats             serverStats
+	cmdStats          map[string]*commandStats // 大写命令名 -> 统计
+	startTime         time.Time
+	runID             string
+	slowlog           *slowLog
+	monitors          monitors
+	pubsub            pubsub
+	blocking          blocking
+	execMu            sync.RWMutex // 命令执行时持有读锁，EXEC 和脚本持有写锁使其对其它连接原子
+	scripts           *scripting
+	functions         map[string]*registeredFunction // 启动时注册，之后只读
+	libraries         []string
+	outputLimits      atomic.Pointer[outputLimits]
+	latency           *latency.Monitor
+	httpServer        *http.Server
+	loading           atomic.Bool // 正在加载 RDB
+	ready             atomic.Bool // 数据加载完成，/readyz 返回 200
+
+	// configMu 保证 CONFIG 命令串行执行，同时保护下面只由 CONFIG 修改的配置值
+	configMu      sync.Mutex
+	configChanged map[string]bool // 通过 CONFIG SET 修改过的配置项，REWRITE 时追加到文件
+	configFile    string
+	requirePass   string
+	aclLogMaxLen  int
+
+	// clientHandlers 需要访问连接状态的命令，如 AUTH、ACL
+	clientHandlers map[string]clientCommandHandler
 }
 
+type clientCommandHandler func(c *client, args []string) (*protocol.Message, error)
+
 func NewApp(opts ...OptionFunc) *App {
 	options := defaultOptions()
 	for _, opt := range opts {
@@ -37,31 +91,112 @@ func NewApp(opts ...OptionFunc) *App {
 	}
 
 	app := &App{
-		// ... 其他字段的初始化 ...
-		opts: options,
+		opts:          options,
+		protocol:      protocol.NewRESPProtocol(),
+		handlers:      make(map[string]commands.CommandHandler),
+		configChanged: make(map[string]bool),
+		startTime:     time.Now(),
+		runID:         newRunID(),
+		monitors:      monitors{byID: make(map[int64]*monitor)},
+		pubsub:        newPubSub(),
+		blocking:      newBlocking(),
 	}
+	app.registerHandlers()
+	app.registerFunctions(options.libraries)
 
 	// 加载配置
 	config.LoadConfig()
 
+	app.acl = acl.New(config.Conf.ACLFile, config.Conf.ACLLogMaxLen)
+	if config.Conf.ACLFile != "" {
+		if err := app.acl.Load(); err != nil && !os.IsNotExist(err) {
+			log.Errorf("Failed to load ACL file: %v", err)
+		}
+	}
+	if config.Conf.RequirePass != "" {
+		app.acl.SetRequirePass(config.Conf.RequirePass)
+	}
+	app.configFile = config.File()
+	app.requirePass = config.Conf.RequirePass
+	app.aclLogMaxLen = config.Conf.ACLLogMaxLen
+	app.slowlog = newSlowLog(config.Conf.SlowlogLogSlowerThan, config.Conf.SlowlogMaxLen)
+	app.latency = latency.New(time.Duration(config.Conf.LatencyMonitorThreshold) * time.Millisecond)
+	limits, err := parseOutputLimits(config.Conf.ClientOutputBufferLimit)
+	if err != nil {
+		log.Errorf("Invalid client_output_buffer_limit: %v", err)
+		limits, _ = parseOutputLimits(defaultOutputLimits)
+	}
+	app.setOutputLimits(limits)
+	app.scripts = newScripting(app, int64(config.Conf.BusyReplyThreshold))
+
+	app.bind, app.port = config.Conf.Bind, config.Conf.Port
+	app.maxClients.Store(int64(config.Conf.MaxClients))
+	app.idleTimeout.Store(int64(config.Conf.Timeout))
+	app.tcpKeepAlive.Store(int64(config.Conf.TCPKeepAlive))
+	if config.Conf.MaxMemory != "" {
+		maxMemory, err := parseMemory(config.Conf.MaxMemory)
+		if err != nil {
+			log.Errorf("Invalid maxmemory: %v", err)
+		}
+		app.maxMemory.Store(maxMemory)
+	}
+
 	rdbConfig := config.GetRDBConfig()
-	app.storage = storage.NewMemoryStorage()
-	app.storage.SetRDBConfig(rdbConfig)
+	memStorage := storage.NewMemoryStorage()
+	memStorage.SetRDBConfig(rdbConfig)
+	app.storage = memStorage
+	if ms, ok := memStorage.(*storage.MemoryStorage); ok {
+		ms.SetLatencyMonitor(app.latency)
+		ms.SetNotifier(func(channel, message string) { app.publish(channel, message) })
+		flags, err := storage.ParseNotifyFlags(config.Conf.NotifyKeyspaceEvents)
+		if err != nil {
+			log.Errorf("Invalid notify_keyspace_events: %v", err)
+		}
+		ms.SetNotifyFlags(flags)
+		ms.SetListConfig(config.Conf.ListMaxListpackSize, config.Conf.ListCompressDepth)
+		ms.SetHashConfig(config.Conf.HashMaxListpackEntries, config.Conf.HashMaxListpackValue)
+	}
 
-	if err := app.storage.LoadRDB(); err != nil {
-		log.Errorf("Failed to load RDB: %v", err)
+	if options.clusterMode && options.nodeID != "" {
+		app.cluster = cluster.NewCluster(options.nodeID)
+		if err := app.cluster.AddNode(&cluster.Node{ID: options.nodeID}); err != nil {
+			log.Errorf("Failed to add local node: %v", err)
+		}
+		if ms, ok := memStorage.(*storage.MemoryStorage); ok {
+			ms.SetCluster(app.cluster)
+		}
+	}
+
+	if options.execMode == ExecModeEventLoop {
+		app.loop = newEventLoop()
+		go app.loop.run()
 	}
 
 	app.startRDBSaver()
+	app.startClientsCron()
 
 	return app
 }
 
+// loadData 加载 RDB 文件，完成之后才认为服务已就绪
+func (a *App) loadData() {
+	a.loading.Store(true)
+	start := time.Now()
+	if err := a.storage.LoadRDB(); err != nil && !os.IsNotExist(err) {
+		log.Errorf("Failed to load RDB: %v", err)
+	} else if err == nil {
+		log.Infof("DB loaded from disk: %.3f seconds", time.Since(start).Seconds())
+	}
+	a.loading.Store(false)
+	a.ready.Store(true)
+}
+
 func (a *App) startRDBSaver() {
 	ticker := time.NewTicker(a.opts.rdbConfig.SaveInterval)
+	a.rdbSaveTicker = ticker
 	go func() {
 		for range ticker.C {
-			if err := a.storage.SaveRDB(); err != nil {
+			if err := a.execute(func() error { return a.storage.SaveRDB() }); err != nil {
 				log.Errorf("Failed to start background RDB save: %v", err)
 			}
 		}
@@ -98,8 +233,7 @@ func (a *App) handleClusterOps(conn network.Conn, msg *protocol.Message) {
 
 // Helper method to send errors
 func (a *App) sendError(conn network.Conn, err error) {
-	errResp := &protocol.Message{Type: "Error", Content: []byte(err.Error())}
-	respData, _ := a.protocol.Pack(errResp)
+	respData, _ := a.protocol.Pack(errorReply(err))
 	conn.Send(respData)
 }
 
@@ -122,88 +256,388 @@ func (a *App) registerHandlers() {
 	for _, cmd := range commands.CommandList {
 		a.handlers[cmd.Name] = cmd.Handler
 	}
-}
-
-func (a *App) Start() {
-	srv := tcp.NewServer(":8080")
-	srv.OnConnect(a.handleConnect)
-	srv.OnDisconnect(a.handleDisconnect)
-	srv.OnReceive(a.handleReceive)
+	a.clientHandlers = map[string]clientCommandHandler{
+		"AUTH":    a.handleAuth,
+		"ACL":     a.handleACL,
+		"CONFIG":  a.handleConfig,
+		"INFO":    a.handleInfo,
+		"SLOWLOG": a.handleSlowlog,
+		"MONITOR": a.handleMonitor,
+		"LATENCY": a.handleLatency,
+
+		"PING":  a.handlePing,
+		"QUIT":  a.handleQuit,
+		"RESET": a.handleReset,
+		"HELLO": a.handleHello,
+
+		"SUBSCRIBE":    a.handleSubscribe,
+		"PSUBSCRIBE":   a.handlePSubscribe,
+		"SSUBSCRIBE":   a.handleSSubscribe,
+		"UNSUBSCRIBE":  a.handleUnsubscribe,
+		"PUNSUBSCRIBE": a.handlePUnsubscribe,
+		"SUNSUBSCRIBE": a.handleSUnsubscribe,
+		"PUBLISH":      a.handlePublish,
+		"SPUBLISH":     a.handleSPublish,
+		"PUBSUB":       a.handlePubSub,
+
+		"MULTI":   a.handleMulti,
+		"EXEC":    a.handleExec,
+		"DISCARD": a.handleDiscard,
+		"WATCH":   a.handleWatch,
+		"UNWATCH": a.handleUnwatch,
+
+		"EVAL":       a.handleEval,
+		"EVALSHA":    a.handleEvalSha,
+		"EVAL_RO":    a.handleEvalRO,
+		"EVALSHA_RO": a.handleEvalShaRO,
+		"SCRIPT":     a.handleScript,
+		"FCALL":      a.handleFCall,
+		"FCALL_RO":   a.handleFCallRO,
+		"FUNCTION":   a.handleFunction,
+
+		"BLPOP":      a.handleBLPop,
+		"BRPOP":      a.handleBRPop,
+		"BLMOVE":     a.handleBLMove,
+		"BRPOPLPUSH": a.handleBRPopLPush,
+		"BLMPOP":     a.handleBLMPop,
+		"CLIENT":     a.handleClient,
+	}
 
-	srv.Start()
-	a.srv = srv
+	// 统计表在启动时创建，之后只读，命令执行时无需加锁
+	a.cmdStats = make(map[string]*commandStats, len(a.handlers)+len(a.clientHandlers))
+	for name := range a.handlers {
+		a.cmdStats[name] = &commandStats{}
+	}
+	for name := range a.clientHandlers {
+		a.cmdStats[name] = &commandStats{}
+	}
 }
 
 func (a *App) handleConnect(conn network.Conn) {
-	log.Debugf("[Gateway] user connect successful: %v", conn.RemoteAddr())
+	a.stats.connectionsReceived.Add(1)
+	if max := a.maxClients.Load(); a.numClients.Add(1) > max && max > 0 {
+		a.numClients.Add(-1)
+		a.stats.rejectedConnections.Add(1)
+		a.sendErrorResponse(conn, "ERR max number of clients reached")
+		conn.Close()
+		return
+	}
+	c := newClient(conn, a.storage.NewView())
+	c.user = acl.DefaultUser
+	// default 用户无需密码时新连接自动认证
+	if u := a.acl.User(acl.DefaultUser); u != nil && u.Enabled && u.NoPass {
+		c.authenticated = true
+	}
+	a.clients.Store(conn.Cid(), c)
+	c.logger().Debugw("client connected")
 }
 
 func (a *App) handleDisconnect(conn network.Conn, err error) {
-	log.Debugf("[Gateway] user connection disconnected: %v, err: %v", conn.RemoteAddr(), err)
+	v, ok := a.clients.LoadAndDelete(conn.Cid())
+	if !ok {
+		return
+	}
+	a.numClients.Add(-1)
+	a.removeMonitor(conn.Cid())
+	a.removeSubscriber(conn.Cid())
+	a.closeWatch(v.(*client))
+	a.blocking.unblock(conn.Cid(), nil)
+	v.(*client).logger().Debugw("client disconnected", log.Pair("err", err))
 }
 
 func (a *App) handleReceive(conn network.Conn, data []byte) {
+	v, ok := a.clients.Load(conn.Cid())
+	if !ok {
+		return
+	}
+	c := v.(*client)
+	c.touch()
+	a.stats.netInputBytes.Add(int64(len(data)))
+
 	msg, err := a.protocol.Unpack(bytes.NewReader(data))
 	if err != nil {
-		log.Errorf("unpack data to struct failed: %v", err)
+		c.logger().Warnw("unpack request failed", log.Pair("err", err))
 		a.sendErrorResponse(conn, "ERR unpack")
 		return
 	}
-	log.Debugf("receive message type:%v, value: %v", msg.Type, msg.Content)
-	response, err := a.processCommand(msg)
-	if err != nil {
-		log.Infof("Error processing command:%v", err)
-		errResp := &protocol.Message{Type: "Error", Content: []byte(err.Error())}
-		respData, _ := a.protocol.Pack(errResp)
-		conn.Send(respData)
+	response := a.dispatch(c, msg)
+	if c.blocked != nil {
+		response = a.waitBlocked(c)
+	}
+	if response == nil {
 		return
 	}
 	respData, err := a.protocol.Pack(response)
 	if err != nil {
-		log.Errorf("pack response failed: %v", err)
+		c.logger().Errorw("pack response failed", log.Pair("err", err))
 		return
 	}
-	conn.Send(respData)
+	a.writeReply(c, respData)
+	if c.closeAfterReply {
+		a.closeAfterWrite(c)
+	}
+	if c.monitorAfterReply {
+		c.monitorAfterReply = false
+		a.addMonitor(c)
+	}
+}
+
+// writeReply 发送命令回复。执行过订阅命令的连接与推送消息共用一个队列，保证顺序
+func (a *App) writeReply(c *client, data []byte) {
+	if s := c.sub.Load(); s != nil {
+		s.push(data)
+		return
+	}
+	a.stats.netOutputBytes.Add(int64(len(data)))
+	c.conn.Send(data)
+}
+
+// closeAfterWrite 在已经排队的输出发送完之后关闭连接
+func (a *App) closeAfterWrite(c *client) {
+	if s := c.sub.Load(); s != nil {
+		s.push(nil)
+		return
+	}
+	c.conn.Close()
+}
+
+// dispatch 按照执行模式运行命令并返回回复，nil 表示无需回复
+func (a *App) dispatch(c *client, msg *protocol.Message) *protocol.Message {
+	if a.scripts.busy() {
+		return a.busyReply(c, msg)
+	}
+	if a.loop == nil {
+		return a.call(c, msg)
+	}
+	a.loop.submit(func() { c.replyCh <- a.call(c, msg) })
+	return <-c.replyCh
+}
+
+// execute 在命令执行的上下文中运行 fn，事件循环模式下与命令互斥
+func (a *App) execute(fn func() error) error {
+	if a.loop == nil {
+		return fn()
+	}
+	errCh := make(chan error, 1)
+	a.loop.submit(func() { errCh <- fn() })
+	return <-errCh
+}
+
+func (a *App) call(c *client, msg *protocol.Message) (reply *protocol.Message) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.logger().Errorw("command panic", log.Pair("panic", r), log.Pair("stack", string(debug.Stack())))
+			reply = errorReply(fmt.Errorf("internal error: %v", r))
+		}
+	}()
+
+	response, err := a.processCommand(c, msg)
+	if err != nil {
+		reply := errorReply(err)
+		a.stats.recordError(reply.Content.(string))
+		return reply
+	}
+	return response
 }
 
-func (a *App) processCommand(msg *protocol.Message) (*protocol.Message, error) {
-	switch msg.Type {
-	case "SimpleString":
-		fmt.Println("SimpleString:", msg.Content)
-	case "Error":
-		fmt.Println("Error:", msg.Content)
-	case "Integer":
-		fmt.Println("Integer:", msg.Content)
-	case "BulkString":
-		fmt.Println("BulkString:", msg.Content)
-	case "Array":
-		fmt.Println("Array:", msg.Content)
-		//command := string(msg.Value.([]byte))
-		cmdArray, ok := msg.Content.([]*protocol.Message)
-		if !ok || len(cmdArray) == 0 {
-			return nil, errors.New("invalid command")
+func (a *App) processCommand(c *client, msg *protocol.Message) (*protocol.Message, error) {
+	if msg.Type != "Array" {
+		return nil, errors.New("Protocol error: expected array of bulk strings")
+	}
+	cmdArray, ok := msg.Content.([]*protocol.Message)
+	if !ok {
+		return nil, errors.New("invalid command")
+	}
+	if len(cmdArray) == 0 {
+		return nil, nil
+	}
+
+	parts := make([]string, len(cmdArray))
+	for i, arg := range cmdArray {
+		b, ok := arg.Content.([]byte)
+		if !ok {
+			return nil, errors.New("Protocol error: expected bulk string")
 		}
-		cmdName := strings.ToUpper(string(cmdArray[0].Content.([]byte)))
-		args := make([]string, len(cmdArray)-1)
-		for i, arg := range cmdArray[1:] {
-			args[i] = string(arg.Content.([]byte))
+		parts[i] = string(b)
+	}
+	cmd, err := a.lookupCommand(parts)
+	if err != nil {
+		c.flagTransaction()
+		return nil, err
+	}
+	if err := a.checkCommand(c, cmd); err != nil {
+		cmd.stats.rejected.Add(1)
+		c.flagTransaction()
+		return nil, err
+	}
+	if c.multi.active && !multiImmediateCommands[cmd.name] {
+		return a.queueCommand(c, cmd)
+	}
+	a.feedMonitors(c, parts[0], cmd)
+	switch execLocks[cmd.name] {
+	case lockExclusive:
+		a.execMu.Lock()
+		defer a.execMu.Unlock()
+	case lockShared:
+		a.execMu.RLock()
+		defer a.execMu.RUnlock()
+	}
+	reply, err := a.execCommand(c, cmd)
+	a.serveBlocked()
+	return reply, err
+}
+
+const (
+	lockShared = iota
+	lockExclusive
+	lockNone
+)
+
+// execLocks 命令执行时持有的 execMu，未列出的命令持有读锁。
+// SCRIPT 不访问数据，不加锁才能在脚本执行期间 SCRIPT KILL
+var execLocks = map[string]int{
+	"EXEC":       lockExclusive,
+	"EVAL":       lockExclusive,
+	"EVALSHA":    lockExclusive,
+	"EVAL_RO":    lockExclusive,
+	"EVALSHA_RO": lockExclusive,
+	"FCALL":      lockExclusive,
+	"FCALL_RO":   lockExclusive,
+	"SCRIPT":     lockNone,
+}
+
+// command 解析之后待执行的命令
+type command struct {
+	name          string   // 大写的命令名
+	args          []string // 不包含命令名
+	spec          commands.CommandSpec
+	handler       commands.CommandHandler
+	clientHandler clientCommandHandler
+	stats         *commandStats
+}
+
+func (a *App) lookupCommand(parts []string) (*command, error) {
+	cmd := &command{name: strings.ToUpper(parts[0]), args: parts[1:]}
+	cmd.handler = a.handlers[cmd.name]
+	cmd.clientHandler = a.clientHandlers[cmd.name]
+	if cmd.handler == nil && cmd.clientHandler == nil {
+		return nil, fmt.Errorf("unknown command '%s'", parts[0])
+	}
+	cmd.spec, _ = commands.LookupSpec(cmd.name)
+	cmd.stats = a.cmdStats[cmd.name]
+	if !cmd.spec.CheckArity(len(parts)) {
+		cmd.stats.rejected.Add(1)
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", strings.ToLower(parts[0]))
+	}
+	return cmd, nil
+}
+
+// checkCommand 执行之前的检查：认证、ACL 权限、maxmemory 和集群槽位
+func (a *App) checkCommand(c *client, cmd *command) error {
+	if !c.authenticated && cmd.spec.Flags&commands.FlagNoAuth == 0 {
+		return consts.ErrAuthRequired
+	}
+	if err := a.checkSubscribeMode(c, cmd); err != nil {
+		return err
+	}
+	if err := a.checkPermission(c, cmd.name, cmd.spec, cmd.args); err != nil {
+		return err
+	}
+	if cmd.spec.Flags&commands.FlagDenyOOM != 0 {
+		if max := a.maxMemory.Load(); max > 0 && a.memory.load() > max {
+			return consts.ErrMaxMemoryReached
 		}
+	}
 
-		// Check if the command should be executed on this node
-		if len(args) > 0 {
-			node := a.cluster.GetNodeForKey(args[0])
-			if !a.cluster.IsLocalNode(node.ID) {
-				return nil, consts.ErrWrongNode
+	// Check if the command should be executed on this node
+	if a.cluster != nil && cmd.clientHandler == nil {
+		for _, key := range cmd.spec.Keys(cmd.args) {
+			node := a.cluster.GetNodeForKey(key)
+			if node != nil && !a.cluster.IsLocalNode(node.ID) {
+				return consts.ErrWrongNode
 			}
 		}
+	}
+	return nil
+}
 
-		cmd, ok := a.handlers[cmdName]
-		if !ok {
-			return nil, fmt.Errorf("unknown command: %s", cmdName)
-		}
-		return cmd(a.storage, args)
+// execCommand 执行命令并更新命令统计、慢日志和延迟监控
+func (a *App) execCommand(c *client, cmd *command) (*protocol.Message, error) {
+	start := time.Now()
+	var reply *protocol.Message
+	var err error
+	if cmd.clientHandler != nil {
+		reply, err = cmd.clientHandler(c, cmd.args)
+	} else {
+		reply, err = cmd.handler(c.storage, cmd.args)
+	}
+	d := time.Since(start)
+	cmd.stats.record(d, err)
+	a.slowlog.record(c, cmd, d)
+	if log.DebugEnabled() {
+		c.logger().Debugw("command", log.Pair("cmd", cmd.name), log.Pair("argc", len(cmd.args)),
+			log.Pair("duration", d), log.Pair("err", err))
+	}
+	if cmd.spec.Flags&commands.FlagFast != 0 {
+		a.latency.Add(latency.EventFastCommand, d)
+	} else {
+		a.latency.Add(latency.EventCommand, d)
+	}
+	a.stats.commandsProcessed.Add(1)
+	if err == nil && cmd.spec.Flags&commands.FlagWrite != 0 {
+		a.blocking.signal(c.storage.SelectedDB(), cmd.spec.Keys(cmd.args))
+	}
+	if err == nil && cmd.spec.Flags&commands.FlagReadOnly != 0 && cmd.spec.FirstKey > 0 {
+		a.stats.recordLookup(reply)
 	}
-	return &protocol.Message{Type: "error", Content: "Invalid message type"}, nil
+	return reply, err
+}
+
+// errorReply 将错误转换为 RESP 错误回复，未带错误码的消息补上 ERR 前缀
+func errorReply(err error) *protocol.Message {
+	msg := err.Error()
+	var se scriptError
+	if errors.As(err, &se) && se.hasCode() {
+		return &protocol.Message{Type: "Error", Content: msg}
+	}
+	if !hasErrorCode(msg) {
+		msg = "ERR " + msg
+	}
+	return &protocol.Message{Type: "Error", Content: msg}
+}
+
+// errorCodes 是回复中可以直接出现的错误码，其他消息即使以大写单词开头
+// （如 "RANK can't be zero"）也要补上 ERR 前缀
+var errorCodes = map[string]bool{
+	"ERR":         true,
+	"WRONGTYPE":   true,
+	"EXECABORT":   true,
+	"NOSCRIPT":    true,
+	"UNBLOCKED":   true,
+	"NOAUTH":      true,
+	"WRONGPASS":   true,
+	"NOPERM":      true,
+	"NOPROTO":     true,
+	"BUSY":        true,
+	"NOTBUSY":     true,
+	"UNKILLABLE":  true,
+	"OOM":         true,
+	"CROSSSLOT":   true,
+	"MOVED":       true,
+	"ASK":         true,
+	"TRYAGAIN":    true,
+	"CLUSTERDOWN": true,
+	"LOADING":     true,
+	"READONLY":    true,
+	"MISCONF":     true,
+	"BUSYKEY":     true,
+	"NOGROUP":     true,
+}
+
+// hasErrorCode 判断消息是否以 errorCodes 中的错误码开头
+func hasErrorCode(msg string) bool {
+	code, _, found := strings.Cut(msg, " ")
+	return found && errorCodes[code]
 }
 
 // readBulkArgs reads bulk string arguments from the reader.
@@ -228,15 +662,41 @@ func readBulkArgs(reader *bufio.Reader) (string, error) {
 }
 
 func (a *App) Stop() {
-	a.srv.Stop()
-	a.rdbSaveTicker.Stop()
-	if err := a.storage.SaveRDB(); err != nil {
+	a.ready.Store(false)
+	if a.httpServer != nil {
+		a.httpServer.Close()
+	}
+	a.listenMu.Lock()
+	for _, srv := range append(a.servers, a.bound...) {
+		srv.Stop()
+	}
+	a.servers, a.bound = nil, nil
+	a.started = false
+	a.listenMu.Unlock()
+	// 重新绑定之前建立的连接不属于当前任何监听，需要单独关闭
+	a.clients.Range(func(_, v interface{}) bool {
+		v.(*client).conn.Close()
+		return true
+	})
+	if a.clientsCronTicker != nil {
+		a.clientsCronTicker.Stop()
+	}
+	if a.tls != nil {
+		a.tls.Close()
+	}
+	if a.rdbSaveTicker != nil {
+		a.rdbSaveTicker.Stop()
+	}
+	if err := a.execute(func() error { return a.storage.SaveRDB() }); err != nil {
 		log.Errorf("Failed to save final RDB: %v", err)
 	}
+	if a.loop != nil {
+		a.loop.stop()
+	}
 }
 
 func (a *App) sendErrorResponse(conn network.Conn, errMsg string) {
-	errResp := &protocol.Message{Type: "Error", Content: []byte(errMsg)}
+	errResp := &protocol.Message{Type: "Error", Content: errMsg}
 	respData, _ := a.protocol.Pack(errResp)
 	conn.Send(respData)
 }
@@ -0,0 +1,222 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/viper"
+	"literedis/pkg/network"
+)
+
+// fakeConn 记录服务端写回的数据，用于在不监听端口的情况下驱动 App
+type fakeConn struct {
+	cid    int64
+	mu     sync.Mutex
+	out    [][]byte
+	closed atomic.Bool
+}
+
+var nextCid atomic.Int64
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{cid: nextCid.Add(1)}
+}
+
+func (c *fakeConn) Cid() int64     { return c.cid }
+func (c *fakeConn) Uid() int64     { return 0 }
+func (c *fakeConn) Bind(uid int64) {}
+func (c *fakeConn) Send(msg []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.out = append(c.out, msg)
+	return nil
+}
+func (c *fakeConn) Push(msg []byte) error    { return c.Send(msg) }
+func (c *fakeConn) State() network.ConnState { return network.ConnOpened }
+func (c *fakeConn) Close() error             { c.closed.Store(true); return nil }
+func (c *fakeConn) LocalIP() string          { return "127.0.0.1" }
+func (c *fakeConn) LocalAddr() string        { return "127.0.0.1:6379" }
+func (c *fakeConn) RemoteIP() string         { return "127.0.0.1" }
+func (c *fakeConn) RemoteAddr() string       { return fmt.Sprintf("127.0.0.1:%d", 10000+c.cid) }
+func (c *fakeConn) Values() url.Values       { return nil }
+func (c *fakeConn) last() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.out) == 0 {
+		return ""
+	}
+	return string(c.out[len(c.out)-1])
+}
+
+func encodeCommand(args ...string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return []byte(b.String())
+}
+
+// do 发送一条命令并返回原始 RESP 回复
+func do(a *App, conn *fakeConn, args ...string) string {
+	a.handleReceive(conn, encodeCommand(args...))
+	return conn.last()
+}
+
+func newTestApp(t *testing.T, mode ExecMode, opts ...OptionFunc) *App {
+	t.Helper()
+	viper.Set("rdb.filename", filepath.Join(t.TempDir(), "dump.rdb"))
+	viper.Set("http.addr", "127.0.0.1:0")
+	a := NewApp(append([]OptionFunc{WithExecMode(mode)}, opts...)...)
+	t.Cleanup(func() {
+		a.rdbSaveTicker.Stop()
+		a.clientsCronTicker.Stop()
+		if a.loop != nil {
+			a.loop.stop()
+		}
+	})
+	return a
+}
+
+var execModes = []ExecMode{ExecModeThreaded, ExecModeEventLoop}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"SET", "k", "v"}, "+OK\r\n"},
+		{[]string{"GET", "k"}, "$1\r\nv\r\n"},
+		{[]string{"APPEND", "k", "al"}, ":3\r\n"},
+		{[]string{"GETRANGE", "k", "0", "1"}, "$2\r\nva\r\n"},
+		{[]string{"EXISTS", "k"}, ":1\r\n"},
+		{[]string{"TYPE", "k"}, "+string\r\n"},
+		{[]string{"DEL", "k"}, ":1\r\n"},
+		{[]string{"EXISTS", "k"}, ":0\r\n"},
+		{[]string{"RPUSH", "l", "a", "b", "c"}, ":3\r\n"},
+		{[]string{"LPUSH", "l", "z"}, ":4\r\n"},
+		{[]string{"LRANGE", "l", "0", "-1"}, "*4\r\n$1\r\nz\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{[]string{"LPOP", "l"}, "$1\r\nz\r\n"},
+		{[]string{"RPOP", "l"}, "$1\r\nc\r\n"},
+		{[]string{"LLEN", "l"}, ":2\r\n"},
+		{[]string{"HSET", "h", "f", "1"}, ":1\r\n"},
+		{[]string{"HGET", "h", "f"}, "$1\r\n1\r\n"},
+		{[]string{"HLEN", "h"}, ":1\r\n"},
+		{[]string{"SADD", "s", "a", "b"}, ":2\r\n"},
+		{[]string{"SCARD", "s"}, ":2\r\n"},
+		{[]string{"ZADD", "z", "1", "m"}, ":1\r\n"},
+		{[]string{"ZCARD", "z"}, ":1\r\n"},
+		{[]string{"NOSUCHCMD"}, "-ERR unknown command 'NOSUCHCMD'\r\n"},
+		{[]string{"FLUSHDB"}, "+OK\r\n"},
+		{[]string{"EXISTS", "l"}, ":0\r\n"},
+	}
+
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			conn := newFakeConn()
+			a.handleConnect(conn)
+			defer a.handleDisconnect(conn, nil)
+
+			for _, tt := range tests {
+				if got := do(a, conn, tt.args...); got != tt.want {
+					t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectIsPerConnection(t *testing.T) {
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			c1, c2 := newFakeConn(), newFakeConn()
+			a.handleConnect(c1)
+			a.handleConnect(c2)
+
+			do(a, c1, "SELECT", "1")
+			do(a, c1, "SET", "k", "db1")
+			if got := do(a, c2, "EXISTS", "k"); got != ":0\r\n" {
+				t.Errorf("key leaked into db 0: %q", got)
+			}
+			do(a, c2, "SELECT", "1")
+			if got := do(a, c2, "GET", "k"); got != "$3\r\ndb1\r\n" {
+				t.Errorf("got %q", got)
+			}
+			do(a, c1, "FLUSHALL")
+		})
+	}
+}
+
+func TestConcurrentAppend(t *testing.T) {
+	const clients, appends = 8, 200
+
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			var wg sync.WaitGroup
+			for i := 0; i < clients; i++ {
+				conn := newFakeConn()
+				a.handleConnect(conn)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < appends; j++ {
+						do(a, conn, "APPEND", "counter", "x")
+					}
+				}()
+			}
+			wg.Wait()
+
+			conn := newFakeConn()
+			a.handleConnect(conn)
+			want := fmt.Sprintf(":%d\r\n", clients*appends+1)
+			if got := do(a, conn, "APPEND", "counter", "x"); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			do(a, conn, "FLUSHALL")
+		})
+	}
+}
+
+func TestTaskQueue(t *testing.T) {
+	q := newTaskQueue()
+	if q.pop() != nil {
+		t.Fatal("expected empty queue")
+	}
+
+	var got []int
+	for i := 0; i < 3; i++ {
+		i := i
+		q.push(&task{fn: func() { got = append(got, i) }})
+	}
+	for tk := q.pop(); tk != nil; tk = q.pop() {
+		tk.fn()
+	}
+	if len(got) != 3 || got[0] != 0 || got[1] != 1 || got[2] != 2 {
+		t.Errorf("tasks ran out of order: %v", got)
+	}
+}
+
+func TestErrorReply(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"syntax error", "ERR syntax error"},
+		{"WRONGTYPE Operation against a key", "WRONGTYPE Operation against a key"},
+		{"ERR already prefixed", "ERR already prefixed"},
+		{"RANK can't be zero", "ERR RANK can't be zero"},
+		{"CLIENT UNBLOCK reason should be TIMEOUT or ERROR", "ERR CLIENT UNBLOCK reason should be TIMEOUT or ERROR"},
+		{"NOSCRIPT No matching script", "NOSCRIPT No matching script"},
+	}
+	for _, tt := range tests {
+		msg := errorReply(fmt.Errorf("%s", tt.in))
+		if msg.Type != "Error" || msg.Content != tt.want {
+			t.Errorf("errorReply(%q) = %v", tt.in, msg.Content)
+		}
+	}
+}
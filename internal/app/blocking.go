@@ -0,0 +1,304 @@
+package app
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"literedis/internal/commands"
+	"literedis/internal/consts"
+	"literedis/internal/storage"
+	"literedis/pkg/protocol"
+)
+
+var errUnblocked = errors.New("UNBLOCKED client unblocked via CLIENT UNBLOCK")
+
+type blockKey struct {
+	db  int
+	key string
+}
+
+// waiter 阻塞在列表上的连接。serve 在命令执行的上下文中尝试从 key 弹出元素，
+// 成功时返回回复，列表不存在时返回 ErrKeyNotFound；dst 不为空时弹出的元素被推入 dst（BLMOVE）
+type waiter struct {
+	c            *client
+	db           int
+	keys         []string
+	timeout      time.Duration // 0 表示一直等待
+	timeoutReply *protocol.Message
+	serve        func(s storage.Storage, key string) (*protocol.Message, error)
+	dst          string
+
+	done     chan *protocol.Message // 容量为 1，nil 表示连接已断开，不再回复
+	finished bool                   // 由 blocking.mu 保护
+}
+
+// blocking 按键索引的阻塞连接，同一个键上的连接按阻塞的先后顺序服务
+type blocking struct {
+	mu       sync.Mutex
+	waiters  map[blockKey][]*waiter
+	byClient map[int64]*waiter
+	ready    []blockKey // 写入之后可能可以服务的键，命令执行结束时处理
+	count    atomic.Int64
+}
+
+func newBlocking() blocking {
+	return blocking{waiters: make(map[blockKey][]*waiter), byClient: make(map[int64]*waiter)}
+}
+
+// block 在 b.mu 下再尝试一次，所有键都不存在时登记等待，其他错误（如 WRONGTYPE）
+// 直接返回。与 signal 使用同一把锁，尝试和登记之间的写入不会丢失
+func (a *App) block(c *client, w *waiter) (*protocol.Message, error) {
+	b := &a.blocking
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range w.keys {
+		reply, err := w.serve(c.storage, key)
+		if err == nil {
+			return reply, nil
+		}
+		if !errors.Is(err, consts.ErrKeyNotFound) {
+			return nil, err
+		}
+	}
+	// EXEC、脚本和函数中的阻塞命令不阻塞，直接返回超时的回复
+	if c.nested {
+		return w.timeoutReply, nil
+	}
+	w.c, w.db = c, c.storage.SelectedDB()
+	w.done = make(chan *protocol.Message, 1)
+	for _, key := range w.keys {
+		k := blockKey{w.db, key}
+		b.waiters[k] = append(b.waiters[k], w)
+	}
+	b.byClient[c.conn.Cid()] = w
+	b.count.Add(1)
+	c.blocked = w
+	return nil, nil
+}
+
+// signal 写命令执行之后调用，标记有连接在等待的键
+func (b *blocking) signal(db int, keys []string) {
+	if b.count.Load() == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range keys {
+		b.markReady(blockKey{db, key})
+	}
+}
+
+func (b *blocking) markReady(k blockKey) {
+	if len(b.waiters[k]) > 0 {
+		b.ready = append(b.ready, k)
+	}
+}
+
+// serveBlocked 在命令执行结束、仍然持有 execMu 时调用，按先后顺序服务等待可用键的连接
+func (a *App) serveBlocked() {
+	b := &a.blocking
+	if b.count.Load() == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for len(b.ready) > 0 {
+		k := b.ready[0]
+		b.ready = b.ready[1:]
+		for len(b.waiters[k]) > 0 {
+			w := b.waiters[k][0]
+			reply, err := w.serve(w.c.storage, k.key)
+			if err != nil {
+				// 键不存在或者不再是列表时继续等待，其他错误（如 BLMOVE 的 dst 类型不对）回复给连接
+				if errors.Is(err, consts.ErrKeyNotFound) || !holdsList(w.c.storage, k.key) {
+					break
+				}
+				b.finish(w, errorReply(err))
+				continue
+			}
+			b.finish(w, reply)
+			if w.dst != "" {
+				b.markReady(blockKey{w.db, w.dst})
+			}
+		}
+	}
+}
+
+// finish 结束等待并把回复交给阻塞的连接，已经结束时返回 false
+func (b *blocking) finish(w *waiter, reply *protocol.Message) bool {
+	if w.finished {
+		return false
+	}
+	w.finished = true
+	for _, key := range w.keys {
+		k := blockKey{w.db, key}
+		list := b.waiters[k]
+		for i, other := range list {
+			if other == w {
+				list = append(list[:i:i], list[i+1:]...)
+				break
+			}
+		}
+		if len(list) == 0 {
+			delete(b.waiters, k)
+		} else {
+			b.waiters[k] = list
+		}
+	}
+	delete(b.byClient, w.c.conn.Cid())
+	b.count.Add(-1)
+	w.done <- reply
+	return true
+}
+
+// unblock 结束连接的等待，reply 为 nil 表示连接已断开
+func (b *blocking) unblock(cid int64, reply *protocol.Message) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	w, ok := b.byClient[cid]
+	return ok && b.finish(w, reply)
+}
+
+// waitBlocked 在连接自己的协程中等待服务、超时、CLIENT UNBLOCK 或断开连接，
+// 此时不持有 execMu，也不占用事件循环
+func (a *App) waitBlocked(c *client) *protocol.Message {
+	w := c.blocked
+	c.blocked = nil
+	if w.timeout > 0 {
+		timer := time.NewTimer(w.timeout)
+		defer timer.Stop()
+		select {
+		case reply := <-w.done:
+			return reply
+		case <-timer.C:
+			a.blocking.mu.Lock()
+			a.blocking.finish(w, w.timeoutReply)
+			a.blocking.mu.Unlock()
+		}
+	}
+	return <-w.done
+}
+
+// parseTimeout 阻塞命令的超时时间，单位为秒，可以是小数
+func parseTimeout(s string) (time.Duration, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) || f > math.MaxInt64/float64(time.Second) {
+		return 0, errors.New("timeout is not a float or out of range")
+	}
+	if f < 0 {
+		return 0, errors.New("timeout is negative")
+	}
+	return time.Duration(f * float64(time.Second)), nil
+}
+
+func nullArray() *protocol.Message {
+	return &protocol.Message{Type: "Array", Content: []*protocol.Message(nil)}
+}
+
+// holdsList 判断 key 当前保存的是列表
+func holdsList(s storage.Storage, key string) bool {
+	typ, err := s.Type(key)
+	return err == nil && typ == "list"
+}
+
+// popOne 从列表的一端弹出一个元素，列表不存在时返回 ErrKeyNotFound
+func popOne(s storage.Storage, key string, left bool) ([]byte, error) {
+	if left {
+		return s.LPop(key)
+	}
+	return s.RPop(key)
+}
+
+// handleBLPop BLPOP key [key ...] timeout
+func (a *App) handleBLPop(c *client, args []string) (*protocol.Message, error) {
+	return a.bpop(c, args, true)
+}
+
+func (a *App) handleBRPop(c *client, args []string) (*protocol.Message, error) {
+	return a.bpop(c, args, false)
+}
+
+func (a *App) bpop(c *client, args []string, left bool) (*protocol.Message, error) {
+	timeout, err := parseTimeout(args[len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+	return a.block(c, &waiter{
+		keys:         args[:len(args)-1],
+		timeout:      timeout,
+		timeoutReply: nullArray(),
+		serve: func(s storage.Storage, key string) (*protocol.Message, error) {
+			value, err := popOne(s, key, left)
+			if err != nil {
+				return nil, err
+			}
+			return array(bulk(key), &protocol.Message{Type: "BulkString", Content: value}), nil
+		},
+	})
+}
+
+// handleBLMove BLMOVE source destination LEFT|RIGHT LEFT|RIGHT timeout
+func (a *App) handleBLMove(c *client, args []string) (*protocol.Message, error) {
+	srcLeft, err := commands.ParseDirection(args[2])
+	if err != nil {
+		return nil, err
+	}
+	dstLeft, err := commands.ParseDirection(args[3])
+	if err != nil {
+		return nil, err
+	}
+	return a.blmove(c, args[0], args[1], srcLeft, dstLeft, args[4])
+}
+
+// handleBRPopLPush BRPOPLPUSH source destination timeout，等同于 BLMOVE source destination RIGHT LEFT
+func (a *App) handleBRPopLPush(c *client, args []string) (*protocol.Message, error) {
+	return a.blmove(c, args[0], args[1], false, true, args[2])
+}
+
+func (a *App) blmove(c *client, src, dst string, srcLeft, dstLeft bool, timeoutArg string) (*protocol.Message, error) {
+	timeout, err := parseTimeout(timeoutArg)
+	if err != nil {
+		return nil, err
+	}
+	return a.block(c, &waiter{
+		keys:         []string{src},
+		dst:          dst,
+		timeout:      timeout,
+		timeoutReply: &protocol.Message{Type: "Null"},
+		serve: func(s storage.Storage, key string) (*protocol.Message, error) {
+			value, err := s.LMove(src, dst, srcLeft, dstLeft)
+			if err != nil {
+				return nil, err
+			}
+			return &protocol.Message{Type: "BulkString", Content: value}, nil
+		},
+	})
+}
+
+// handleBLMPop BLMPOP timeout numkeys key [key ...] LEFT|RIGHT [COUNT count]
+func (a *App) handleBLMPop(c *client, args []string) (*protocol.Message, error) {
+	timeout, err := parseTimeout(args[0])
+	if err != nil {
+		return nil, err
+	}
+	keys, left, count, err := commands.ParseMPop(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	return a.block(c, &waiter{
+		keys:         keys,
+		timeout:      timeout,
+		timeoutReply: nullArray(),
+		serve: func(s storage.Storage, key string) (*protocol.Message, error) {
+			reply, ok, err := commands.MPop(s, key, left, count)
+			if err == nil && !ok {
+				err = consts.ErrKeyNotFound
+			}
+			return reply, err
+		},
+	})
+}
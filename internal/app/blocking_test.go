@@ -0,0 +1,258 @@
+package app
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockedDo 在后台发送阻塞命令，等到连接登记等待之后返回回复的通道
+func blockedDo(t *testing.T, a *App, conn *fakeConn, args ...string) <-chan string {
+	t.Helper()
+	n := a.blocking.count.Load()
+	ch := make(chan string, 1)
+	go func() { ch <- do(a, conn, args...) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for a.blocking.count.Load() <= n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%q never blocked", args)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return ch
+}
+
+func recvReply(t *testing.T, ch <-chan string) string {
+	t.Helper()
+	select {
+	case got := <-ch:
+		return got
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocked command never returned")
+	}
+	return ""
+}
+
+func TestBlockingPop(t *testing.T) {
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			c1, c2, c3 := newFakeConn(), newFakeConn(), newFakeConn()
+			a.handleConnect(c1)
+			a.handleConnect(c2)
+			a.handleConnect(c3)
+
+			do(a, c3, "RPUSH", "l", "a")
+			if got := do(a, c1, "BLPOP", "missing", "l", "0"); got != "*2\r\n$1\r\nl\r\n$1\r\na\r\n" {
+				t.Errorf("BLPOP with data = %q", got)
+			}
+
+			// 先阻塞的连接先被服务
+			r1 := blockedDo(t, a, c1, "BLPOP", "q", "0")
+			r2 := blockedDo(t, a, c2, "BRPOP", "other", "q", "0")
+			if got := do(a, c3, "INFO", "clients"); !strings.Contains(got, "blocked_clients:2\r\n") {
+				t.Errorf("INFO clients = %q", got)
+			}
+			do(a, c3, "RPUSH", "q", "x", "y")
+			if got := recvReply(t, r1); got != "*2\r\n$1\r\nq\r\n$1\r\nx\r\n" {
+				t.Errorf("first waiter = %q", got)
+			}
+			if got := recvReply(t, r2); got != "*2\r\n$1\r\nq\r\n$1\r\ny\r\n" {
+				t.Errorf("second waiter = %q", got)
+			}
+			if got := do(a, c3, "EXISTS", "q"); got != ":0\r\n" {
+				t.Errorf("EXISTS q = %q", got)
+			}
+
+			// 超时
+			start := time.Now()
+			if got := do(a, c1, "BLPOP", "q", "0.05"); got != "*-1\r\n" {
+				t.Errorf("BLPOP timeout = %q", got)
+			}
+			if d := time.Since(start); d < 50*time.Millisecond {
+				t.Errorf("BLPOP returned after %v", d)
+			}
+			if n := a.blocking.count.Load(); n != 0 {
+				t.Errorf("%d waiters left", n)
+			}
+
+			// 不同数据库中的同名键互不影响
+			r1 = blockedDo(t, a, c1, "BLPOP", "q", "0")
+			do(a, c3, "SELECT", "1")
+			do(a, c3, "RPUSH", "q", "db1")
+			do(a, c3, "SELECT", "0")
+			do(a, c3, "LPUSH", "q", "db0")
+			if got := recvReply(t, r1); got != "*2\r\n$1\r\nq\r\n$3\r\ndb0\r\n" {
+				t.Errorf("BLPOP across dbs = %q", got)
+			}
+
+			for _, tt := range []struct {
+				args []string
+				want string
+			}{
+				{[]string{"BLPOP", "q", "-1"}, "-ERR timeout is negative\r\n"},
+				{[]string{"BLPOP", "q", "x"}, "-ERR timeout is not a float or out of range\r\n"},
+				{[]string{"BLMOVE", "a", "b", "UP", "LEFT", "0"}, "-ERR syntax error\r\n"},
+				{[]string{"BLMPOP", "0", "0", "a", "LEFT"}, "-ERR numkeys should be greater than 0\r\n"},
+				{[]string{"BLMPOP", "0", "1", "a", "LEFT", "COUNT", "0"}, "-ERR count should be greater than 0\r\n"},
+			} {
+				if got := do(a, c1, tt.args...); got != tt.want {
+					t.Errorf("%q = %q, want %q", tt.args, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBlockingMove(t *testing.T) {
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			c1, c2, c3 := newFakeConn(), newFakeConn(), newFakeConn()
+			a.handleConnect(c1)
+			a.handleConnect(c2)
+			a.handleConnect(c3)
+
+			// BLMOVE 推入的元素继续唤醒等待 dst 的连接
+			r1 := blockedDo(t, a, c1, "BLMOVE", "src", "dst", "RIGHT", "LEFT", "0")
+			r2 := blockedDo(t, a, c2, "BLPOP", "dst", "0")
+			do(a, c3, "RPUSH", "src", "a", "b")
+			if got := recvReply(t, r1); got != "$1\r\nb\r\n" {
+				t.Errorf("BLMOVE = %q", got)
+			}
+			if got := recvReply(t, r2); got != "*2\r\n$3\r\ndst\r\n$1\r\nb\r\n" {
+				t.Errorf("BLPOP dst = %q", got)
+			}
+			if got := do(a, c3, "LRANGE", "src", "0", "-1"); got != "*1\r\n$1\r\na\r\n" {
+				t.Errorf("LRANGE src = %q", got)
+			}
+
+			if got := do(a, c1, "BRPOPLPUSH", "src", "src", "0"); got != "$1\r\na\r\n" {
+				t.Errorf("BRPOPLPUSH rotate = %q", got)
+			}
+			if got := do(a, c1, "BLMOVE", "none", "dst", "LEFT", "LEFT", "0.01"); got != "$-1\r\n" {
+				t.Errorf("BLMOVE timeout = %q", got)
+			}
+
+			r1 = blockedDo(t, a, c1, "BLMPOP", "0", "2", "x", "y", "LEFT", "COUNT", "2")
+			do(a, c3, "RPUSH", "y", "1", "2", "3")
+			if got := recvReply(t, r1); got != "*2\r\n$1\r\ny\r\n*2\r\n$1\r\n1\r\n$1\r\n2\r\n" {
+				t.Errorf("BLMPOP = %q", got)
+			}
+			if got := do(a, c1, "BLMPOP", "0", "2", "x", "y", "RIGHT", "COUNT", "5"); got != "*2\r\n$1\r\ny\r\n*1\r\n$1\r\n3\r\n" {
+				t.Errorf("BLMPOP with data = %q", got)
+			}
+		})
+	}
+}
+
+func TestUnblock(t *testing.T) {
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			c1, c2 := newFakeConn(), newFakeConn()
+			a.handleConnect(c1)
+			a.handleConnect(c2)
+			id := strconv.FormatInt(c1.Cid(), 10)
+
+			if got := do(a, c2, "CLIENT", "UNBLOCK", id); got != ":0\r\n" {
+				t.Errorf("CLIENT UNBLOCK idle client = %q", got)
+			}
+			r := blockedDo(t, a, c1, "BLPOP", "q", "0")
+			if got := do(a, c2, "CLIENT", "UNBLOCK", id); got != ":1\r\n" {
+				t.Errorf("CLIENT UNBLOCK = %q", got)
+			}
+			if got := recvReply(t, r); got != "*-1\r\n" {
+				t.Errorf("unblocked by timeout = %q", got)
+			}
+
+			r = blockedDo(t, a, c1, "BLMOVE", "q", "d", "LEFT", "LEFT", "0")
+			do(a, c2, "CLIENT", "UNBLOCK", id, "ERROR")
+			if got := recvReply(t, r); got != "-UNBLOCKED client unblocked via CLIENT UNBLOCK\r\n" {
+				t.Errorf("unblocked by error = %q", got)
+			}
+
+			// 断开连接
+			r = blockedDo(t, a, c1, "BLPOP", "q", "0")
+			a.handleDisconnect(c1, nil)
+			recvReply(t, r)
+			if n := a.blocking.count.Load(); n != 0 {
+				t.Errorf("%d waiters after disconnect", n)
+			}
+			do(a, c2, "RPUSH", "q", "v")
+			if got := do(a, c2, "LLEN", "q"); got != ":1\r\n" {
+				t.Errorf("disconnected client was served: LLEN = %q", got)
+			}
+		})
+	}
+}
+
+func TestBlockingInMultiAndScripts(t *testing.T) {
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			conn := newFakeConn()
+			a.handleConnect(conn)
+
+			do(a, conn, "MULTI")
+			do(a, conn, "BLPOP", "q", "0")
+			do(a, conn, "RPUSH", "q", "v")
+			do(a, conn, "BLPOP", "q", "0")
+			if got := do(a, conn, "EXEC"); got != "*3\r\n*-1\r\n:1\r\n*2\r\n$1\r\nq\r\n$1\r\nv\r\n" {
+				t.Errorf("EXEC = %q", got)
+			}
+			if got := do(a, conn, "EVAL", "return redis.call('BLPOP', KEYS[1], 0)", "1", "q"); got != "$-1\r\n" {
+				t.Errorf("EVAL BLPOP = %q", got)
+			}
+			if n := a.blocking.count.Load(); n != 0 {
+				t.Errorf("%d waiters left", n)
+			}
+		})
+	}
+}
+
+func TestBlockingWrongType(t *testing.T) {
+	const wrongType = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			c1, c2 := newFakeConn(), newFakeConn()
+			a.handleConnect(c1)
+			a.handleConnect(c2)
+
+			do(a, c2, "SET", "s", "x")
+			do(a, c2, "RPUSH", "src", "a")
+			for _, args := range [][]string{
+				{"BLPOP", "s", "0"},
+				{"BRPOP", "missing", "s", "0"},
+				{"BLMPOP", "0", "1", "s", "LEFT"},
+				{"BLMOVE", "src", "s", "LEFT", "LEFT", "0"},
+				{"BRPOPLPUSH", "src", "s", "0"},
+			} {
+				if got := do(a, c1, args...); got != wrongType {
+					t.Errorf("%q = %q", args, got)
+				}
+			}
+			if n := a.blocking.count.Load(); n != 0 {
+				t.Errorf("%d waiters left", n)
+			}
+			if got := do(a, c2, "LRANGE", "src", "0", "-1"); got != "*1\r\n$1\r\na\r\n" {
+				t.Errorf("LRANGE src = %q", got)
+			}
+			if got := do(a, c2, "GET", "s"); got != "$1\r\nx\r\n" {
+				t.Errorf("GET s = %q", got)
+			}
+
+			// 等待期间 dst 变成其他类型时回复错误，元素留在 src
+			r := blockedDo(t, a, c1, "BLMOVE", "q", "s", "LEFT", "LEFT", "0")
+			do(a, c2, "RPUSH", "q", "v")
+			if got := recvReply(t, r); got != wrongType {
+				t.Errorf("BLMOVE served into string = %q", got)
+			}
+			if got := do(a, c2, "LRANGE", "q", "0", "-1"); got != "*1\r\n$1\r\nv\r\n" {
+				t.Errorf("LRANGE q = %q", got)
+			}
+		})
+	}
+}
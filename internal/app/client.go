@@ -0,0 +1,127 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"literedis/internal/storage"
+	"literedis/pkg/log"
+	"literedis/pkg/network"
+	"literedis/pkg/protocol"
+)
+
+// client 保存单个连接的会话状态
+type client struct {
+	conn    network.Conn
+	storage storage.Storage        // 带有该连接当前所选数据库的存储视图
+	replyCh chan *protocol.Message // 事件循环模式下用于回传命令结果
+
+	name          string // 客户端名称，记录在慢日志等诊断信息中
+	user          string // 当前认证的 ACL 用户
+	authenticated bool
+
+	closeAfterReply   bool // 回复发送之后关闭连接
+	monitorAfterReply bool // 回复发送之后进入 MONITOR 模式
+
+	resp atomic.Int32               // 协议版本，2 或 3，由 HELLO 切换
+	sub  atomic.Pointer[subscriber] // 执行过订阅命令之后不为空，回复经由订阅者的队列发送
+
+	multi multiState // MULTI 之后排队的命令
+	watch watchState // WATCH 的键
+
+	blocked *waiter // 阻塞命令登记等待之后不为空，由 handleReceive 等待结果
+	nested  bool    // 正在执行 EXEC、脚本或函数中的命令，阻塞命令不阻塞
+
+	lastInteraction atomic.Int64 // 最近一次收到命令的时间（UnixNano），用于空闲超时
+
+	lgMu  sync.Mutex // 断开连接可能发生在其它协程，如空闲超时、Stop
+	lg    *log.Logger
+	lgKey clientLogKey // 创建 lg 时的名称、用户和数据库，变化之后重新创建
+}
+
+type clientLogKey struct {
+	name string
+	user string
+	db   int
+}
+
+func newClient(conn network.Conn, view storage.Storage) *client {
+	c := &client{
+		conn:    conn,
+		storage: view,
+		replyCh: make(chan *protocol.Message, 1),
+	}
+	c.resp.Store(2)
+	c.touch()
+	return c
+}
+
+func (c *client) protoVersion() int {
+	return int(c.resp.Load())
+}
+
+func (c *client) touch() {
+	c.lastInteraction.Store(time.Now().UnixNano())
+}
+
+// idle 返回客户端距离上次交互的时长
+func (c *client) idle(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, c.lastInteraction.Load()))
+}
+
+// logger 返回带有客户端上下文的 logger，服务该连接时输出的日志都通过它记录
+func (c *client) logger() *log.Logger {
+	key := clientLogKey{name: c.name, user: c.user, db: c.storage.SelectedDB()}
+	c.lgMu.Lock()
+	defer c.lgMu.Unlock()
+	if c.lg == nil || key != c.lgKey {
+		c.lg = log.With(
+			log.Pair("cid", c.conn.Cid()),
+			log.Pair("addr", c.conn.RemoteAddr()),
+			log.Pair("name", key.name),
+			log.Pair("user", key.user),
+			log.Pair("db", key.db),
+		)
+		c.lgKey = key
+	}
+	return c.lg
+}
+
+// info 返回客户端描述，格式同 CLIENT LIST 的一行
+func (c *client) info() string {
+	return fmt.Sprintf("id=%d addr=%s laddr=%s user=%s db=%d",
+		c.conn.Cid(), c.conn.RemoteAddr(), c.conn.LocalAddr(), c.user, c.storage.SelectedDB())
+}
+
+// clientsCronInterval 检查空闲连接的间隔
+const clientsCronInterval = time.Second
+
+func (a *App) startClientsCron() {
+	ticker := time.NewTicker(clientsCronInterval)
+	a.clientsCronTicker = ticker
+	go func() {
+		for now := range ticker.C {
+			a.closeIdleClients(now)
+			a.stats.sample(a.memory.load())
+		}
+	}()
+}
+
+// closeIdleClients 关闭空闲时间超过 timeout 的连接
+func (a *App) closeIdleClients(now time.Time) {
+	timeout := time.Duration(a.idleTimeout.Load()) * time.Second
+	if timeout <= 0 {
+		return
+	}
+	a.clients.Range(func(_, v interface{}) bool {
+		c := v.(*client)
+		// 与 Redis 相同，订阅模式的连接不会因空闲被关闭
+		if c.idle(now) > timeout && !a.subscribed(c) {
+			log.Debugf("closing idle client: %s", c.info())
+			c.conn.Close()
+		}
+		return true
+	})
+}
@@ -0,0 +1,507 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"literedis/config"
+	"literedis/internal/storage"
+	"literedis/pkg/log"
+	"literedis/pkg/protocol"
+)
+
+// configParam 运行时配置项。parse 校验并转换取值，apply 让对应的子系统立即生效
+type configParam struct {
+	name      string // CONFIG GET/SET 使用的名称
+	key       string // 配置文件中的键，如 max_clients、rdb.save_interval
+	tag       string // 写回配置文件时的 YAML 类型
+	immutable bool   // 只能在配置文件中修改
+
+	parse  func(value string) (interface{}, error)
+	format func(v interface{}) string
+	get    func(a *App) interface{}
+	apply  func(a *App, v interface{}) error
+}
+
+func intConfig(name, key string, min, max int64, get func(a *App) int64, apply func(a *App, n int64) error) *configParam {
+	return &configParam{
+		name: name,
+		key:  key,
+		tag:  "!!int",
+		parse: func(value string) (interface{}, error) {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, errors.New("argument couldn't be parsed into an integer")
+			}
+			if n < min || n > max {
+				return nil, fmt.Errorf("argument must be between %d and %d inclusive", min, max)
+			}
+			return n, nil
+		},
+		format: func(v interface{}) string { return strconv.FormatInt(v.(int64), 10) },
+		get:    func(a *App) interface{} { return get(a) },
+		apply:  func(a *App, v interface{}) error { return apply(a, v.(int64)) },
+	}
+}
+
+func stringConfig(name, key string, get func(a *App) string, apply func(a *App, s string) error) *configParam {
+	return &configParam{
+		name:   name,
+		key:    key,
+		tag:    "!!str",
+		parse:  func(value string) (interface{}, error) { return value, nil },
+		format: func(v interface{}) string { return v.(string) },
+		get:    func(a *App) interface{} { return get(a) },
+		apply:  func(a *App, v interface{}) error { return apply(a, v.(string)) },
+	}
+}
+
+// enumConfig 取值必须是 values 之一，不区分大小写
+func enumConfig(name, key string, values []string, get func(a *App) string, apply func(a *App, s string) error) *configParam {
+	p := stringConfig(name, key, get, apply)
+	p.parse = func(value string) (interface{}, error) {
+		for _, v := range values {
+			if strings.EqualFold(v, value) {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("argument(s) must be one of the following: %s", strings.Join(values, ", "))
+	}
+	return p
+}
+
+// durationConfig 时间长度，如 5m、30s
+func durationConfig(name, key string, min time.Duration, get func(a *App) time.Duration, apply func(a *App, d time.Duration) error) *configParam {
+	return &configParam{
+		name: name,
+		key:  key,
+		tag:  "!!str",
+		parse: func(value string) (interface{}, error) {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, errors.New("argument couldn't be parsed into a duration")
+			}
+			if d < min {
+				return nil, fmt.Errorf("argument must be at least %s", min)
+			}
+			return d, nil
+		},
+		format: func(v interface{}) string { return v.(time.Duration).String() },
+		get:    func(a *App) interface{} { return get(a) },
+		apply:  func(a *App, v interface{}) error { return apply(a, v.(time.Duration)) },
+	}
+}
+
+// memoryConfig 字节数，支持 kb、mb、gb 等单位
+func memoryConfig(name, key string, get func(a *App) int64, apply func(a *App, n int64) error) *configParam {
+	p := intConfig(name, key, 0, 1<<63-1, get, apply)
+	p.parse = func(value string) (interface{}, error) {
+		n, err := parseMemory(value)
+		if err != nil {
+			return nil, errors.New("argument must be a memory value")
+		}
+		return n, nil
+	}
+	return p
+}
+
+// notifyConfig notify-keyspace-events，取值按 Redis 的顺序规范化，如 "EKx" 显示为 "xKE"
+func notifyConfig() *configParam {
+	p := stringConfig("notify-keyspace-events", "notify_keyspace_events",
+		func(a *App) string {
+			if ms, ok := a.storage.(*storage.MemoryStorage); ok {
+				return ms.NotifyFlags().String()
+			}
+			return ""
+		},
+		func(a *App, s string) error {
+			flags, _ := storage.ParseNotifyFlags(s)
+			if ms, ok := a.storage.(*storage.MemoryStorage); ok {
+				ms.SetNotifyFlags(flags)
+			}
+			return nil
+		})
+	p.parse = func(value string) (interface{}, error) {
+		flags, err := storage.ParseNotifyFlags(value)
+		if err != nil {
+			return nil, err
+		}
+		return flags.String(), nil
+	}
+	return p
+}
+
+// listConfig 新建列表的节点大小和压缩深度，只对之后创建的列表生效
+func (a *App) listConfig() (fill, compressDepth int) {
+	if ms, ok := a.storage.(*storage.MemoryStorage); ok {
+		return ms.ListConfig()
+	}
+	return 0, 0
+}
+
+func (a *App) setListConfig(fill, compressDepth int) {
+	if ms, ok := a.storage.(*storage.MemoryStorage); ok {
+		ms.SetListConfig(fill, compressDepth)
+	}
+}
+
+// hashConfig 哈希使用 listpack 编码的上限，超过后转换为 hashtable
+func (a *App) hashConfig() (maxEntries, maxValue int) {
+	if ms, ok := a.storage.(*storage.MemoryStorage); ok {
+		return ms.HashConfig()
+	}
+	return 0, 0
+}
+
+func (a *App) setHashConfig(maxEntries, maxValue int) {
+	if ms, ok := a.storage.(*storage.MemoryStorage); ok {
+		ms.SetHashConfig(maxEntries, maxValue)
+	}
+}
+
+// outputLimitsConfig client-output-buffer-limit，CONFIG SET 只修改给出的类别
+func outputLimitsConfig() *configParam {
+	return &configParam{
+		name: "client-output-buffer-limit",
+		key:  "client_output_buffer_limit",
+		tag:  "!!str",
+		parse: func(value string) (interface{}, error) {
+			return parseOutputLimits(value)
+		},
+		format: func(v interface{}) string { return v.(outputLimits).String() },
+		get:    func(a *App) interface{} { return *a.outputLimits.Load() },
+		apply: func(a *App, v interface{}) error {
+			a.setOutputLimits(v.(outputLimits))
+			return nil
+		},
+	}
+}
+
+// immutableConfig 只读配置项，值来自启动时的配置文件
+func immutableConfig(name, key string, get func() string) *configParam {
+	p := stringConfig(name, key, func(*App) string { return get() }, nil)
+	p.immutable = true
+	return p
+}
+
+var memoryUnits = []struct {
+	suffix string
+	mul    int64
+}{
+	{"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10},
+	{"g", 1000 * 1000 * 1000}, {"m", 1000 * 1000}, {"k", 1000}, {"b", 1},
+}
+
+// parseMemory 解析内存大小，如 100mb、1gb、1024
+func parseMemory(s string) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	mul := int64(1)
+	for _, u := range memoryUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mul = strings.TrimSuffix(s, u.suffix), u.mul
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid memory value: %q", s)
+	}
+	return n * mul, nil
+}
+
+// configParams 所有运行时配置项，按名称排序
+var configParams = sortConfigParams([]*configParam{
+	stringConfig("bind", "bind",
+		func(a *App) string {
+			a.listenMu.Lock()
+			defer a.listenMu.Unlock()
+			return a.bind
+		},
+		(*App).setBind),
+	intConfig("port", "port", 0, 65535,
+		func(a *App) int64 {
+			a.listenMu.Lock()
+			defer a.listenMu.Unlock()
+			return int64(a.port)
+		},
+		func(a *App, n int64) error { return a.setPort(int(n)) }),
+	intConfig("maxclients", "max_clients", 1, 1<<31-1,
+		func(a *App) int64 { return a.maxClients.Load() },
+		func(a *App, n int64) error { a.maxClients.Store(n); return nil }),
+	intConfig("timeout", "timeout", 0, 1<<31-1,
+		func(a *App) int64 { return a.idleTimeout.Load() },
+		func(a *App, n int64) error { a.idleTimeout.Store(n); return nil }),
+	intConfig("tcp-keepalive", "tcp_keepalive", 0, 1<<31-1,
+		func(a *App) int64 { return a.tcpKeepAlive.Load() },
+		func(a *App, n int64) error { a.tcpKeepAlive.Store(n); return nil }),
+	memoryConfig("maxmemory", "maxmemory",
+		func(a *App) int64 { return a.maxMemory.Load() },
+		func(a *App, n int64) error { a.maxMemory.Store(n); return nil }),
+	enumConfig("loglevel", "log_level", []string{"debug", "info", "warn", "error"},
+		func(a *App) string { return log.Level() },
+		func(a *App, s string) error { return log.SetLevel(s) }),
+	stringConfig("logfile", "log_file",
+		func(a *App) string { return log.File() },
+		func(a *App, s string) error { return log.SetFile(s) }),
+	stringConfig("requirepass", "require_pass",
+		func(a *App) string { return a.requirePass },
+		func(a *App, s string) error {
+			a.requirePass = s
+			a.acl.SetRequirePass(s)
+			return nil
+		}),
+	intConfig("acllog-max-len", "acllog_max_len", 0, 1<<31-1,
+		func(a *App) int64 { return int64(a.aclLogMaxLen) },
+		func(a *App, n int64) error {
+			a.aclLogMaxLen = int(n)
+			a.acl.Log.SetMaxLen(int(n))
+			return nil
+		}),
+	intConfig("slowlog-log-slower-than", "slowlog_log_slower_than", -1, 1<<31-1,
+		func(a *App) int64 { return a.slowlog.slowerThan.Load() },
+		func(a *App, n int64) error { a.slowlog.slowerThan.Store(n); return nil }),
+	intConfig("slowlog-max-len", "slowlog_max_len", 0, 1<<31-1,
+		func(a *App) int64 { return int64(a.slowlog.maxLen()) },
+		func(a *App, n int64) error { a.slowlog.setMaxLen(int(n)); return nil }),
+	intConfig("latency-monitor-threshold", "latency_monitor_threshold", 0, 1<<31-1,
+		func(a *App) int64 { return a.latency.Threshold().Milliseconds() },
+		func(a *App, n int64) error { a.latency.SetThreshold(time.Duration(n) * time.Millisecond); return nil }),
+	intConfig("busy-reply-threshold", "busy_reply_threshold", 0, 1<<31-1,
+		func(a *App) int64 { return a.scripts.busyThreshold.Load() },
+		func(a *App, n int64) error { a.scripts.busyThreshold.Store(n); return nil }),
+	intConfig("list-max-listpack-size", "list_max_listpack_size", -5, 1<<15,
+		func(a *App) int64 { fill, _ := a.listConfig(); return int64(fill) },
+		func(a *App, n int64) error {
+			_, depth := a.listConfig()
+			a.setListConfig(int(n), depth)
+			return nil
+		}),
+	intConfig("list-compress-depth", "list_compress_depth", 0, 1<<31-1,
+		func(a *App) int64 { _, depth := a.listConfig(); return int64(depth) },
+		func(a *App, n int64) error {
+			fill, _ := a.listConfig()
+			a.setListConfig(fill, int(n))
+			return nil
+		}),
+	intConfig("hash-max-listpack-entries", "hash_max_listpack_entries", 0, 1<<31-1,
+		func(a *App) int64 { entries, _ := a.hashConfig(); return int64(entries) },
+		func(a *App, n int64) error {
+			_, value := a.hashConfig()
+			a.setHashConfig(int(n), value)
+			return nil
+		}),
+	intConfig("hash-max-listpack-value", "hash_max_listpack_value", 0, 1<<31-1,
+		func(a *App) int64 { _, value := a.hashConfig(); return int64(value) },
+		func(a *App, n int64) error {
+			entries, _ := a.hashConfig()
+			a.setHashConfig(entries, int(n))
+			return nil
+		}),
+	notifyConfig(),
+	outputLimitsConfig(),
+	stringConfig("dbfilename", "rdb.filename",
+		func(a *App) string { return a.storage.GetRDBConfig().Filename },
+		func(a *App, s string) error {
+			if s == "" || filepath.Base(s) != s {
+				return errors.New("dbfilename can't be a path, just a filename")
+			}
+			return a.updateRDBConfig(func(c *config.RDBConfig) { c.Filename = s })
+		}),
+	durationConfig("rdb-save-interval", "rdb.save_interval", time.Second,
+		func(a *App) time.Duration { return a.storage.GetRDBConfig().SaveInterval },
+		func(a *App, d time.Duration) error {
+			if a.rdbSaveTicker != nil {
+				a.rdbSaveTicker.Reset(d)
+			}
+			return a.updateRDBConfig(func(c *config.RDBConfig) { c.SaveInterval = d })
+		}),
+	intConfig("rdb-compression-level", "rdb.compression_level", -1, 9,
+		func(a *App) int64 { return int64(a.storage.GetRDBConfig().CompressionLevel) },
+		func(a *App, n int64) error {
+			return a.updateRDBConfig(func(c *config.RDBConfig) { c.CompressionLevel = int(n) })
+		}),
+	intConfig("rdb-auto-save-changes", "rdb.auto_save_changes", 1, 1<<31-1,
+		func(a *App) int64 { return int64(a.storage.GetRDBConfig().AutoSaveChanges) },
+		func(a *App, n int64) error {
+			return a.updateRDBConfig(func(c *config.RDBConfig) { c.AutoSaveChanges = int(n) })
+		}),
+
+	immutableConfig("databases", "databases", func() string { return strconv.Itoa(config.Conf.Databases) }),
+	immutableConfig("exec-mode", "exec_mode", func() string { return config.Conf.ExecMode }),
+	immutableConfig("aclfile", "acl_file", func() string { return config.Conf.ACLFile }),
+	immutableConfig("unixsocket", "unix_socket", func() string { return config.Conf.UnixSocket }),
+	immutableConfig("unixsocketperm", "unix_socket_perm", func() string { return config.Conf.UnixSocketPerm }),
+	immutableConfig("tls-port", "tls_port", func() string { return strconv.Itoa(config.Conf.TLSPort) }),
+	immutableConfig("tls-cert-file", "tls_cert_file", func() string { return config.Conf.TLSCertFile }),
+	immutableConfig("tls-key-file", "tls_key_file", func() string { return config.Conf.TLSKeyFile }),
+	immutableConfig("tls-ca-cert-file", "tls_ca_cert_file", func() string { return config.Conf.TLSCACertFile }),
+	immutableConfig("tls-auth-clients", "tls_auth_clients", func() string { return config.Conf.TLSAuthClients }),
+})
+
+func sortConfigParams(params []*configParam) []*configParam {
+	sort.Slice(params, func(i, j int) bool { return params[i].name < params[j].name })
+	return params
+}
+
+func lookupConfigParam(name string) *configParam {
+	name = strings.ToLower(name)
+	for _, p := range configParams {
+		if p.name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// updateRDBConfig 修改存储层的 RDB 配置，下一次保存时生效
+func (a *App) updateRDBConfig(update func(c *config.RDBConfig)) error {
+	c := a.storage.GetRDBConfig()
+	update(&c)
+	a.storage.SetRDBConfig(c)
+	return nil
+}
+
+func (a *App) handleConfig(c *client, args []string) (*protocol.Message, error) {
+	name := args[0]
+	sub := strings.ToLower(name)
+	args = args[1:]
+	wrongArgs := fmt.Errorf("wrong number of arguments for 'config|%s' command", sub)
+
+	switch sub {
+	case "get":
+		if len(args) < 1 {
+			return nil, wrongArgs
+		}
+		return a.configGet(args), nil
+
+	case "set":
+		if len(args) < 2 || len(args)%2 != 0 {
+			return nil, wrongArgs
+		}
+		if err := a.configSet(args); err != nil {
+			return nil, err
+		}
+		return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+
+	case "rewrite":
+		if len(args) != 0 {
+			return nil, wrongArgs
+		}
+		if err := a.configRewrite(); err != nil {
+			if errors.Is(err, config.ErrNoConfigFile) {
+				return nil, err
+			}
+			log.Errorf("CONFIG REWRITE failed: %v", err)
+			return nil, fmt.Errorf("Rewriting config file: %v", err)
+		}
+		return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+
+	case "resetstat":
+		if len(args) != 0 {
+			return nil, wrongArgs
+		}
+		a.resetStats()
+		return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+	}
+	return nil, fmt.Errorf("unknown subcommand '%s'. Try CONFIG HELP.", name)
+}
+
+// configGet 返回名称匹配任一 glob 模式的配置项
+func (a *App) configGet(patterns []string) *protocol.Message {
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+
+	replies := []*protocol.Message{}
+	for _, p := range configParams {
+		for _, pattern := range patterns {
+			if ok, _ := filepath.Match(strings.ToLower(pattern), p.name); ok {
+				replies = append(replies, bulk(p.name), bulk(p.format(p.get(a))))
+				break
+			}
+		}
+	}
+	return &protocol.Message{Type: "Array", Content: replies}
+}
+
+// configSet 先校验全部参数再依次生效，任一项失败时恢复已经修改的配置项
+func (a *App) configSet(args []string) error {
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+
+	type change struct {
+		param    *configParam
+		old, new interface{}
+	}
+	changes := make([]change, 0, len(args)/2)
+	seen := make(map[string]bool)
+	for i := 0; i < len(args); i += 2 {
+		p := lookupConfigParam(args[i])
+		if p == nil {
+			return fmt.Errorf("Unknown option or number of arguments for CONFIG SET - '%s'", args[i])
+		}
+		if seen[p.name] {
+			return setFailed(p.name, errors.New("duplicate parameter"))
+		}
+		seen[p.name] = true
+		if p.immutable {
+			return setFailed(p.name, errors.New("can't set immutable config"))
+		}
+		v, err := p.parse(args[i+1])
+		if err != nil {
+			return setFailed(p.name, err)
+		}
+		changes = append(changes, change{param: p, old: p.get(a), new: v})
+	}
+
+	for i, ch := range changes {
+		if err := ch.param.apply(a, ch.new); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				if rerr := changes[j].param.apply(a, changes[j].old); rerr != nil {
+					log.Errorf("restore config %s failed: %v", changes[j].param.name, rerr)
+				}
+			}
+			return setFailed(ch.param.name, err)
+		}
+	}
+	for _, ch := range changes {
+		a.configChanged[ch.param.name] = true
+	}
+	return nil
+}
+
+func setFailed(name string, err error) error {
+	return fmt.Errorf("ERR CONFIG SET failed (possibly related to argument '%s') - %v", name, err)
+}
+
+// configRewrite 把当前配置写回配置文件：文件中已有的项更新为当前值，
+// 通过 CONFIG SET 修改过的项追加到文件中
+func (a *App) configRewrite() error {
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+
+	entries := make([]config.Entry, 0, len(configParams))
+	for _, p := range configParams {
+		if p.immutable {
+			continue
+		}
+		entries = append(entries, config.Entry{
+			Key:    p.key,
+			Value:  p.format(p.get(a)),
+			Tag:    p.tag,
+			Append: a.configChanged[p.name],
+		})
+	}
+	return config.Rewrite(a.configFile, entries)
+}
+
+func (a *App) resetStats() {
+	a.stats.reset()
+	for _, st := range a.cmdStats {
+		st.reset()
+	}
+}
@@ -0,0 +1,263 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"literedis/pkg/log"
+)
+
+func TestConfigGetSet(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"CONFIG", "SET", "tcp-keepalive", "60", "TIMEOUT", "0"}, "+OK\r\n"},
+		{[]string{"CONFIG", "GET", "tcp-keepalive"}, "*2\r\n$13\r\ntcp-keepalive\r\n$2\r\n60\r\n"},
+		{[]string{"CONFIG", "GET", "nosuch"}, "*0\r\n"},
+		{[]string{"CONFIG", "SET", "nosuch", "1"}, "-ERR Unknown option or number of arguments for CONFIG SET - 'nosuch'\r\n"},
+		{[]string{"CONFIG", "SET", "maxclients", "0"}, "-ERR CONFIG SET failed (possibly related to argument 'maxclients') - argument must be between 1 and 2147483647 inclusive\r\n"},
+		{[]string{"CONFIG", "SET", "timeout", "abc"}, "-ERR CONFIG SET failed (possibly related to argument 'timeout') - argument couldn't be parsed into an integer\r\n"},
+		{[]string{"CONFIG", "SET", "timeout"}, "-ERR wrong number of arguments for 'config|set' command\r\n"},
+		{[]string{"CONFIG", "FOO"}, "-ERR unknown subcommand 'FOO'. Try CONFIG HELP.\r\n"},
+	}
+	for _, tt := range tests {
+		if got := do(a, conn, tt.args...); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+	if got := a.keepAlivePeriod(); got != time.Minute {
+		t.Errorf("keepAlivePeriod() = %v", got)
+	}
+}
+
+func TestConfigGetGlob(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	want := "*6\r\n$21\r\nrdb-auto-save-changes\r\n$4\r\n1000\r\n" +
+		"$21\r\nrdb-compression-level\r\n$1\r\n6\r\n" +
+		"$17\r\nrdb-save-interval\r\n$4\r\n5m0s\r\n"
+	if got := do(a, conn, "CONFIG", "GET", "rdb-*"); got != want {
+		t.Errorf("CONFIG GET rdb-* = %q", got)
+	}
+	if got := do(a, conn, "CONFIG", "GET", "MAXCLIENTS", "max*"); !strings.HasPrefix(got, "*4\r\n$10\r\nmaxclients\r\n") {
+		t.Errorf("CONFIG GET maxclients max* = %q", got)
+	}
+}
+
+func TestConfigSetApply(t *testing.T) {
+	a := newTestApp(t, ExecModeEventLoop)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+	t.Cleanup(func() { log.SetLevel("info") })
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"CONFIG", "SET", "loglevel", "DEBUG", "rdb-save-interval", "1m", "dbfilename", "other.rdb"}, "+OK\r\n"},
+		{[]string{"CONFIG", "GET", "loglevel"}, "*2\r\n$8\r\nloglevel\r\n$5\r\ndebug\r\n"},
+		{[]string{"CONFIG", "SET", "loglevel", "loud"}, "-ERR CONFIG SET failed (possibly related to argument 'loglevel') - argument(s) must be one of the following: debug, info, warn, error\r\n"},
+		{[]string{"CONFIG", "SET", "databases", "4"}, "-ERR CONFIG SET failed (possibly related to argument 'databases') - can't set immutable config\r\n"},
+		{[]string{"CONFIG", "SET", "timeout", "1", "TIMEOUT", "2"}, "-ERR CONFIG SET failed (possibly related to argument 'timeout') - duplicate parameter\r\n"},
+		// dbfilename 生效失败时，之前已经生效的 maxclients 恢复原值
+		{[]string{"CONFIG", "SET", "maxclients", "5", "dbfilename", "dir/x.rdb"}, "-ERR CONFIG SET failed (possibly related to argument 'dbfilename') - dbfilename can't be a path, just a filename\r\n"},
+		{[]string{"CONFIG", "GET", "maxclients"}, "*2\r\n$10\r\nmaxclients\r\n$5\r\n10000\r\n"},
+	}
+	for _, tt := range tests {
+		if got := do(a, conn, tt.args...); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+
+	if got := log.Level(); got != "debug" {
+		t.Errorf("log level = %s", got)
+	}
+	rdb := a.storage.GetRDBConfig()
+	if rdb.SaveInterval != time.Minute || filepath.Base(rdb.Filename) != "other.rdb" {
+		t.Errorf("RDB config not applied: %+v", rdb)
+	}
+}
+
+func TestConfigSetLogfile(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+	t.Cleanup(func() { log.SetFile("") })
+
+	file := filepath.Join(t.TempDir(), "literedis.log")
+	if got := do(a, conn, "CONFIG", "SET", "logfile", file); got != "+OK\r\n" {
+		t.Fatalf("CONFIG SET logfile = %q", got)
+	}
+	if log.File() != file {
+		t.Errorf("log file = %q", log.File())
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("log file not created: %v", err)
+	}
+	bad := filepath.Join(file, "x.log")
+	if got := do(a, conn, "CONFIG", "SET", "logfile", bad); !strings.HasPrefix(got, "-ERR CONFIG SET failed (possibly related to argument 'logfile')") {
+		t.Errorf("CONFIG SET logfile under a file = %q", got)
+	}
+	if log.File() != file {
+		t.Errorf("log file after failed set = %q", log.File())
+	}
+}
+
+func TestMaxMemory(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	do(a, conn, "SET", "k", "v")
+	if got := do(a, conn, "CONFIG", "SET", "maxmemory", "1kb"); got != "+OK\r\n" {
+		t.Fatalf("CONFIG SET maxmemory = %q", got)
+	}
+	if got := do(a, conn, "CONFIG", "GET", "maxmemory"); got != "*2\r\n$9\r\nmaxmemory\r\n$4\r\n1024\r\n" {
+		t.Errorf("CONFIG GET maxmemory = %q", got)
+	}
+	if got := do(a, conn, "SET", "k2", "v"); got != "-OOM command not allowed when used memory > 'maxmemory'.\r\n" {
+		t.Errorf("SET over maxmemory = %q", got)
+	}
+	if got := do(a, conn, "GET", "k"); got != "$1\r\nv\r\n" {
+		t.Errorf("GET over maxmemory = %q", got)
+	}
+	if got := do(a, conn, "DEL", "k"); got != ":1\r\n" {
+		t.Errorf("DEL over maxmemory = %q", got)
+	}
+	do(a, conn, "CONFIG", "SET", "maxmemory", "0")
+	if got := do(a, conn, "SET", "k2", "v"); got != "+OK\r\n" {
+		t.Errorf("SET after removing the limit = %q", got)
+	}
+}
+
+func TestParseMemory(t *testing.T) {
+	tests := map[string]int64{"0": 0, "100": 100, "1k": 1000, "1kb": 1024, "2MB": 2 << 20, "1gb": 1 << 30}
+	for in, want := range tests {
+		if got, err := parseMemory(in); err != nil || got != want {
+			t.Errorf("parseMemory(%q) = %d, %v", in, got, err)
+		}
+	}
+	for _, bad := range []string{"", "abc", "-1", "1tb"} {
+		if _, err := parseMemory(bad); err == nil {
+			t.Errorf("parseMemory(%q) should fail", bad)
+		}
+	}
+}
+
+func TestConfigRewrite(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "literedis.yaml")
+	os.WriteFile(file, []byte("# 监听端口\nport: 0\n\n# 连接\ntimeout: 0 # 秒\nrdb:\n  # 保存间隔\n  save_interval: 5m\n"), 0644)
+
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	if got := do(a, conn, "CONFIG", "REWRITE"); got != "-ERR The server is running without a config file\r\n" {
+		t.Errorf("REWRITE without file = %q", got)
+	}
+
+	a.configFile = file
+	do(a, conn, "CONFIG", "SET", "timeout", "30", "rdb-save-interval", "10s", "maxclients", "50")
+	if got := do(a, conn, "CONFIG", "REWRITE"); got != "+OK\r\n" {
+		t.Fatalf("REWRITE = %q", got)
+	}
+
+	data, _ := os.ReadFile(file)
+	got := string(data)
+	for _, want := range []string{"# 监听端口\nport: 8080\n", "timeout: 30 # 秒", "  # 保存间隔\n  save_interval: 10s", "max_clients: 50"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rewritten file missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "tcp_keepalive") {
+		t.Errorf("unchanged parameter was appended:\n%s", got)
+	}
+}
+
+func TestConfigResetStat(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+	if a.stats.connectionsReceived.Load() != 1 {
+		t.Fatalf("connectionsReceived = %d", a.stats.connectionsReceived.Load())
+	}
+	if got := do(a, conn, "CONFIG", "RESETSTAT"); got != "+OK\r\n" {
+		t.Fatalf("RESETSTAT = %q", got)
+	}
+	if a.stats.connectionsReceived.Load() != 0 {
+		t.Error("stats not reset")
+	}
+}
+
+func TestListConfig(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"CONFIG", "GET", "list-max-listpack-size"}, "*2\r\n$22\r\nlist-max-listpack-size\r\n$2\r\n-2\r\n"},
+		{[]string{"RPUSH", "small", "a", "b", "c"}, ":3\r\n"},
+		{[]string{"OBJECT", "ENCODING", "small"}, "$8\r\nlistpack\r\n"},
+		{[]string{"CONFIG", "SET", "list-max-listpack-size", "2", "list-compress-depth", "1"}, "+OK\r\n"},
+		{[]string{"RPUSH", "big", "a", "b", "c"}, ":3\r\n"},
+		{[]string{"OBJECT", "ENCODING", "big"}, "$9\r\nquicklist\r\n"},
+		// 已有的列表保持创建时的设置
+		{[]string{"RPUSH", "small", "d"}, ":4\r\n"},
+		{[]string{"OBJECT", "ENCODING", "small"}, "$8\r\nlistpack\r\n"},
+		{[]string{"RPOP", "big"}, "$1\r\nc\r\n"},
+		{[]string{"OBJECT", "ENCODING", "big"}, "$8\r\nlistpack\r\n"},
+		{[]string{"CONFIG", "SET", "list-max-listpack-size", "-6"}, "-ERR CONFIG SET failed (possibly related to argument 'list-max-listpack-size') - argument must be between -5 and 32768 inclusive\r\n"},
+		{[]string{"SET", "n", "12345"}, "+OK\r\n"},
+		{[]string{"OBJECT", "ENCODING", "n"}, "$3\r\nint\r\n"},
+		{[]string{"OBJECT", "ENCODING", "missing"}, "$-1\r\n"},
+		{[]string{"OBJECT", "FOO", "n"}, "-ERR unknown subcommand 'FOO'. Try OBJECT HELP.\r\n"},
+	}
+	for _, tt := range tests {
+		if got := do(a, conn, tt.args...); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestHashConfig(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"CONFIG", "GET", "hash-max-listpack-entries"}, "*2\r\n$25\r\nhash-max-listpack-entries\r\n$3\r\n128\r\n"},
+		{[]string{"HSET", "h", "a", "1"}, ":1\r\n"},
+		{[]string{"HSET", "h", "b", "2"}, ":1\r\n"},
+		{[]string{"OBJECT", "ENCODING", "h"}, "$8\r\nlistpack\r\n"},
+		{[]string{"CONFIG", "SET", "hash-max-listpack-entries", "2"}, "+OK\r\n"},
+		{[]string{"OBJECT", "ENCODING", "h"}, "$8\r\nlistpack\r\n"},
+		// 已有的哈希在下次写入时按新的上限转换
+		{[]string{"HSET", "h", "c", "3"}, ":1\r\n"},
+		{[]string{"OBJECT", "ENCODING", "h"}, "$9\r\nhashtable\r\n"},
+		{[]string{"CONFIG", "SET", "hash-max-listpack-value", "3"}, "+OK\r\n"},
+		{[]string{"HSET", "long", "f", "abcd"}, ":1\r\n"},
+		{[]string{"OBJECT", "ENCODING", "long"}, "$9\r\nhashtable\r\n"},
+		{[]string{"HGET", "long", "f"}, "$4\r\nabcd\r\n"},
+	}
+	for _, tt := range tests {
+		if got := do(a, conn, tt.args...); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"literedis/internal/acl"
+	"literedis/pkg/protocol"
+)
+
+// subscribeModeCommands RESP2 连接处于订阅模式时允许执行的命令
+var subscribeModeCommands = map[string]bool{
+	"SUBSCRIBE": true, "PSUBSCRIBE": true, "SSUBSCRIBE": true,
+	"UNSUBSCRIBE": true, "PUNSUBSCRIBE": true, "SUNSUBSCRIBE": true,
+	"PING": true, "QUIT": true, "RESET": true,
+}
+
+func (a *App) handlePing(c *client, args []string) (*protocol.Message, error) {
+	if len(args) > 1 {
+		return nil, errors.New("wrong number of arguments for 'ping' command")
+	}
+	// RESP2 的订阅模式下回复必须是数组，客户端才能与推送的消息区分
+	if c.protoVersion() == 2 && a.subscribed(c) {
+		msg := ""
+		if len(args) == 1 {
+			msg = args[0]
+		}
+		return array(bulk("pong"), bulk(msg)), nil
+	}
+	if len(args) == 1 {
+		return bulk(args[0]), nil
+	}
+	return &protocol.Message{Type: "SimpleString", Content: "PONG"}, nil
+}
+
+func (a *App) handleQuit(c *client, args []string) (*protocol.Message, error) {
+	c.closeAfterReply = true
+	return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+}
+
+// handleReset 把连接恢复到刚建立时的状态：取消订阅和事务、选择 0 号数据库、使用 RESP2、
+// 清除名称并切换回 default 用户
+func (a *App) handleReset(c *client, args []string) (*protocol.Message, error) {
+	a.unsubscribeAll(c.conn.Cid())
+	c.discardTransaction()
+	a.unwatchAll(c)
+	c.storage.Select(0)
+	c.resp.Store(2)
+	c.name = ""
+	c.user = acl.DefaultUser
+	u := a.acl.User(acl.DefaultUser)
+	c.authenticated = u != nil && u.Enabled && u.NoPass
+	return &protocol.Message{Type: "SimpleString", Content: "RESET"}, nil
+}
+
+// handleHello HELLO [protover [AUTH username password] [SETNAME clientname]]
+func (a *App) handleHello(c *client, args []string) (*protocol.Message, error) {
+	proto := c.protoVersion()
+	var name *string
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, errors.New("Protocol version is not an integer or out of range")
+		}
+		if v < 2 || v > 3 {
+			return nil, errors.New("NOPROTO unsupported protocol version")
+		}
+		proto = v
+		for i := 1; i < len(args); i++ {
+			switch opt := strings.ToUpper(args[i]); {
+			case opt == "AUTH" && i+2 < len(args):
+				if _, err := a.handleAuth(c, args[i+1:i+3]); err != nil {
+					return nil, err
+				}
+				i += 2
+			case opt == "SETNAME" && i+1 < len(args):
+				if strings.ContainsAny(args[i+1], " \n") {
+					return nil, errors.New("Client names cannot contain spaces, newlines or special characters.")
+				}
+				name = &args[i+1]
+				i++
+			default:
+				return nil, errors.New("Syntax error in HELLO option '" + args[i] + "'")
+			}
+		}
+	}
+	if !c.authenticated {
+		return nil, errors.New("NOAUTH HELLO must be called with the client already authenticated, " +
+			"otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client " +
+			"and select the RESP protocol version at the same time")
+	}
+
+	c.resp.Store(int32(proto))
+	if name != nil {
+		c.name = *name
+	}
+	mode := "standalone"
+	if a.cluster != nil {
+		mode = "cluster"
+	}
+	return replyMap(c,
+		bulk("server"), bulk("literedis"),
+		bulk("version"), bulk(redisVersion),
+		bulk("proto"), integer(int64(proto)),
+		bulk("id"), integer(c.conn.Cid()),
+		bulk("mode"), bulk(mode),
+		bulk("role"), bulk("master"),
+		bulk("modules"), array(),
+	), nil
+}
+
+// replyMap 生成键值对回复，RESP3 连接使用 map 类型，RESP2 连接使用扁平数组
+func replyMap(c *client, kvs ...*protocol.Message) *protocol.Message {
+	msg := array(kvs...)
+	if c.protoVersion() == 3 {
+		msg.Type = "Map"
+	}
+	return msg
+}
+
+// checkSubscribeMode RESP2 连接在订阅模式下只能执行订阅相关的命令
+func (a *App) checkSubscribeMode(c *client, cmd *command) error {
+	if c.protoVersion() != 2 || subscribeModeCommands[cmd.name] || !a.subscribed(c) {
+		return nil
+	}
+	return errors.New("Can't execute '" + strings.ToLower(cmd.name) +
+		"': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context")
+}
+
+// handleClient CLIENT ID|GETNAME|SETNAME|UNBLOCK
+func (a *App) handleClient(c *client, args []string) (*protocol.Message, error) {
+	switch sub := strings.ToUpper(args[0]); {
+	case sub == "ID" && len(args) == 1:
+		return integer(c.conn.Cid()), nil
+	case sub == "GETNAME" && len(args) == 1:
+		if c.name == "" {
+			return &protocol.Message{Type: "Null"}, nil
+		}
+		return bulk(c.name), nil
+	case sub == "SETNAME" && len(args) == 2:
+		if strings.ContainsAny(args[1], " \n") {
+			return nil, errors.New("Client names cannot contain spaces, newlines or special characters.")
+		}
+		c.name = args[1]
+		return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+	case sub == "UNBLOCK" && (len(args) == 2 || len(args) == 3):
+		return a.clientUnblock(args[1:])
+	case sub == "HELP":
+		return array(
+			bulk("CLIENT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:"),
+			bulk("GETNAME"),
+			bulk("ID"),
+			bulk("SETNAME <name>"),
+			bulk("UNBLOCK <clientid> [TIMEOUT|ERROR]"),
+		), nil
+	}
+	return nil, fmt.Errorf("unknown subcommand '%s'. Try CLIENT HELP.", args[0])
+}
+
+// clientUnblock CLIENT UNBLOCK id [TIMEOUT|ERROR]，默认按超时处理
+func (a *App) clientUnblock(args []string) (*protocol.Message, error) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return nil, errors.New("value is not an integer or out of range")
+	}
+	byError := false
+	if len(args) == 2 {
+		switch strings.ToUpper(args[1]) {
+		case "TIMEOUT":
+		case "ERROR":
+			byError = true
+		default:
+			return nil, errors.New("CLIENT UNBLOCK reason should be TIMEOUT or ERROR")
+		}
+	}
+	a.blocking.mu.Lock()
+	defer a.blocking.mu.Unlock()
+	w, ok := a.blocking.byClient[id]
+	if !ok {
+		return integer(0), nil
+	}
+	reply := w.timeoutReply
+	if byError {
+		reply = errorReply(errUnblocked)
+	}
+	a.blocking.finish(w, reply)
+	return integer(1), nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package app
+
+import "time"
+
+// cpuUsage 当前平台不支持读取 CPU 时间，返回 0
+func cpuUsage() (sys, user time.Duration) {
+	return 0, 0
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package app
+
+import (
+	"syscall"
+	"time"
+)
+
+// cpuUsage 返回进程的系统态和用户态 CPU 时间
+func cpuUsage() (sys, user time.Duration) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, 0
+	}
+	return time.Duration(ru.Stime.Nano()), time.Duration(ru.Utime.Nano())
+}
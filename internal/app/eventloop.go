@@ -0,0 +1,109 @@
+package app
+
+import (
+	"sync/atomic"
+)
+
+// task is a unit of work queued on the event loop
+type task struct {
+	next atomic.Pointer[task]
+	fn   func()
+}
+
+// taskQueue is a lock-free multi-producer single-consumer queue (Vyukov's
+// intrusive MPSC queue). Any goroutine may push, only the loop pops.
+type taskQueue struct {
+	head atomic.Pointer[task] // most recently pushed task, swapped by producers
+	tail *task                // next task to consume, owned by the consumer
+	stub task
+}
+
+func newTaskQueue() *taskQueue {
+	q := &taskQueue{}
+	q.head.Store(&q.stub)
+	q.tail = &q.stub
+	return q
+}
+
+// push appends t to the queue. It never blocks and is safe for concurrent use.
+func (q *taskQueue) push(t *task) {
+	t.next.Store(nil)
+	prev := q.head.Swap(t)
+	prev.next.Store(t)
+}
+
+// pop removes the oldest task. It returns nil when the queue is empty or when
+// a producer is halfway through a push; that producer wakes the loop once the
+// push is visible.
+func (q *taskQueue) pop() *task {
+	tail := q.tail
+	next := tail.next.Load()
+	if tail == &q.stub {
+		if next == nil {
+			return nil
+		}
+		q.tail = next
+		tail = next
+		next = next.next.Load()
+	}
+	if next != nil {
+		q.tail = next
+		return tail
+	}
+	if tail != q.head.Load() {
+		return nil
+	}
+	q.push(&q.stub)
+	next = tail.next.Load()
+	if next != nil {
+		q.tail = next
+		return tail
+	}
+	return nil
+}
+
+// eventLoop executes every submitted function on one dedicated goroutine,
+// giving commands strict serial semantics without fine-grained locking.
+type eventLoop struct {
+	queue  *taskQueue
+	wakeup chan struct{}
+	done   chan struct{}
+	closed atomic.Bool
+}
+
+func newEventLoop() *eventLoop {
+	return &eventLoop{
+		queue:  newTaskQueue(),
+		wakeup: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// submit queues fn to run on the loop goroutine
+func (l *eventLoop) submit(fn func()) {
+	l.queue.push(&task{fn: fn})
+	select {
+	case l.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// run processes queued tasks until stop is called
+func (l *eventLoop) run() {
+	for {
+		for t := l.queue.pop(); t != nil; t = l.queue.pop() {
+			t.fn()
+		}
+		select {
+		case <-l.wakeup:
+		case <-l.done:
+			return
+		}
+	}
+}
+
+func (l *eventLoop) stop() {
+	if l.closed.CompareAndSwap(false, true) {
+		close(l.done)
+	}
+}
@@ -0,0 +1,215 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"literedis/internal/consts"
+	"literedis/pkg/log"
+	"literedis/pkg/protocol"
+)
+
+// FunctionFlag 服务端函数的标志，与 Redis Functions 的 flags 相同
+type FunctionFlag int
+
+const (
+	// FunctionNoWrites 函数只读，可以通过 FCALL_RO 调用
+	FunctionNoWrites FunctionFlag = 1 << iota
+	// FunctionAllowOOM 超过 maxmemory 时仍然允许执行
+	FunctionAllowOOM
+	// FunctionNoCluster 集群模式下不能执行
+	FunctionNoCluster
+)
+
+var functionFlagNames = []struct {
+	flag FunctionFlag
+	name string
+}{
+	{FunctionNoWrites, "no-writes"},
+	{FunctionAllowOOM, "allow-oom"},
+	{FunctionNoCluster, "no-cluster"},
+}
+
+// Names 返回标志的名称，用于 FUNCTION LIST
+func (f FunctionFlag) Names() []string {
+	names := []string{}
+	for _, fn := range functionFlagNames {
+		if f&fn.flag != 0 {
+			names = append(names, fn.name)
+		}
+	}
+	return names
+}
+
+// FunctionHandler 服务端函数的实现，keys 和 args 是 FCALL 传入的键和参数
+type FunctionHandler func(tx *Tx, keys, args []string) (*protocol.Message, error)
+
+// Function 通过 FCALL 调用的服务端函数
+type Function struct {
+	Name        string
+	Description string
+	Flags       FunctionFlag
+	Handler     FunctionHandler
+}
+
+// FunctionLibrary 一组服务端函数，由嵌入程序通过 WithFunctionLibrary 注册
+type FunctionLibrary struct {
+	Name      string
+	Functions []Function
+}
+
+// WithFunctionLibrary 注册服务端函数库，函数名在所有库之间唯一
+func WithFunctionLibrary(lib FunctionLibrary) OptionFunc {
+	return func(o *options) { o.libraries = append(o.libraries, lib) }
+}
+
+// Tx 函数执行期间调用者数据库的视图。函数在 execMu 的写锁下执行，期间其它连接的命令
+// 都要等待，因此函数中的多次调用是原子的。与 Redis 相同，出错时已经执行的写命令不会回滚
+type Tx struct {
+	a     *App
+	scope *callScope
+}
+
+// Call 在调用者当前的数据库上执行命令，只能访问 FCALL 声明的键，
+// no-writes 函数不能执行写命令
+func (tx *Tx) Call(args ...string) (*protocol.Message, error) {
+	if len(args) == 0 {
+		return nil, errors.New("Please specify at least one argument for this call")
+	}
+	return tx.a.scopedCommand(tx.scope, args)
+}
+
+// DB 返回调用者当前选择的数据库
+func (tx *Tx) DB() int {
+	return tx.scope.c.storage.SelectedDB()
+}
+
+// registeredFunction 注册之后的函数及其所属的库
+type registeredFunction struct {
+	Function
+	library string
+}
+
+// registerFunctions 注册 WithFunctionLibrary 传入的函数库，重复的函数名被忽略
+func (a *App) registerFunctions(libs []FunctionLibrary) {
+	a.functions = make(map[string]*registeredFunction)
+	for _, lib := range libs {
+		a.libraries = append(a.libraries, lib.Name)
+		for _, fn := range lib.Functions {
+			if _, ok := a.functions[fn.Name]; ok || fn.Handler == nil {
+				log.Errorf("Ignoring function %q in library %q: duplicate name or nil handler", fn.Name, lib.Name)
+				continue
+			}
+			a.functions[fn.Name] = &registeredFunction{Function: fn, library: lib.Name}
+		}
+	}
+}
+
+// handleFCall FCALL function numkeys [key ...] [arg ...]
+func (a *App) handleFCall(c *client, args []string) (*protocol.Message, error) {
+	return a.fcall(c, args, false)
+}
+
+func (a *App) handleFCallRO(c *client, args []string) (*protocol.Message, error) {
+	return a.fcall(c, args, true)
+}
+
+// fcall 执行函数。processCommand 为 FCALL 持有 execMu 的写锁，EXEC 中执行时由 EXEC 持有
+func (a *App) fcall(c *client, args []string, readOnly bool) (*protocol.Message, error) {
+	fn, ok := a.functions[args[0]]
+	if !ok {
+		return nil, errors.New("Function not found")
+	}
+	keys, argv, err := parseNumKeys(args[1], args[2:])
+	if err != nil {
+		return nil, err
+	}
+	noWrites := fn.Flags&FunctionNoWrites != 0
+	if readOnly && !noWrites {
+		return nil, errors.New("Can not execute a script with write flag using *_ro command.")
+	}
+	if fn.Flags&FunctionNoCluster != 0 && a.cluster != nil {
+		return nil, errors.New("Can not run script on cluster, 'no-cluster' flag is set.")
+	}
+	if !noWrites && fn.Flags&FunctionAllowOOM == 0 {
+		if max := a.maxMemory.Load(); max > 0 && a.memory.load() > max {
+			return nil, consts.ErrMaxMemoryReached
+		}
+	}
+	// 函数中的 SELECT 不影响连接
+	db := c.storage.SelectedDB()
+	defer c.storage.Select(db)
+	return fn.Handler(&Tx{a: a, scope: newCallScope(c, keys, readOnly || noWrites)}, keys, argv)
+}
+
+// handleFunction FUNCTION LIST [LIBRARYNAME pattern] | HELP
+func (a *App) handleFunction(c *client, args []string) (*protocol.Message, error) {
+	switch sub := strings.ToUpper(args[0]); sub {
+	case "LIST":
+		pattern := ""
+		for i := 1; i < len(args); i++ {
+			switch opt := strings.ToUpper(args[i]); {
+			case opt == "LIBRARYNAME" && i+1 < len(args) && pattern == "":
+				pattern = args[i+1]
+				i++
+			case opt == "WITHCODE":
+				// Go 函数没有源码，接受该选项但不返回 library_code
+			default:
+				return nil, fmt.Errorf("Unknown argument %s", args[i])
+			}
+		}
+		return a.functionList(c, pattern), nil
+	case "HELP":
+		return array(
+			bulk("FUNCTION <subcommand> [<arg> [value] [opt] ...]. Subcommands are:"),
+			bulk("LIST [LIBRARYNAME <library_name_pattern>] [WITHCODE]"),
+			bulk("    Return general information on all the libraries. Functions are registered"),
+			bulk("    by the server with WithFunctionLibrary and can't be loaded or deleted."),
+		), nil
+	}
+	return nil, fmt.Errorf("unknown subcommand '%s'. Try FUNCTION HELP.", args[0])
+}
+
+func (a *App) functionList(c *client, pattern string) *protocol.Message {
+	libs := make([]*protocol.Message, 0, len(a.libraries))
+	for _, lib := range a.libraries {
+		if pattern != "" {
+			if ok, _ := filepath.Match(pattern, lib); !ok {
+				continue
+			}
+		}
+		var names []string
+		for name, fn := range a.functions {
+			if fn.library == lib {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		fns := make([]*protocol.Message, 0, len(names))
+		for _, name := range names {
+			fn := a.functions[name]
+			desc := &protocol.Message{Type: "Null"}
+			if fn.Description != "" {
+				desc = bulk(fn.Description)
+			}
+			flags := make([]*protocol.Message, 0, 3)
+			for _, f := range fn.Flags.Names() {
+				flags = append(flags, bulk(f))
+			}
+			fns = append(fns, replyMap(c,
+				bulk("name"), bulk(name),
+				bulk("description"), desc,
+				bulk("flags"), array(flags...),
+			))
+		}
+		libs = append(libs, replyMap(c,
+			bulk("library_name"), bulk(lib),
+			bulk("engine"), bulk("GO"),
+			bulk("functions"), array(fns...),
+		))
+	}
+	return array(libs...)
+}
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"literedis/pkg/protocol"
+)
+
+var testLibrary = FunctionLibrary{
+	Name: "counter",
+	Functions: []Function{
+		{
+			Name:        "incr",
+			Description: "increments a counter stored as a string",
+			Handler: func(tx *Tx, keys, args []string) (*protocol.Message, error) {
+				reply, err := tx.Call("GET", keys[0])
+				if err != nil {
+					return nil, err
+				}
+				n := 0
+				if b, ok := reply.Content.([]byte); ok && b != nil {
+					if n, err = strconv.Atoi(string(b)); err != nil {
+						return nil, errors.New("value is not an integer")
+					}
+				}
+				n++
+				if _, err := tx.Call("SET", keys[0], strconv.Itoa(n)); err != nil {
+					return nil, err
+				}
+				return &protocol.Message{Type: "Integer", Content: int64(n)}, nil
+			},
+		},
+		{
+			Name:  "peek",
+			Flags: FunctionNoWrites,
+			Handler: func(tx *Tx, keys, args []string) (*protocol.Message, error) {
+				return tx.Call("GET", keys[0])
+			},
+		},
+		{
+			Name:  "sneaky",
+			Flags: FunctionNoWrites | FunctionAllowOOM,
+			Handler: func(tx *Tx, keys, args []string) (*protocol.Message, error) {
+				return tx.Call("SET", keys[0], "x")
+			},
+		},
+	},
+}
+
+func TestFCall(t *testing.T) {
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode, WithFunctionLibrary(testLibrary))
+			conn := newFakeConn()
+			a.handleConnect(conn)
+
+			tests := []struct {
+				args []string
+				want string
+			}{
+				{[]string{"FCALL", "incr", "1", "c"}, ":1\r\n"},
+				{[]string{"FCALL", "incr", "1", "c"}, ":2\r\n"},
+				{[]string{"FCALL_RO", "peek", "1", "c"}, "$1\r\n2\r\n"},
+				{[]string{"FCALL_RO", "incr", "1", "c"}, "-ERR Can not execute a script with write flag using *_ro command.\r\n"},
+				{[]string{"FCALL", "sneaky", "1", "c"}, "-ERR Write commands are not allowed from read-only scripts.\r\n"},
+				{[]string{"FCALL", "peek", "1", "other"}, "$-1\r\n"},
+				{[]string{"FCALL", "nope", "0"}, "-ERR Function not found\r\n"},
+				{[]string{"FCALL", "incr", "2", "c"}, "-ERR Number of keys can't be greater than number of args\r\n"},
+				{[]string{"GET", "c"}, "$1\r\n2\r\n"},
+			}
+			for _, tt := range tests {
+				if got := do(a, conn, tt.args...); got != tt.want {
+					t.Errorf("%q = %q, want %q", tt.args, got, tt.want)
+				}
+			}
+
+			// 只能访问声明的键
+			lib := FunctionLibrary{Name: "x", Functions: []Function{{Name: "leak",
+				Handler: func(tx *Tx, keys, args []string) (*protocol.Message, error) { return tx.Call("GET", "c") }}}}
+			a2 := newTestApp(t, mode, WithFunctionLibrary(lib))
+			a2.handleConnect(conn)
+			if got := do(a2, conn, "FCALL", "leak", "0"); !strings.Contains(got, "not declared") {
+				t.Errorf("undeclared key = %q", got)
+			}
+		})
+	}
+}
+
+func TestFunctionList(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded, WithFunctionLibrary(testLibrary),
+		WithFunctionLibrary(FunctionLibrary{Name: "empty"}))
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	want := "*1\r\n*6\r\n$12\r\nlibrary_name\r\n$7\r\ncounter\r\n$6\r\nengine\r\n$2\r\nGO\r\n$9\r\nfunctions\r\n*3\r\n" +
+		"*6\r\n$4\r\nname\r\n$4\r\nincr\r\n$11\r\ndescription\r\n$39\r\nincrements a counter stored as a string\r\n$5\r\nflags\r\n*0\r\n" +
+		"*6\r\n$4\r\nname\r\n$4\r\npeek\r\n$11\r\ndescription\r\n$-1\r\n$5\r\nflags\r\n*1\r\n$9\r\nno-writes\r\n" +
+		"*6\r\n$4\r\nname\r\n$6\r\nsneaky\r\n$11\r\ndescription\r\n$-1\r\n$5\r\nflags\r\n*2\r\n$9\r\nno-writes\r\n$9\r\nallow-oom\r\n"
+	if got := do(a, conn, "FUNCTION", "LIST", "LIBRARYNAME", "co*"); got != want {
+		t.Errorf("FUNCTION LIST = %q\nwant %q", got, want)
+	}
+	if got := do(a, conn, "FUNCTION", "LIST"); !strings.HasPrefix(got, "*2\r\n") {
+		t.Errorf("FUNCTION LIST = %q", got)
+	}
+	do(a, conn, "HELLO", "3")
+	if got := do(a, conn, "FUNCTION", "LIST", "LIBRARYNAME", "empty"); got !=
+		"*1\r\n%3\r\n$12\r\nlibrary_name\r\n$5\r\nempty\r\n$6\r\nengine\r\n$2\r\nGO\r\n$9\r\nfunctions\r\n*0\r\n" {
+		t.Errorf("FUNCTION LIST RESP3 = %q", got)
+	}
+	if got := do(a, conn, "FUNCTION", "LOAD", "x"); !strings.HasPrefix(got, "-ERR unknown subcommand 'LOAD'") {
+		t.Errorf("FUNCTION LOAD = %q", got)
+	}
+}
+
+func TestFCallIsAtomic(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded, WithFunctionLibrary(testLibrary))
+	const clients, rounds = 8, 50
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		conn := newFakeConn()
+		a.handleConnect(conn)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				do(a, conn, "FCALL", "incr", "1", "n")
+			}
+		}()
+	}
+	wg.Wait()
+	conn := newFakeConn()
+	a.handleConnect(conn)
+	if got := do(a, conn, "GET", "n"); got != "$3\r\n400\r\n" {
+		t.Errorf("GET n = %q, lost updates", got)
+	}
+}
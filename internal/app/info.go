@@ -0,0 +1,257 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"sort"
+	"strings"
+	"time"
+
+	"literedis/pkg/protocol"
+)
+
+// redisVersion 对客户端声明的兼容版本，客户端据此判断支持的命令
+const redisVersion = "7.2.0"
+
+// infoSection INFO 的一个分组，def 表示是否包含在默认输出中
+type infoSection struct {
+	name  string
+	def   bool
+	write func(a *App, w *infoWriter)
+}
+
+var infoSections = []infoSection{
+	{"Server", true, (*App).infoServer},
+	{"Clients", true, (*App).infoClients},
+	{"Memory", true, (*App).infoMemory},
+	{"Persistence", true, (*App).infoPersistence},
+	{"Stats", true, (*App).infoStats},
+	{"Replication", true, (*App).infoReplication},
+	{"CPU", true, (*App).infoCPU},
+	{"Commandstats", false, (*App).infoCommandStats},
+	{"Errorstats", true, (*App).infoErrorStats},
+	{"Cluster", true, (*App).infoCluster},
+	{"Keyspace", true, (*App).infoKeyspace},
+}
+
+type infoWriter struct {
+	b strings.Builder
+}
+
+func (w *infoWriter) field(key string, value interface{}) {
+	fmt.Fprintf(&w.b, "%s:%v\r\n", key, value)
+}
+
+func (a *App) handleInfo(c *client, args []string) (*protocol.Message, error) {
+	all, def := false, len(args) == 0
+	selected := make(map[string]bool)
+	for _, arg := range args {
+		switch name := strings.ToLower(arg); name {
+		case "all", "everything":
+			all = true
+		case "default":
+			def = true
+		default:
+			selected[name] = true
+		}
+	}
+
+	var out strings.Builder
+	for _, s := range infoSections {
+		if !all && !(def && s.def) && !selected[strings.ToLower(s.name)] {
+			continue
+		}
+		if out.Len() > 0 {
+			out.WriteString("\r\n")
+		}
+		w := &infoWriter{}
+		s.write(a, w)
+		fmt.Fprintf(&out, "# %s\r\n%s", s.name, w.b.String())
+	}
+	return bulk(out.String()), nil
+}
+
+func (a *App) infoServer(w *infoWriter) {
+	uptime := time.Since(a.startTime)
+	mode := "standalone"
+	if a.cluster != nil {
+		mode = "cluster"
+	}
+	executable, _ := os.Executable()
+
+	a.listenMu.Lock()
+	port := a.port
+	a.listenMu.Unlock()
+
+	w.field("redis_version", redisVersion)
+	w.field("redis_mode", mode)
+	w.field("os", runtime.GOOS+" "+runtime.GOARCH)
+	w.field("arch_bits", 32<<(^uint(0)>>63))
+	w.field("go_version", runtime.Version())
+	w.field("exec_mode", a.opts.execMode)
+	w.field("process_id", os.Getpid())
+	w.field("run_id", a.runID)
+	w.field("tcp_port", port)
+	w.field("server_time_usec", time.Now().UnixMicro())
+	w.field("uptime_in_seconds", int64(uptime.Seconds()))
+	w.field("uptime_in_days", int64(uptime.Hours()/24))
+	w.field("executable", executable)
+	w.field("config_file", a.configFile)
+}
+
+func (a *App) infoClients(w *infoWriter) {
+	w.field("connected_clients", a.numClients.Load())
+	w.field("maxclients", a.maxClients.Load())
+	w.field("blocked_clients", a.blocking.count.Load())
+}
+
+func (a *App) infoMemory(w *infoWriter) {
+	used := a.memory.load()
+	peak := max(a.stats.peakMemory.Load(), used)
+	sample := []metrics.Sample{{Name: "/memory/classes/total:bytes"}}
+	metrics.Read(sample)
+	rss := int64(sample[0].Value.Uint64())
+	maxMemory := a.maxMemory.Load()
+
+	w.field("used_memory", used)
+	w.field("used_memory_human", humanBytes(used))
+	w.field("used_memory_rss", rss)
+	w.field("used_memory_rss_human", humanBytes(rss))
+	w.field("used_memory_peak", peak)
+	w.field("used_memory_peak_human", humanBytes(peak))
+	w.field("maxmemory", maxMemory)
+	w.field("maxmemory_human", humanBytes(maxMemory))
+	w.field("maxmemory_policy", "noeviction")
+	w.field("mem_allocator", "go")
+}
+
+func (a *App) infoPersistence(w *infoWriter) {
+	st := a.storage.GetRDBStats()
+	lastSave := st.LastSaveTime
+	if lastSave.IsZero() {
+		lastSave = a.startTime
+	}
+	w.field("loading", boolInt(a.loading.Load()))
+	w.field("rdb_changes_since_last_save", st.ChangesSinceLastSave)
+	w.field("rdb_bgsave_in_progress", boolInt(st.SaveInProgress))
+	w.field("rdb_last_save_time", lastSave.Unix())
+	w.field("rdb_last_bgsave_status", st.LastSaveStatus)
+	w.field("rdb_last_bgsave_time_sec", int64(st.LastSaveDuration.Seconds()))
+	w.field("rdb_saves", st.TotalSaves)
+	w.field("rdb_last_save_size", st.LastSaveSize)
+	w.field("rdb_total_keys_saved", st.TotalKeysSaved)
+	w.field("aof_enabled", 0)
+}
+
+func (a *App) infoStats(w *infoWriter) {
+	w.field("total_connections_received", a.stats.connectionsReceived.Load())
+	w.field("total_commands_processed", a.stats.commandsProcessed.Load())
+	w.field("instantaneous_ops_per_sec", a.stats.opsPerSec.Load())
+	w.field("total_net_input_bytes", a.stats.netInputBytes.Load())
+	w.field("total_net_output_bytes", a.stats.netOutputBytes.Load())
+	w.field("rejected_connections", a.stats.rejectedConnections.Load())
+	w.field("expired_keys", a.storage.ExpiredKeys())
+	w.field("evicted_keys", 0)
+	w.field("keyspace_hits", a.stats.keyspaceHits.Load())
+	w.field("keyspace_misses", a.stats.keyspaceMisses.Load())
+	w.field("total_error_replies", a.stats.errorReplies.Load())
+	w.field("client_output_buffer_limit_disconnections", a.stats.outputLimitDisconnections.Load())
+	a.pubsub.mu.RLock()
+	w.field("pubsub_channels", len(a.pubsub.index[subChannel]))
+	w.field("pubsub_patterns", len(a.pubsub.index[subPattern]))
+	w.field("pubsubshard_channels", len(a.pubsub.index[subShard]))
+	a.pubsub.mu.RUnlock()
+}
+
+func (a *App) infoReplication(w *infoWriter) {
+	w.field("role", "master")
+	w.field("connected_slaves", 0)
+	w.field("master_replid", a.runID)
+	w.field("master_repl_offset", 0)
+}
+
+func (a *App) infoCPU(w *infoWriter) {
+	sys, user := cpuUsage()
+	w.field("used_cpu_sys", fmt.Sprintf("%.6f", sys.Seconds()))
+	w.field("used_cpu_user", fmt.Sprintf("%.6f", user.Seconds()))
+}
+
+// infoCommandStats 只输出被调用过的命令
+func (a *App) infoCommandStats(w *infoWriter) {
+	names := make([]string, 0, len(a.cmdStats))
+	for name := range a.cmdStats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		st := a.cmdStats[name]
+		calls, usec := st.calls.Load(), st.usec.Load()
+		rejected, failed := st.rejected.Load(), st.failed.Load()
+		if calls == 0 && rejected == 0 {
+			continue
+		}
+		var perCall float64
+		if calls > 0 {
+			perCall = float64(usec) / float64(calls)
+		}
+		w.field("cmdstat_"+strings.ToLower(name), fmt.Sprintf("calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=%d,failed_calls=%d",
+			calls, usec, perCall, rejected, failed))
+	}
+}
+
+func (a *App) infoErrorStats(w *infoWriter) {
+	counts := a.stats.errorCounts()
+	codes := make([]string, 0, len(counts))
+	for code := range counts {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		w.field("errorstat_"+code, fmt.Sprintf("count=%d", counts[code]))
+	}
+}
+
+func (a *App) infoCluster(w *infoWriter) {
+	w.field("cluster_enabled", boolInt(a.cluster != nil))
+}
+
+func (a *App) infoKeyspace(w *infoWriter) {
+	for _, st := range a.storage.KeyspaceStats() {
+		w.field(fmt.Sprintf("db%d", st.DB), fmt.Sprintf("keys=%d,expires=%d,avg_ttl=%d",
+			st.Keys, st.Expires, st.AvgTTL.Milliseconds()))
+	}
+}
+
+// humanBytes 按照 Redis 的格式输出内存大小，如 1.50M
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	f := float64(n)
+	for _, suffix := range []string{"K", "M", "G", "T"} {
+		f /= unit
+		if f < unit || suffix == "T" {
+			return fmt.Sprintf("%.2f%s", f, suffix)
+		}
+	}
+	return ""
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// newRunID 生成 40 位十六进制的随机 ID，每次启动不同
+func newRunID() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
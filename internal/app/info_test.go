@@ -0,0 +1,83 @@
+package app
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestInfoSections(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	def := do(a, conn, "INFO")
+	for _, section := range []string{"# Server", "# Clients", "# Memory", "# Persistence", "# Stats",
+		"# Replication", "# CPU", "# Errorstats", "# Cluster", "# Keyspace"} {
+		if !strings.Contains(def, section+"\r\n") {
+			t.Errorf("default INFO missing %s", section)
+		}
+	}
+	if strings.Contains(def, "# Commandstats") {
+		t.Error("commandstats must not be in the default sections")
+	}
+
+	got := do(a, conn, "INFO", "clients", "CPU")
+	if !regexp.MustCompile(`^\$\d+\r\n# Clients\r\nconnected_clients:1\r\nmaxclients:10000\r\nblocked_clients:0\r\n\r\n# CPU\r\nused_cpu_sys:[\d.]+\r\nused_cpu_user:[\d.]+\r\n\r\n$`).MatchString(got) {
+		t.Errorf("INFO clients cpu = %q", got)
+	}
+	if got := do(a, conn, "INFO", "nosuch"); got != "$0\r\n\r\n" {
+		t.Errorf("INFO nosuch = %q", got)
+	}
+}
+
+func TestInfoCounters(t *testing.T) {
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			conn := newFakeConn()
+			a.handleConnect(conn)
+
+			do(a, conn, "SET", "a", "1")
+			do(a, conn, "SET", "b", "2")
+			do(a, conn, "EXPIRE", "b", "100")
+			do(a, conn, "GET", "a")
+			do(a, conn, "GET", "missing")
+			do(a, conn, "GET")
+			do(a, conn, "NOSUCHCMD")
+
+			info := do(a, conn, "INFO", "everything")
+			for _, want := range []string{
+				"total_commands_processed:5\r\n",
+				"keyspace_hits:1\r\n",
+				"keyspace_misses:1\r\n",
+				"total_error_replies:2\r\n",
+				"cmdstat_set:calls=2,",
+				"cmdstat_get:calls=2,",
+				"rejected_calls=1,failed_calls=0\r\n",
+				"errorstat_ERR:count=2\r\n",
+				"db0:keys=2,expires=1,avg_ttl=",
+				"rdb_changes_since_last_save:",
+			} {
+				if !strings.Contains(info, want) {
+					t.Errorf("INFO missing %q", want)
+				}
+			}
+
+			do(a, conn, "CONFIG", "RESETSTAT")
+			info = do(a, conn, "INFO", "stats", "commandstats", "errorstats")
+			if !strings.Contains(info, "total_commands_processed:1\r\n") || strings.Contains(info, "cmdstat_set") || strings.Contains(info, "errorstat_") {
+				t.Errorf("RESETSTAT did not clear stats: %q", info)
+			}
+		})
+	}
+}
+
+func TestHumanBytes(t *testing.T) {
+	tests := map[int64]string{0: "0B", 1023: "1023B", 1024: "1.00K", 1536 * 1024: "1.50M", 3 << 30: "3.00G"}
+	for n, want := range tests {
+		if got := humanBytes(n); got != want {
+			t.Errorf("humanBytes(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"literedis/pkg/protocol"
+)
+
+func (a *App) handleLatency(c *client, args []string) (*protocol.Message, error) {
+	name := args[0]
+	sub := strings.ToLower(name)
+	args = args[1:]
+	wrongArgs := fmt.Errorf("wrong number of arguments for 'latency|%s' command", sub)
+
+	switch sub {
+	case "latest":
+		if len(args) != 0 {
+			return nil, wrongArgs
+		}
+		latest := a.latency.Latest()
+		items := make([]*protocol.Message, len(latest))
+		for i, l := range latest {
+			items[i] = array(
+				bulk(l.Event),
+				integer(l.Time.Unix()),
+				integer(l.Latency.Milliseconds()),
+				integer(l.Max.Milliseconds()),
+			)
+		}
+		return array(items...), nil
+	case "history":
+		if len(args) != 1 {
+			return nil, wrongArgs
+		}
+		history := a.latency.History(args[0])
+		items := make([]*protocol.Message, len(history))
+		for i, s := range history {
+			items[i] = array(integer(s.Time.Unix()), integer(s.Latency.Milliseconds()))
+		}
+		return array(items...), nil
+	case "reset":
+		return integer(int64(a.latency.Reset(args...))), nil
+	case "doctor":
+		if len(args) != 0 {
+			return nil, wrongArgs
+		}
+		return bulk(a.latency.Doctor()), nil
+	case "histogram":
+		return a.latencyHistogram(args), nil
+	}
+	return nil, fmt.Errorf("unknown subcommand '%s'. Try LATENCY HELP.", name)
+}
+
+// latencyHistogram 返回命令耗时的累计分布，没有指定命令时返回所有执行过的命令
+func (a *App) latencyHistogram(names []string) *protocol.Message {
+	if len(names) == 0 {
+		for name := range a.cmdStats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	var items []*protocol.Message
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToUpper(name)
+		st, ok := a.cmdStats[name]
+		if !ok || seen[name] || st.calls.Load() == 0 {
+			continue
+		}
+		seen[name] = true
+		bounds, counts := st.histogram()
+		hist := make([]*protocol.Message, 0, 2*len(bounds))
+		for i := range bounds {
+			hist = append(hist, integer(bounds[i]), integer(counts[i]))
+		}
+		items = append(items, bulk(strings.ToLower(name)), array(
+			bulk("calls"), integer(st.calls.Load()),
+			bulk("histogram_usec"), array(hist...),
+		))
+	}
+	return array(items...)
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"literedis/internal/latency"
+)
+
+func TestLatency(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	if got := do(a, conn, "LATENCY", "LATEST"); got != "*0\r\n" {
+		t.Fatalf("LATENCY LATEST = %q", got)
+	}
+	if got := do(a, conn, "CONFIG", "SET", "latency-monitor-threshold", "5"); got != "+OK\r\n" {
+		t.Fatalf("CONFIG SET = %q", got)
+	}
+	a.latency.Add(latency.EventCommand, 12*time.Millisecond)
+	a.latency.Add(latency.EventExpireCycle, 4*time.Millisecond)
+
+	got := do(a, conn, "LATENCY", "LATEST")
+	if !regexp.MustCompile(`^\*1\r\n\*4\r\n\$7\r\ncommand\r\n:\d+\r\n:12\r\n:12\r\n$`).MatchString(got) {
+		t.Errorf("LATENCY LATEST = %q", got)
+	}
+	got = do(a, conn, "LATENCY", "HISTORY", "command")
+	if !regexp.MustCompile(`^\*1\r\n\*2\r\n:\d+\r\n:12\r\n$`).MatchString(got) {
+		t.Errorf("LATENCY HISTORY = %q", got)
+	}
+	if got := do(a, conn, "LATENCY", "HISTORY", "fork"); got != "*0\r\n" {
+		t.Errorf("LATENCY HISTORY fork = %q", got)
+	}
+	if got := do(a, conn, "LATENCY", "DOCTOR"); !strings.Contains(got, "1. command: 1 latency spikes") {
+		t.Errorf("LATENCY DOCTOR = %q", got)
+	}
+	if got := do(a, conn, "LATENCY", "RESET"); got != ":1\r\n" {
+		t.Errorf("LATENCY RESET = %q", got)
+	}
+	if got := do(a, conn, "LATENCY", "NOPE"); !strings.HasPrefix(got, "-ERR unknown subcommand 'NOPE'") {
+		t.Errorf("LATENCY NOPE = %q", got)
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	do(a, conn, "SET", "k", "v")
+	do(a, conn, "SET", "k", "v")
+	do(a, conn, "GET", "k")
+
+	got := do(a, conn, "LATENCY", "HISTOGRAM", "set", "SET", "nosuch", "ttl")
+	if !regexp.MustCompile(`^\*2\r\n\$3\r\nset\r\n\*4\r\n\$5\r\ncalls\r\n:2\r\n\$14\r\nhistogram_usec\r\n\*\d+\r\n(:\d+\r\n)+$`).MatchString(got) {
+		t.Errorf("LATENCY HISTOGRAM set = %q", got)
+	}
+	got = do(a, conn, "LATENCY", "HISTOGRAM")
+	if !strings.Contains(got, "$3\r\nget\r\n") || !strings.Contains(got, "$3\r\nset\r\n") || strings.Contains(got, "ttl") {
+		t.Errorf("LATENCY HISTOGRAM = %q", got)
+	}
+}
+
+func TestCommandHistogram(t *testing.T) {
+	var s commandStats
+	for _, d := range []time.Duration{0, time.Microsecond, 3 * time.Microsecond, 4 * time.Microsecond, 100 * time.Microsecond} {
+		s.record(d, nil)
+	}
+	bounds, counts := s.histogram()
+	wantBounds := []int64{1, 2, 4, 8, 16, 32, 64, 128}
+	wantCounts := []int64{2, 2, 4, 4, 4, 4, 4, 5}
+	if len(bounds) != len(wantBounds) {
+		t.Fatalf("bounds = %v counts = %v", bounds, counts)
+	}
+	for i := range bounds {
+		if bounds[i] != wantBounds[i] || counts[i] != wantCounts[i] {
+			t.Fatalf("bounds = %v counts = %v", bounds, counts)
+		}
+	}
+	s.reset()
+	if bounds, _ := s.histogram(); bounds != nil {
+		t.Errorf("histogram after reset = %v", bounds)
+	}
+}
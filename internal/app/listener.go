@@ -0,0 +1,247 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"literedis/config"
+	"literedis/pkg/log"
+	"literedis/pkg/network"
+	"literedis/pkg/network/tcp"
+)
+
+// Start 启动 HTTP 服务并加载数据，然后在每个 bind 地址上启动明文端口和 TLS 端口，
+// 并启动 unix socket 监听
+func (a *App) Start() error {
+	if err := a.startHTTP(); err != nil {
+		a.Stop()
+		return err
+	}
+	a.loadData()
+	if err := a.startListeners(); err != nil {
+		a.Stop()
+		return err
+	}
+	if len(a.servers)+len(a.bound) == 0 {
+		return errors.New("no listener configured, set port, tls_port or unix_socket")
+	}
+	return nil
+}
+
+func (a *App) startListeners() error {
+	if a.opts.server != nil {
+		if err := a.listen(a.opts.server); err != nil {
+			return err
+		}
+		a.servers = append(a.servers, a.opts.server)
+	}
+
+	if config.Conf.TLSPort != 0 {
+		if err := a.initTLS(); err != nil {
+			return err
+		}
+	}
+
+	a.listenMu.Lock()
+	bound, err := a.bindListeners(a.bind, a.port)
+	a.bound = bound
+	a.started = true
+	a.listenMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if config.Conf.UnixSocket != "" {
+		perm, err := parseSocketPerm(config.Conf.UnixSocketPerm)
+		if err != nil {
+			return err
+		}
+		srv := tcp.NewUnixServer(config.Conf.UnixSocket, perm)
+		if err := a.listen(srv); err != nil {
+			return err
+		}
+		a.servers = append(a.servers, srv)
+	}
+	return nil
+}
+
+// bindAddr bind 配置中的一个地址
+type bindAddr struct {
+	host     string
+	optional bool // "-" 前缀，地址不可用时跳过而不是报错
+}
+
+// parseBind 解析空格分隔的 bind 配置，"*" 表示所有 IPv4 地址，"::*" 表示所有 IPv6 地址，
+// 空字符串表示所有地址
+func parseBind(bind string) []bindAddr {
+	fields := strings.Fields(bind)
+	if len(fields) == 0 {
+		return []bindAddr{{}}
+	}
+	addrs := make([]bindAddr, 0, len(fields))
+	for _, f := range fields {
+		var b bindAddr
+		if strings.HasPrefix(f, "-") {
+			b.optional = true
+			f = f[1:]
+		}
+		switch f {
+		case "*":
+			f = "0.0.0.0"
+		case "::*":
+			f = "::"
+		}
+		b.host = f
+		addrs = append(addrs, b)
+	}
+	return addrs
+}
+
+// bindListeners 在每个 bind 地址上监听明文端口和 TLS 端口，必需的地址监听失败时
+// 关闭本次已经启动的监听并返回错误
+func (a *App) bindListeners(bind string, port int) ([]network.Server, error) {
+	var servers []network.Server
+	for _, b := range parseBind(bind) {
+		if port != 0 && a.opts.server == nil {
+			srv := tcp.NewServer(net.JoinHostPort(b.host, strconv.Itoa(port)), tcp.WithKeepAlive(a.keepAlivePeriod))
+			started, err := a.bindListener(srv, b)
+			if err != nil {
+				closeListeners(servers)
+				return nil, err
+			}
+			if started {
+				servers = append(servers, srv)
+			}
+		}
+		if config.Conf.TLSPort != 0 && a.tls != nil {
+			srv := tcp.NewServer(net.JoinHostPort(b.host, strconv.Itoa(config.Conf.TLSPort)),
+				tcp.WithTLS(a.tls.Config()), tcp.WithKeepAlive(a.keepAlivePeriod))
+			started, err := a.bindListener(srv, b)
+			if err != nil {
+				closeListeners(servers)
+				return nil, err
+			}
+			if started {
+				servers = append(servers, srv)
+			}
+		}
+	}
+	return servers, nil
+}
+
+// bindListener 启动监听，可选地址监听失败时返回 false 而不是错误
+func (a *App) bindListener(srv network.Server, b bindAddr) (bool, error) {
+	err := a.listen(srv)
+	if err == nil {
+		return true, nil
+	}
+	if b.optional {
+		log.Warnf("skip optional bind address %q: %v", b.host, err)
+		return false, nil
+	}
+	return false, err
+}
+
+// closeListeners 关闭监听，已建立的连接不受影响
+func closeListeners(servers []network.Server) {
+	for _, srv := range servers {
+		if lc, ok := srv.(interface{ CloseListener() error }); ok {
+			lc.CloseListener()
+		} else {
+			srv.Stop()
+		}
+	}
+}
+
+func (a *App) setBind(bind string) error {
+	a.listenMu.Lock()
+	defer a.listenMu.Unlock()
+	return a.rebind(bind, a.port)
+}
+
+func (a *App) setPort(port int) error {
+	a.listenMu.Lock()
+	defer a.listenMu.Unlock()
+	return a.rebind(a.bind, port)
+}
+
+// rebind 切换到新的 bind 地址和端口，已建立的连接保持不变，失败时恢复原来的监听。
+// 调用方需要持有 listenMu
+func (a *App) rebind(bind string, port int) error {
+	if !a.started {
+		a.bind, a.port = bind, port
+		return nil
+	}
+
+	// 先关闭旧的监听，新旧地址相同时才能重新监听
+	closeListeners(a.bound)
+	bound, err := a.bindListeners(bind, port)
+	if err != nil {
+		old, rerr := a.bindListeners(a.bind, a.port)
+		if rerr != nil {
+			log.Errorf("restore listeners on %q port %d failed: %v", a.bind, a.port, rerr)
+		}
+		a.bound = old
+		return err
+	}
+	a.bound = bound
+	a.bind, a.port = bind, port
+	return nil
+}
+
+func (a *App) keepAlivePeriod() time.Duration {
+	return time.Duration(a.tcpKeepAlive.Load()) * time.Second
+}
+
+// parseSocketPerm 解析八进制的 unixsocketperm，空字符串表示使用默认权限
+func parseSocketPerm(s string) (os.FileMode, error) {
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+	perm, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || perm > 0777 {
+		return 0, fmt.Errorf("invalid unix_socket_perm: %q", s)
+	}
+	return os.FileMode(perm), nil
+}
+
+func (a *App) listen(srv network.Server) error {
+	srv.OnConnect(a.handleConnect)
+	srv.OnDisconnect(a.handleDisconnect)
+	srv.OnReceive(a.handleReceive)
+	return srv.Start()
+}
+
+func (a *App) initTLS() error {
+	clientAuth, err := tcp.ParseClientAuth(config.Conf.TLSAuthClients)
+	if err != nil {
+		return err
+	}
+	reloader, err := tcp.NewTLSReloader(tcp.TLSFiles{
+		CertFile:   config.Conf.TLSCertFile,
+		KeyFile:    config.Conf.TLSKeyFile,
+		CAFile:     config.Conf.TLSCACertFile,
+		ClientAuth: clientAuth,
+	})
+	if err != nil {
+		return err
+	}
+	if err := reloader.Watch(); err != nil {
+		log.Warnf("watch tls certificates failed, only SIGHUP will reload them: %v", err)
+	}
+	a.tls = reloader
+	return nil
+}
+
+// ReloadTLS 重新加载 TLS 证书，已建立的连接不受影响
+func (a *App) ReloadTLS() error {
+	if a.tls == nil {
+		return nil
+	}
+	return a.tls.Reload()
+}
@@ -0,0 +1,177 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	pkgclient "literedis/pkg/client"
+)
+
+// setConfig 临时修改配置项，测试结束后恢复
+func setConfig(t *testing.T, key string, value interface{}) {
+	t.Helper()
+	old := viper.Get(key)
+	viper.Set(key, value)
+	t.Cleanup(func() { viper.Set(key, old) })
+}
+
+func TestUnixSocketListener(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "literedis.sock")
+	setConfig(t, "port", 0)
+	setConfig(t, "unix_socket", path)
+	setConfig(t, "unix_socket_perm", "700")
+
+	a := newTestApp(t, ExecModeThreaded)
+	if err := a.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Stop()
+	if len(a.servers) != 1 || a.servers[0].Protocol() != "unix" {
+		t.Fatalf("expected a single unix listener, got %d", len(a.servers))
+	}
+
+	c, err := pkgclient.NewClient("unix://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Set("k", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.Get("k"); err != nil || v != "v" {
+		t.Fatalf("GET = %q, %v", v, err)
+	}
+}
+
+func TestNoListenerConfigured(t *testing.T) {
+	setConfig(t, "port", 0)
+	a := newTestApp(t, ExecModeThreaded)
+	if err := a.Start(); err == nil {
+		a.Stop()
+		t.Fatal("expected an error when no listener is configured")
+	}
+}
+
+func TestParseSocketPerm(t *testing.T) {
+	if perm, err := parseSocketPerm("755"); err != nil || perm != 0755 {
+		t.Errorf("parseSocketPerm(755) = %o, %v", perm, err)
+	}
+	if perm, err := parseSocketPerm(""); err != nil || perm != 0 {
+		t.Errorf("parseSocketPerm('') = %o, %v", perm, err)
+	}
+	for _, bad := range []string{"999", "abc", "7777"} {
+		if _, err := parseSocketPerm(bad); err == nil {
+			t.Errorf("parseSocketPerm(%q) should fail", bad)
+		}
+	}
+}
+
+func TestBindAndRebind(t *testing.T) {
+	setConfig(t, "bind", "127.0.0.1 -192.0.2.1")
+	setConfig(t, "port", 8895)
+
+	a := newTestApp(t, ExecModeThreaded)
+	if err := a.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Stop()
+	if len(a.bound) != 1 {
+		t.Fatalf("optional unavailable address should be skipped, got %d listeners", len(a.bound))
+	}
+
+	c, err := pkgclient.NewClient("127.0.0.1:8895")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if reply, err := c.Do("CONFIG", "SET", "port", "8894"); err != nil || reply != "OK" {
+		t.Fatalf("CONFIG SET port = %v, %v", reply, err)
+	}
+
+	// 已建立的连接不受重新绑定影响
+	if err := c.Set("k", "v", 0); err != nil {
+		t.Fatalf("existing connection closed by rebind: %v", err)
+	}
+	if _, err := pkgclient.NewClient("127.0.0.1:8895", pkgclient.WithDialTimeout(time.Second)); err == nil {
+		t.Error("old port is still listening")
+	}
+	c2, err := pkgclient.NewClient("127.0.0.1:8894")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	if v, err := c2.Get("k"); err != nil || v != "v" {
+		t.Fatalf("GET = %q, %v", v, err)
+	}
+
+	// 监听失败时保留原来的地址
+	if _, err := c2.Do("CONFIG", "SET", "bind", "192.0.2.1"); err == nil {
+		t.Fatal("expected bind failure")
+	}
+	if reply, err := c2.Do("CONFIG", "GET", "bind"); err != nil || fmt.Sprint(reply) != "[bind 127.0.0.1 -192.0.2.1]" {
+		t.Errorf("CONFIG GET bind = %v, %v", reply, err)
+	}
+	c3, err := pkgclient.NewClient("127.0.0.1:8894")
+	if err != nil {
+		t.Fatalf("listener not restored: %v", err)
+	}
+	c3.Close()
+}
+
+func TestMaxClients(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	first := newFakeConn()
+	a.handleConnect(first)
+	if got := do(a, first, "CONFIG", "SET", "maxclients", "1"); got != "+OK\r\n" {
+		t.Fatalf("CONFIG SET maxclients = %q", got)
+	}
+
+	second := newFakeConn()
+	a.handleConnect(second)
+	if got := second.last(); got != "-ERR max number of clients reached\r\n" || !second.closed.Load() {
+		t.Fatalf("second client = %q, closed=%v", got, second.closed.Load())
+	}
+
+	a.handleDisconnect(first, nil)
+	third := newFakeConn()
+	a.handleConnect(third)
+	if third.closed.Load() {
+		t.Error("client rejected after a slot was freed")
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	a := newTestApp(t, ExecModeEventLoop)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	a.closeIdleClients(time.Now().Add(time.Hour))
+	if conn.closed.Load() {
+		t.Fatal("timeout 0 must not close idle clients")
+	}
+	if got := do(a, conn, "CONFIG", "SET", "timeout", "10"); got != "+OK\r\n" {
+		t.Fatalf("CONFIG SET timeout = %q", got)
+	}
+	a.closeIdleClients(time.Now().Add(5 * time.Second))
+	if conn.closed.Load() {
+		t.Fatal("client closed before timeout")
+	}
+	a.closeIdleClients(time.Now().Add(11 * time.Second))
+	if !conn.closed.Load() {
+		t.Fatal("idle client not closed")
+	}
+}
+
+func TestParseBind(t *testing.T) {
+	got := parseBind("* -::* 127.0.0.1")
+	want := []bindAddr{{"0.0.0.0", false}, {"::", true}, {"127.0.0.1", false}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("parseBind = %v, want %v", got, want)
+	}
+	if got := parseBind(""); len(got) != 1 || got[0].host != "" {
+		t.Errorf("parseBind('') = %v", got)
+	}
+}
@@ -0,0 +1,218 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"literedis/config"
+	"literedis/pkg/log"
+)
+
+// metricsPrefix 所有指标名称的前缀
+const metricsPrefix = "literedis_"
+
+// metricsHistogramStep 导出命令耗时直方图时每隔几个桶取一个上界，即 1us、4us、16us……
+const metricsHistogramStep = 2
+
+// metricsHistogramBuckets 导出的最大桶上界为 2^24 微秒（约 16 秒），更慢的调用只计入 +Inf
+const metricsHistogramBuckets = 25
+
+// startHTTP 启动 /metrics、/healthz 和 /readyz，http.addr 为空时不启动
+func (a *App) startHTTP() error {
+	addr := config.Conf.HTTP.Addr
+	if addr == "" {
+		return nil
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	a.httpServer = &http.Server{Handler: a.httpHandler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := a.httpServer.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("http server on %s stopped: %v", addr, err)
+		}
+	}()
+	log.Infof("serving metrics on %s", ln.Addr())
+	return nil
+}
+
+func (a *App) httpHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", a.handleMetrics)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	// 数据加载完成之前返回 503，负载均衡不应把流量转发过来
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !a.ready.Load() {
+			http.Error(w, "loading", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ready")
+	})
+	return mux
+}
+
+// metricsWriter 输出 Prometheus 文本格式
+type metricsWriter struct {
+	b strings.Builder
+}
+
+// family 写入指标的 HELP 和 TYPE
+func (w *metricsWriter) family(name, typ, help string) {
+	fmt.Fprintf(&w.b, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricsPrefix, name, help, metricsPrefix, name, typ)
+}
+
+// sample 写入一个样本，labels 为成对的标签名和标签值
+func (w *metricsWriter) sample(name string, value interface{}, labels ...string) {
+	w.b.WriteString(metricsPrefix + name)
+	if len(labels) > 0 {
+		w.b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.b.WriteByte(',')
+			}
+			fmt.Fprintf(&w.b, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		w.b.WriteByte('}')
+	}
+	switch v := value.(type) {
+	case float64:
+		fmt.Fprintf(&w.b, " %s\n", strconv.FormatFloat(v, 'g', -1, 64))
+	default:
+		fmt.Fprintf(&w.b, " %v\n", v)
+	}
+}
+
+func (w *metricsWriter) gauge(name, help string, value interface{}) {
+	w.family(name, "gauge", help)
+	w.sample(name, value)
+}
+
+func (w *metricsWriter) counter(name, help string, value interface{}) {
+	w.family(name, "counter", help)
+	w.sample(name, value)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func (a *App) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	mw := &metricsWriter{}
+	a.writeMetrics(mw)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(mw.b.String()))
+}
+
+func (a *App) writeMetrics(w *metricsWriter) {
+	w.gauge("uptime_seconds", "Seconds since the server started.", time.Since(a.startTime).Seconds())
+	w.gauge("ready", "Whether the dataset has been loaded and the server accepts traffic.", boolInt(a.ready.Load()))
+
+	// clients
+	w.gauge("connected_clients", "Number of client connections.", a.numClients.Load())
+	w.gauge("max_clients", "Maximum number of client connections.", a.maxClients.Load())
+	w.counter("connections_received_total", "Total number of connections accepted.", a.stats.connectionsReceived.Load())
+	w.counter("rejected_connections_total", "Connections rejected because of maxclients.", a.stats.rejectedConnections.Load())
+
+	// memory
+	used := a.memory.load()
+	w.gauge("memory_used_bytes", "Heap memory used by live objects.", used)
+	w.gauge("memory_peak_bytes", "Peak heap memory used by live objects.", max(a.stats.peakMemory.Load(), used))
+	w.gauge("memory_max_bytes", "Value of maxmemory, 0 means unlimited.", a.maxMemory.Load())
+
+	// stats
+	w.counter("commands_processed_total", "Total number of commands processed.", a.stats.commandsProcessed.Load())
+	w.counter("net_input_bytes_total", "Total bytes read from clients.", a.stats.netInputBytes.Load())
+	w.counter("net_output_bytes_total", "Total bytes written to clients.", a.stats.netOutputBytes.Load())
+	w.counter("keyspace_hits_total", "Successful key lookups.", a.stats.keyspaceHits.Load())
+	w.counter("keyspace_misses_total", "Failed key lookups.", a.stats.keyspaceMisses.Load())
+	w.counter("expired_keys_total", "Keys deleted because their TTL expired.", a.storage.ExpiredKeys())
+	w.counter("evicted_keys_total", "Keys evicted because of maxmemory.", 0)
+	w.counter("error_replies_total", "Total number of error replies.", a.stats.errorReplies.Load())
+
+	a.writeCommandMetrics(w)
+
+	// keyspace
+	keyspace := a.storage.KeyspaceStats()
+	w.family("keyspace_keys", "gauge", "Number of keys per database.")
+	for _, st := range keyspace {
+		w.sample("keyspace_keys", st.Keys, "db", "db"+strconv.Itoa(st.DB))
+	}
+	w.family("keyspace_expiring_keys", "gauge", "Number of keys with a TTL per database.")
+	for _, st := range keyspace {
+		w.sample("keyspace_expiring_keys", st.Expires, "db", "db"+strconv.Itoa(st.DB))
+	}
+	w.family("keyspace_avg_ttl_seconds", "gauge", "Average TTL of keys with an expire per database.")
+	for _, st := range keyspace {
+		w.sample("keyspace_avg_ttl_seconds", st.AvgTTL.Seconds(), "db", "db"+strconv.Itoa(st.DB))
+	}
+
+	// persistence
+	rdb := a.storage.GetRDBStats()
+	w.gauge("rdb_changes_since_last_save", "Number of changes since the last save.", rdb.ChangesSinceLastSave)
+	w.gauge("rdb_save_in_progress", "Whether a background save is running.", boolInt(rdb.SaveInProgress))
+	w.counter("rdb_saves_total", "Number of successful saves.", rdb.TotalSaves)
+	w.gauge("rdb_last_save_ok", "Whether the last save succeeded.", boolInt(rdb.LastSaveStatus == "ok"))
+	var lastSave float64
+	if !rdb.LastSaveTime.IsZero() {
+		lastSave = float64(rdb.LastSaveTime.Unix())
+	}
+	w.gauge("rdb_last_save_timestamp_seconds", "Unix time of the last successful save.", lastSave)
+	w.gauge("rdb_last_save_duration_seconds", "Duration of the last successful save.", rdb.LastSaveDuration.Seconds())
+	w.gauge("rdb_last_save_size_bytes", "Size of the file written by the last save.", rdb.LastSaveSize)
+
+	// replication
+	w.gauge("connected_slaves", "Number of connected replicas.", 0)
+	w.gauge("master_repl_offset", "Replication offset of this instance.", 0)
+}
+
+// writeCommandMetrics 按命令输出调用次数、耗时和耗时直方图，只包含执行过的命令
+func (a *App) writeCommandMetrics(w *metricsWriter) {
+	names := make([]string, 0, len(a.cmdStats))
+	for name, st := range a.cmdStats {
+		if st.calls.Load() > 0 || st.rejected.Load() > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	w.family("commands_total", "counter", "Number of calls per command.")
+	for _, name := range names {
+		w.sample("commands_total", a.cmdStats[name].calls.Load(), "cmd", strings.ToLower(name))
+	}
+	w.family("commands_rejected_total", "counter", "Calls rejected before execution per command.")
+	for _, name := range names {
+		w.sample("commands_rejected_total", a.cmdStats[name].rejected.Load(), "cmd", strings.ToLower(name))
+	}
+	w.family("commands_failed_total", "counter", "Calls that returned an error per command.")
+	for _, name := range names {
+		w.sample("commands_failed_total", a.cmdStats[name].failed.Load(), "cmd", strings.ToLower(name))
+	}
+
+	w.family("command_duration_seconds", "histogram", "Command execution time.")
+	for _, name := range names {
+		st := a.cmdStats[name]
+		cmd := strings.ToLower(name)
+		var cumulative int64
+		for i := range st.hist {
+			cumulative += st.hist[i].Load()
+			if i < metricsHistogramBuckets && i%metricsHistogramStep == 0 {
+				le := strconv.FormatFloat(float64(int64(1)<<i)/1e6, 'g', -1, 64)
+				w.sample("command_duration_seconds_bucket", cumulative, "cmd", cmd, "le", le)
+			}
+		}
+		w.sample("command_duration_seconds_bucket", cumulative, "cmd", cmd, "le", "+Inf")
+		w.sample("command_duration_seconds_sum", float64(st.usec.Load())/1e6, "cmd", cmd)
+		w.sample("command_duration_seconds_count", cumulative, "cmd", cmd)
+	}
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, h http.Handler, path string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestHealthAndReadiness(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	h := a.httpHandler()
+
+	if code, body := get(t, h, "/healthz"); code != http.StatusOK || body != "ok\n" {
+		t.Errorf("/healthz = %d %q", code, body)
+	}
+	if code, _ := get(t, h, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz before load = %d", code)
+	}
+	a.loadData()
+	if code, body := get(t, h, "/readyz"); code != http.StatusOK || body != "ready\n" {
+		t.Errorf("/readyz after load = %d %q", code, body)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+	do(a, conn, "SET", "a", "1")
+	do(a, conn, "SET", "b", "2")
+	do(a, conn, "EXPIRE", "b", "100")
+	do(a, conn, "GET", "a")
+	do(a, conn, "GET")
+	do(a, conn, "SELECT", "3")
+	do(a, conn, "SET", "c", "3")
+
+	rec := httptest.NewRecorder()
+	a.httpHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE literedis_connected_clients gauge\nliteredis_connected_clients 1\n",
+		"literedis_commands_processed_total 6\n",
+		"# TYPE literedis_commands_total counter\n",
+		`literedis_commands_total{cmd="set"} 3` + "\n",
+		`literedis_commands_rejected_total{cmd="get"} 1` + "\n",
+		"# TYPE literedis_command_duration_seconds histogram\n",
+		`literedis_command_duration_seconds_bucket{cmd="set",le="1e-06"} `,
+		`literedis_command_duration_seconds_bucket{cmd="set",le="16.777216"} 3` + "\n",
+		`literedis_command_duration_seconds_bucket{cmd="set",le="+Inf"} 3` + "\n",
+		`literedis_command_duration_seconds_count{cmd="set"} 3` + "\n",
+		`literedis_keyspace_keys{db="db0"} 2` + "\n",
+		`literedis_keyspace_keys{db="db3"} 1` + "\n",
+		`literedis_keyspace_expiring_keys{db="db0"} 1` + "\n",
+		"literedis_keyspace_hits_total 1\n",
+		"literedis_expired_keys_total 0\n",
+		"literedis_evicted_keys_total 0\n",
+		"# TYPE literedis_memory_used_bytes gauge\n",
+		"literedis_rdb_changes_since_last_save ",
+		"literedis_rdb_last_save_ok 1\n",
+		"literedis_master_repl_offset 0\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+
+	// 每个样本都属于前面声明过的指标
+	declared := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		if name, ok := strings.CutPrefix(line, "# TYPE "); ok {
+			declared[strings.Fields(name)[0]] = true
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name := strings.FieldsFunc(line, func(r rune) bool { return r == '{' || r == ' ' })[0]
+		base := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(name, "_bucket"), "_sum"), "_count")
+		if !declared[name] && !declared[base] {
+			t.Errorf("sample %q has no TYPE", line)
+		}
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got := escapeLabel("a\\b\"c\nd"); got != `a\\b\"c\nd` {
+		t.Errorf("escapeLabel = %s", got)
+	}
+}
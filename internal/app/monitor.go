@@ -0,0 +1,139 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"literedis/internal/commands"
+	"literedis/pkg/log"
+	"literedis/pkg/protocol"
+)
+
+// monitorBufferSize 每个 MONITOR 连接最多缓冲的行数，写满说明客户端读取太慢，直接断开
+const monitorBufferSize = 1024
+
+// monitor 一个执行了 MONITOR 的连接，由单独的协程把缓冲的行写到连接上，
+// 避免慢客户端阻塞命令执行
+type monitor struct {
+	c    *client
+	ch   chan []byte
+	done chan struct{}
+}
+
+// monitors 所有 MONITOR 连接
+type monitors struct {
+	mu   sync.RWMutex
+	byID map[int64]*monitor
+}
+
+func (a *App) handleMonitor(c *client, args []string) (*protocol.Message, error) {
+	c.monitorAfterReply = true
+	return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+}
+
+// addMonitor 在 +OK 发送之后调用，保证 OK 是连接收到的第一行
+func (a *App) addMonitor(c *client) {
+	a.monitors.mu.Lock()
+	defer a.monitors.mu.Unlock()
+	if _, ok := a.monitors.byID[c.conn.Cid()]; ok {
+		return
+	}
+	m := &monitor{c: c, ch: make(chan []byte, monitorBufferSize), done: make(chan struct{})}
+	a.monitors.byID[c.conn.Cid()] = m
+	go a.writeMonitor(m)
+}
+
+func (a *App) removeMonitor(cid int64) {
+	a.monitors.mu.Lock()
+	defer a.monitors.mu.Unlock()
+	if m, ok := a.monitors.byID[cid]; ok {
+		delete(a.monitors.byID, cid)
+		close(m.done)
+	}
+}
+
+func (a *App) writeMonitor(m *monitor) {
+	for {
+		select {
+		case <-m.done:
+			return
+		case line := <-m.ch:
+			if err := m.c.conn.Send(line); err != nil {
+				return
+			}
+			a.stats.netOutputBytes.Add(int64(len(line)))
+		}
+	}
+}
+
+// feedMonitors 把即将执行的命令发送给所有 MONITOR 连接。管理命令不输出，
+// 密码等敏感参数会被隐藏
+func (a *App) feedMonitors(c *client, name string, cmd *command) {
+	if cmd.spec.Flags&commands.FlagAdmin != 0 {
+		return
+	}
+	a.monitors.mu.RLock()
+	if len(a.monitors.byID) == 0 {
+		a.monitors.mu.RUnlock()
+		return
+	}
+
+	now := time.Now()
+	var b strings.Builder
+	fmt.Fprintf(&b, "+%d.%06d [%d %s]", now.Unix(), now.Nanosecond()/1000, c.storage.SelectedDB(), c.conn.RemoteAddr())
+	b.WriteString(" " + quoteArg(name))
+	for _, arg := range redactArgs(cmd.name, cmd.args) {
+		b.WriteString(" " + quoteArg(arg))
+	}
+	b.WriteString("\r\n")
+	line := []byte(b.String())
+
+	var slow []*monitor
+	for _, m := range a.monitors.byID {
+		select {
+		case m.ch <- line:
+		default:
+			slow = append(slow, m)
+		}
+	}
+	a.monitors.mu.RUnlock()
+
+	// 关闭连接会触发 handleDisconnect 移除 monitor，需要在释放锁之后进行
+	for _, m := range slow {
+		log.Warnf("closing slow monitor client: %s", m.c.info())
+		m.c.conn.Close()
+	}
+}
+
+// quoteArg 按照 Redis 的格式给参数加上双引号，转义特殊字符和不可打印字符
+func quoteArg(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; ch {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(ch)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\a':
+			b.WriteString(`\a`)
+		case '\b':
+			b.WriteString(`\b`)
+		default:
+			if ch < 0x20 || ch >= 0x7f {
+				fmt.Fprintf(&b, `\x%02x`, ch)
+			} else {
+				b.WriteByte(ch)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
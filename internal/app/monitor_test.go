@@ -0,0 +1,125 @@
+package app
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitOutput 等待连接收到至少 n 次写入，返回所有写入的内容
+func waitOutput(t *testing.T, conn *fakeConn, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		conn.mu.Lock()
+		out := make([]string, len(conn.out))
+		for i, b := range conn.out {
+			out[i] = string(b)
+		}
+		conn.mu.Unlock()
+		if len(out) >= n || time.Now().After(deadline) {
+			return out
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			mon, conn := newFakeConn(), newFakeConn()
+			a.handleConnect(mon)
+			a.handleConnect(conn)
+
+			if got := do(a, mon, "MONITOR"); got != "+OK\r\n" {
+				t.Fatalf("MONITOR = %q", got)
+			}
+			do(a, conn, "SELECT", "1")
+			do(a, conn, "set", "a b", "x\n\"y\"\x01")
+			do(a, conn, "CONFIG", "GET", "port")
+			do(a, conn, "AUTH", "secret")
+			do(a, conn, "GET")
+
+			out := waitOutput(t, mon, 4)
+			if len(out) != 4 {
+				t.Fatalf("monitor output = %q", out)
+			}
+			addr := regexp.QuoteMeta(conn.RemoteAddr())
+			want := []string{
+				`^\+OK\r\n$`,
+				`^\+\d+\.\d{6} \[0 ` + addr + `\] "SELECT" "1"\r\n$`,
+				`^\+\d+\.\d{6} \[1 ` + addr + `\] "set" "a b" "x\\n\\"y\\"\\x01"\r\n$`,
+				`^\+\d+\.\d{6} \[1 ` + addr + `\] "AUTH" "\(redacted\)"\r\n$`,
+			}
+			for i, re := range want {
+				if !regexp.MustCompile(re).MatchString(out[i]) {
+					t.Errorf("line %d = %q, want %s", i, out[i], re)
+				}
+			}
+
+			a.handleDisconnect(mon, nil)
+			do(a, conn, "PING")
+			time.Sleep(20 * time.Millisecond)
+			if out := waitOutput(t, mon, 0); len(out) != 4 {
+				t.Errorf("disconnected monitor still fed: %q", out[4:])
+			}
+		})
+	}
+}
+
+// blockingConn 发送时阻塞，模拟读取很慢的客户端
+type blockingConn struct {
+	*fakeConn
+	release chan struct{}
+}
+
+func (c *blockingConn) Send(msg []byte) error {
+	<-c.release
+	return c.fakeConn.Send(msg)
+}
+
+func TestMonitorDropsSlowClient(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	mon := &blockingConn{fakeConn: newFakeConn(), release: make(chan struct{})}
+	defer close(mon.release)
+	conn := newFakeConn()
+	a.handleConnect(mon)
+	a.handleConnect(conn)
+
+	// 直接注册，+OK 的发送会被阻塞
+	v, _ := a.clients.Load(mon.Cid())
+	a.addMonitor(v.(*client))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < monitorBufferSize+10; i++ {
+			do(a, conn, "SET", "k", strings.Repeat("v", i%10))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow monitor blocked command execution")
+	}
+	if !mon.closed.Load() {
+		t.Error("slow monitor was not closed")
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	tests := map[string]string{
+		"":           `""`,
+		"abc":        `"abc"`,
+		"a\"b\\c":    `"a\"b\\c"`,
+		"\r\n\t\a\b": `"\r\n\t\a\b"`,
+		"\x00\xff":   `"\x00\xff"`,
+	}
+	for in, want := range tests {
+		if got := quoteArg(in); got != want {
+			t.Errorf("quoteArg(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"literedis/pkg/protocol"
+)
+
+// multiImmediateCommands MULTI 之后立即执行而不排队的命令
+var multiImmediateCommands = map[string]bool{
+	"MULTI": true, "EXEC": true, "DISCARD": true, "WATCH": true, "QUIT": true, "RESET": true,
+}
+
+// noMultiCommands 不能在事务中执行的命令，排队时报错并使事务失败
+var noMultiCommands = map[string]bool{
+	"MONITOR":   true,
+	"SUBSCRIBE": true, "PSUBSCRIBE": true, "SSUBSCRIBE": true,
+	"UNSUBSCRIBE": true, "PUNSUBSCRIBE": true, "SUNSUBSCRIBE": true,
+}
+
+var errExecAbort = errors.New("EXECABORT Transaction discarded because of previous errors.")
+
+// multiState 事务状态，只由连接自己的命令访问
+type multiState struct {
+	active bool
+	dirty  bool // 排队时出现过错误，EXEC 返回 EXECABORT
+	queue  []*command
+}
+
+// watchState WATCH 的键及其版本号。断开连接可能发生在其它协程，需要加锁
+type watchState struct {
+	mu     sync.Mutex
+	keys   []watchedKey
+	closed bool
+}
+
+type watchedKey struct {
+	db      int
+	key     string
+	version uint64
+}
+
+// flagTransaction 事务中的命令在排队时出错，EXEC 时放弃整个事务
+func (c *client) flagTransaction() {
+	if c.multi.active {
+		c.multi.dirty = true
+	}
+}
+
+func (c *client) discardTransaction() {
+	c.multi = multiState{}
+}
+
+// queueCommand 把 MULTI 之后的命令加入队列，EXEC 时执行
+func (a *App) queueCommand(c *client, cmd *command) (*protocol.Message, error) {
+	if noMultiCommands[cmd.name] {
+		c.multi.dirty = true
+		return nil, errors.New("Command not allowed inside a transaction")
+	}
+	c.multi.queue = append(c.multi.queue, cmd)
+	return &protocol.Message{Type: "SimpleString", Content: "QUEUED"}, nil
+}
+
+// 下面的错误消息以大写的命令名开头，需要显式加上 ERR，否则会被当作错误码
+func (a *App) handleMulti(c *client, args []string) (*protocol.Message, error) {
+	if c.multi.active {
+		return nil, errors.New("ERR MULTI calls can not be nested")
+	}
+	c.multi.active = true
+	return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+}
+
+func (a *App) handleDiscard(c *client, args []string) (*protocol.Message, error) {
+	if !c.multi.active {
+		return nil, errors.New("ERR DISCARD without MULTI")
+	}
+	c.discardTransaction()
+	a.unwatchAll(c)
+	return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+}
+
+// handleExec 依次执行排队的命令。processCommand 为 EXEC 持有 execMu 的写锁，
+// 其它连接的命令都要等待，因此事务对其它客户端是原子的
+func (a *App) handleExec(c *client, args []string) (*protocol.Message, error) {
+	if !c.multi.active {
+		return nil, errors.New("ERR EXEC without MULTI")
+	}
+	queue, dirty := c.multi.queue, c.multi.dirty
+	c.discardTransaction()
+	defer a.unwatchAll(c)
+	if dirty {
+		return nil, errExecAbort
+	}
+
+	if a.watchedKeysChanged(c) {
+		return &protocol.Message{Type: "Array", Content: []*protocol.Message(nil)}, nil
+	}
+	replies := make([]*protocol.Message, 0, len(queue))
+	c.nested = true
+	defer func() { c.nested = false }()
+	for _, cmd := range queue {
+		a.feedMonitors(c, strings.ToLower(cmd.name), cmd)
+		reply, err := a.execCommand(c, cmd)
+		if err != nil {
+			reply = errorReply(err)
+			a.stats.recordError(reply.Content.(string))
+		} else if reply == nil {
+			reply = &protocol.Message{Type: "BulkString"}
+		}
+		replies = append(replies, reply)
+	}
+	return array(replies...), nil
+}
+
+// handleWatch WATCH key [key ...]，记录键的当前版本号
+func (a *App) handleWatch(c *client, args []string) (*protocol.Message, error) {
+	if c.multi.active {
+		return nil, errors.New("ERR WATCH inside MULTI is not allowed")
+	}
+	db := c.storage.SelectedDB()
+	c.watch.mu.Lock()
+	defer c.watch.mu.Unlock()
+	if c.watch.closed {
+		return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+	}
+next:
+	for _, key := range args {
+		for _, w := range c.watch.keys {
+			if w.db == db && w.key == key {
+				continue next
+			}
+		}
+		c.watch.keys = append(c.watch.keys, watchedKey{db: db, key: key, version: a.storage.Watch(db, key)})
+	}
+	return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+}
+
+func (a *App) handleUnwatch(c *client, args []string) (*protocol.Message, error) {
+	a.unwatchAll(c)
+	return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+}
+
+// watchedKeysChanged 检查 WATCH 之后是否有键被修改、过期或清空
+func (a *App) watchedKeysChanged(c *client) bool {
+	c.watch.mu.Lock()
+	defer c.watch.mu.Unlock()
+	for _, w := range c.watch.keys {
+		if a.storage.KeyVersion(w.db, w.key) != w.version {
+			return true
+		}
+	}
+	return false
+}
+
+// unwatchAll 释放连接 WATCH 的所有键
+func (a *App) unwatchAll(c *client) {
+	c.watch.mu.Lock()
+	defer c.watch.mu.Unlock()
+	a.releaseWatched(c)
+}
+
+// closeWatch 断开连接时释放 WATCH 的键，之后的 WATCH 不再生效
+func (a *App) closeWatch(c *client) {
+	c.watch.mu.Lock()
+	defer c.watch.mu.Unlock()
+	a.releaseWatched(c)
+	c.watch.closed = true
+}
+
+func (a *App) releaseWatched(c *client) {
+	for _, w := range c.watch.keys {
+		a.storage.Unwatch(w.db, w.key)
+	}
+	c.watch.keys = nil
+}
@@ -0,0 +1,205 @@
+package app
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMultiExec(t *testing.T) {
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			conn := newFakeConn()
+			a.handleConnect(conn)
+
+			if got := do(a, conn, "EXEC"); got != "-ERR EXEC without MULTI\r\n" {
+				t.Errorf("EXEC without MULTI = %q", got)
+			}
+			do(a, conn, "MULTI")
+			if got := do(a, conn, "MULTI"); got != "-ERR MULTI calls can not be nested\r\n" {
+				t.Errorf("nested MULTI = %q", got)
+			}
+			if got := do(a, conn, "SET", "k", "v"); got != "+QUEUED\r\n" {
+				t.Errorf("SET in MULTI = %q", got)
+			}
+			do(a, conn, "GET", "k")
+			do(a, conn, "LPUSH", "k2")
+			do(a, conn, "GET", "missing")
+			want := "*4\r\n+OK\r\n$1\r\nv\r\n-ERR wrong number of arguments for 'lpush' command\r\n$-1\r\n"
+			if got := do(a, conn, "EXEC"); got == want {
+				t.Errorf("EXEC ran a transaction with a queue-time error")
+			} else if got != "-EXECABORT Transaction discarded because of previous errors.\r\n" {
+				t.Errorf("EXEC after arity error = %q", got)
+			}
+			if got := do(a, conn, "GET", "k"); got != "$-1\r\n" {
+				t.Errorf("aborted transaction wrote k: %q", got)
+			}
+
+			// 执行时的错误不影响其它命令
+			do(a, conn, "MULTI")
+			do(a, conn, "SET", "k", "v")
+			do(a, conn, "CONFIG", "SET", "no-such-param", "1")
+			do(a, conn, "GET", "k")
+			got := do(a, conn, "EXEC")
+			if !strings.HasPrefix(got, "*3\r\n+OK\r\n-ERR ") || !strings.HasSuffix(got, "$1\r\nv\r\n") {
+				t.Errorf("EXEC = %q", got)
+			}
+
+			do(a, conn, "MULTI")
+			do(a, conn, "SET", "k", "other")
+			if got := do(a, conn, "DISCARD"); got != "+OK\r\n" {
+				t.Errorf("DISCARD = %q", got)
+			}
+			if got := do(a, conn, "GET", "k"); got != "$1\r\nv\r\n" {
+				t.Errorf("discarded transaction wrote k: %q", got)
+			}
+			if got := do(a, conn, "DISCARD"); got != "-ERR DISCARD without MULTI\r\n" {
+				t.Errorf("DISCARD without MULTI = %q", got)
+			}
+
+			do(a, conn, "MULTI")
+			if got := do(a, conn, "SUBSCRIBE", "ch"); got != "-ERR Command not allowed inside a transaction\r\n" {
+				t.Errorf("SUBSCRIBE in MULTI = %q", got)
+			}
+			if got := do(a, conn, "EXEC"); !strings.HasPrefix(got, "-EXECABORT") {
+				t.Errorf("EXEC after SUBSCRIBE = %q", got)
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			conn, other := newFakeConn(), newFakeConn()
+			a.handleConnect(conn)
+			a.handleConnect(other)
+
+			// 未修改时正常执行
+			do(a, conn, "SET", "k", "1")
+			do(a, conn, "WATCH", "k", "missing")
+			do(a, conn, "MULTI")
+			do(a, conn, "SET", "k", "2")
+			if got := do(a, conn, "EXEC"); got != "*1\r\n+OK\r\n" {
+				t.Errorf("EXEC = %q", got)
+			}
+
+			// 其它连接修改之后 EXEC 返回 nil
+			do(a, conn, "WATCH", "k")
+			do(a, other, "SET", "k", "3")
+			do(a, conn, "MULTI")
+			do(a, conn, "SET", "k", "4")
+			if got := do(a, conn, "EXEC"); got != "*-1\r\n" {
+				t.Errorf("EXEC after concurrent write = %q", got)
+			}
+			if got := do(a, conn, "GET", "k"); got != "$1\r\n3\r\n" {
+				t.Errorf("GET k = %q", got)
+			}
+
+			// EXEC 之后不再 WATCH
+			do(a, conn, "MULTI")
+			do(a, conn, "SET", "k", "5")
+			if got := do(a, conn, "EXEC"); got != "*1\r\n+OK\r\n" {
+				t.Errorf("EXEC after previous EXEC = %q", got)
+			}
+
+			// UNWATCH
+			do(a, conn, "WATCH", "k")
+			do(a, other, "SET", "k", "6")
+			do(a, conn, "UNWATCH")
+			do(a, conn, "MULTI")
+			if got := do(a, conn, "WATCH", "k"); got != "-ERR WATCH inside MULTI is not allowed\r\n" {
+				t.Errorf("WATCH in MULTI = %q", got)
+			}
+			if got := do(a, conn, "EXEC"); got != "*0\r\n" {
+				t.Errorf("EXEC after UNWATCH = %q", got)
+			}
+
+			// 同名的键在不同数据库中相互独立
+			do(a, conn, "WATCH", "k")
+			do(a, other, "SELECT", "1")
+			do(a, other, "SET", "k", "x")
+			do(a, conn, "MULTI")
+			if got := do(a, conn, "EXEC"); got != "*0\r\n" {
+				t.Errorf("EXEC after write to another db = %q", got)
+			}
+
+			// 过期
+			do(a, conn, "SET", "e", "v")
+			do(a, conn, "EXPIRE", "e", "1")
+			do(a, conn, "WATCH", "e")
+			time.Sleep(1100 * time.Millisecond)
+			do(a, conn, "MULTI")
+			if got := do(a, conn, "EXEC"); got != "*-1\r\n" {
+				t.Errorf("EXEC after expiry = %q", got)
+			}
+
+			// FLUSHDB 只影响存在的键
+			do(a, conn, "WATCH", "k", "missing")
+			do(a, other, "SELECT", "0")
+			do(a, other, "FLUSHDB")
+			do(a, conn, "MULTI")
+			if got := do(a, conn, "EXEC"); got != "*-1\r\n" {
+				t.Errorf("EXEC after FLUSHDB = %q", got)
+			}
+			do(a, conn, "WATCH", "missing")
+			do(a, other, "FLUSHALL")
+			do(a, conn, "MULTI")
+			if got := do(a, conn, "EXEC"); got != "*0\r\n" {
+				t.Errorf("EXEC after flushing a missing key = %q", got)
+			}
+		})
+	}
+}
+
+func TestExecIsAtomic(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	const clients, rounds = 8, 50
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		conn := newFakeConn()
+		a.handleConnect(conn)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				do(a, conn, "MULTI")
+				do(a, conn, "RPUSH", "l", "a")
+				do(a, conn, "RPUSH", "l", "b")
+				do(a, conn, "EXEC")
+			}
+		}()
+	}
+	wg.Wait()
+
+	items, err := a.storage.LRange("l", 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2*clients*rounds {
+		t.Fatalf("len = %d", len(items))
+	}
+	for i := 0; i < len(items); i += 2 {
+		if string(items[i]) != "a" || string(items[i+1]) != "b" {
+			t.Fatalf("transactions interleaved at %d: %q %q", i, items[i], items[i+1])
+		}
+	}
+}
+
+func TestDisconnectReleasesWatchedKeys(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+	do(a, conn, "WATCH", "k")
+	c := a.mustClient(t, conn)
+	a.handleDisconnect(conn, nil)
+	if len(c.watch.keys) != 0 || !c.watch.closed {
+		t.Errorf("watch state after disconnect = %+v", c.watch.keys)
+	}
+	if got := do(a, conn, "WATCH", "k"); got != "" && len(c.watch.keys) != 0 {
+		t.Errorf("WATCH after disconnect tracked keys")
+	}
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"compress/gzip"
+	"fmt"
 	"literedis/pkg/network"
 	"strings"
 	"time"
@@ -9,6 +10,37 @@ import (
 
 const defaultName = "literedis"
 
+// ExecMode 命令执行模式
+type ExecMode int
+
+const (
+	// ExecModeThreaded 在各连接自己的 goroutine 中执行命令，依赖存储层的锁
+	ExecModeThreaded ExecMode = iota
+	// ExecModeEventLoop 所有命令由单个事件循环 goroutine 串行执行
+	ExecModeEventLoop
+)
+
+func (m ExecMode) String() string {
+	switch m {
+	case ExecModeThreaded:
+		return "threaded"
+	case ExecModeEventLoop:
+		return "eventloop"
+	}
+	return fmt.Sprintf("ExecMode(%d)", int(m))
+}
+
+// ParseExecMode 解析配置中的执行模式，空字符串表示默认的 threaded
+func ParseExecMode(s string) (ExecMode, error) {
+	switch strings.ToLower(s) {
+	case "", "threaded":
+		return ExecModeThreaded, nil
+	case "eventloop", "event_loop", "event-loop":
+		return ExecModeEventLoop, nil
+	}
+	return ExecModeThreaded, fmt.Errorf("invalid exec mode: %q", s)
+}
+
 type RDBConfig struct {
 	Filename         string
 	SaveInterval     time.Duration
@@ -24,6 +56,8 @@ type options struct {
 	clusterNodes []string
 	clusterMode  bool
 	rdbConfig    RDBConfig
+	execMode     ExecMode
+	libraries    []FunctionLibrary
 }
 
 type OptionFunc func(o *options)
@@ -73,3 +107,7 @@ func WithClusterMode(enable bool) OptionFunc {
 func WithRDBConfig(config RDBConfig) OptionFunc {
 	return func(o *options) { o.rdbConfig = config }
 }
+
+func WithExecMode(mode ExecMode) OptionFunc {
+	return func(o *options) { o.execMode = mode }
+}
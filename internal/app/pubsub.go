@@ -0,0 +1,542 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"literedis/internal/cluster"
+	"literedis/internal/consts"
+	"literedis/pkg/glob"
+	"literedis/pkg/log"
+	"literedis/pkg/protocol"
+)
+
+// 订阅的种类：普通频道、模式和分片频道
+const (
+	subChannel = iota
+	subPattern
+	subShard
+)
+
+// subKinds 每种订阅的确认消息类型
+var subKinds = [...]struct {
+	subscribe, unsubscribe string
+}{
+	subChannel: {"subscribe", "unsubscribe"},
+	subPattern: {"psubscribe", "punsubscribe"},
+	subShard:   {"ssubscribe", "sunsubscribe"},
+}
+
+// subscriber 进入过订阅模式的连接。之后该连接的所有输出，包括命令回复、订阅确认和
+// 推送的消息，都经由队列按顺序写出，由单独的协程发送，发布消息时不会被慢客户端阻塞
+type subscriber struct {
+	c    *client
+	wake chan struct{}
+	done chan struct{}
+
+	mu        sync.Mutex
+	queue     [][]byte // nil 表示发送完之前的数据后关闭连接
+	pending   int64    // 队列中尚未发送的字节数
+	softSince time.Time
+
+	subs   [3]map[string]struct{} // 按种类保存订阅的频道或模式，由 pubsub.mu 保护
+	active atomic.Int64           // 订阅总数，大于 0 时处于订阅模式
+}
+
+// count 返回订阅确认中的数量：分片频道单独计数，频道和模式合并计数
+func (s *subscriber) count(kind int) int64 {
+	if kind == subShard {
+		return int64(len(s.subs[subShard]))
+	}
+	return int64(len(s.subs[subChannel]) + len(s.subs[subPattern]))
+}
+
+// pubsub 所有订阅关系
+type pubsub struct {
+	mu    sync.RWMutex
+	byID  map[int64]*subscriber
+	index [3]map[string]map[int64]*subscriber // 按种类：频道或模式 -> 订阅者
+}
+
+func newPubSub() pubsub {
+	var index [3]map[string]map[int64]*subscriber
+	for i := range index {
+		index[i] = make(map[string]map[int64]*subscriber)
+	}
+	return pubsub{byID: make(map[int64]*subscriber), index: index}
+}
+
+// subscribed 判断连接是否处于订阅模式
+func (a *App) subscribed(c *client) bool {
+	s := c.sub.Load()
+	return s != nil && s.active.Load() > 0
+}
+
+// ensureSubscriber 返回连接的订阅者，第一次调用时创建发送队列。调用方持有 pubsub.mu
+func (a *App) ensureSubscriber(c *client) *subscriber {
+	if s, ok := a.pubsub.byID[c.conn.Cid()]; ok {
+		return s
+	}
+	s := &subscriber{c: c, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	for i := range s.subs {
+		s.subs[i] = make(map[string]struct{})
+	}
+	a.pubsub.byID[c.conn.Cid()] = s
+	c.sub.Store(s)
+	go a.writeSubscriber(s)
+	return s
+}
+
+func (a *App) handleSubscribe(c *client, args []string) (*protocol.Message, error) {
+	return a.subscribe(c, args, subChannel)
+}
+
+func (a *App) handlePSubscribe(c *client, args []string) (*protocol.Message, error) {
+	return a.subscribe(c, args, subPattern)
+}
+
+func (a *App) handleSSubscribe(c *client, args []string) (*protocol.Message, error) {
+	if err := a.checkShardChannels(args); err != nil {
+		return nil, err
+	}
+	return a.subscribe(c, args, subShard)
+}
+
+func (a *App) handleUnsubscribe(c *client, args []string) (*protocol.Message, error) {
+	return a.unsubscribe(c, args, subChannel)
+}
+
+func (a *App) handlePUnsubscribe(c *client, args []string) (*protocol.Message, error) {
+	return a.unsubscribe(c, args, subPattern)
+}
+
+func (a *App) handleSUnsubscribe(c *client, args []string) (*protocol.Message, error) {
+	return a.unsubscribe(c, args, subShard)
+}
+
+// checkChannels 检查 ACL 频道权限
+func (a *App) checkChannels(c *client, channels []string, pattern bool) error {
+	u := a.acl.User(c.user)
+	if u == nil {
+		return nil
+	}
+	for _, ch := range channels {
+		if !u.CanAccessChannel(ch, pattern) {
+			a.acl.Log.Add("channel", "toplevel", ch, c.user, c.info())
+			return permissionError(c.user, "channel", ch)
+		}
+	}
+	return nil
+}
+
+// checkShardChannels 集群模式下分片频道按哈希槽路由，所有频道必须属于本节点上的同一个槽
+func (a *App) checkShardChannels(channels []string) error {
+	if a.cluster == nil || len(channels) == 0 {
+		return nil
+	}
+	slot := cluster.KeySlot(channels[0])
+	for _, ch := range channels[1:] {
+		if cluster.KeySlot(ch) != slot {
+			return errors.New("CROSSSLOT Keys in request don't hash to the same slot")
+		}
+	}
+	if node := a.cluster.GetNodeForSlot(slot); node != nil && !a.cluster.IsLocalNode(node.ID) {
+		return consts.ErrWrongNode
+	}
+	return nil
+}
+
+// subscribe 订阅频道或模式。确认通过订阅者的队列发送，保证排在之后收到的消息前面，
+// 因此命令本身没有回复
+func (a *App) subscribe(c *client, args []string, kind int) (*protocol.Message, error) {
+	if err := a.checkChannels(c, args, kind == subPattern); err != nil {
+		return nil, err
+	}
+
+	a.pubsub.mu.Lock()
+	s := a.ensureSubscriber(c)
+	index, cid := a.pubsub.index[kind], c.conn.Cid()
+	for _, ch := range args {
+		if _, ok := s.subs[kind][ch]; !ok {
+			s.subs[kind][ch] = struct{}{}
+			s.active.Add(1)
+			if index[ch] == nil {
+				index[ch] = make(map[int64]*subscriber)
+			}
+			index[ch][cid] = s
+		}
+		s.push(a.pushFrame(c, bulk(subKinds[kind].subscribe), bulk(ch), integer(s.count(kind))))
+	}
+	a.pubsub.mu.Unlock()
+	return nil, nil
+}
+
+// unsubscribe 取消订阅，没有参数时取消该种类的全部订阅
+func (a *App) unsubscribe(c *client, args []string, kind int) (*protocol.Message, error) {
+	a.pubsub.mu.Lock()
+	defer a.pubsub.mu.Unlock()
+	s := a.ensureSubscriber(c)
+	if len(args) == 0 {
+		for ch := range s.subs[kind] {
+			args = append(args, ch)
+		}
+		sort.Strings(args)
+	}
+	name := subKinds[kind].unsubscribe
+	if len(args) == 0 {
+		s.push(a.pushFrame(c, bulk(name), &protocol.Message{Type: "Null"}, integer(s.count(kind))))
+		return nil, nil
+	}
+	for _, ch := range args {
+		a.pubsub.drop(s, kind, ch)
+		s.push(a.pushFrame(c, bulk(name), bulk(ch), integer(s.count(kind))))
+	}
+	return nil, nil
+}
+
+// drop 取消一个订阅。调用方持有 mu
+func (ps *pubsub) drop(s *subscriber, kind int, ch string) {
+	if _, ok := s.subs[kind][ch]; !ok {
+		return
+	}
+	delete(s.subs[kind], ch)
+	s.active.Add(-1)
+	cid := s.c.conn.Cid()
+	delete(ps.index[kind][ch], cid)
+	if len(ps.index[kind][ch]) == 0 {
+		delete(ps.index[kind], ch)
+	}
+}
+
+// unsubscribeAll 不发送确认，直接取消连接的全部订阅，用于 RESET 和断开连接
+func (a *App) unsubscribeAll(cid int64) *subscriber {
+	a.pubsub.mu.Lock()
+	defer a.pubsub.mu.Unlock()
+	s, ok := a.pubsub.byID[cid]
+	if !ok {
+		return nil
+	}
+	for kind := range s.subs {
+		for ch := range s.subs[kind] {
+			a.pubsub.drop(s, kind, ch)
+		}
+	}
+	return s
+}
+
+// removeSubscriber 连接断开时取消所有订阅并停止发送协程
+func (a *App) removeSubscriber(cid int64) {
+	s := a.unsubscribeAll(cid)
+	if s == nil {
+		return
+	}
+	a.pubsub.mu.Lock()
+	delete(a.pubsub.byID, cid)
+	a.pubsub.mu.Unlock()
+	close(s.done)
+}
+
+// pushFrame 生成推送消息：RESP3 连接使用 push 类型，RESP2 连接使用数组
+func (a *App) pushFrame(c *client, items ...*protocol.Message) []byte {
+	msg := array(items...)
+	if c.protoVersion() == 3 {
+		msg.Type = "Push"
+	}
+	data, _ := a.protocol.Pack(msg)
+	return data
+}
+
+// frameCache 同一条消息按 RESP2 和 RESP3 各编码一次
+type frameCache struct {
+	a     *App
+	items []*protocol.Message
+	data  [2][]byte
+}
+
+func (f *frameCache) get(c *client) []byte {
+	i := c.protoVersion() - 2
+	if f.data[i] == nil {
+		f.data[i] = f.a.pushFrame(c, f.items...)
+	}
+	return f.data[i]
+}
+
+// publish 把消息发送给频道的订阅者和匹配的模式订阅者，返回接收者数量。
+// 键空间通知在持有数据库锁时调用，因此这里不能阻塞
+func (a *App) publish(channel, message string) int64 {
+	limit := a.outputLimit("pubsub")
+	now := time.Now()
+	var n int64
+	var slow []*subscriber
+	deliver := func(subs map[int64]*subscriber, f *frameCache) {
+		for _, s := range subs {
+			if !s.deliver(f.get(s.c), limit, now) {
+				slow = append(slow, s)
+			}
+			n++
+		}
+	}
+
+	a.pubsub.mu.RLock()
+	if subs := a.pubsub.index[subChannel][channel]; len(subs) > 0 {
+		deliver(subs, &frameCache{a: a, items: []*protocol.Message{bulk("message"), bulk(channel), bulk(message)}})
+	}
+	for pattern, subs := range a.pubsub.index[subPattern] {
+		if glob.Match(pattern, channel) {
+			deliver(subs, &frameCache{a: a, items: []*protocol.Message{bulk("pmessage"), bulk(pattern), bulk(channel), bulk(message)}})
+		}
+	}
+	a.pubsub.mu.RUnlock()
+
+	a.closeSlowSubscribers(slow)
+	return n
+}
+
+// spublish 把消息发送给分片频道的订阅者
+func (a *App) spublish(channel, message string) int64 {
+	limit := a.outputLimit("pubsub")
+	now := time.Now()
+	var n int64
+	var slow []*subscriber
+
+	a.pubsub.mu.RLock()
+	f := &frameCache{a: a, items: []*protocol.Message{bulk("smessage"), bulk(channel), bulk(message)}}
+	for _, s := range a.pubsub.index[subShard][channel] {
+		if !s.deliver(f.get(s.c), limit, now) {
+			slow = append(slow, s)
+		}
+		n++
+	}
+	a.pubsub.mu.RUnlock()
+
+	a.closeSlowSubscribers(slow)
+	return n
+}
+
+// closeSlowSubscribers 关闭会触发 handleDisconnect 取消订阅，需要在释放锁之后进行
+func (a *App) closeSlowSubscribers(slow []*subscriber) {
+	for _, s := range slow {
+		a.stats.outputLimitDisconnections.Add(1)
+		log.Warnf("closing client that reached the pubsub output buffer limit: %s", s.c.info())
+		s.c.conn.Close()
+	}
+}
+
+func (a *App) handlePublish(c *client, args []string) (*protocol.Message, error) {
+	if err := a.checkChannels(c, args[:1], false); err != nil {
+		return nil, err
+	}
+	return integer(a.publish(args[0], args[1])), nil
+}
+
+func (a *App) handleSPublish(c *client, args []string) (*protocol.Message, error) {
+	if err := a.checkShardChannels(args[:1]); err != nil {
+		return nil, err
+	}
+	if err := a.checkChannels(c, args[:1], false); err != nil {
+		return nil, err
+	}
+	return integer(a.spublish(args[0], args[1])), nil
+}
+
+func (a *App) handlePubSub(c *client, args []string) (*protocol.Message, error) {
+	name := args[0]
+	sub := strings.ToLower(name)
+	args = args[1:]
+	wrongArgs := fmt.Errorf("wrong number of arguments for 'pubsub|%s' command", sub)
+
+	a.pubsub.mu.RLock()
+	defer a.pubsub.mu.RUnlock()
+	switch sub {
+	case "channels", "shardchannels":
+		if len(args) > 1 {
+			return nil, wrongArgs
+		}
+		index := a.pubsub.index[subChannel]
+		if sub == "shardchannels" {
+			index = a.pubsub.index[subShard]
+		}
+		var names []string
+		for ch := range index {
+			if len(args) == 1 {
+				if !glob.Match(args[0], ch) {
+					continue
+				}
+			}
+			names = append(names, ch)
+		}
+		sort.Strings(names)
+		items := make([]*protocol.Message, len(names))
+		for i, ch := range names {
+			items[i] = bulk(ch)
+		}
+		return array(items...), nil
+	case "numsub", "shardnumsub":
+		index := a.pubsub.index[subChannel]
+		if sub == "shardnumsub" {
+			index = a.pubsub.index[subShard]
+		}
+		items := make([]*protocol.Message, 0, 2*len(args))
+		for _, ch := range args {
+			items = append(items, bulk(ch), integer(int64(len(index[ch]))))
+		}
+		return array(items...), nil
+	case "numpat":
+		if len(args) != 0 {
+			return nil, wrongArgs
+		}
+		return integer(int64(len(a.pubsub.index[subPattern]))), nil
+	}
+	return nil, fmt.Errorf("unknown subcommand '%s'. Try PUBSUB HELP.", name)
+}
+
+// push 把数据放入发送队列，不检查输出缓冲区限制，用于命令回复和订阅确认
+func (s *subscriber) push(data []byte) {
+	s.mu.Lock()
+	s.queue = append(s.queue, data)
+	s.pending += int64(len(data))
+	s.mu.Unlock()
+	s.signal()
+}
+
+// deliver 放入一条推送消息并检查输出缓冲区限制，超过时返回 false，调用方应断开连接
+func (s *subscriber) deliver(data []byte, limit bufferLimit, now time.Time) bool {
+	s.mu.Lock()
+	s.queue = append(s.queue, data)
+	s.pending += int64(len(data))
+	exceeded := limit.exceeded(s.pending, &s.softSince, now)
+	s.mu.Unlock()
+	s.signal()
+	return !exceeded
+}
+
+func (s *subscriber) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (a *App) writeSubscriber(s *subscriber) {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.wake:
+		}
+		s.mu.Lock()
+		batch := s.queue
+		s.queue = nil
+		s.mu.Unlock()
+
+		for _, data := range batch {
+			if data == nil {
+				s.c.conn.Close()
+				return
+			}
+			if err := s.c.conn.Send(data); err != nil {
+				return
+			}
+			a.stats.netOutputBytes.Add(int64(len(data)))
+			s.mu.Lock()
+			s.pending -= int64(len(data))
+			s.mu.Unlock()
+		}
+	}
+}
+
+// bufferLimit client-output-buffer-limit 中一个类别的限制，0 表示不限制
+type bufferLimit struct {
+	hard, soft  int64 // 字节
+	softSeconds int64 // 连续超过软限制多少秒之后断开
+}
+
+// exceeded 判断待发送的数据是否超过限制，softSince 记录开始超过软限制的时间
+func (l bufferLimit) exceeded(pending int64, softSince *time.Time, now time.Time) bool {
+	if l.hard > 0 && pending >= l.hard {
+		return true
+	}
+	if l.soft > 0 && pending >= l.soft {
+		if softSince.IsZero() {
+			*softSince = now
+			return false
+		}
+		return now.Sub(*softSince) > time.Duration(l.softSeconds)*time.Second
+	}
+	*softSince = time.Time{}
+	return false
+}
+
+// outputLimits 客户端类别 -> 输出缓冲区限制，类别为 normal、replica 和 pubsub。
+// 命令回复同步写出，目前只有 pubsub 的限制会生效
+type outputLimits map[string]bufferLimit
+
+var outputLimitClasses = []string{"normal", "replica", "pubsub"}
+
+// defaultOutputLimits 与 Redis 的默认值相同
+const defaultOutputLimits = "normal 0 0 0 replica 256mb 64mb 60 pubsub 32mb 8mb 60"
+
+// parseOutputLimits 解析 "<class> <hard> <soft> <soft seconds>"，可以包含多个类别
+func parseOutputLimits(s string) (outputLimits, error) {
+	fields := strings.Fields(s)
+	if len(fields)%4 != 0 {
+		return nil, errors.New("wrong number of arguments in buffer limit configuration.")
+	}
+	limits := make(outputLimits)
+	for i := 0; i < len(fields); i += 4 {
+		class := strings.ToLower(fields[i])
+		if class == "slave" {
+			class = "replica"
+		}
+		if !slices.Contains(outputLimitClasses, class) {
+			return nil, errors.New("Invalid client class specified in buffer limit configuration.")
+		}
+		hard, err1 := parseMemory(fields[i+1])
+		soft, err2 := parseMemory(fields[i+2])
+		secs, err3 := strconv.ParseInt(fields[i+3], 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil || secs < 0 {
+			return nil, errors.New("Error in hard, soft or soft_seconds setting in buffer limit configuration.")
+		}
+		limits[class] = bufferLimit{hard: hard, soft: soft, softSeconds: secs}
+	}
+	return limits, nil
+}
+
+// String 按 CONFIG GET 的格式输出，replica 显示为 slave
+func (l outputLimits) String() string {
+	parts := make([]string, 0, len(outputLimitClasses))
+	for _, class := range outputLimitClasses {
+		limit := l[class]
+		name := class
+		if class == "replica" {
+			name = "slave"
+		}
+		parts = append(parts, fmt.Sprintf("%s %d %d %d", name, limit.hard, limit.soft, limit.softSeconds))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (a *App) outputLimit(class string) bufferLimit {
+	return (*a.outputLimits.Load())[class]
+}
+
+// setOutputLimits 更新 limits 中包含的类别，其它类别保持不变
+func (a *App) setOutputLimits(limits outputLimits) {
+	merged := make(outputLimits, len(outputLimitClasses))
+	if old := a.outputLimits.Load(); old != nil {
+		for class, limit := range *old {
+			merged[class] = limit
+		}
+	}
+	for class, limit := range limits {
+		merged[class] = limit
+	}
+	a.outputLimits.Store(&merged)
+}
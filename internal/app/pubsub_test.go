@@ -0,0 +1,363 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"literedis/internal/cluster"
+)
+
+func TestSubscribeKeyspaceEvents(t *testing.T) {
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			sub, psub, conn := newFakeConn(), newFakeConn(), newFakeConn()
+			a.handleConnect(sub)
+			a.handleConnect(psub)
+			a.handleConnect(conn)
+
+			if got := do(a, conn, "CONFIG", "SET", "notify-keyspace-events", "EKg$"); got != "+OK\r\n" {
+				t.Fatalf("CONFIG SET = %q", got)
+			}
+			if got := do(a, conn, "CONFIG", "GET", "notify-keyspace-events"); !strings.Contains(got, "g$KE") {
+				t.Errorf("CONFIG GET = %q", got)
+			}
+			do(a, sub, "SUBSCRIBE", "__keyevent@1__:set", "__keyevent@1__:set", "other")
+			do(a, psub, "PSUBSCRIBE", "__keyspace@1__:*")
+
+			do(a, conn, "SELECT", "1")
+			do(a, conn, "SET", "foo", "bar")
+			do(a, conn, "DEL", "foo")
+			do(a, conn, "HSET", "h", "f", "v") // 未开启 h 类别
+
+			out := waitOutput(t, sub, 4)
+			want := []string{
+				"*3\r\n$9\r\nsubscribe\r\n$18\r\n__keyevent@1__:set\r\n:1\r\n",
+				"*3\r\n$9\r\nsubscribe\r\n$18\r\n__keyevent@1__:set\r\n:1\r\n",
+				"*3\r\n$9\r\nsubscribe\r\n$5\r\nother\r\n:2\r\n",
+				"*3\r\n$7\r\nmessage\r\n$18\r\n__keyevent@1__:set\r\n$3\r\nfoo\r\n",
+			}
+			if strings.Join(out, "") != strings.Join(want, "") {
+				t.Errorf("subscriber output = %q\nwant %q", out, want)
+			}
+
+			out = waitOutput(t, psub, 3)
+			want = []string{
+				"*3\r\n$10\r\npsubscribe\r\n$16\r\n__keyspace@1__:*\r\n:1\r\n",
+				"*4\r\n$8\r\npmessage\r\n$16\r\n__keyspace@1__:*\r\n$18\r\n__keyspace@1__:foo\r\n$3\r\nset\r\n",
+				"*4\r\n$8\r\npmessage\r\n$16\r\n__keyspace@1__:*\r\n$18\r\n__keyspace@1__:foo\r\n$3\r\ndel\r\n",
+			}
+			time.Sleep(20 * time.Millisecond)
+			if out = waitOutput(t, psub, 0); strings.Join(out, "") != strings.Join(want, "") {
+				t.Errorf("pattern subscriber output = %q\nwant %q", out, want)
+			}
+
+			a.handleDisconnect(sub, nil)
+			a.handleDisconnect(psub, nil)
+			if n := a.publish("__keyevent@1__:set", "x"); n != 0 {
+				t.Errorf("publish reached %d disconnected subscribers", n)
+			}
+			for kind, index := range a.pubsub.index {
+				if len(index) != 0 {
+					t.Errorf("subscriptions of kind %d left after disconnect: %v", kind, index)
+				}
+			}
+		})
+	}
+}
+
+func TestNotifyKeyspaceEventsConfig(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+	if got := do(a, conn, "CONFIG", "SET", "notify-keyspace-events", "KQ"); !strings.HasPrefix(got, "-ERR") {
+		t.Errorf("invalid flags accepted: %q", got)
+	}
+	do(a, conn, "CONFIG", "SET", "notify-keyspace-events", "KEA")
+	if got := do(a, conn, "CONFIG", "GET", "notify-keyspace-events"); !strings.Contains(got, "$3\r\nAKE\r\n") {
+		t.Errorf("CONFIG GET = %q", got)
+	}
+}
+
+// lastOutput 等待连接收到第 n 次写入并返回它
+func lastOutput(t *testing.T, conn *fakeConn, n int) string {
+	t.Helper()
+	out := waitOutput(t, conn, n)
+	if len(out) < n {
+		t.Fatalf("got %d writes, want %d: %q", len(out), n, out)
+	}
+	return out[n-1]
+}
+
+func TestPubSubCommands(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	sub, conn := newFakeConn(), newFakeConn()
+	a.handleConnect(sub)
+	a.handleConnect(conn)
+
+	do(a, sub, "SUBSCRIBE", "news", "sport")
+	do(a, sub, "PSUBSCRIBE", "n*")
+	if got := lastOutput(t, sub, 3); got != "*3\r\n$10\r\npsubscribe\r\n$2\r\nn*\r\n:3\r\n" {
+		t.Errorf("PSUBSCRIBE = %q", got)
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"PUBLISH", "news", "hi"}, ":2\r\n"},
+		{[]string{"PUBLISH", "sport", "hi"}, ":1\r\n"},
+		{[]string{"PUBLISH", "weather", "hi"}, ":0\r\n"},
+		{[]string{"PUBSUB", "CHANNELS"}, "*2\r\n$4\r\nnews\r\n$5\r\nsport\r\n"},
+		{[]string{"PUBSUB", "CHANNELS", "s*"}, "*1\r\n$5\r\nsport\r\n"},
+		{[]string{"PUBSUB", "NUMSUB", "news", "weather"}, "*4\r\n$4\r\nnews\r\n:1\r\n$7\r\nweather\r\n:0\r\n"},
+		{[]string{"PUBSUB", "NUMPAT"}, ":1\r\n"},
+		{[]string{"PUBSUB", "NUMPAT", "x"}, "-ERR wrong number of arguments for 'pubsub|numpat' command\r\n"},
+		{[]string{"PUBSUB", "FOO"}, "-ERR unknown subcommand 'FOO'. Try PUBSUB HELP.\r\n"},
+	}
+	for _, tt := range tests {
+		if got := do(a, conn, tt.args...); got != tt.want {
+			t.Errorf("%v = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+	if got := lastOutput(t, sub, 6); got != "*3\r\n$7\r\nmessage\r\n$5\r\nsport\r\n$2\r\nhi\r\n" {
+		t.Errorf("last message = %q", got)
+	}
+
+	do(a, sub, "UNSUBSCRIBE")
+	if got := lastOutput(t, sub, 8); got != "*3\r\n$11\r\nunsubscribe\r\n$5\r\nsport\r\n:1\r\n" {
+		t.Errorf("UNSUBSCRIBE = %q", got)
+	}
+	// 模式中的 * 可以匹配 /
+	if got := do(a, conn, "PUBLISH", "news/world", "hi"); got != ":1\r\n" {
+		t.Errorf("PUBLISH news/world = %q", got)
+	}
+	if got := lastOutput(t, sub, 9); got != "*4\r\n$8\r\npmessage\r\n$2\r\nn*\r\n$10\r\nnews/world\r\n$2\r\nhi\r\n" {
+		t.Errorf("pmessage = %q", got)
+	}
+	do(a, sub, "PUNSUBSCRIBE", "n*")
+	if got := lastOutput(t, sub, 10); got != "*3\r\n$12\r\npunsubscribe\r\n$2\r\nn*\r\n:0\r\n" {
+		t.Errorf("PUNSUBSCRIBE = %q", got)
+	}
+	do(a, sub, "UNSUBSCRIBE")
+	if got := lastOutput(t, sub, 11); got != "*3\r\n$11\r\nunsubscribe\r\n$-1\r\n:0\r\n" {
+		t.Errorf("UNSUBSCRIBE without subscriptions = %q", got)
+	}
+
+	// 取消全部订阅之后退出订阅模式，回复仍按顺序写出
+	do(a, sub, "SET", "k", "v")
+	if got := lastOutput(t, sub, 12); got != "+OK\r\n" {
+		t.Errorf("SET after unsubscribe = %q", got)
+	}
+}
+
+func TestSubscribeMode(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	resp2, resp3, conn := newFakeConn(), newFakeConn(), newFakeConn()
+	a.handleConnect(resp2)
+	a.handleConnect(resp3)
+	a.handleConnect(conn)
+
+	if got := do(a, resp3, "HELLO", "3"); !strings.HasPrefix(got, "%7\r\n") || !strings.Contains(got, "$5\r\nproto\r\n:3\r\n") {
+		t.Fatalf("HELLO 3 = %q", got)
+	}
+	do(a, resp2, "SUBSCRIBE", "ch")
+	do(a, resp3, "SUBSCRIBE", "ch")
+	if got := lastOutput(t, resp3, 2); got != ">3\r\n$9\r\nsubscribe\r\n$2\r\nch\r\n:1\r\n" {
+		t.Errorf("RESP3 SUBSCRIBE = %q", got)
+	}
+
+	do(a, resp2, "GET", "k")
+	want := "-ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context\r\n"
+	if got := lastOutput(t, resp2, 2); got != want {
+		t.Errorf("GET in RESP2 subscribe mode = %q", got)
+	}
+	do(a, resp2, "PING")
+	if got := lastOutput(t, resp2, 3); got != "*2\r\n$4\r\npong\r\n$0\r\n\r\n" {
+		t.Errorf("PING in RESP2 subscribe mode = %q", got)
+	}
+	do(a, resp3, "GET", "k")
+	if got := lastOutput(t, resp3, 3); got != "$-1\r\n" {
+		t.Errorf("GET in RESP3 subscribe mode = %q", got)
+	}
+	do(a, resp3, "PING")
+	if got := lastOutput(t, resp3, 4); got != "+PONG\r\n" {
+		t.Errorf("PING in RESP3 subscribe mode = %q", got)
+	}
+
+	do(a, conn, "PUBLISH", "ch", "hi")
+	if got := lastOutput(t, resp2, 4); got != "*3\r\n$7\r\nmessage\r\n$2\r\nch\r\n$2\r\nhi\r\n" {
+		t.Errorf("RESP2 message = %q", got)
+	}
+	if got := lastOutput(t, resp3, 5); got != ">3\r\n$7\r\nmessage\r\n$2\r\nch\r\n$2\r\nhi\r\n" {
+		t.Errorf("RESP3 message = %q", got)
+	}
+
+	do(a, resp2, "RESET")
+	if got := lastOutput(t, resp2, 5); got != "+RESET\r\n" {
+		t.Errorf("RESET = %q", got)
+	}
+	if a.subscribed(a.mustClient(t, resp2)) {
+		t.Error("RESET kept subscriptions")
+	}
+	do(a, resp2, "QUIT")
+	if got := lastOutput(t, resp2, 6); got != "+OK\r\n" {
+		t.Errorf("QUIT = %q", got)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !resp2.closed.Load() {
+		t.Error("QUIT did not close the connection")
+	}
+}
+
+func (a *App) mustClient(t *testing.T, conn *fakeConn) *client {
+	t.Helper()
+	v, ok := a.clients.Load(conn.Cid())
+	if !ok {
+		t.Fatalf("client %d not found", conn.Cid())
+	}
+	return v.(*client)
+}
+
+func TestHello(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"HELLO", "4"}, "-NOPROTO unsupported protocol version\r\n"},
+		{[]string{"HELLO", "x"}, "-ERR Protocol version is not an integer or out of range\r\n"},
+		{[]string{"HELLO", "3", "SETNAME"}, "-ERR Syntax error in HELLO option 'SETNAME'\r\n"},
+		{[]string{"HELLO", "2", "SETNAME", "worker"}, "*14\r\n$6\r\nserver\r\n$9\r\nliteredis\r\n"},
+	}
+	for _, tt := range tests {
+		if got := do(a, conn, tt.args...); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("%v = %q, want prefix %q", tt.args, got, tt.want)
+		}
+	}
+	if c := a.mustClient(t, conn); c.name != "worker" || c.protoVersion() != 2 {
+		t.Errorf("name = %q, proto = %d", c.name, c.protoVersion())
+	}
+
+	do(a, conn, "CONFIG", "SET", "requirepass", "secret")
+	other := newFakeConn()
+	a.handleConnect(other)
+	if got := do(a, other, "HELLO", "3"); !strings.HasPrefix(got, "-NOAUTH") {
+		t.Errorf("HELLO without auth = %q", got)
+	}
+	if got := do(a, other, "HELLO", "3", "AUTH", "default", "secret"); !strings.HasPrefix(got, "%7\r\n") {
+		t.Errorf("HELLO with AUTH = %q", got)
+	}
+}
+
+func TestShardedPubSub(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	sub, conn := newFakeConn(), newFakeConn()
+	a.handleConnect(sub)
+	a.handleConnect(conn)
+
+	do(a, sub, "SSUBSCRIBE", "{user}a", "{user}b")
+	do(a, sub, "SUBSCRIBE", "{user}a")
+	if got := lastOutput(t, sub, 3); got != "*3\r\n$9\r\nsubscribe\r\n$7\r\n{user}a\r\n:1\r\n" {
+		t.Errorf("SUBSCRIBE count should not include shard channels: %q", got)
+	}
+	if got := do(a, conn, "SPUBLISH", "{user}a", "hi"); got != ":1\r\n" {
+		t.Errorf("SPUBLISH = %q", got)
+	}
+	if got := lastOutput(t, sub, 4); got != "*3\r\n$8\r\nsmessage\r\n$7\r\n{user}a\r\n$2\r\nhi\r\n" {
+		t.Errorf("smessage = %q", got)
+	}
+	if got := do(a, conn, "PUBSUB", "SHARDNUMSUB", "{user}b"); got != "*2\r\n$7\r\n{user}b\r\n:1\r\n" {
+		t.Errorf("PUBSUB SHARDNUMSUB = %q", got)
+	}
+	if got := do(a, conn, "PUBSUB", "SHARDCHANNELS"); got != "*2\r\n$7\r\n{user}a\r\n$7\r\n{user}b\r\n" {
+		t.Errorf("PUBSUB SHARDCHANNELS = %q", got)
+	}
+
+	a.cluster = cluster.NewCluster("local")
+	a.cluster.AddNode(&cluster.Node{ID: "local"})
+	if got := do(a, conn, "SSUBSCRIBE", "{a}1", "{b}2"); !strings.HasPrefix(got, "-CROSSSLOT") {
+		t.Errorf("SSUBSCRIBE across slots = %q", got)
+	}
+	if got := do(a, conn, "SPUBLISH", "{user}b", "x"); got != ":1\r\n" {
+		t.Errorf("SPUBLISH in cluster mode = %q", got)
+	}
+}
+
+func TestOutputBufferLimitConfig(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	if got := do(a, conn, "CONFIG", "SET", "client-output-buffer-limit", "pubsub 1mb 512kb 10"); got != "+OK\r\n" {
+		t.Fatalf("CONFIG SET = %q", got)
+	}
+	want := "normal 0 0 0 slave 268435456 67108864 60 pubsub 1048576 524288 10"
+	if got := do(a, conn, "CONFIG", "GET", "client-output-buffer-limit"); !strings.Contains(got, want) {
+		t.Errorf("CONFIG GET = %q, want %q", got, want)
+	}
+	for _, bad := range []string{"pubsub 1mb 512kb", "bogus 0 0 0", "pubsub x 0 0", "pubsub 0 0 -1"} {
+		if got := do(a, conn, "CONFIG", "SET", "client-output-buffer-limit", bad); !strings.HasPrefix(got, "-ERR") {
+			t.Errorf("CONFIG SET %q = %q", bad, got)
+		}
+	}
+}
+
+func TestBufferLimitExceeded(t *testing.T) {
+	now := time.Now()
+	var since time.Time
+	l := bufferLimit{hard: 100, soft: 50, softSeconds: 1}
+	if l.exceeded(60, &since, now) || since.IsZero() {
+		t.Fatal("soft limit should start the timer")
+	}
+	if l.exceeded(60, &since, now.Add(time.Second)) {
+		t.Error("soft limit exceeded before soft seconds passed")
+	}
+	if !l.exceeded(60, &since, now.Add(1100*time.Millisecond)) {
+		t.Error("soft limit not enforced")
+	}
+	if l.exceeded(10, &since, now.Add(2*time.Second)) || !since.IsZero() {
+		t.Error("dropping below the soft limit should reset the timer")
+	}
+	if !l.exceeded(100, &since, now) {
+		t.Error("hard limit not enforced")
+	}
+	if (bufferLimit{}).exceeded(1<<40, &since, now) {
+		t.Error("zero limit should be unlimited")
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	sub := &blockingConn{fakeConn: newFakeConn(), release: make(chan struct{})}
+	defer close(sub.release)
+	conn := newFakeConn()
+	a.handleConnect(sub)
+	a.handleConnect(conn)
+	do(a, conn, "CONFIG", "SET", "client-output-buffer-limit", "pubsub 4kb 0 0")
+
+	a.handleReceive(sub, encodeCommand("SUBSCRIBE", "ch"))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			a.publish("ch", strings.Repeat("x", 100))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow subscriber blocked publishing")
+	}
+	if !sub.closed.Load() {
+		t.Error("slow subscriber was not closed")
+	}
+	if got := do(a, conn, "INFO", "stats"); !strings.Contains(got, "client_output_buffer_limit_disconnections:") ||
+		strings.Contains(got, "client_output_buffer_limit_disconnections:0") {
+		t.Errorf("disconnection not counted: %q", got)
+	}
+}
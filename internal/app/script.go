@@ -0,0 +1,582 @@
+package app
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+
+	"literedis/internal/commands"
+	"literedis/pkg/log"
+	"literedis/pkg/protocol"
+)
+
+// noScriptCommands 不能在脚本中通过 redis.call 执行的命令
+var noScriptCommands = map[string]bool{
+	"MULTI": true, "EXEC": true, "DISCARD": true, "WATCH": true, "UNWATCH": true,
+	"EVAL": true, "EVALSHA": true, "EVAL_RO": true, "EVALSHA_RO": true, "SCRIPT": true,
+	"FCALL": true, "FCALL_RO": true, "FUNCTION": true,
+	"SUBSCRIBE": true, "PSUBSCRIBE": true, "SSUBSCRIBE": true,
+	"UNSUBSCRIBE": true, "PUNSUBSCRIBE": true, "SUNSUBSCRIBE": true,
+	"MONITOR": true, "AUTH": true, "HELLO": true, "RESET": true, "QUIT": true,
+}
+
+var (
+	errBusyScript   = errors.New("BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE.")
+	errNoScript     = errors.New("NOSCRIPT No matching script. Please use EVAL.")
+	errNotBusy      = errors.New("NOTBUSY No scripts in execution right now.")
+	errUnkillable   = errors.New("UNKILLABLE Sorry the script already executed write commands against the dataset. You can either wait the script termination or kill the server in a hard way using the SHUTDOWN NOSAVE command.")
+	errScriptKilled = errors.New("ERR Script killed by user with SCRIPT KILL...")
+)
+
+// scripting 脚本缓存和解释器。解释器只有一个，脚本在 execMu 的写锁下执行，
+// 因此对其它客户端是原子的
+type scripting struct {
+	mu    sync.Mutex
+	cache map[string]*lua.FunctionProto // SHA1 -> 编译之后的脚本
+
+	L       *lua.LState
+	running atomic.Pointer[runningScript]
+
+	busyThreshold atomic.Int64 // 毫秒，脚本执行超过该时间之后其它命令返回 BUSY
+}
+
+// callScope 脚本和函数中执行命令的范围：调用者的连接、声明的键和是否只读
+type callScope struct {
+	c        *client
+	keys     map[string]bool // 声明的键，只能访问这些键
+	readOnly bool
+	wrote    atomic.Bool // 执行过写命令
+}
+
+func newCallScope(c *client, keys []string, readOnly bool) *callScope {
+	sc := &callScope{c: c, readOnly: readOnly, keys: make(map[string]bool, len(keys))}
+	for _, key := range keys {
+		sc.keys[key] = true
+	}
+	return sc
+}
+
+// runningScript 正在执行的脚本，执行过写命令之后不能再被 SCRIPT KILL 中断
+type runningScript struct {
+	*callScope
+	sha    string
+	start  time.Time
+	cancel context.CancelFunc
+	killed atomic.Bool
+}
+
+func newScripting(a *App, busyThreshold int64) *scripting {
+	s := &scripting{cache: make(map[string]*lua.FunctionProto)}
+	s.busyThreshold.Store(busyThreshold)
+	s.L = newScriptState(a)
+	return s
+}
+
+// newScriptState 创建沙箱化的解释器：只加载 base、table、string 和 math，
+// 移除访问文件系统的函数，全局变量只读
+func newScriptState(a *App) *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	// 去掉访问文件系统、写标准输出以及可以绕过全局变量保护的函数
+	for _, name := range []string{
+		"dofile", "loadfile", "load", "loadstring", "require", "module", "_printregs", "print",
+		"getmetatable", "setmetatable", "rawset", "getfenv", "setfenv",
+	} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	redis := L.NewTable()
+	L.SetFuncs(redis, map[string]lua.LGFunction{
+		"call":         func(L *lua.LState) int { return a.scriptCall(L, true) },
+		"pcall":        func(L *lua.LState) int { return a.scriptCall(L, false) },
+		"error_reply":  func(L *lua.LState) int { return statusTable(L, "err", L.CheckString(1)) },
+		"status_reply": func(L *lua.LState) int { return statusTable(L, "ok", L.CheckString(1)) },
+		"sha1hex": func(L *lua.LState) int {
+			L.Push(lua.LString(sha1Hex(L.CheckString(1))))
+			return 1
+		},
+		"log": a.scriptLog,
+	})
+	for i, name := range []string{"LOG_DEBUG", "LOG_VERBOSE", "LOG_NOTICE", "LOG_WARNING"} {
+		redis.RawSetString(name, lua.LNumber(i))
+	}
+	L.SetGlobal("redis", redis)
+
+	// 状态在脚本之间共享，库和全局变量都改成只读，避免一个脚本的修改影响后面的脚本。
+	// 全局变量移到 globals 中，_G 本身保持为空，读写都经过元方法
+	globals := L.NewTable()
+	var names []lua.LValue
+	L.G.Global.ForEach(func(k, v lua.LValue) {
+		globals.RawSet(k, v)
+		names = append(names, k)
+	})
+	for _, k := range names {
+		L.G.Global.RawSet(k, lua.LNil)
+	}
+	for _, name := range []string{"redis", lua.TabLibName, lua.StringLibName, lua.MathLibName} {
+		globals.RawSetString(name, readOnlyTable(L, globals.RawGetString(name).(*lua.LTable)))
+	}
+	globals.RawSetString("_G", L.G.Global)
+
+	mt := L.NewTable()
+	mt.RawSetString("__newindex", L.NewFunction(func(L *lua.LState) int {
+		if globals.RawGet(L.CheckAny(2)) != lua.LNil {
+			L.RaiseError("Attempt to modify a readonly table")
+		}
+		L.RaiseError("Script attempted to create global variable '%s'", L.CheckAny(2).String())
+		return 0
+	}))
+	mt.RawSetString("__index", L.NewFunction(func(L *lua.LState) int {
+		v := globals.RawGet(L.CheckAny(2))
+		if v == lua.LNil {
+			L.RaiseError("Script attempted to access nonexistent global variable '%s'", L.CheckAny(2).String())
+		}
+		L.Push(v)
+		return 1
+	}))
+	L.SetMetatable(L.G.Global, mt)
+	return L
+}
+
+// readOnlyTable 返回 t 的只读代理：读取转发给 t，写入报错
+func readOnlyTable(L *lua.LState, t *lua.LTable) *lua.LTable {
+	mt := L.NewTable()
+	mt.RawSetString("__index", t)
+	mt.RawSetString("__newindex", L.NewFunction(func(L *lua.LState) int {
+		L.RaiseError("Attempt to modify a readonly table")
+		return 0
+	}))
+	proxy := L.NewTable()
+	L.SetMetatable(proxy, mt)
+	return proxy
+}
+
+func statusTable(L *lua.LState, field, msg string) int {
+	t := L.NewTable()
+	t.RawSetString(field, lua.LString(msg))
+	L.Push(t)
+	return 1
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// load 编译脚本并加入缓存，返回 SHA1
+func (s *scripting) load(src string) (string, *lua.FunctionProto, error) {
+	sha := sha1Hex(src)
+	s.mu.Lock()
+	proto, ok := s.cache[sha]
+	s.mu.Unlock()
+	if ok {
+		return sha, proto, nil
+	}
+	chunk, err := parse.Parse(strings.NewReader(src), "user_script")
+	if err != nil {
+		return "", nil, fmt.Errorf("Error compiling script (new function): %v", err)
+	}
+	proto, err = lua.Compile(chunk, "user_script")
+	if err != nil {
+		return "", nil, fmt.Errorf("Error compiling script (new function): %v", err)
+	}
+	s.mu.Lock()
+	s.cache[sha] = proto
+	s.mu.Unlock()
+	return sha, proto, nil
+}
+
+func (s *scripting) lookup(sha string) *lua.FunctionProto {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cache[strings.ToLower(sha)]
+}
+
+// busy 脚本执行时间超过 busy-reply-threshold
+func (s *scripting) busy() bool {
+	rs := s.running.Load()
+	return rs != nil && time.Since(rs.start) >= time.Duration(s.busyThreshold.Load())*time.Millisecond
+}
+
+// busyReply 脚本执行超时期间，SCRIPT KILL 直接执行，其它命令返回 BUSY
+func (a *App) busyReply(c *client, msg *protocol.Message) *protocol.Message {
+	if parts, ok := msg.Content.([]*protocol.Message); ok && len(parts) == 2 {
+		name, _ := parts[0].Content.([]byte)
+		sub, _ := parts[1].Content.([]byte)
+		if strings.EqualFold(string(name), "SCRIPT") && strings.EqualFold(string(sub), "KILL") {
+			return a.call(c, msg)
+		}
+	}
+	reply := errorReply(errBusyScript)
+	a.stats.recordError(reply.Content.(string))
+	return reply
+}
+
+// handleEval EVAL script numkeys [key ...] [arg ...]
+func (a *App) handleEval(c *client, args []string) (*protocol.Message, error) {
+	return a.eval(c, args, false, false)
+}
+
+func (a *App) handleEvalSha(c *client, args []string) (*protocol.Message, error) {
+	return a.eval(c, args, true, false)
+}
+
+func (a *App) handleEvalRO(c *client, args []string) (*protocol.Message, error) {
+	return a.eval(c, args, false, true)
+}
+
+func (a *App) handleEvalShaRO(c *client, args []string) (*protocol.Message, error) {
+	return a.eval(c, args, true, true)
+}
+
+func (a *App) eval(c *client, args []string, bySha, readOnly bool) (*protocol.Message, error) {
+	keys, argv, err := parseNumKeys(args[1], args[2:])
+	if err != nil {
+		return nil, err
+	}
+	var sha string
+	var proto *lua.FunctionProto
+	if bySha {
+		sha = strings.ToLower(args[0])
+		if proto = a.scripts.lookup(sha); proto == nil {
+			return nil, errNoScript
+		}
+	} else if sha, proto, err = a.scripts.load(args[0]); err != nil {
+		return nil, err
+	}
+	return a.runScript(c, sha, proto, keys, argv, readOnly)
+}
+
+// parseNumKeys 拆分 numkeys 之后的键和参数
+func parseNumKeys(numkeys string, rest []string) (keys, argv []string, err error) {
+	n, err := strconv.Atoi(numkeys)
+	if err != nil {
+		return nil, nil, errors.New("value is not an integer or out of range")
+	}
+	if n < 0 {
+		return nil, nil, errors.New("Number of keys can't be negative")
+	}
+	if n > len(rest) {
+		return nil, nil, errors.New("Number of keys can't be greater than number of args")
+	}
+	return rest[:n], rest[n:], nil
+}
+
+// runScript 执行脚本。调用者已经持有 execMu 的写锁（EVAL）或者处于 EXEC 中
+func (a *App) runScript(c *client, sha string, proto *lua.FunctionProto, keys, argv []string, readOnly bool) (*protocol.Message, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rs := &runningScript{callScope: newCallScope(c, keys, readOnly), sha: sha, start: time.Now(), cancel: cancel}
+	a.scripts.running.Store(rs)
+	defer a.scripts.running.Store(nil)
+	// 脚本中的 SELECT 不影响连接
+	db := c.storage.SelectedDB()
+	defer c.storage.Select(db)
+
+	L := a.scripts.L
+	L.SetContext(ctx)
+	defer L.RemoveContext()
+	L.G.Global.RawSetString("KEYS", stringTable(L, keys))
+	L.G.Global.RawSetString("ARGV", stringTable(L, argv))
+	L.Push(L.NewFunctionFromProto(proto))
+	if err := L.PCall(0, 1, nil); err != nil {
+		if rs.killed.Load() {
+			return nil, errScriptKilled
+		}
+		if apiErr, ok := err.(*lua.ApiError); ok {
+			if t, ok := apiErr.Object.(*lua.LTable); ok {
+				if msg, ok := t.RawGetString("err").(lua.LString); ok {
+					return nil, scriptError(msg)
+				}
+			}
+			return nil, fmt.Errorf("%s script: %s", apiErr.Object.String(), sha)
+		}
+		return nil, err
+	}
+	ret := L.Get(-1)
+	L.Pop(1)
+	reply := luaToReply(L, ret)
+	if reply.Type == "Error" {
+		return nil, scriptError(reply.Content.(string))
+	}
+	return reply, nil
+}
+
+// scriptError 是脚本通过 error_reply 或 {err=...} 返回的错误。脚本可以使用任意错误码，
+// 以全大写单词开头时原样回复，否则由 errorReply 补上 ERR 前缀
+type scriptError string
+
+func (e scriptError) Error() string { return string(e) }
+
+// hasCode 判断错误是否以全大写的错误码开头，如 MYERR
+func (e scriptError) hasCode() bool {
+	code, _, found := strings.Cut(string(e), " ")
+	if !found || len(code) < 2 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func stringTable(L *lua.LState, items []string) *lua.LTable {
+	t := L.CreateTable(len(items), 0)
+	for _, s := range items {
+		t.Append(lua.LString(s))
+	}
+	return t
+}
+
+// scriptCall 实现 redis.call 和 redis.pcall，命令经过命令表、ACL 和声明的键检查之后执行
+func (a *App) scriptCall(L *lua.LState, raise bool) int {
+	rs := a.scripts.running.Load()
+	reply, err := a.scriptCommand(L, rs)
+	if err != nil {
+		t := L.NewTable()
+		t.RawSetString("err", lua.LString(errorReply(err).Content.(string)))
+		if raise {
+			L.Error(t, 1)
+		}
+		L.Push(t)
+		return 1
+	}
+	L.Push(replyToLua(L, reply))
+	return 1
+}
+
+func (a *App) scriptCommand(L *lua.LState, rs *runningScript) (*protocol.Message, error) {
+	n := L.GetTop()
+	if n == 0 {
+		return nil, errors.New("Please specify at least one argument for this redis lib call")
+	}
+	parts := make([]string, n)
+	for i := range parts {
+		switch v := L.Get(i + 1).(type) {
+		case lua.LString, lua.LNumber:
+			parts[i] = v.String()
+		default:
+			return nil, errors.New("Lua redis lib command arguments must be strings or integers")
+		}
+	}
+	return a.scopedCommand(rs.callScope, parts)
+}
+
+// scopedCommand 执行脚本或函数中的命令，经过命令表、ACL、声明的键和只读检查
+func (a *App) scopedCommand(sc *callScope, parts []string) (*protocol.Message, error) {
+	cmd, err := a.lookupCommand(parts)
+	if err != nil {
+		if a.handlers[strings.ToUpper(parts[0])] == nil && a.clientHandlers[strings.ToUpper(parts[0])] == nil {
+			return nil, errors.New("Unknown Redis command called from script")
+		}
+		return nil, err
+	}
+	if noScriptCommands[cmd.name] {
+		return nil, errors.New("This Redis command is not allowed from script")
+	}
+	write := cmd.spec.Flags&commands.FlagWrite != 0
+	if write && sc.readOnly {
+		return nil, errors.New("Write commands are not allowed from read-only scripts.")
+	}
+	for _, key := range cmd.spec.Keys(cmd.args) {
+		if !sc.keys[key] {
+			return nil, fmt.Errorf("Script attempted to access key '%s' which was not declared in KEYS", key)
+		}
+	}
+	if err := a.checkCommand(sc.c, cmd); err != nil {
+		cmd.stats.rejected.Add(1)
+		return nil, err
+	}
+	if write {
+		sc.wrote.Store(true)
+	}
+	a.feedMonitors(sc.c, strings.ToLower(cmd.name), cmd)
+	nested := sc.c.nested
+	sc.c.nested = true
+	reply, err := a.execCommand(sc.c, cmd)
+	sc.c.nested = nested
+	if err == nil && reply == nil {
+		reply = &protocol.Message{Type: "Null"}
+	}
+	return reply, err
+}
+
+// scriptLog redis.log(level, message...)
+func (a *App) scriptLog(L *lua.LState) int {
+	level := L.CheckInt(1)
+	parts := make([]string, 0, L.GetTop()-1)
+	for i := 2; i <= L.GetTop(); i++ {
+		parts = append(parts, L.Get(i).String())
+	}
+	msg := strings.Join(parts, " ")
+	lg := log.With(log.Pair("script", a.scripts.running.Load().sha))
+	switch level {
+	case 0, 1:
+		lg.Debugw(msg)
+	case 2:
+		lg.Infow(msg)
+	case 3:
+		lg.Warnw(msg)
+	default:
+		L.RaiseError("Invalid debug level.")
+	}
+	return 0
+}
+
+// replyToLua 命令回复转换为 Lua 值：整数为 number，空值为 false，状态和错误为带 ok、err 字段的 table
+func replyToLua(L *lua.LState, msg *protocol.Message) lua.LValue {
+	switch msg.Type {
+	case "Integer":
+		n, _ := strconv.ParseInt(fmt.Sprint(msg.Content), 10, 64)
+		return lua.LNumber(n)
+	case "SimpleString", "Error":
+		field := "ok"
+		if msg.Type == "Error" {
+			field = "err"
+		}
+		t := L.NewTable()
+		t.RawSetString(field, lua.LString(fmt.Sprint(msg.Content)))
+		return t
+	case "BulkString":
+		switch v := msg.Content.(type) {
+		case []byte:
+			if v != nil {
+				return lua.LString(v)
+			}
+		case string:
+			return lua.LString(v)
+		}
+		return lua.LFalse
+	case "Array", "Map", "Push":
+		items, ok := msg.Content.([]*protocol.Message)
+		if !ok {
+			switch v := msg.Content.(type) {
+			case []string:
+				items = make([]*protocol.Message, len(v))
+				for i, s := range v {
+					items[i] = bulk(s)
+				}
+			case [][]byte:
+				items = make([]*protocol.Message, len(v))
+				for i, b := range v {
+					items[i] = &protocol.Message{Type: "BulkString", Content: b}
+				}
+			}
+		}
+		if items == nil {
+			return lua.LFalse
+		}
+		t := L.CreateTable(len(items), 0)
+		for _, item := range items {
+			t.Append(replyToLua(L, item))
+		}
+		return t
+	}
+	return lua.LFalse
+}
+
+// luaToReply 脚本返回值转换为回复：number 截断为整数，true 为 1，false 和 nil 为空值，
+// table 按数组转换到第一个 nil 为止
+func luaToReply(L *lua.LState, v lua.LValue) *protocol.Message {
+	switch v := v.(type) {
+	case lua.LString:
+		return &protocol.Message{Type: "BulkString", Content: []byte(v)}
+	case lua.LNumber:
+		return integer(int64(v))
+	case lua.LBool:
+		if v {
+			return integer(1)
+		}
+	case *lua.LTable:
+		if msg, ok := v.RawGetString("err").(lua.LString); ok {
+			return &protocol.Message{Type: "Error", Content: string(msg)}
+		}
+		if msg, ok := v.RawGetString("ok").(lua.LString); ok {
+			return &protocol.Message{Type: "SimpleString", Content: string(msg)}
+		}
+		var items []*protocol.Message
+		for i := 1; ; i++ {
+			item := v.RawGetInt(i)
+			if item == lua.LNil {
+				break
+			}
+			items = append(items, luaToReply(L, item))
+		}
+		return array(items...)
+	}
+	return &protocol.Message{Type: "Null"}
+}
+
+// handleScript SCRIPT LOAD|EXISTS|FLUSH|KILL
+func (a *App) handleScript(c *client, args []string) (*protocol.Message, error) {
+	switch sub := strings.ToUpper(args[0]); {
+	case sub == "LOAD" && len(args) == 2:
+		sha, _, err := a.scripts.load(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return bulk(sha), nil
+	case sub == "EXISTS" && len(args) >= 2:
+		items := make([]*protocol.Message, 0, len(args)-1)
+		for _, sha := range args[1:] {
+			if a.scripts.lookup(sha) != nil {
+				items = append(items, integer(1))
+			} else {
+				items = append(items, integer(0))
+			}
+		}
+		return array(items...), nil
+	case sub == "FLUSH" && len(args) <= 2:
+		if len(args) == 2 && !strings.EqualFold(args[1], "ASYNC") && !strings.EqualFold(args[1], "SYNC") {
+			return nil, errors.New("SCRIPT FLUSH only support SYNC|ASYNC option")
+		}
+		a.scripts.mu.Lock()
+		a.scripts.cache = make(map[string]*lua.FunctionProto)
+		a.scripts.mu.Unlock()
+		return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+	case sub == "KILL" && len(args) == 1:
+		rs := a.scripts.running.Load()
+		if rs == nil {
+			return nil, errNotBusy
+		}
+		if rs.wrote.Load() {
+			return nil, errUnkillable
+		}
+		rs.killed.Store(true)
+		rs.cancel()
+		return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+	case sub == "HELP":
+		return array(
+			bulk("SCRIPT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:"),
+			bulk("EXISTS <sha1> [<sha1> ...]"),
+			bulk("FLUSH [ASYNC|SYNC]"),
+			bulk("KILL"),
+			bulk("LOAD <script>"),
+		), nil
+	}
+	return nil, fmt.Errorf("unknown subcommand '%s'. Try SCRIPT HELP.", args[0])
+}
@@ -0,0 +1,175 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"EVAL", "return 1", "0"}, ":1\r\n"},
+		{[]string{"EVAL", "return 3.9", "0"}, ":3\r\n"},
+		{[]string{"EVAL", "return 'x'", "0"}, "$1\r\nx\r\n"},
+		{[]string{"EVAL", "return true", "0"}, ":1\r\n"},
+		{[]string{"EVAL", "return false", "0"}, "$-1\r\n"},
+		{[]string{"EVAL", "return nil", "0"}, "$-1\r\n"},
+		{[]string{"EVAL", "return {1, 'a', {2}, nil, 3}", "0"}, "*3\r\n:1\r\n$1\r\na\r\n*1\r\n:2\r\n"},
+		{[]string{"EVAL", "return redis.status_reply('FINE')", "0"}, "+FINE\r\n"},
+		{[]string{"EVAL", "return redis.error_reply('MYERR bad')", "0"}, "-MYERR bad\r\n"},
+		{[]string{"EVAL", "return {KEYS[1], KEYS[2], ARGV[1]}", "2", "a", "b", "c"}, "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		{[]string{"EVAL", "return redis.sha1hex('')", "0"}, "$40\r\nda39a3ee5e6b4b0d3255bfef95601890afd80709\r\n"},
+		{[]string{"EVAL", "return 1", "2", "a"}, "-ERR Number of keys can't be greater than number of args\r\n"},
+		{[]string{"EVAL", "return 1", "-1"}, "-ERR Number of keys can't be negative\r\n"},
+
+		{[]string{"EVAL", "return redis.call('SET', KEYS[1], ARGV[1])", "1", "k", "v"}, "+OK\r\n"},
+		{[]string{"EVAL", "return redis.call('GET', KEYS[1])", "1", "k"}, "$1\r\nv\r\n"},
+		{[]string{"EVAL", "return redis.call('GET', 'missing')", "1", "missing"}, "$-1\r\n"},
+		{[]string{"EVAL", "return redis.call('RPUSH', KEYS[1], 'a', 2)", "1", "l"}, ":2\r\n"},
+		{[]string{"EVAL", "return redis.call('LRANGE', KEYS[1], 0, -1)", "1", "l"}, "*2\r\n$1\r\na\r\n$1\r\n2\r\n"},
+		{[]string{"EVAL", "return redis.call('GET', 'k')", "0"},
+			"-ERR Script attempted to access key 'k' which was not declared in KEYS\r\n"},
+		{[]string{"EVAL", "return redis.call('GET')", "0"}, "-ERR wrong number of arguments for 'get' command\r\n"},
+		{[]string{"EVAL", "return redis.call('NOPE')", "0"}, "-ERR Unknown Redis command called from script\r\n"},
+		{[]string{"EVAL", "return redis.call('MULTI')", "0"}, "-ERR This Redis command is not allowed from script\r\n"},
+		{[]string{"EVAL", "local r = redis.pcall('GET') return r['err']", "0"},
+			"$47\r\nERR wrong number of arguments for 'get' command\r\n"},
+		{[]string{"EVAL_RO", "return redis.call('GET', KEYS[1])", "1", "k"}, "$1\r\nv\r\n"},
+		{[]string{"EVAL_RO", "return redis.call('SET', KEYS[1], 'x')", "1", "k"},
+			"-ERR Write commands are not allowed from read-only scripts.\r\n"},
+
+		// 沙箱
+		{[]string{"EVAL", "x = 1", "0"}, "-ERR user_script:1: Script attempted to create global variable 'x' script: "},
+		{[]string{"EVAL", "return os.time()", "0"}, "-ERR user_script:1: Script attempted to access nonexistent global variable 'os' script: "},
+		{[]string{"EVAL", "return loadfile", "0"}, "-ERR user_script:1: Script attempted to access nonexistent global variable 'loadfile' script: "},
+		{[]string{"EVAL", "rawset(_G, 'x', 1)", "0"}, "-ERR user_script:1: Script attempted to access nonexistent global variable 'rawset' script: "},
+		{[]string{"EVAL", "setmetatable(_G, nil) x = 1", "0"}, "-ERR user_script:1: Script attempted to access nonexistent global variable 'setmetatable' script: "},
+		{[]string{"EVAL", "setfenv(0, {})", "0"}, "-ERR user_script:1: Script attempted to access nonexistent global variable 'setfenv' script: "},
+		{[]string{"EVAL", "print('x')", "0"}, "-ERR user_script:1: Script attempted to access nonexistent global variable 'print' script: "},
+		{[]string{"EVAL", "return x", "0"}, "-ERR user_script:1: Script attempted to access nonexistent global variable 'x' script: "},
+		{[]string{"EVAL", "return string.upper('a') .. math.max(1, 2) .. table.concat({'b'})", "0"}, "$3\r\nA2b\r\n"},
+		{[]string{"EVAL", "return (", "0"}, "-ERR Error compiling script (new function): "},
+		{[]string{"EVAL", "redis.call = function() return 'x' end", "0"}, "-ERR user_script:1: Attempt to modify a readonly table script: "},
+		{[]string{"EVAL", "string.upper = nil", "0"}, "-ERR user_script:1: Attempt to modify a readonly table script: "},
+		{[]string{"EVAL", "redis = {call = function() return 'x' end}", "0"}, "-ERR user_script:1: Attempt to modify a readonly table script: "},
+		{[]string{"EVAL", "return redis.call('GET', KEYS[1]) .. ('a'):upper()", "1", "k"}, "$2\r\nvA\r\n"},
+	}
+
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			conn := newFakeConn()
+			a.handleConnect(conn)
+			for _, tt := range tests {
+				got := do(a, conn, tt.args...)
+				if got != tt.want && !(strings.HasSuffix(tt.want, ": ") && strings.HasPrefix(got, tt.want)) {
+					t.Errorf("%q = %q, want %q", tt.args, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestScriptCache(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+
+	sha := sha1Hex("return ARGV[1]")
+	if got := do(a, conn, "EVALSHA", sha, "0", "x"); !strings.HasPrefix(got, "-NOSCRIPT") {
+		t.Errorf("EVALSHA before LOAD = %q", got)
+	}
+	if got := do(a, conn, "SCRIPT", "LOAD", "return ARGV[1]"); got != "$40\r\n"+sha+"\r\n" {
+		t.Errorf("SCRIPT LOAD = %q", got)
+	}
+	if got := do(a, conn, "EVALSHA", strings.ToUpper(sha), "0", "x"); got != "$1\r\nx\r\n" {
+		t.Errorf("EVALSHA = %q", got)
+	}
+	do(a, conn, "EVAL", "return 2", "0")
+	if got := do(a, conn, "SCRIPT", "EXISTS", sha, sha1Hex("return 2"), "nope"); got != "*3\r\n:1\r\n:1\r\n:0\r\n" {
+		t.Errorf("SCRIPT EXISTS = %q", got)
+	}
+	if got := do(a, conn, "SCRIPT", "FLUSH"); got != "+OK\r\n" {
+		t.Errorf("SCRIPT FLUSH = %q", got)
+	}
+	if got := do(a, conn, "SCRIPT", "EXISTS", sha); got != "*1\r\n:0\r\n" {
+		t.Errorf("SCRIPT EXISTS after FLUSH = %q", got)
+	}
+	if got := do(a, conn, "SCRIPT", "KILL"); got != "-NOTBUSY No scripts in execution right now.\r\n" {
+		t.Errorf("SCRIPT KILL = %q", got)
+	}
+}
+
+func TestScriptKill(t *testing.T) {
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			conn, other := newFakeConn(), newFakeConn()
+			a.handleConnect(conn)
+			a.handleConnect(other)
+			do(a, other, "CONFIG", "SET", "busy-reply-threshold", "10")
+
+			done := make(chan string)
+			go func() { done <- do(a, conn, "EVAL", "while true do end", "0") }()
+			deadline := time.Now().Add(5 * time.Second)
+			for !a.scripts.busy() {
+				if time.Now().After(deadline) {
+					t.Fatal("script never became busy")
+				}
+				time.Sleep(time.Millisecond)
+			}
+			if got := do(a, other, "GET", "k"); !strings.HasPrefix(got, "-BUSY ") {
+				t.Errorf("GET during script = %q", got)
+			}
+			if got := do(a, other, "SCRIPT", "KILL"); got != "+OK\r\n" {
+				t.Errorf("SCRIPT KILL = %q", got)
+			}
+			select {
+			case got := <-done:
+				if got != "-ERR Script killed by user with SCRIPT KILL...\r\n" {
+					t.Errorf("killed EVAL = %q", got)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("script not killed")
+			}
+			if got := do(a, other, "GET", "k"); got != "$-1\r\n" {
+				t.Errorf("GET after kill = %q", got)
+			}
+		})
+	}
+}
+
+func TestScriptUnkillableAfterWrite(t *testing.T) {
+	a := newTestApp(t, ExecModeThreaded)
+	conn := newFakeConn()
+	a.handleConnect(conn)
+	rs := &runningScript{callScope: &callScope{}, start: time.Now(), cancel: func() {}}
+	rs.wrote.Store(true)
+	a.scripts.running.Store(rs)
+	defer a.scripts.running.Store(nil)
+	if got := do(a, conn, "SCRIPT", "KILL"); !strings.HasPrefix(got, "-UNKILLABLE ") {
+		t.Errorf("SCRIPT KILL = %q", got)
+	}
+	if rs.killed.Load() {
+		t.Error("script killed after writing")
+	}
+}
+
+func TestEvalInMulti(t *testing.T) {
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			conn := newFakeConn()
+			a.handleConnect(conn)
+			do(a, conn, "MULTI")
+			do(a, conn, "EVAL", "return redis.call('SET', KEYS[1], 'v')", "1", "k")
+			do(a, conn, "GET", "k")
+			if got := do(a, conn, "EXEC"); got != "*2\r\n+OK\r\n$1\r\nv\r\n" {
+				t.Errorf("EXEC = %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"literedis/pkg/protocol"
+)
+
+const (
+	slowlogMaxArgc   = 32  // 每条记录最多保存的参数个数，包含命令名
+	slowlogMaxArgLen = 128 // 每个参数最多保存的字节数
+)
+
+// slowlogEntry SLOWLOG GET 返回的一条记录
+type slowlogEntry struct {
+	id       int64
+	time     time.Time
+	duration time.Duration
+	args     []string // 包含命令名，已截断并隐藏敏感参数
+	addr     string
+	name     string
+}
+
+// slowLog 执行时间超过阈值的命令，保存在固定容量的环形缓冲区中
+type slowLog struct {
+	slowerThan atomic.Int64 // 微秒，负数表示关闭
+
+	mu      sync.Mutex
+	entries []slowlogEntry // 容量为 maxLen 的环形缓冲区
+	head    int            // 下一条记录写入的位置
+	size    int
+	nextID  int64
+}
+
+func newSlowLog(slowerThan, maxLen int) *slowLog {
+	l := &slowLog{entries: make([]slowlogEntry, maxLen)}
+	l.slowerThan.Store(int64(slowerThan))
+	return l
+}
+
+// record 命令执行时间超过阈值时写入一条记录
+func (l *slowLog) record(c *client, cmd *command, d time.Duration) {
+	threshold := l.slowerThan.Load()
+	if threshold < 0 || d < time.Duration(threshold)*time.Microsecond {
+		return
+	}
+	e := slowlogEntry{
+		time:     time.Now(),
+		duration: d,
+		args:     slowlogArgs(cmd.name, cmd.args),
+		addr:     c.conn.RemoteAddr(),
+		name:     c.name,
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.id = l.nextID
+	l.nextID++
+	if len(l.entries) == 0 {
+		return
+	}
+	l.entries[l.head] = e
+	l.head = (l.head + 1) % len(l.entries)
+	if l.size < len(l.entries) {
+		l.size++
+	}
+}
+
+// slowlogArgs 截断参数个数和长度，避免大命令占用过多内存
+func slowlogArgs(name string, args []string) []string {
+	argv := append([]string{name}, redactArgs(name, args)...)
+	if len(argv) > slowlogMaxArgc {
+		more := len(argv) - slowlogMaxArgc + 1
+		argv = append(argv[:slowlogMaxArgc-1], fmt.Sprintf("... (%d more arguments)", more))
+	}
+	for i, arg := range argv {
+		if len(arg) > slowlogMaxArgLen {
+			argv[i] = fmt.Sprintf("%s... (%d more bytes)", arg[:slowlogMaxArgLen], len(arg)-slowlogMaxArgLen)
+		}
+	}
+	return argv
+}
+
+// latest 返回最新的 count 条记录，最新的在前，count 小于 0 返回全部
+func (l *slowLog) latest(count int) []slowlogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if count < 0 || count > l.size {
+		count = l.size
+	}
+	entries := make([]slowlogEntry, count)
+	for i := range entries {
+		entries[i] = l.entries[(l.head-1-i+len(l.entries))%len(l.entries)]
+	}
+	return entries
+}
+
+func (l *slowLog) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.size
+}
+
+func (l *slowLog) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	clear(l.entries)
+	l.head, l.size = 0, 0
+}
+
+// setMaxLen 修改容量，保留最新的记录
+func (l *slowLog) setMaxLen(maxLen int) {
+	kept := l.latest(maxLen)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = make([]slowlogEntry, maxLen)
+	l.size = len(kept)
+	for i, e := range kept {
+		l.entries[len(kept)-1-i] = e
+	}
+	l.head = 0
+	if maxLen > 0 {
+		l.head = l.size % maxLen
+	}
+}
+
+func (l *slowLog) maxLen() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.entries)
+}
+
+// redactArgs 隐藏密码等敏感参数，用于 SLOWLOG 和 MONITOR
+func redactArgs(name string, args []string) []string {
+	const redacted = "(redacted)"
+	out := append([]string(nil), args...)
+	switch name {
+	case "AUTH":
+		for i := range out {
+			out[i] = redacted
+		}
+	case "ACL":
+		if len(out) > 2 && strings.EqualFold(out[0], "setuser") {
+			for i := 2; i < len(out); i++ {
+				if r := out[i]; r != "" && strings.ContainsRune("><#!", rune(r[0])) {
+					out[i] = redacted
+				}
+			}
+		}
+	case "CONFIG":
+		if len(out) > 0 && strings.EqualFold(out[0], "set") {
+			for i := 1; i+1 < len(out); i += 2 {
+				if strings.EqualFold(out[i], "requirepass") {
+					out[i+1] = redacted
+				}
+			}
+		}
+	}
+	return out
+}
+
+func (a *App) handleSlowlog(c *client, args []string) (*protocol.Message, error) {
+	sub := strings.ToLower(args[0])
+	switch sub {
+	case "get":
+		if len(args) > 2 {
+			return nil, fmt.Errorf("wrong number of arguments for 'slowlog|%s' command", sub)
+		}
+		count := 10
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < -1 {
+				return nil, fmt.Errorf("count should be greater than or equal to -1")
+			}
+			count = n
+		}
+		entries := a.slowlog.latest(count)
+		items := make([]*protocol.Message, len(entries))
+		for i, e := range entries {
+			argv := make([]*protocol.Message, len(e.args))
+			for j, arg := range e.args {
+				argv[j] = bulk(arg)
+			}
+			items[i] = &protocol.Message{Type: "Array", Content: []*protocol.Message{
+				integer(e.id),
+				integer(e.time.Unix()),
+				integer(e.duration.Microseconds()),
+				{Type: "Array", Content: argv},
+				bulk(e.addr),
+				bulk(e.name),
+			}}
+		}
+		return &protocol.Message{Type: "Array", Content: items}, nil
+	case "len":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("wrong number of arguments for 'slowlog|%s' command", sub)
+		}
+		return integer(int64(a.slowlog.len())), nil
+	case "reset":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("wrong number of arguments for 'slowlog|%s' command", sub)
+		}
+		a.slowlog.reset()
+		return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+	}
+	return nil, fmt.Errorf("unknown subcommand '%s'. Try SLOWLOG HELP.", args[0])
+}
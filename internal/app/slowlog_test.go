@@ -0,0 +1,114 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlowlog(t *testing.T) {
+	for _, mode := range execModes {
+		t.Run(mode.String(), func(t *testing.T) {
+			a := newTestApp(t, mode)
+			conn := newFakeConn()
+			a.handleConnect(conn)
+
+			do(a, conn, "CONFIG", "SET", "slowlog-log-slower-than", "0")
+			do(a, conn, "SLOWLOG", "RESET")
+			do(a, conn, "SET", "k", strings.Repeat("v", 200))
+			do(a, conn, "AUTH", "secret")
+
+			// SLOWLOG RESET 本身也会被记录
+			if got := do(a, conn, "SLOWLOG", "LEN"); got != ":3\r\n" {
+				t.Fatalf("SLOWLOG LEN = %q", got)
+			}
+			// 中间又记录了 SLOWLOG LEN
+			got := do(a, conn, "SLOWLOG", "GET", "3")
+			if !strings.HasPrefix(got, "*3\r\n*6\r\n:4\r\n") {
+				t.Fatalf("SLOWLOG GET 3 = %q", got)
+			}
+			// 最新的记录在前
+			auth := strings.Index(got, "$4\r\nAUTH\r\n$10\r\n(redacted)\r\n")
+			set := strings.Index(got, "$3\r\nSET\r\n$1\r\nk\r\n$147\r\n"+strings.Repeat("v", 128)+"... (72 more bytes)\r\n")
+			if auth < 0 || set < 0 || auth > set {
+				t.Errorf("SLOWLOG GET 3 = %q", got)
+			}
+			if strings.Contains(got, "secret") {
+				t.Error("AUTH password leaked into slowlog")
+			}
+			if !strings.Contains(got, conn.RemoteAddr()+"\r\n$0\r\n\r\n") {
+				t.Errorf("client address missing: %q", got)
+			}
+
+			do(a, conn, "CONFIG", "SET", "slowlog-log-slower-than", "-1")
+			do(a, conn, "SLOWLOG", "RESET")
+			do(a, conn, "GET", "k")
+			if got := do(a, conn, "SLOWLOG", "LEN"); got != ":0\r\n" {
+				t.Errorf("disabled slowlog LEN = %q", got)
+			}
+			if got := do(a, conn, "SLOWLOG", "GET"); got != "*0\r\n" {
+				t.Errorf("empty SLOWLOG GET = %q", got)
+			}
+			if got := do(a, conn, "SLOWLOG", "GET", "-2"); !strings.HasPrefix(got, "-ERR count should be") {
+				t.Errorf("SLOWLOG GET -2 = %q", got)
+			}
+			if got := do(a, conn, "SLOWLOG", "NOPE"); !strings.HasPrefix(got, "-ERR unknown subcommand 'NOPE'") {
+				t.Errorf("SLOWLOG NOPE = %q", got)
+			}
+		})
+	}
+}
+
+func TestSlowlogRing(t *testing.T) {
+	l := newSlowLog(0, 3)
+	c := &client{conn: newFakeConn(), name: "worker"}
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		l.record(c, &command{name: "GET", args: []string{key}}, 0)
+	}
+	keys := func() string {
+		var s []string
+		for _, e := range l.latest(-1) {
+			s = append(s, e.args[1])
+		}
+		return strings.Join(s, "")
+	}
+	if got := keys(); got != "edc" {
+		t.Fatalf("entries = %s, want edc", got)
+	}
+	if e := l.latest(1)[0]; e.id != 4 || e.name != "worker" {
+		t.Errorf("latest entry = %+v", e)
+	}
+
+	l.setMaxLen(2)
+	if got := keys(); got != "ed" {
+		t.Errorf("after shrink = %s, want ed", got)
+	}
+	l.setMaxLen(4)
+	l.record(c, &command{name: "GET", args: []string{"f"}}, 0)
+	if got := keys(); got != "fed" {
+		t.Errorf("after grow = %s, want fed", got)
+	}
+	l.setMaxLen(0)
+	l.record(c, &command{name: "GET", args: []string{"g"}}, 0)
+	if l.len() != 0 {
+		t.Errorf("len with max 0 = %d", l.len())
+	}
+}
+
+func TestSlowlogArgs(t *testing.T) {
+	args := make([]string, 40)
+	for i := range args {
+		args[i] = "x"
+	}
+	argv := slowlogArgs("DEL", args)
+	if len(argv) != slowlogMaxArgc || argv[len(argv)-1] != "... (10 more arguments)" {
+		t.Errorf("argv = %v", argv)
+	}
+	argv = slowlogArgs("CONFIG", []string{"SET", "requirepass", "pw", "maxclients", "10"})
+	if strings.Join(argv, " ") != "CONFIG SET requirepass (redacted) maxclients 10" {
+		t.Errorf("argv = %v", argv)
+	}
+	argv = slowlogArgs("ACL", []string{"SETUSER", "alice", "on", ">pw", "~*"})
+	if strings.Join(argv, " ") != "ACL SETUSER alice on (redacted) ~*" {
+		t.Errorf("argv = %v", argv)
+	}
+}
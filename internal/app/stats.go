@@ -0,0 +1,175 @@
+package app
+
+import (
+	"math/bits"
+	"runtime/metrics"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"literedis/pkg/protocol"
+)
+
+// serverStats 服务器运行统计，由命令分发时维护，CONFIG RESETSTAT 时清零
+type serverStats struct {
+	connectionsReceived atomic.Int64
+	rejectedConnections atomic.Int64
+	commandsProcessed   atomic.Int64
+	netInputBytes       atomic.Int64
+	netOutputBytes      atomic.Int64
+	keyspaceHits        atomic.Int64
+	keyspaceMisses      atomic.Int64
+	errorReplies        atomic.Int64
+
+	outputLimitDisconnections atomic.Int64 // 因超过输出缓冲区限制被断开的连接数
+
+	opsPerSec  atomic.Int64 // 最近一秒处理的命令数
+	lastOps    int64        // 上一次采样时的 commandsProcessed，只由 clientsCron 访问
+	peakMemory atomic.Int64
+
+	errorsMu sync.Mutex
+	errors   map[string]int64 // 错误码 -> 次数
+}
+
+func (s *serverStats) reset() {
+	s.connectionsReceived.Store(0)
+	s.rejectedConnections.Store(0)
+	s.commandsProcessed.Store(0)
+	s.netInputBytes.Store(0)
+	s.netOutputBytes.Store(0)
+	s.keyspaceHits.Store(0)
+	s.keyspaceMisses.Store(0)
+	s.errorReplies.Store(0)
+	s.outputLimitDisconnections.Store(0)
+	s.peakMemory.Store(0)
+
+	s.errorsMu.Lock()
+	s.errors = nil
+	s.errorsMu.Unlock()
+}
+
+// recordError 按错误码统计错误回复，如 ERR、WRONGTYPE
+func (s *serverStats) recordError(msg string) {
+	s.errorReplies.Add(1)
+	code, _, _ := strings.Cut(msg, " ")
+	s.errorsMu.Lock()
+	if s.errors == nil {
+		s.errors = make(map[string]int64)
+	}
+	s.errors[code]++
+	s.errorsMu.Unlock()
+}
+
+func (s *serverStats) errorCounts() map[string]int64 {
+	s.errorsMu.Lock()
+	defer s.errorsMu.Unlock()
+	counts := make(map[string]int64, len(s.errors))
+	for code, n := range s.errors {
+		counts[code] = n
+	}
+	return counts
+}
+
+// recordLookup 根据只读命令的回复统计键空间命中，空回复视为未命中
+func (s *serverStats) recordLookup(reply *protocol.Message) {
+	if reply == nil || reply.Type == "Null" || (reply.Type == "BulkString" && reply.Content == nil) {
+		s.keyspaceMisses.Add(1)
+		return
+	}
+	s.keyspaceHits.Add(1)
+}
+
+// sample 每秒调用一次，计算每秒命令数并记录内存峰值
+func (s *serverStats) sample(usedMemory int64) {
+	ops := s.commandsProcessed.Load()
+	s.opsPerSec.Store(max(ops-s.lastOps, 0))
+	s.lastOps = ops
+	for {
+		peak := s.peakMemory.Load()
+		if usedMemory <= peak || s.peakMemory.CompareAndSwap(peak, usedMemory) {
+			return
+		}
+	}
+}
+
+// histogramBuckets 命令耗时直方图的桶数，第 i 个桶统计耗时在 (2^(i-1), 2^i] 微秒之间的调用
+const histogramBuckets = 40
+
+// commandStats 单个命令的统计
+type commandStats struct {
+	calls    atomic.Int64
+	usec     atomic.Int64
+	rejected atomic.Int64 // 执行之前被拒绝，如参数个数错误、没有权限
+	failed   atomic.Int64 // 执行时返回错误
+	hist     [histogramBuckets]atomic.Int64
+}
+
+func (s *commandStats) record(d time.Duration, err error) {
+	usec := d.Microseconds()
+	s.calls.Add(1)
+	s.usec.Add(usec)
+	if err != nil {
+		s.failed.Add(1)
+	}
+	s.hist[min(bits.Len64(uint64(max(usec, 1)-1)), histogramBuckets-1)].Add(1)
+}
+
+func (s *commandStats) reset() {
+	s.calls.Store(0)
+	s.usec.Store(0)
+	s.rejected.Store(0)
+	s.failed.Store(0)
+	for i := range s.hist {
+		s.hist[i].Store(0)
+	}
+}
+
+// histogram 返回累计的耗时分布，从第一个非空桶到最后一个非空桶，bounds 为每个桶的上界（微秒）
+func (s *commandStats) histogram() (bounds, counts []int64) {
+	first, last := -1, -1
+	var hist [histogramBuckets]int64
+	for i := range s.hist {
+		if hist[i] = s.hist[i].Load(); hist[i] > 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return nil, nil
+	}
+	var total int64
+	for i := 0; i <= last; i++ {
+		total += hist[i]
+		if i >= first {
+			bounds = append(bounds, 1<<i)
+			counts = append(counts, total)
+		}
+	}
+	return bounds, counts
+}
+
+// memoryRefreshInterval 内存占用的缓存时间，避免每条写命令都读取运行时统计
+const memoryRefreshInterval = 100 * time.Millisecond
+
+// memoryUsage 缓存的堆内存占用
+type memoryUsage struct {
+	used    atomic.Int64
+	updated atomic.Int64 // UnixNano
+}
+
+// load 返回堆上对象占用的字节数，超过刷新间隔时重新读取
+func (m *memoryUsage) load() int64 {
+	now := time.Now().UnixNano()
+	if now-m.updated.Load() < int64(memoryRefreshInterval) {
+		return m.used.Load()
+	}
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	used := int64(sample[0].Value.Uint64())
+	m.used.Store(used)
+	m.updated.Store(now)
+	return used
+}
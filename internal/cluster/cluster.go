@@ -72,11 +72,9 @@ func (c *Cluster) RemoveNode(nodeID string) error {
 	return nil
 }
 
+// GetNodeForKey 按键所属的哈希槽选择节点，同一个槽的键和分片频道总在同一节点
 func (c *Cluster) GetNodeForKey(key string) *Node {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	nodeID := c.hash.Get(key)
-	return c.nodes[nodeID]
+	return c.GetNodeForSlot(KeySlot(key))
 }
 
 func (c *Cluster) IsLocalNode(nodeID string) bool {
@@ -0,0 +1,43 @@
+package cluster
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SlotCount 哈希槽数量，与 Redis Cluster 相同
+const SlotCount = 16384
+
+// KeySlot 返回键所属的哈希槽。键中包含非空的 {hashtag} 时只对 hashtag 计算，
+// 使相关的键和分片频道落在同一个槽
+func KeySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % SlotCount)
+}
+
+// crc16 CRC16-CCITT (XMODEM)
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// GetNodeForSlot 返回负责哈希槽的节点
+func (c *Cluster) GetNodeForSlot(slot int) *Node {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.nodes[c.hash.Get(strconv.Itoa(slot))]
+}
@@ -0,0 +1,23 @@
+package cluster
+
+import "testing"
+
+func TestKeySlot(t *testing.T) {
+	tests := map[string]int{
+		"":                0,
+		"foo":             12182,
+		"123456789":       12739,
+		"{user1000}.a":    KeySlot("user1000"),
+		"foo{}{bar}":      KeySlot("foo{}{bar}"),
+		"foo{{bar}}zap":   KeySlot("{bar"),
+		"{user1000}.b{x}": KeySlot("user1000"),
+	}
+	for key, want := range tests {
+		if got := KeySlot(key); got != want {
+			t.Errorf("KeySlot(%q) = %d, want %d", key, got, want)
+		}
+	}
+	if KeySlot("foo{}{bar}") == KeySlot("bar") {
+		t.Error("empty hashtag should hash the whole key")
+	}
+}
@@ -0,0 +1,309 @@
+package commands
+
+import (
+	"errors"
+	"literedis/internal/consts"
+	"literedis/internal/datastruct/dsstring"
+	"literedis/internal/storage"
+	"literedis/pkg/protocol"
+	"strconv"
+	"strings"
+)
+
+// maxBitOffset 字符串最大 512MB，最后一位的位置
+const maxBitOffset = 1<<32 - 1
+
+var (
+	errBitOffset    = errors.New("bit offset is not an integer or out of range")
+	errBitValue     = errors.New("bit is not an integer or out of range")
+	errBitFieldType = errors.New("Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+)
+
+func registerBitmapCommands() {
+	RegisterCommand("SETBIT", handleSetBit)
+	RegisterCommand("GETBIT", handleGetBit)
+	RegisterCommand("BITCOUNT", handleBitCount)
+	RegisterCommand("BITPOS", handleBitPos)
+	RegisterCommand("BITOP", handleBitOp)
+	RegisterCommand("BITFIELD", handleBitField)
+	RegisterCommand("BITFIELD_RO", handleBitFieldRO)
+}
+
+// parseBitOffset 解析位偏移，hash 为 true 时参数形如 #N，表示第 N 个 bits 位宽的字段
+func parseBitOffset(arg string, hash bool, bits int) (uint64, error) {
+	if hash {
+		arg = arg[1:]
+	}
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errBitOffset
+	}
+	if hash {
+		if n > maxBitOffset/int64(bits) {
+			return 0, errBitOffset
+		}
+		n *= int64(bits)
+	}
+	if n > maxBitOffset {
+		return 0, errBitOffset
+	}
+	return uint64(n), nil
+}
+
+func handleSetBit(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 3 {
+		return nil, consts.ErrInvalidArgument
+	}
+	offset, err := parseBitOffset(args[1], false, 1)
+	if err != nil {
+		return nil, err
+	}
+	if args[2] != "0" && args[2] != "1" {
+		return nil, errBitValue
+	}
+	old, err := s.SetBit(args[0], offset, int(args[2][0]-'0'))
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "Integer", Content: int64(old)}, nil
+}
+
+func handleGetBit(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 2 {
+		return nil, consts.ErrInvalidArgument
+	}
+	offset, err := parseBitOffset(args[1], false, 1)
+	if err != nil {
+		return nil, err
+	}
+	bit, err := s.GetBit(args[0], offset)
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "Integer", Content: int64(bit)}, nil
+}
+
+// getBitmap 返回字符串的值，键不存在时返回 nil。
+// 空字符串返回空切片，和不存在的键区分开，BITPOS 对两者的回复不同
+func getBitmap(s storage.Storage, key string) ([]byte, error) {
+	value, err := s.GetString(key)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, nil
+	}
+	return value, err
+}
+
+// parseBitRange 解析 start end [BYTE|BIT]，返回是否以位为单位
+func parseBitRange(args []string) (start, end int64, bitMode bool, err error) {
+	if start, err = strconv.ParseInt(args[0], 10, 64); err != nil {
+		return 0, 0, false, consts.ErrNotInteger
+	}
+	if end, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+		return 0, 0, false, consts.ErrNotInteger
+	}
+	if len(args) == 3 {
+		switch strings.ToUpper(args[2]) {
+		case "BYTE":
+		case "BIT":
+			bitMode = true
+		default:
+			return 0, 0, false, consts.ErrSyntaxError
+		}
+	}
+	return start, end, bitMode, nil
+}
+
+// handleBitCount BITCOUNT key [start end [BYTE|BIT]]
+func handleBitCount(s storage.Storage, args []string) (*protocol.Message, error) {
+	start, end, bitMode := int64(0), int64(-1), false
+	switch len(args) {
+	case 1:
+	case 3, 4:
+		var err error
+		if start, end, bitMode, err = parseBitRange(args[1:]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, consts.ErrSyntaxError
+	}
+	value, err := getBitmap(s, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "Integer", Content: dsstring.BitCount(value, start, end, bitMode)}, nil
+}
+
+// handleBitPos BITPOS key bit [start [end [BYTE|BIT]]]
+func handleBitPos(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) < 2 || len(args) > 5 {
+		return nil, consts.ErrSyntaxError
+	}
+	bit, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, consts.ErrNotInteger
+	}
+	if bit != 0 && bit != 1 {
+		return nil, errors.New("The bit argument must be 1 or 0.")
+	}
+	start, end, endGiven, bitMode := int64(0), int64(-1), false, false
+	switch len(args) {
+	case 3:
+		if start, err = strconv.ParseInt(args[2], 10, 64); err != nil {
+			return nil, consts.ErrNotInteger
+		}
+	case 4, 5:
+		if start, end, bitMode, err = parseBitRange(args[2:]); err != nil {
+			return nil, err
+		}
+		endGiven = true
+	}
+
+	value, err := getBitmap(s, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		// 不存在的键视为全 0 的字符串
+		return &protocol.Message{Type: "Integer", Content: -bit}, nil
+	}
+	pos := dsstring.BitPos(value, int(bit), start, end, endGiven, bitMode)
+	return &protocol.Message{Type: "Integer", Content: pos}, nil
+}
+
+// handleBitOp BITOP AND|OR|XOR|NOT|DIFF|ONE destkey key [key ...]
+func handleBitOp(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) < 3 {
+		return nil, consts.ErrInvalidArgument
+	}
+	var op dsstring.BitOp
+	switch name := strings.ToUpper(args[0]); name {
+	case "AND":
+		op = dsstring.BitAnd
+	case "OR":
+		op = dsstring.BitOr
+	case "XOR":
+		op = dsstring.BitXor
+	case "NOT":
+		op = dsstring.BitNot
+	case "DIFF":
+		op = dsstring.BitDiff
+	case "ONE":
+		op = dsstring.BitOne
+	default:
+		return nil, consts.ErrSyntaxError
+	}
+	keys := args[2:]
+	if op == dsstring.BitNot && len(keys) != 1 {
+		return nil, errors.New("ERR BITOP NOT must be called with a single source key.")
+	}
+	if op == dsstring.BitDiff && len(keys) < 2 {
+		return nil, errors.New("ERR BITOP DIFF must be called with at least two source keys.")
+	}
+	n, err := s.BitOp(op, args[1], keys...)
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "Integer", Content: n}, nil
+}
+
+func handleBitField(s storage.Storage, args []string) (*protocol.Message, error) {
+	return bitField(s, args, false)
+}
+
+func handleBitFieldRO(s storage.Storage, args []string) (*protocol.Message, error) {
+	return bitField(s, args, true)
+}
+
+// bitField BITFIELD key [GET type offset] [SET type offset value]
+// [INCRBY type offset increment] [OVERFLOW WRAP|SAT|FAIL] ...
+func bitField(s storage.Storage, args []string, readOnly bool) (*protocol.Message, error) {
+	if len(args) == 0 {
+		return nil, consts.ErrInvalidArgument
+	}
+	var ops []dsstring.BitFieldOp
+	overflow := dsstring.OverflowWrap
+	for i := 1; i < len(args); {
+		sub := strings.ToUpper(args[i])
+		if sub == "OVERFLOW" && i+1 < len(args) {
+			switch strings.ToUpper(args[i+1]) {
+			case "WRAP":
+				overflow = dsstring.OverflowWrap
+			case "SAT":
+				overflow = dsstring.OverflowSat
+			case "FAIL":
+				overflow = dsstring.OverflowFail
+			default:
+				return nil, errors.New("Invalid OVERFLOW type specified")
+			}
+			i += 2
+			continue
+		}
+
+		op := dsstring.BitFieldOp{Overflow: overflow}
+		argc := 3
+		switch sub {
+		case "GET":
+			op.Opcode = dsstring.BitFieldGet
+		case "SET":
+			op.Opcode, argc = dsstring.BitFieldSet, 4
+		case "INCRBY":
+			op.Opcode, argc = dsstring.BitFieldIncrBy, 4
+		default:
+			return nil, consts.ErrSyntaxError
+		}
+		if i+argc > len(args) {
+			return nil, consts.ErrSyntaxError
+		}
+		var err error
+		if op.Signed, op.Bits, err = parseBitFieldType(args[i+1]); err != nil {
+			return nil, err
+		}
+		offset := args[i+2]
+		if op.Offset, err = parseBitOffset(offset, strings.HasPrefix(offset, "#"), op.Bits); err != nil {
+			return nil, err
+		}
+		if argc == 4 {
+			if op.Value, err = strconv.ParseInt(args[i+3], 10, 64); err != nil {
+				return nil, consts.ErrNotInteger
+			}
+		}
+		if readOnly && op.Opcode != dsstring.BitFieldGet {
+			return nil, errors.New("BITFIELD_RO only supports the GET subcommand")
+		}
+		ops = append(ops, op)
+		i += argc
+	}
+
+	results, err := s.BitField(args[0], ops)
+	if err != nil {
+		return nil, err
+	}
+	reply := make([]*protocol.Message, len(results))
+	for i, r := range results {
+		if r.Failed {
+			reply[i] = &protocol.Message{Type: "BulkString", Content: nil}
+		} else {
+			reply[i] = &protocol.Message{Type: "Integer", Content: r.Value}
+		}
+	}
+	return &protocol.Message{Type: "Array", Content: reply}, nil
+}
+
+// parseBitFieldType 解析 i1 到 i64、u1 到 u63
+func parseBitFieldType(arg string) (signed bool, bits int, err error) {
+	if len(arg) < 2 {
+		return false, 0, errBitFieldType
+	}
+	switch arg[0] {
+	case 'i', 'I':
+		signed = true
+	case 'u', 'U':
+	default:
+		return false, 0, errBitFieldType
+	}
+	bits, err = strconv.Atoi(arg[1:])
+	if err != nil || bits < 1 || (signed && bits > 64) || (!signed && bits > 63) {
+		return false, 0, errBitFieldType
+	}
+	return signed, bits, nil
+}
@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"literedis/internal/storage"
+	"literedis/pkg/protocol"
+	"testing"
+)
+
+// intReply 执行返回整数的命令
+func intReply(t *testing.T, handler CommandHandler, s storage.Storage, args ...string) int64 {
+	t.Helper()
+	msg, err := handler(s, args)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return msg.Content.(int64)
+}
+
+func TestHandleSetBitGetBit(t *testing.T) {
+	s := storage.NewMemoryStorage()
+
+	// 每天一个位图，用户 ID 作为偏移
+	for _, id := range []string{"5", "1000", "5"} {
+		handleSetBit(s, []string{"dau:2024-01-01", id, "1"})
+	}
+	if got := intReply(t, handleBitCount, s, "dau:2024-01-01"); got != 2 {
+		t.Errorf("BITCOUNT = %d, want 2", got)
+	}
+	if got := intReply(t, handleGetBit, s, "dau:2024-01-01", "1000"); got != 1 {
+		t.Errorf("GETBIT 1000 = %d", got)
+	}
+	if got := intReply(t, handleGetBit, s, "missing", "1000"); got != 0 {
+		t.Errorf("GETBIT on missing key = %d", got)
+	}
+	if n, _ := s.StrLen("dau:2024-01-01"); n != 126 {
+		t.Errorf("STRLEN = %d, want 126", n)
+	}
+
+	s.Set("n", []byte("1"))
+	if got := intReply(t, handleSetBit, s, "n", "6", "1"); got != 0 {
+		t.Errorf("SETBIT on a shared integer = %d", got)
+	}
+	if v, _ := s.Get("n"); string(v) != "3" {
+		t.Errorf("after SETBIT = %q, want 3", v)
+	}
+	s.Set("one", []byte("1"))
+	if v, _ := s.Get("one"); string(v) != "1" {
+		t.Error("SETBIT modified the shared integer")
+	}
+
+	for _, c := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"k", "-1", "1"}, "bit offset is not an integer or out of range"},
+		{[]string{"k", "4294967296", "1"}, "bit offset is not an integer or out of range"},
+		{[]string{"k", "1", "2"}, "bit is not an integer or out of range"},
+	} {
+		if _, err := handleSetBit(s, c.args); err == nil || err.Error() != c.err {
+			t.Errorf("SETBIT %v: %v", c.args, err)
+		}
+	}
+	s.LPush("l", []byte("x"))
+	if _, err := handleSetBit(s, []string{"l", "1", "1"}); err != storage.ErrWrongType {
+		t.Errorf("SETBIT on a list: %v", err)
+	}
+}
+
+func TestHandleBitCountBitPos(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	s.Set("k", []byte("foobar"))
+
+	cases := []struct {
+		args []string
+		want int64
+	}{
+		{[]string{"k"}, 26},
+		{[]string{"k", "1", "1"}, 6},
+		{[]string{"k", "5", "30", "BIT"}, 17},
+		{[]string{"k", "0", "0", "byte"}, 4},
+		{[]string{"missing"}, 0},
+	}
+	for _, c := range cases {
+		if got := intReply(t, handleBitCount, s, c.args...); got != c.want {
+			t.Errorf("BITCOUNT %v = %d, want %d", c.args, got, c.want)
+		}
+	}
+	for _, args := range [][]string{{"k", "1"}, {"k", "0", "1", "NIBBLE"}} {
+		if _, err := handleBitCount(s, args); err == nil {
+			t.Errorf("BITCOUNT %v should fail", args)
+		}
+	}
+
+	s.Set("b", []byte{0xff, 0xf0, 0x00})
+	posCases := []struct {
+		args []string
+		want int64
+	}{
+		{[]string{"b", "0"}, 12},
+		{[]string{"b", "1", "2"}, -1},
+		{[]string{"b", "1", "7", "15", "BIT"}, 7},
+		{[]string{"missing", "0"}, 0},
+		{[]string{"missing", "1"}, -1},
+		{[]string{"empty", "0"}, -1},
+		{[]string{"empty", "1"}, -1},
+	}
+	s.Set("empty", []byte{})
+	for _, c := range posCases {
+		if got := intReply(t, handleBitPos, s, c.args...); got != c.want {
+			t.Errorf("BITPOS %v = %d, want %d", c.args, got, c.want)
+		}
+	}
+	if _, err := handleBitPos(s, []string{"b", "2"}); err == nil || err.Error() != "The bit argument must be 1 or 0." {
+		t.Errorf("BITPOS with bit 2: %v", err)
+	}
+}
+
+func TestHandleBitOp(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	handleSetBit(s, []string{"mon", "1", "1"})
+	handleSetBit(s, []string{"mon", "2", "1"})
+	handleSetBit(s, []string{"tue", "2", "1"})
+	handleSetBit(s, []string{"tue", "20", "1"})
+
+	// 两天都活跃、任一天活跃、只在周一活跃、只在一天活跃
+	for op, want := range map[string]int64{"AND": 1, "OR": 3, "DIFF": 1, "ONE": 2} {
+		if n := intReply(t, handleBitOp, s, op, "dest", "mon", "tue"); n != 3 {
+			t.Errorf("BITOP %s length = %d, want 3", op, n)
+		}
+		if got := intReply(t, handleBitCount, s, "dest"); got != want {
+			t.Errorf("BITOP %s count = %d, want %d", op, got, want)
+		}
+	}
+	handleBitOp(s, []string{"NOT", "dest", "mon"})
+	if v, _ := s.Get("dest"); len(v) != 1 || v[0] != 0x9f {
+		t.Errorf("BITOP NOT = %08b", v)
+	}
+	if n := intReply(t, handleBitOp, s, "OR", "dest", "missing"); n != 0 || s.Exists("dest") {
+		t.Error("BITOP with an empty result should delete the destination")
+	}
+
+	for _, args := range [][]string{
+		{"NOT", "dest", "mon", "tue"},
+		{"DIFF", "dest", "mon"},
+		{"NAND", "dest", "mon"},
+	} {
+		if _, err := handleBitOp(s, args); err == nil {
+			t.Errorf("BITOP %v should fail", args)
+		}
+	}
+}
+
+func TestHandleBitField(t *testing.T) {
+	s := storage.NewMemoryStorage()
+
+	msg, err := handleBitField(s, []string{"k", "SET", "u8", "#1", "200", "GET", "u8", "8", "INCRBY", "u8", "#1", "100",
+		"OVERFLOW", "SAT", "INCRBY", "u8", "8", "100", "OVERFLOW", "FAIL", "INCRBY", "i8", "0", "200"})
+	if err != nil {
+		t.Fatalf("BITFIELD failed: %v", err)
+	}
+	reply := msg.Content.([]*protocol.Message)
+	want := []interface{}{int64(0), int64(200), int64(44), int64(144), nil}
+	for i, m := range reply {
+		if m.Content != want[i] {
+			t.Errorf("BITFIELD reply %d = %v, want %v", i, m.Content, want[i])
+		}
+	}
+
+	msg, _ = handleBitFieldRO(s, []string{"k", "GET", "i8", "8", "GET", "u4", "#2"})
+	reply = msg.Content.([]*protocol.Message)
+	if reply[0].Content.(int64) != -112 || reply[1].Content.(int64) != 9 {
+		t.Errorf("BITFIELD_RO = %v %v", reply[0].Content, reply[1].Content)
+	}
+	handleBitField(s, []string{"ro", "GET", "u8", "0"})
+	if s.Exists("ro") {
+		t.Error("BITFIELD with only GET should not create the key")
+	}
+
+	for _, c := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"k", "GET", "u64", "0"}, "Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is."},
+		{[]string{"k", "GET", "i65", "0"}, "Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is."},
+		{[]string{"k", "OVERFLOW", "MAYBE"}, "Invalid OVERFLOW type specified"},
+		{[]string{"k", "GET", "u8"}, "syntax error"},
+		{[]string{"k", "GET", "u8", "#x"}, "bit offset is not an integer or out of range"},
+		{[]string{"k", "SET", "u8", "0", "x"}, "value is not an integer or out of range"},
+	} {
+		if _, err := handleBitField(s, c.args); err == nil || err.Error() != c.err {
+			t.Errorf("BITFIELD %v: %v", c.args, err)
+		}
+	}
+	if _, err := handleBitFieldRO(s, []string{"k", "OVERFLOW", "SAT", "INCRBY", "u8", "0", "1"}); err == nil ||
+		err.Error() != "BITFIELD_RO only supports the GET subcommand" {
+		t.Errorf("BITFIELD_RO with INCRBY: %v", err)
+	}
+}
@@ -20,6 +20,7 @@ func RegisterCommand(name string, handler CommandHandler) {
 
 func init() {
 	registerStringCommands()
+	registerBitmapCommands()
 	registerHashCommands()
 	registerListCommands()
 	registerSetCommands()
@@ -2,8 +2,21 @@ package commands
 
 import (
 	"errors"
+	"fmt"
+	"literedis/internal/consts"
 	"literedis/internal/storage"
 	"literedis/pkg/protocol"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errFieldsMissing  = errors.New("Mandatory argument FIELDS is missing or not at the right position")
+	errNumFields      = errors.New("Parameter `numFields` should be greater than 0")
+	errNumFieldsMatch = errors.New("The `numfields` parameter must match the number of arguments")
+	errNegativeExpire = errors.New("invalid expire time, must be >= 0")
 )
 
 func registerHashCommands() {
@@ -11,6 +24,29 @@ func registerHashCommands() {
 	RegisterCommand("HGET", handleHGet)
 	RegisterCommand("HDEL", handleHDel)
 	RegisterCommand("HLEN", handleHLen)
+	RegisterCommand("HEXISTS", handleHExists)
+	RegisterCommand("HKEYS", handleHKeys)
+	RegisterCommand("HVALS", handleHVals)
+	RegisterCommand("HGETALL", handleHGetAll)
+	RegisterCommand("HMGET", handleHMGet)
+	RegisterCommand("HSETNX", handleHSetNX)
+	RegisterCommand("HINCRBY", handleHIncrBy)
+	RegisterCommand("HINCRBYFLOAT", handleHIncrByFloat)
+	RegisterCommand("HSTRLEN", handleHStrLen)
+	RegisterCommand("HRANDFIELD", handleHRandField)
+	RegisterCommand("HSCAN", handleHScan)
+
+	RegisterCommand("HEXPIRE", handleHExpire)
+	RegisterCommand("HPEXPIRE", handleHPExpire)
+	RegisterCommand("HEXPIREAT", handleHExpireAt)
+	RegisterCommand("HPEXPIREAT", handleHPExpireAt)
+	RegisterCommand("HTTL", handleHTTL)
+	RegisterCommand("HPTTL", handleHPTTL)
+	RegisterCommand("HEXPIRETIME", handleHExpireTime)
+	RegisterCommand("HPEXPIRETIME", handleHPExpireTime)
+	RegisterCommand("HPERSIST", handleHPersist)
+	RegisterCommand("HGETEX", handleHGetEx)
+	RegisterCommand("HSETEX", handleHSetEx)
 }
 
 func handleHSet(s storage.Storage, args []string) (*protocol.Message, error) {
@@ -33,7 +69,7 @@ func handleHSet(s storage.Storage, args []string) (*protocol.Message, error) {
 		return nil, err
 	}
 
-	return &protocol.Message{Type: "Integer", Content: count}, nil
+	return &protocol.Message{Type: "Integer", Content: int64(count)}, nil
 }
 
 func handleHGet(s storage.Storage, args []string) (*protocol.Message, error) {
@@ -66,7 +102,7 @@ func handleHDel(s storage.Storage, args []string) (*protocol.Message, error) {
 		return nil, err
 	}
 
-	return &protocol.Message{Type: "Integer", Content: count}, nil
+	return &protocol.Message{Type: "Integer", Content: int64(count)}, nil
 }
 
 func handleHLen(s storage.Storage, args []string) (*protocol.Message, error) {
@@ -80,5 +116,458 @@ func handleHLen(s storage.Storage, args []string) (*protocol.Message, error) {
 		return nil, err
 	}
 
-	return &protocol.Message{Type: "Integer", Content: length}, nil
+	return &protocol.Message{Type: "Integer", Content: int64(length)}, nil
+}
+
+func handleHExists(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 2 {
+		return nil, consts.ErrInvalidArgument
+	}
+	exists, err := s.HExists(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return boolReply(exists), nil
+}
+
+func handleHKeys(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 1 {
+		return nil, consts.ErrInvalidArgument
+	}
+	keys, err := s.HKeys(args[0])
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, len(keys))
+	for i, k := range keys {
+		values[i] = []byte(k)
+	}
+	return &protocol.Message{Type: "Array", Content: values}, nil
+}
+
+func handleHVals(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 1 {
+		return nil, consts.ErrInvalidArgument
+	}
+	return hashArray(s.HVals(args[0]))
+}
+
+func handleHGetAll(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 1 {
+		return nil, consts.ErrInvalidArgument
+	}
+	return hashArray(s.HGetAll(args[0]))
+}
+
+// hashArray 返回字符串数组，键不存在时返回空数组
+func hashArray(values [][]byte, err error) (*protocol.Message, error) {
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = [][]byte{}
+	}
+	return &protocol.Message{Type: "Array", Content: values}, nil
+}
+
+func handleHMGet(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) < 2 {
+		return nil, consts.ErrInvalidArgument
+	}
+	values, err := s.HMGet(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	reply := make([]*protocol.Message, len(values))
+	for i, v := range values {
+		reply[i] = &protocol.Message{Type: "BulkString", Content: v}
+	}
+	return &protocol.Message{Type: "Array", Content: reply}, nil
+}
+
+func handleHSetNX(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 3 {
+		return nil, consts.ErrInvalidArgument
+	}
+	ok, err := s.HSetNX(args[0], args[1], []byte(args[2]))
+	if err != nil {
+		return nil, err
+	}
+	return boolReply(ok), nil
+}
+
+func handleHIncrBy(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 3 {
+		return nil, consts.ErrInvalidArgument
+	}
+	delta, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return nil, consts.ErrNotInteger
+	}
+	value, err := s.HIncrBy(args[0], args[1], delta)
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "Integer", Content: value}, nil
+}
+
+func handleHIncrByFloat(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 3 {
+		return nil, consts.ErrInvalidArgument
+	}
+	delta, err := storage.ParseFloat([]byte(args[2]))
+	if err != nil {
+		return nil, err
+	}
+	if math.IsInf(delta, 0) {
+		return nil, consts.ErrNaNOrInfinity
+	}
+	return bulkOrNil(s.HIncrByFloat(args[0], args[1], delta))
+}
+
+func handleHStrLen(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 2 {
+		return nil, consts.ErrInvalidArgument
+	}
+	n, err := s.HStrLen(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "Integer", Content: int64(n)}, nil
+}
+
+// handleHRandField HRANDFIELD key [count [WITHVALUES]]
+func handleHRandField(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) < 1 || len(args) > 3 {
+		return nil, consts.ErrSyntaxError
+	}
+	if len(args) == 1 {
+		fields, err := s.HRandField(args[0], 1, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			return &protocol.Message{Type: "BulkString", Content: nil}, nil
+		}
+		return &protocol.Message{Type: "BulkString", Content: fields[0]}, nil
+	}
+
+	count, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, consts.ErrNotInteger
+	}
+	withValues := false
+	if len(args) == 3 {
+		if !strings.EqualFold(args[2], "WITHVALUES") {
+			return nil, consts.ErrSyntaxError
+		}
+		withValues = true
+	}
+	// count 取反之后不能溢出，WITHVALUES 时返回的元素个数是 count 的两倍
+	if count < -math.MaxInt64 || (withValues && count < -math.MaxInt64/2) {
+		return nil, errors.New("value is out of range")
+	}
+	return hashArray(s.HRandField(args[0], count, withValues))
+}
+
+// handleHScan HSCAN key cursor [MATCH pattern] [COUNT count]
+func handleHScan(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) < 2 {
+		return nil, consts.ErrInvalidArgument
+	}
+	cursor, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	match, count := "", 10
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, consts.ErrSyntaxError
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			// * 匹配所有 field，不需要逐个检查
+			if args[i+1] != "*" {
+				match = args[i+1]
+			}
+		case "COUNT":
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return nil, consts.ErrNotInteger
+			}
+			if n < 1 {
+				return nil, consts.ErrSyntaxError
+			}
+			count = n
+		default:
+			return nil, consts.ErrSyntaxError
+		}
+	}
+
+	next, values, err := s.HScan(args[0], cursor, match, count)
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = [][]byte{}
+	}
+	return &protocol.Message{Type: "Array", Content: []*protocol.Message{
+		{Type: "BulkString", Content: []byte(strconv.FormatUint(next, 10))},
+		{Type: "Array", Content: values},
+	}}, nil
+}
+
+// parseFields 解析 FIELDS numfields field ...，per 为每个 field 占用的参数个数
+func parseFields(args []string, per int) ([]string, error) {
+	if len(args) < 2 || !strings.EqualFold(args[0], "FIELDS") {
+		return nil, errFieldsMissing
+	}
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || n <= 0 {
+		return nil, errNumFields
+	}
+	rest := args[2:]
+	if int64(len(rest)) != n*int64(per) {
+		return nil, errNumFieldsMatch
+	}
+	return rest, nil
+}
+
+// parseFieldExpire 解析 field 的过期时间，和 EXPIRE 不同，0 和过去的时间也是合法的，
+// 表示立即删除 field
+func parseFieldExpire(cmd, arg string, unit time.Duration, absolute bool) (time.Time, error) {
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return time.Time{}, consts.ErrNotInteger
+	}
+	if n < 0 {
+		return time.Time{}, errNegativeExpire
+	}
+	invalid := fmt.Errorf("invalid expire time in '%s' command", cmd)
+	scale := int64(unit / time.Millisecond)
+	if n > math.MaxInt64/scale {
+		return time.Time{}, invalid
+	}
+	ms := n * scale
+	if !absolute {
+		now := time.Now().UnixMilli()
+		if ms > math.MaxInt64-now {
+			return time.Time{}, invalid
+		}
+		ms += now
+	}
+	return time.UnixMilli(ms), nil
+}
+
+func integerArray(values []int64) *protocol.Message {
+	reply := make([]*protocol.Message, len(values))
+	for i, v := range values {
+		reply[i] = &protocol.Message{Type: "Integer", Content: v}
+	}
+	return &protocol.Message{Type: "Array", Content: reply}
+}
+
+func handleHExpire(s storage.Storage, args []string) (*protocol.Message, error) {
+	return hexpire(s, "hexpire", args, time.Second, false)
+}
+
+func handleHPExpire(s storage.Storage, args []string) (*protocol.Message, error) {
+	return hexpire(s, "hpexpire", args, time.Millisecond, false)
+}
+
+func handleHExpireAt(s storage.Storage, args []string) (*protocol.Message, error) {
+	return hexpire(s, "hexpireat", args, time.Second, true)
+}
+
+func handleHPExpireAt(s storage.Storage, args []string) (*protocol.Message, error) {
+	return hexpire(s, "hpexpireat", args, time.Millisecond, true)
+}
+
+// hexpire HEXPIRE key seconds [NX|XX|GT|LT] FIELDS numfields field [field ...]
+func hexpire(s storage.Storage, cmd string, args []string, unit time.Duration, absolute bool) (*protocol.Message, error) {
+	if len(args) < 4 {
+		return nil, consts.ErrInvalidArgument
+	}
+	at, err := parseFieldExpire(cmd, args[1], unit, absolute)
+	if err != nil {
+		return nil, err
+	}
+	cond := storage.ExpireAlways
+	rest := args[2:]
+	switch strings.ToUpper(rest[0]) {
+	case "NX":
+		cond = storage.ExpireNX
+	case "XX":
+		cond = storage.ExpireXX
+	case "GT":
+		cond = storage.ExpireGT
+	case "LT":
+		cond = storage.ExpireLT
+	}
+	if cond != storage.ExpireAlways {
+		rest = rest[1:]
+	}
+	fields, err := parseFields(rest, 1)
+	if err != nil {
+		return nil, err
+	}
+	results, err := s.HExpire(args[0], at, cond, fields...)
+	if err != nil {
+		return nil, err
+	}
+	return integerArray(results), nil
+}
+
+func handleHTTL(s storage.Storage, args []string) (*protocol.Message, error) {
+	return httl(s, args, func(ms, now int64) int64 { return (ms - now + 999) / 1000 })
+}
+
+func handleHPTTL(s storage.Storage, args []string) (*protocol.Message, error) {
+	return httl(s, args, func(ms, now int64) int64 { return ms - now })
+}
+
+func handleHExpireTime(s storage.Storage, args []string) (*protocol.Message, error) {
+	return httl(s, args, func(ms, now int64) int64 { return ms / 1000 })
+}
+
+func handleHPExpireTime(s storage.Storage, args []string) (*protocol.Message, error) {
+	return httl(s, args, func(ms, now int64) int64 { return ms })
+}
+
+// httl HTTL key FIELDS numfields field [field ...]，convert 把过期时间的 Unix 毫秒数
+// 转换为返回值，-2 和 -1 原样返回
+func httl(s storage.Storage, args []string, convert func(ms, now int64) int64) (*protocol.Message, error) {
+	if len(args) < 3 {
+		return nil, consts.ErrInvalidArgument
+	}
+	fields, err := parseFields(args[1:], 1)
+	if err != nil {
+		return nil, err
+	}
+	results, err := s.HPExpireTime(args[0], fields...)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	for i, ms := range results {
+		if ms >= 0 {
+			results[i] = convert(ms, now)
+		}
+	}
+	return integerArray(results), nil
+}
+
+// handleHPersist HPERSIST key FIELDS numfields field [field ...]
+func handleHPersist(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) < 3 {
+		return nil, consts.ErrInvalidArgument
+	}
+	fields, err := parseFields(args[1:], 1)
+	if err != nil {
+		return nil, err
+	}
+	results, err := s.HPersist(args[0], fields...)
+	if err != nil {
+		return nil, err
+	}
+	return integerArray(results), nil
+}
+
+// parseFieldExpireOption 解析 EX、PX、EXAT、PXAT 选项，不是这些选项时返回 false
+func parseFieldExpireOption(cmd string, args []string) (time.Time, bool, error) {
+	opt := strings.ToUpper(args[0])
+	switch opt {
+	case "EX", "PX", "EXAT", "PXAT":
+	default:
+		return time.Time{}, false, nil
+	}
+	if len(args) < 2 {
+		return time.Time{}, true, consts.ErrSyntaxError
+	}
+	unit := time.Second
+	if opt[0] == 'P' {
+		unit = time.Millisecond
+	}
+	at, err := parseExpireTime(cmd, args[1], unit, strings.HasSuffix(opt, "AT"))
+	return at, true, err
+}
+
+// handleHGetEx HGETEX key [EX seconds|PX milliseconds|EXAT timestamp|PXAT timestamp|PERSIST]
+// FIELDS numfields field [field ...]
+func handleHGetEx(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) < 4 {
+		return nil, consts.ErrInvalidArgument
+	}
+	var at time.Time
+	persist := false
+	rest := args[1:]
+	if strings.EqualFold(rest[0], "PERSIST") {
+		persist, rest = true, rest[1:]
+	} else if t, ok, err := parseFieldExpireOption("hgetex", rest); err != nil {
+		return nil, err
+	} else if ok {
+		at, rest = t, rest[2:]
+	}
+	fields, err := parseFields(rest, 1)
+	if err != nil {
+		return nil, err
+	}
+	values, err := s.HGetEx(args[0], at, persist, fields...)
+	if err != nil {
+		return nil, err
+	}
+	reply := make([]*protocol.Message, len(values))
+	for i, v := range values {
+		reply[i] = &protocol.Message{Type: "BulkString", Content: v}
+	}
+	return &protocol.Message{Type: "Array", Content: reply}, nil
+}
+
+// handleHSetEx HSETEX key [FNX|FXX] [EX seconds|PX milliseconds|EXAT timestamp|PXAT timestamp|KEEPTTL]
+// FIELDS numfields field value [field value ...]
+func handleHSetEx(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) < 4 {
+		return nil, consts.ErrInvalidArgument
+	}
+	var opts storage.HSetExOptions
+	rest := args[1:]
+	for len(rest) > 0 && !strings.EqualFold(rest[0], "FIELDS") {
+		switch opt := strings.ToUpper(rest[0]); opt {
+		case "FNX", "FXX":
+			if opts.FNX || opts.FXX {
+				return nil, consts.ErrSyntaxError
+			}
+			opts.FNX, opts.FXX = opt == "FNX", opt == "FXX"
+			rest = rest[1:]
+		case "KEEPTTL":
+			if opts.KeepTTL || !opts.ExpireAt.IsZero() {
+				return nil, consts.ErrSyntaxError
+			}
+			opts.KeepTTL, rest = true, rest[1:]
+		default:
+			at, ok, err := parseFieldExpireOption("hsetex", rest)
+			if err != nil {
+				return nil, err
+			}
+			if !ok || opts.KeepTTL || !opts.ExpireAt.IsZero() {
+				return nil, consts.ErrSyntaxError
+			}
+			opts.ExpireAt, rest = at, rest[2:]
+		}
+	}
+	pairs, err := parseFields(rest, 2)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, 0, len(pairs)/2)
+	values := make([][]byte, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		fields = append(fields, pairs[i])
+		values = append(values, []byte(pairs[i+1]))
+	}
+	ok, err := s.HSetEx(args[0], fields, values, opts)
+	if err != nil {
+		return nil, err
+	}
+	return boolReply(ok), nil
 }
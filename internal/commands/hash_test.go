@@ -0,0 +1,331 @@
+package commands
+
+import (
+	"fmt"
+	"literedis/internal/consts"
+	"literedis/internal/storage"
+	"literedis/pkg/protocol"
+	"strings"
+	"testing"
+	"time"
+)
+
+// arrayReply 执行返回字符串数组的命令
+func arrayReply(t *testing.T, handler CommandHandler, s storage.Storage, args ...string) []string {
+	t.Helper()
+	msg, err := handler(s, args)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var out []string
+	for _, v := range msg.Content.([][]byte) {
+		out = append(out, string(v))
+	}
+	return out
+}
+
+func TestHandleHashReads(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	handleHSet(s, []string{"user:1", "name", "alice"})
+	handleHSet(s, []string{"user:1", "age", "30"})
+
+	if got := strings.Join(arrayReply(t, handleHGetAll, s, "user:1"), ","); got != "name,alice,age,30" {
+		t.Errorf("HGETALL = %s", got)
+	}
+	if got := strings.Join(arrayReply(t, handleHKeys, s, "user:1"), ","); got != "name,age" {
+		t.Errorf("HKEYS = %s", got)
+	}
+	if got := strings.Join(arrayReply(t, handleHVals, s, "user:1"), ","); got != "alice,30" {
+		t.Errorf("HVALS = %s", got)
+	}
+	if got := arrayReply(t, handleHGetAll, s, "missing"); len(got) != 0 {
+		t.Errorf("HGETALL on missing key = %v", got)
+	}
+	if intReply(t, handleHExists, s, "user:1", "name") != 1 || intReply(t, handleHExists, s, "user:1", "x") != 0 {
+		t.Error("HEXISTS")
+	}
+	if got := intReply(t, handleHStrLen, s, "user:1", "name"); got != 5 {
+		t.Errorf("HSTRLEN = %d", got)
+	}
+
+	msg, _ := handleHMGet(s, []string{"user:1", "age", "x", "name"})
+	values := msg.Content.([]*protocol.Message)
+	if string(values[0].Content.([]byte)) != "30" || values[1].Content.([]byte) != nil || string(values[2].Content.([]byte)) != "alice" {
+		t.Errorf("HMGET = %v", values)
+	}
+
+	s.Set("str", []byte("v"))
+	if _, err := handleHGetAll(s, []string{"str"}); err != storage.ErrWrongType {
+		t.Errorf("HGETALL on a string: %v", err)
+	}
+}
+
+func TestHandleHashWrites(t *testing.T) {
+	s := storage.NewMemoryStorage()
+
+	if intReply(t, handleHSetNX, s, "h", "f", "1") != 1 || intReply(t, handleHSetNX, s, "h", "f", "2") != 0 {
+		t.Error("HSETNX")
+	}
+	if got := intReply(t, handleHIncrBy, s, "h", "f", "10"); got != 11 {
+		t.Errorf("HINCRBY = %d", got)
+	}
+	if got := intReply(t, handleHIncrBy, s, "h", "new", "-3"); got != -3 {
+		t.Errorf("HINCRBY on a new field = %d", got)
+	}
+	msg, err := handleHIncrByFloat(s, []string{"h", "f", "0.5"})
+	if err != nil || string(msg.Content.([]byte)) != "11.5" {
+		t.Errorf("HINCRBYFLOAT = %v, %v", msg, err)
+	}
+
+	handleHSet(s, []string{"h", "s", "abc"})
+	if _, err := handleHIncrBy(s, []string{"h", "s", "1"}); err != storage.ErrHashNotInteger {
+		t.Errorf("HINCRBY on a string value: %v", err)
+	}
+	if _, err := handleHIncrBy(s, []string{"h", "f", "1"}); err != storage.ErrHashNotInteger {
+		t.Errorf("HINCRBY on a float value: %v", err)
+	}
+	if _, err := handleHIncrByFloat(s, []string{"h", "s", "1"}); err != storage.ErrHashNotFloat {
+		t.Errorf("HINCRBYFLOAT on a string value: %v", err)
+	}
+	handleHSet(s, []string{"h", "max", "9223372036854775807"})
+	if _, err := handleHIncrBy(s, []string{"h", "max", "1"}); err == nil {
+		t.Error("HINCRBY should detect overflow")
+	}
+}
+
+func TestHandleHRandField(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	handleHSet(s, []string{"h", "a", "1"})
+	handleHSet(s, []string{"h", "b", "2"})
+	handleHSet(s, []string{"h", "c", "3"})
+
+	msg, _ := handleHRandField(s, []string{"h"})
+	if f := string(msg.Content.([]byte)); f != "a" && f != "b" && f != "c" {
+		t.Errorf("HRANDFIELD = %q", f)
+	}
+	msg, _ = handleHRandField(s, []string{"missing"})
+	if msg.Content != nil {
+		t.Errorf("HRANDFIELD on missing key = %v", msg.Content)
+	}
+
+	// 正数不重复，最多返回全部 field
+	got := arrayReply(t, handleHRandField, s, "h", "10")
+	seen := make(map[string]bool)
+	for _, f := range got {
+		seen[f] = true
+	}
+	if len(got) != 3 || len(seen) != 3 {
+		t.Errorf("HRANDFIELD 10 = %v", got)
+	}
+	// 负数可以重复
+	if got := arrayReply(t, handleHRandField, s, "h", "-7"); len(got) != 7 {
+		t.Errorf("HRANDFIELD -7 = %v", got)
+	}
+	got = arrayReply(t, handleHRandField, s, "h", "2", "WITHVALUES")
+	if len(got) != 4 || got[1] != map[string]string{"a": "1", "b": "2", "c": "3"}[got[0]] {
+		t.Errorf("HRANDFIELD WITHVALUES = %v", got)
+	}
+	if got := arrayReply(t, handleHRandField, s, "missing", "3"); len(got) != 0 {
+		t.Errorf("HRANDFIELD count on missing key = %v", got)
+	}
+	for _, args := range [][]string{
+		{"h", "-9223372036854775808"},
+		{"h", "-9223372036854775808", "WITHVALUES"},
+		{"h", "-4611686018427387904", "WITHVALUES"},
+	} {
+		if _, err := handleHRandField(s, args); err == nil || err.Error() != "value is out of range" {
+			t.Errorf("HRANDFIELD %v: %v", args, err)
+		}
+	}
+	if got := arrayReply(t, handleHRandField, s, "h", "-2000", "WITHVALUES"); len(got) != 4000 {
+		t.Errorf("HRANDFIELD -2000 WITHVALUES returned %d elements", len(got))
+	}
+}
+
+func TestHandleHScan(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	for i := 0; i < 300; i++ {
+		handleHSet(s, []string{"h", fmt.Sprint("f", i), fmt.Sprint(i)})
+	}
+
+	seen := make(map[string]bool)
+	cursor := "0"
+	for {
+		msg, err := handleHScan(s, []string{"h", cursor, "MATCH", "f1*", "COUNT", "20"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply := msg.Content.([]*protocol.Message)
+		items := reply[1].Content.([][]byte)
+		for i := 0; i < len(items); i += 2 {
+			if !strings.HasPrefix(string(items[i]), "f1") {
+				t.Fatalf("HSCAN MATCH returned %s", items[i])
+			}
+			seen[string(items[i])] = true
+		}
+		cursor = string(reply[0].Content.([]byte))
+		if cursor == "0" {
+			break
+		}
+	}
+	// f1, f10-f19, f100-f199
+	if len(seen) != 111 {
+		t.Errorf("HSCAN returned %d matching fields, want 111", len(seen))
+	}
+
+	if _, err := handleHScan(s, []string{"h", "x"}); err == nil || err.Error() != "invalid cursor" {
+		t.Errorf("invalid cursor: %v", err)
+	}
+	if _, err := handleHScan(s, []string{"h", "0", "COUNT", "0"}); err == nil {
+		t.Error("COUNT 0 should fail")
+	}
+
+	// MATCH 中的 * 可以匹配 /
+	handleHSet(s, []string{"paths", "a/b", "1", "c", "2"})
+	msg, _ := handleHScan(s, []string{"paths", "0", "MATCH", "a*"})
+	if items := msg.Content.([]*protocol.Message)[1].Content.([][]byte); len(items) != 2 || string(items[0]) != "a/b" {
+		t.Errorf("HSCAN MATCH a* = %q", items)
+	}
+}
+
+// intArray 执行返回整数数组的命令
+func intArray(t *testing.T, handler CommandHandler, s storage.Storage, args ...string) []int64 {
+	t.Helper()
+	msg, err := handler(s, args)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var out []int64
+	for _, m := range msg.Content.([]*protocol.Message) {
+		out = append(out, m.Content.(int64))
+	}
+	return out
+}
+
+func TestHandleHExpire(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	handleHSet(s, []string{"session", "token", "t", "user", "u", "csrf", "c"})
+
+	got := intArray(t, handleHExpire, s, "session", "100", "FIELDS", "2", "token", "missing")
+	if fmt.Sprint(got) != "[1 -2]" {
+		t.Errorf("HEXPIRE = %v", got)
+	}
+	if got := intArray(t, handleHTTL, s, "session", "FIELDS", "3", "token", "user", "missing"); fmt.Sprint(got) != "[100 -1 -2]" {
+		t.Errorf("HTTL = %v", got)
+	}
+	if got := intArray(t, handleHPTTL, s, "session", "FIELDS", "1", "token"); got[0] <= 99000 || got[0] > 100000 {
+		t.Errorf("HPTTL = %v", got)
+	}
+
+	// NX、XX、GT、LT
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"session", "50", "NX", "FIELDS", "2", "token", "user"}, "[0 1]"},
+		{[]string{"session", "200", "XX", "FIELDS", "2", "token", "csrf"}, "[1 0]"},
+		{[]string{"session", "100", "GT", "FIELDS", "2", "token", "csrf"}, "[0 0]"},
+		{[]string{"session", "100", "LT", "FIELDS", "2", "token", "csrf"}, "[1 1]"},
+	}
+	for _, tt := range tests {
+		if got := intArray(t, handleHExpire, s, tt.args...); fmt.Sprint(got) != tt.want {
+			t.Errorf("HEXPIRE %v = %v, want %s", tt.args, got, tt.want)
+		}
+	}
+
+	at := time.Now().Add(time.Hour).Unix()
+	intArray(t, handleHExpireAt, s, "session", fmt.Sprint(at), "FIELDS", "1", "user")
+	if got := intArray(t, handleHExpireTime, s, "session", "FIELDS", "1", "user"); got[0] != at {
+		t.Errorf("HEXPIRETIME = %v, want %d", got, at)
+	}
+	if got := intArray(t, handleHPersist, s, "session", "FIELDS", "2", "user", "user"); fmt.Sprint(got) != "[1 -1]" {
+		t.Errorf("HPERSIST = %v", got)
+	}
+
+	// 过去的时间直接删除 field，最后一个 field 被删除时键也被删除
+	if got := intArray(t, handleHExpire, s, "session", "0", "FIELDS", "2", "token", "csrf"); fmt.Sprint(got) != "[2 2]" {
+		t.Errorf("HEXPIRE 0 = %v", got)
+	}
+	intArray(t, handleHPExpireAt, s, "session", "1", "FIELDS", "1", "user")
+	if s.Exists("session") {
+		t.Error("session should be deleted once all fields expired")
+	}
+	if got := intArray(t, handleHExpire, s, "session", "10", "FIELDS", "1", "a"); fmt.Sprint(got) != "[-2]" {
+		t.Errorf("HEXPIRE on a missing key = %v", got)
+	}
+
+	errTests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"h", "10", "1", "f"}, "Mandatory argument FIELDS is missing or not at the right position"},
+		{[]string{"h", "10", "FIELDS", "0", "f"}, "Parameter `numFields` should be greater than 0"},
+		{[]string{"h", "10", "FIELDS", "2", "f"}, "The `numfields` parameter must match the number of arguments"},
+		{[]string{"h", "-1", "FIELDS", "1", "f"}, "invalid expire time, must be >= 0"},
+		{[]string{"h", "9223372036854775807", "FIELDS", "1", "f"}, "invalid expire time in 'hexpire' command"},
+	}
+	for _, tt := range errTests {
+		if _, err := handleHExpire(s, tt.args); err == nil || err.Error() != tt.want {
+			t.Errorf("HEXPIRE %v: %v, want %q", tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestHandleHGetExHSetEx(t *testing.T) {
+	s := storage.NewMemoryStorage()
+
+	if intReply(t, handleHSetEx, s, "h", "FNX", "EX", "100", "FIELDS", "2", "a", "1", "b", "2") != 1 {
+		t.Fatal("HSETEX FNX on a new hash should succeed")
+	}
+	if got := intArray(t, handleHTTL, s, "h", "FIELDS", "2", "a", "b"); fmt.Sprint(got) != "[100 100]" {
+		t.Errorf("HTTL after HSETEX = %v", got)
+	}
+	if intReply(t, handleHSetEx, s, "h", "FNX", "FIELDS", "2", "a", "x", "c", "3") != 0 {
+		t.Error("HSETEX FNX with an existing field should fail")
+	}
+	if intReply(t, handleHSetEx, s, "h", "FXX", "FIELDS", "2", "a", "x", "c", "3") != 0 {
+		t.Error("HSETEX FXX with a missing field should fail")
+	}
+	if intReply(t, handleHSetEx, s, "h", "FXX", "KEEPTTL", "FIELDS", "1", "a", "x") != 1 {
+		t.Error("HSETEX FXX KEEPTTL")
+	}
+	// 没有 KEEPTTL 时清除过期时间
+	intReply(t, handleHSetEx, s, "h", "FIELDS", "1", "b", "y")
+	if got := intArray(t, handleHTTL, s, "h", "FIELDS", "2", "a", "b"); fmt.Sprint(got) != "[100 -1]" {
+		t.Errorf("HTTL after KEEPTTL = %v", got)
+	}
+
+	msg, err := handleHGetEx(s, []string{"h", "PX", "5000", "FIELDS", "3", "a", "missing", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := msg.Content.([]*protocol.Message)
+	if string(values[0].Content.([]byte)) != "x" || values[1].Content.([]byte) != nil || string(values[2].Content.([]byte)) != "y" {
+		t.Errorf("HGETEX = %v", values)
+	}
+	if got := intArray(t, handleHTTL, s, "h", "FIELDS", "2", "a", "b"); fmt.Sprint(got) != "[5 5]" {
+		t.Errorf("HTTL after HGETEX PX = %v", got)
+	}
+	handleHGetEx(s, []string{"h", "PERSIST", "FIELDS", "1", "a"})
+	if got := intArray(t, handleHTTL, s, "h", "FIELDS", "1", "a"); got[0] != -1 {
+		t.Errorf("HTTL after HGETEX PERSIST = %v", got)
+	}
+
+	for _, args := range [][]string{
+		{"h", "EX", "10", "PX", "10", "FIELDS", "1", "a", "1"},
+		{"h", "FNX", "FXX", "FIELDS", "1", "a", "1"},
+		{"h", "KEEPTTL", "EX", "10", "FIELDS", "1", "a", "1"},
+	} {
+		if _, err := handleHSetEx(s, args); err != consts.ErrSyntaxError {
+			t.Errorf("HSETEX %v: %v", args, err)
+		}
+	}
+	if _, err := handleHSetEx(s, []string{"h", "FIELDS", "2", "a", "1"}); err == nil {
+		t.Error("HSETEX with a missing value should fail")
+	}
+
+	s.Set("str", []byte("v"))
+	if _, err := handleHExpire(s, []string{"str", "10", "FIELDS", "1", "a"}); err != storage.ErrWrongType {
+		t.Errorf("HEXPIRE on a string: %v", err)
+	}
+}
@@ -2,9 +2,11 @@ package commands
 
 import (
 	"errors"
+	"fmt"
 	"literedis/internal/storage"
 	"literedis/pkg/protocol"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,6 +17,7 @@ func registerKeyCommands() {
 	RegisterCommand("EXPIRE", handleExpire)
 	RegisterCommand("TTL", handleTTL)
 	RegisterCommand("TYPE", handleType)
+	RegisterCommand("OBJECT", handleObject)
 }
 
 func handleKeys(s storage.Storage, args []string) (*protocol.Message, error) {
@@ -42,7 +45,7 @@ func handleDel(s storage.Storage, args []string) (*protocol.Message, error) {
 		}
 	}
 
-	return &protocol.Message{Type: "Integer", Content: count}, nil
+	return &protocol.Message{Type: "Integer", Content: int64(count)}, nil
 }
 
 func handleExists(s storage.Storage, args []string) (*protocol.Message, error) {
@@ -58,7 +61,7 @@ func handleExists(s storage.Storage, args []string) (*protocol.Message, error) {
 		}
 	}
 
-	return &protocol.Message{Type: "Integer", Content: count}, nil
+	return &protocol.Message{Type: "Integer", Content: int64(count)}, nil
 }
 
 func handleExpire(s storage.Storage, args []string) (*protocol.Message, error) {
@@ -82,7 +85,7 @@ func handleExpire(s storage.Storage, args []string) (*protocol.Message, error) {
 		result = 1
 	}
 
-	return &protocol.Message{Type: "Integer", Content: result}, nil
+	return &protocol.Message{Type: "Integer", Content: int64(result)}, nil
 }
 
 func handleTTL(s storage.Storage, args []string) (*protocol.Message, error) {
@@ -110,3 +113,33 @@ func handleType(s storage.Storage, args []string) (*protocol.Message, error) {
 
 	return &protocol.Message{Type: "SimpleString", Content: keyType}, nil
 }
+
+var objectHelp = []string{
+	"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"ENCODING <key>",
+	"    Return the kind of internal representation used in order to store the value",
+	"    associated with a <key>.",
+	"HELP",
+	"    Print this help.",
+}
+
+// handleObject OBJECT ENCODING key | HELP
+func handleObject(s storage.Storage, args []string) (*protocol.Message, error) {
+	switch sub := strings.ToUpper(args[0]); sub {
+	case "HELP":
+		return &protocol.Message{Type: "Array", Content: objectHelp}, nil
+	case "ENCODING":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("wrong number of arguments for 'object|%s' command", strings.ToLower(sub))
+		}
+		encoding, err := s.(storage.KeyStorage).ObjectEncoding(args[1])
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			return &protocol.Message{Type: "BulkString", Content: nil}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &protocol.Message{Type: "BulkString", Content: encoding}, nil
+	}
+	return nil, fmt.Errorf("unknown subcommand '%s'. Try OBJECT HELP.", args[0])
+}
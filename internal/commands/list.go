@@ -6,6 +6,7 @@ import (
 	"literedis/internal/storage"
 	"literedis/pkg/protocol"
 	"strconv"
+	"strings"
 )
 
 func registerListCommands() {
@@ -15,6 +16,17 @@ func registerListCommands() {
 	RegisterCommand("RPOP", handleRPop)
 	RegisterCommand("LLEN", handleLLen)
 	RegisterCommand("LRANGE", handleLRange)
+	RegisterCommand("LINDEX", handleLIndex)
+	RegisterCommand("LSET", handleLSet)
+	RegisterCommand("LPUSHX", handleLPushX)
+	RegisterCommand("RPUSHX", handleRPushX)
+	RegisterCommand("LINSERT", handleLInsert)
+	RegisterCommand("LREM", handleLRem)
+	RegisterCommand("LTRIM", handleLTrim)
+	RegisterCommand("LPOS", handleLPos)
+	RegisterCommand("LMOVE", handleLMove)
+	RegisterCommand("RPOPLPUSH", handleRPopLPush)
+	RegisterCommand("LMPOP", handleLMPop)
 }
 
 func handleLPush(s storage.Storage, args []string) (*protocol.Message, error) {
@@ -56,35 +68,48 @@ func handleRPush(s storage.Storage, args []string) (*protocol.Message, error) {
 }
 
 func handleLPop(s storage.Storage, args []string) (*protocol.Message, error) {
-	if len(args) != 1 {
-		return nil, errors.New("LPOP command requires one argument")
-	}
-
-	value, err := s.LPop(args[0])
-	if err != nil {
-		if err == storage.ErrKeyNotFound {
-			return &protocol.Message{Type: "BulkString", Content: nil}, nil
-		}
-		return nil, err
-	}
-
-	return &protocol.Message{Type: "BulkString", Content: value}, nil
+	return handlePop(s, args, true)
 }
 
 func handleRPop(s storage.Storage, args []string) (*protocol.Message, error) {
-	if len(args) != 1 {
-		return nil, errors.New("RPOP command requires one argument")
-	}
+	return handlePop(s, args, false)
+}
 
-	value, err := s.RPop(args[0])
-	if err != nil {
-		if err == storage.ErrKeyNotFound {
+// handlePop LPOP|RPOP key [count]，带 count 时返回数组，键不存在时返回空数组
+func handlePop(s storage.Storage, args []string, left bool) (*protocol.Message, error) {
+	switch len(args) {
+	case 1:
+		pop := s.RPop
+		if left {
+			pop = s.LPop
+		}
+		value, err := pop(args[0])
+		if errors.Is(err, consts.ErrKeyNotFound) {
 			return &protocol.Message{Type: "BulkString", Content: nil}, nil
 		}
-		return nil, err
+		if err != nil {
+			return nil, err
+		}
+		return &protocol.Message{Type: "BulkString", Content: value}, nil
+	case 2:
+		count, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || count < 0 {
+			return nil, errors.New("value is out of range, must be positive")
+		}
+		pop := s.RPopCount
+		if left {
+			pop = s.LPopCount
+		}
+		values, err := pop(args[0], count)
+		if errors.Is(err, consts.ErrKeyNotFound) {
+			return &protocol.Message{Type: "Array", Content: []*protocol.Message(nil)}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &protocol.Message{Type: "Array", Content: values}, nil
 	}
-
-	return &protocol.Message{Type: "BulkString", Content: value}, nil
+	return nil, consts.ErrSyntaxError
 }
 
 func handleLLen(s storage.Storage, args []string) (*protocol.Message, error) {
@@ -97,7 +122,7 @@ func handleLLen(s storage.Storage, args []string) (*protocol.Message, error) {
 		return nil, err
 	}
 
-	return &protocol.Message{Type: "Integer", Content: length}, nil
+	return &protocol.Message{Type: "Integer", Content: int64(length)}, nil
 }
 
 func handleLRange(s storage.Storage, args []string) (*protocol.Message, error) {
@@ -125,3 +150,282 @@ func handleLRange(s storage.Storage, args []string) (*protocol.Message, error) {
 
 	return &protocol.Message{Type: "Array", Content: values}, nil
 }
+
+func handleLIndex(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 2 {
+		return nil, consts.ErrInvalidArgument
+	}
+	index, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, consts.ErrNotInteger
+	}
+	value, err := s.LIndex(args[0], index)
+	if errors.Is(err, consts.ErrKeyNotFound) {
+		return &protocol.Message{Type: "BulkString", Content: nil}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "BulkString", Content: value}, nil
+}
+
+func handleLSet(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 3 {
+		return nil, consts.ErrInvalidArgument
+	}
+	index, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, consts.ErrNotInteger
+	}
+	if err := s.LSet(args[0], index, []byte(args[2])); err != nil {
+		if errors.Is(err, consts.ErrKeyNotFound) {
+			return nil, errors.New("no such key")
+		}
+		return nil, err
+	}
+	return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+}
+
+func handleLPushX(s storage.Storage, args []string) (*protocol.Message, error) {
+	return handlePushX(s, args, true)
+}
+
+func handleRPushX(s storage.Storage, args []string) (*protocol.Message, error) {
+	return handlePushX(s, args, false)
+}
+
+// handlePushX LPUSHX|RPUSHX key element [element ...]，只在列表存在时插入
+func handlePushX(s storage.Storage, args []string, left bool) (*protocol.Message, error) {
+	if len(args) < 2 {
+		return nil, consts.ErrInvalidArgument
+	}
+	values := make([][]byte, len(args)-1)
+	for i, v := range args[1:] {
+		values[i] = []byte(v)
+	}
+	push := s.RPushX
+	if left {
+		push = s.LPushX
+	}
+	length, err := push(args[0], values...)
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "Integer", Content: length}, nil
+}
+
+// handleLInsert LINSERT key BEFORE|AFTER pivot element
+func handleLInsert(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 4 {
+		return nil, consts.ErrInvalidArgument
+	}
+	var before bool
+	switch strings.ToUpper(args[1]) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+	default:
+		return nil, consts.ErrSyntaxError
+	}
+	length, err := s.LInsert(args[0], before, []byte(args[2]), []byte(args[3]))
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "Integer", Content: length}, nil
+}
+
+// handleLRem LREM key count element
+func handleLRem(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 3 {
+		return nil, consts.ErrInvalidArgument
+	}
+	count, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, consts.ErrNotInteger
+	}
+	removed, err := s.LRem(args[0], count, []byte(args[2]))
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "Integer", Content: removed}, nil
+}
+
+// handleLTrim LTRIM key start stop
+func handleLTrim(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 3 {
+		return nil, consts.ErrInvalidArgument
+	}
+	start, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, consts.ErrNotInteger
+	}
+	stop, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return nil, consts.ErrNotInteger
+	}
+	if err := s.LTrim(args[0], start, stop); err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+}
+
+// handleLPos LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+func handleLPos(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) < 2 || len(args)%2 != 0 {
+		return nil, consts.ErrSyntaxError
+	}
+	rank, count, maxLen := int64(1), int64(1), int64(0)
+	withCount := false
+	for i := 2; i < len(args); i += 2 {
+		n, err := strconv.ParseInt(args[i+1], 10, 64)
+		if err != nil {
+			return nil, consts.ErrNotInteger
+		}
+		switch strings.ToUpper(args[i]) {
+		case "RANK":
+			if n == 0 {
+				return nil, errors.New("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				return nil, errors.New("ERR COUNT can't be negative")
+			}
+			count, withCount = n, true
+		case "MAXLEN":
+			if n < 0 {
+				return nil, errors.New("ERR MAXLEN can't be negative")
+			}
+			maxLen = n
+		default:
+			return nil, consts.ErrSyntaxError
+		}
+	}
+	positions, err := s.LPos(args[0], []byte(args[1]), rank, count, maxLen)
+	if err != nil {
+		return nil, err
+	}
+	if !withCount {
+		if len(positions) == 0 {
+			return &protocol.Message{Type: "BulkString", Content: nil}, nil
+		}
+		return &protocol.Message{Type: "Integer", Content: positions[0]}, nil
+	}
+	replies := make([]*protocol.Message, len(positions))
+	for i, pos := range positions {
+		replies[i] = &protocol.Message{Type: "Integer", Content: pos}
+	}
+	return &protocol.Message{Type: "Array", Content: replies}, nil
+}
+
+// handleLMove LMOVE source destination LEFT|RIGHT LEFT|RIGHT
+func handleLMove(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 4 {
+		return nil, consts.ErrInvalidArgument
+	}
+	srcLeft, err := ParseDirection(args[2])
+	if err != nil {
+		return nil, err
+	}
+	dstLeft, err := ParseDirection(args[3])
+	if err != nil {
+		return nil, err
+	}
+	return move(s, args[0], args[1], srcLeft, dstLeft)
+}
+
+// handleRPopLPush RPOPLPUSH source destination，等同于 LMOVE source destination RIGHT LEFT
+func handleRPopLPush(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 2 {
+		return nil, consts.ErrInvalidArgument
+	}
+	return move(s, args[0], args[1], false, true)
+}
+
+func move(s storage.Storage, src, dst string, srcLeft, dstLeft bool) (*protocol.Message, error) {
+	value, err := s.LMove(src, dst, srcLeft, dstLeft)
+	if errors.Is(err, consts.ErrKeyNotFound) {
+		return &protocol.Message{Type: "BulkString", Content: nil}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "BulkString", Content: value}, nil
+}
+
+// handleLMPop LMPOP numkeys key [key ...] LEFT|RIGHT [COUNT count]，
+// 从第一个非空的列表弹出元素
+func handleLMPop(s storage.Storage, args []string) (*protocol.Message, error) {
+	keys, left, count, err := ParseMPop(args)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		reply, ok, err := MPop(s, key, left, count)
+		if err != nil || ok {
+			return reply, err
+		}
+	}
+	return &protocol.Message{Type: "Array", Content: []*protocol.Message(nil)}, nil
+}
+
+// MPop 从 key 的一端弹出至多 count 个元素，回复为 [key, [element ...]]，列表不存在时返回 false
+func MPop(s storage.Storage, key string, left bool, count int64) (*protocol.Message, bool, error) {
+	pop := s.RPopCount
+	if left {
+		pop = s.LPopCount
+	}
+	values, err := pop(key, count)
+	if errors.Is(err, consts.ErrKeyNotFound) || (err == nil && len(values) == 0) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &protocol.Message{Type: "Array", Content: []*protocol.Message{
+		{Type: "BulkString", Content: []byte(key)},
+		{Type: "Array", Content: values},
+	}}, true, nil
+}
+
+// ParseDirection LEFT|RIGHT，LEFT 返回 true
+func ParseDirection(s string) (bool, error) {
+	switch strings.ToUpper(s) {
+	case "LEFT":
+		return true, nil
+	case "RIGHT":
+		return false, nil
+	}
+	return false, consts.ErrSyntaxError
+}
+
+// ParseMPop numkeys key [key ...] LEFT|RIGHT [COUNT count]
+func ParseMPop(args []string) (keys []string, left bool, count int64, err error) {
+	if len(args) == 0 {
+		return nil, false, 0, consts.ErrSyntaxError
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return nil, false, 0, errors.New("numkeys should be greater than 0")
+	}
+	if n > len(args)-2 {
+		return nil, false, 0, consts.ErrSyntaxError
+	}
+	keys = args[1 : n+1]
+	if left, err = ParseDirection(args[n+1]); err != nil {
+		return nil, false, 0, err
+	}
+	count = 1
+	rest := args[n+2:]
+	switch {
+	case len(rest) == 0:
+	case len(rest) == 2 && strings.EqualFold(rest[0], "COUNT"):
+		count, err = strconv.ParseInt(rest[1], 10, 64)
+		if err != nil || count <= 0 {
+			return nil, false, 0, errors.New("count should be greater than 0")
+		}
+	default:
+		return nil, false, 0, consts.ErrSyntaxError
+	}
+	return keys, left, count, nil
+}
@@ -0,0 +1,228 @@
+package commands
+
+import (
+	"literedis/internal/consts"
+	"literedis/internal/storage"
+	"literedis/pkg/protocol"
+	"reflect"
+	"testing"
+)
+
+func rangeOf(t *testing.T, s storage.Storage, key string) []string {
+	t.Helper()
+	values, err := s.LRange(key, 0, -1)
+	if err != nil {
+		return nil
+	}
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = string(v)
+	}
+	return out
+}
+
+func TestHandlePopCount(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	handleRPush(s, []string{"l", "a", "b", "c", "d"})
+
+	msg, err := handleLPop(s, []string{"l", "2"})
+	if err != nil {
+		t.Fatalf("LPOP count failed: %v", err)
+	}
+	if got := msg.Content.([][]byte); len(got) != 2 || string(got[0]) != "a" || string(got[1]) != "b" {
+		t.Errorf("LPOP l 2 = %q", got)
+	}
+	msg, _ = handleRPop(s, []string{"l", "0"})
+	if got := msg.Content.([][]byte); msg.Type != "Array" || len(got) != 0 {
+		t.Errorf("RPOP l 0 = %v", msg.Content)
+	}
+	msg, _ = handleRPop(s, []string{"l", "10"})
+	if got := msg.Content.([][]byte); len(got) != 2 || string(got[0]) != "d" {
+		t.Errorf("RPOP l 10 = %q", got)
+	}
+	msg, _ = handleLPop(s, []string{"l", "1"})
+	if msg.Type != "Array" || msg.Content.([]*protocol.Message) != nil {
+		t.Errorf("LPOP on missing key = %v, want null array", msg.Content)
+	}
+	msg, _ = handleLPop(s, []string{"l"})
+	if msg.Type != "BulkString" || msg.Content != nil {
+		t.Errorf("LPOP on missing key = %v, want nil", msg.Content)
+	}
+	if _, err := handleLPop(s, []string{"l", "-1"}); err == nil {
+		t.Error("LPOP with negative count should fail")
+	}
+}
+
+func TestHandleLInsertLRemLTrim(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	handleRPush(s, []string{"l", "a", "b", "a", "c", "a"})
+
+	msg, _ := handleLInsert(s, []string{"l", "after", "b", "x"})
+	if msg.Content.(int64) != 6 {
+		t.Errorf("LINSERT = %v", msg.Content)
+	}
+	msg, _ = handleLInsert(s, []string{"l", "BEFORE", "nope", "x"})
+	if msg.Content.(int64) != -1 {
+		t.Errorf("LINSERT missing pivot = %v", msg.Content)
+	}
+	msg, _ = handleLInsert(s, []string{"missing", "BEFORE", "a", "x"})
+	if msg.Content.(int64) != 0 {
+		t.Errorf("LINSERT missing key = %v", msg.Content)
+	}
+	if _, err := handleLInsert(s, []string{"l", "AROUND", "a", "x"}); err == nil {
+		t.Error("LINSERT with bad position should fail")
+	}
+
+	msg, _ = handleLRem(s, []string{"l", "-2", "a"})
+	if msg.Content.(int64) != 2 {
+		t.Errorf("LREM = %v", msg.Content)
+	}
+	if got := rangeOf(t, s, "l"); !reflect.DeepEqual(got, []string{"a", "b", "x", "c"}) {
+		t.Errorf("after LREM: %v", got)
+	}
+
+	handleLTrim(s, []string{"l", "1", "-2"})
+	if got := rangeOf(t, s, "l"); !reflect.DeepEqual(got, []string{"b", "x"}) {
+		t.Errorf("after LTRIM: %v", got)
+	}
+	handleLTrim(s, []string{"l", "5", "10"})
+	if n, _ := s.LLen("l"); n != 0 {
+		t.Errorf("LTRIM out of range left %d elements", n)
+	}
+}
+
+func TestHandleLPos(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	handleRPush(s, []string{"l", "a", "b", "c", "1", "2", "3", "c", "c"})
+
+	cases := []struct {
+		args []string
+		want interface{}
+	}{
+		{[]string{"l", "c"}, int64(2)},
+		{[]string{"l", "c", "RANK", "2"}, int64(6)},
+		{[]string{"l", "c", "RANK", "-1"}, int64(7)},
+		{[]string{"l", "c", "COUNT", "2"}, []int64{2, 6}},
+		{[]string{"l", "c", "COUNT", "0", "RANK", "-1"}, []int64{7, 6, 2}},
+		{[]string{"l", "c", "COUNT", "0", "MAXLEN", "7"}, []int64{2, 6}},
+		{[]string{"l", "z"}, nil},
+		{[]string{"l", "z", "COUNT", "0"}, []int64{}},
+		{[]string{"missing", "c", "COUNT", "1"}, []int64{}},
+	}
+	for _, c := range cases {
+		msg, err := handleLPos(s, c.args)
+		if err != nil {
+			t.Fatalf("LPOS %v failed: %v", c.args, err)
+		}
+		var got interface{}
+		switch msg.Type {
+		case "Integer":
+			got = msg.Content
+		case "Array":
+			positions := []int64{}
+			for _, m := range msg.Content.([]*protocol.Message) {
+				positions = append(positions, m.Content.(int64))
+			}
+			got = positions
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("LPOS %v = %v, want %v", c.args, got, c.want)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"l", "c", "RANK", "0"},
+		{"l", "c", "COUNT", "-1"},
+		{"l", "c", "MAXLEN", "-1"},
+		{"l", "c", "RANK"},
+		{"l", "c", "FOO", "1"},
+	} {
+		if _, err := handleLPos(s, args); err == nil {
+			t.Errorf("LPOS %v should fail", args)
+		}
+	}
+}
+
+func TestHandleLMoveAndLMPop(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	handleRPush(s, []string{"src", "a", "b", "c"})
+
+	msg, _ := handleLMove(s, []string{"src", "dst", "LEFT", "RIGHT"})
+	if string(msg.Content.([]byte)) != "a" {
+		t.Errorf("LMOVE = %v", msg.Content)
+	}
+	msg, _ = handleRPopLPush(s, []string{"src", "dst"})
+	if string(msg.Content.([]byte)) != "c" {
+		t.Errorf("RPOPLPUSH = %v", msg.Content)
+	}
+	if got := rangeOf(t, s, "dst"); !reflect.DeepEqual(got, []string{"c", "a"}) {
+		t.Errorf("dst = %v", got)
+	}
+	msg, _ = handleLMove(s, []string{"missing", "dst", "LEFT", "LEFT"})
+	if msg.Content != nil {
+		t.Errorf("LMOVE from missing key = %v", msg.Content)
+	}
+
+	msg, err := handleLMPop(s, []string{"3", "missing", "dst", "src", "RIGHT", "COUNT", "5"})
+	if err != nil {
+		t.Fatalf("LMPOP failed: %v", err)
+	}
+	reply := msg.Content.([]*protocol.Message)
+	if string(reply[0].Content.([]byte)) != "dst" || len(reply[1].Content.([][]byte)) != 2 {
+		t.Errorf("LMPOP = %v %v", reply[0].Content, reply[1].Content)
+	}
+	msg, _ = handleLMPop(s, []string{"1", "dst", "LEFT"})
+	if msg.Content.([]*protocol.Message) != nil {
+		t.Errorf("LMPOP on empty keys = %v", msg.Content)
+	}
+	if _, err := handleLMPop(s, []string{"2", "a", "LEFT"}); err == nil {
+		t.Error("LMPOP with too few keys should fail")
+	}
+	if _, err := handleLMPop(s, []string{"9223372036854775807", "k", "LEFT"}); err != consts.ErrSyntaxError {
+		t.Errorf("LMPOP with huge numkeys = %v, want syntax error", err)
+	}
+
+	msg, _ = handleLPushX(s, []string{"missing", "x"})
+	if msg.Content.(int64) != 0 {
+		t.Errorf("LPUSHX on missing key = %v", msg.Content)
+	}
+	msg, _ = handleRPushX(s, []string{"src", "x", "y"})
+	if msg.Content.(int64) != 3 {
+		t.Errorf("RPUSHX = %v", msg.Content)
+	}
+}
+
+func TestHandleListWrongType(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	s.Set("s", []byte("x"))
+	handleRPush(s, []string{"big", "1", "2"})
+
+	for _, c := range []struct {
+		name    string
+		handler func(storage.Storage, []string) (*protocol.Message, error)
+		args    []string
+	}{
+		{"LMOVE into string", handleLMove, []string{"big", "s", "LEFT", "LEFT"}},
+		{"RPOPLPUSH into string", handleRPopLPush, []string{"big", "s"}},
+		{"LMOVE from string", handleLMove, []string{"s", "big", "LEFT", "LEFT"}},
+		{"LPUSHX", handleLPushX, []string{"s", "a"}},
+		{"RPUSHX", handleRPushX, []string{"s", "a"}},
+		{"LINSERT", handleLInsert, []string{"s", "BEFORE", "x", "a"}},
+		{"LMPOP", handleLMPop, []string{"2", "s", "big", "LEFT"}},
+		{"LPUSH", handleLPush, []string{"s", "a"}},
+	} {
+		if _, err := c.handler(s, c.args); err != storage.ErrWrongType {
+			t.Errorf("%s: err = %v, want WRONGTYPE", c.name, err)
+		}
+	}
+
+	if typ, _ := s.Type("s"); typ != "string" {
+		t.Errorf("TYPE s = %s", typ)
+	}
+	if v, _ := s.Get("s"); string(v) != "x" {
+		t.Errorf("GET s = %q", v)
+	}
+	if got := rangeOf(t, s, "big"); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("big = %v", got)
+	}
+}
@@ -46,7 +46,7 @@ func handleSAdd(storage storage.Storage, args []string) (*protocol.Message, erro
 
 	log.Printf("SADD %s: added %d members, took %v", key, added, duration)
 
-	return &protocol.Message{Type: "Integer", Content: added}, nil
+	return &protocol.Message{Type: "Integer", Content: int64(added)}, nil
 }
 
 func handleSMembers(storage storage.Storage, args []string) (*protocol.Message, error) {
@@ -80,7 +80,7 @@ func handleSRem(storage storage.Storage, args []string) (*protocol.Message, erro
 
 	log.Printf("SREM %s: removed %d members, took %v", key, removed, duration)
 
-	return &protocol.Message{Type: "Integer", Content: removed}, nil
+	return &protocol.Message{Type: "Integer", Content: int64(removed)}, nil
 }
 
 func handleSCard(storage storage.Storage, args []string) (*protocol.Message, error) {
@@ -93,5 +93,5 @@ func handleSCard(storage storage.Storage, args []string) (*protocol.Message, err
 		return nil, err
 	}
 
-	return &protocol.Message{Type: "Integer", Content: count}, nil
+	return &protocol.Message{Type: "Integer", Content: int64(count)}, nil
 }
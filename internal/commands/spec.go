@@ -0,0 +1,283 @@
+package commands
+
+import (
+	"sort"
+	"strings"
+)
+
+// CommandFlag 描述命令的行为特征
+type CommandFlag uint32
+
+const (
+	FlagWrite    CommandFlag = 1 << iota // 会修改数据
+	FlagReadOnly                         // 只读取数据
+	FlagAdmin                            // 管理命令
+	FlagFast                             // 时间复杂度为 O(1) 或 O(log N)
+	FlagPubSub                           // 发布订阅相关
+	FlagNoAuth                           // 认证之前也允许执行
+	FlagDenyOOM                          // 可能增加内存占用，超过 maxmemory 时拒绝
+)
+
+// Categories 所有 ACL 命令分类（不带 @ 前缀）
+var Categories = []string{
+	"keyspace", "read", "write", "set", "sortedset", "list", "hash", "string",
+	"bitmap", "hyperloglog", "geo", "stream", "pubsub", "admin", "fast", "slow",
+	"blocking", "dangerous", "connection", "transaction", "scripting",
+}
+
+// CommandSpec 命令元数据，用于参数个数检查、ACL 权限和键提取
+type CommandSpec struct {
+	Arity    int         // 包含命令名的参数个数，负数表示至少 -Arity 个
+	Flags    CommandFlag // 行为特征
+	Groups   []string    // 不能由 Flags 推导出的 ACL 分类
+	FirstKey int         // 第一个键的位置（命令名为 0），0 表示没有键
+	LastKey  int         // 最后一个键的位置，-1 表示最后一个参数
+	Step     int         // 相邻两个键之间的间隔
+}
+
+var commandSpecs = map[string]CommandSpec{
+	// string
+	"GET":         {Arity: 2, Flags: FlagReadOnly | FlagFast, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"SET":         {Arity: -3, Flags: FlagWrite | FlagDenyOOM, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"APPEND":      {Arity: 3, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"GETRANGE":    {Arity: 4, Flags: FlagReadOnly, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"SETRANGE":    {Arity: 4, Flags: FlagWrite | FlagDenyOOM, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"SUBSTR":      {Arity: 4, Flags: FlagReadOnly, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"STRLEN":      {Arity: 2, Flags: FlagReadOnly | FlagFast, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"INCR":        {Arity: 2, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"DECR":        {Arity: 2, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"INCRBY":      {Arity: 3, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"DECRBY":      {Arity: 3, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"INCRBYFLOAT": {Arity: 3, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"MSET":        {Arity: -3, Flags: FlagWrite | FlagDenyOOM, Groups: []string{"string"}, FirstKey: 1, LastKey: -1, Step: 2},
+	"MSETNX":      {Arity: -3, Flags: FlagWrite | FlagDenyOOM, Groups: []string{"string"}, FirstKey: 1, LastKey: -1, Step: 2},
+	"MGET":        {Arity: -2, Flags: FlagReadOnly | FlagFast, Groups: []string{"string"}, FirstKey: 1, LastKey: -1, Step: 1},
+	"SETNX":       {Arity: 3, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"SETEX":       {Arity: 4, Flags: FlagWrite | FlagDenyOOM, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"PSETEX":      {Arity: 4, Flags: FlagWrite | FlagDenyOOM, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"GETSET":      {Arity: 3, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"GETDEL":      {Arity: 2, Flags: FlagWrite | FlagFast, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"GETEX":       {Arity: -2, Flags: FlagWrite | FlagFast, Groups: []string{"string"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"LCS":         {Arity: -3, Flags: FlagReadOnly, Groups: []string{"string"}, FirstKey: 1, LastKey: 2, Step: 1},
+
+	// bitmap
+	"SETBIT":      {Arity: 4, Flags: FlagWrite | FlagDenyOOM, Groups: []string{"bitmap"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"GETBIT":      {Arity: 3, Flags: FlagReadOnly | FlagFast, Groups: []string{"bitmap"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"BITCOUNT":    {Arity: -2, Flags: FlagReadOnly, Groups: []string{"bitmap"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"BITPOS":      {Arity: -3, Flags: FlagReadOnly, Groups: []string{"bitmap"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"BITOP":       {Arity: -4, Flags: FlagWrite | FlagDenyOOM, Groups: []string{"bitmap"}, FirstKey: 2, LastKey: -1, Step: 1},
+	"BITFIELD":    {Arity: -2, Flags: FlagWrite | FlagDenyOOM, Groups: []string{"bitmap"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"BITFIELD_RO": {Arity: -2, Flags: FlagReadOnly | FlagFast, Groups: []string{"bitmap"}, FirstKey: 1, LastKey: 1, Step: 1},
+
+	// hash
+	"HSET":         {Arity: -4, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HGET":         {Arity: 3, Flags: FlagReadOnly | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HDEL":         {Arity: -3, Flags: FlagWrite | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HLEN":         {Arity: 2, Flags: FlagReadOnly | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HEXISTS":      {Arity: 3, Flags: FlagReadOnly | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HKEYS":        {Arity: 2, Flags: FlagReadOnly, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HVALS":        {Arity: 2, Flags: FlagReadOnly, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HGETALL":      {Arity: 2, Flags: FlagReadOnly, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HMGET":        {Arity: -3, Flags: FlagReadOnly | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HSETNX":       {Arity: 4, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HINCRBY":      {Arity: 4, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HINCRBYFLOAT": {Arity: 4, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HSTRLEN":      {Arity: 3, Flags: FlagReadOnly | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HRANDFIELD":   {Arity: -2, Flags: FlagReadOnly, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HSCAN":        {Arity: -3, Flags: FlagReadOnly, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HEXPIRE":      {Arity: -6, Flags: FlagWrite | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HPEXPIRE":     {Arity: -6, Flags: FlagWrite | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HEXPIREAT":    {Arity: -6, Flags: FlagWrite | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HPEXPIREAT":   {Arity: -6, Flags: FlagWrite | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HTTL":         {Arity: -5, Flags: FlagReadOnly | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HPTTL":        {Arity: -5, Flags: FlagReadOnly | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HEXPIRETIME":  {Arity: -5, Flags: FlagReadOnly | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HPEXPIRETIME": {Arity: -5, Flags: FlagReadOnly | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HPERSIST":     {Arity: -5, Flags: FlagWrite | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HGETEX":       {Arity: -5, Flags: FlagWrite | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"HSETEX":       {Arity: -6, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"hash"}, FirstKey: 1, LastKey: 1, Step: 1},
+
+	// list
+	"LPUSH":     {Arity: -3, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"list"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"RPUSH":     {Arity: -3, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"list"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"LPOP":      {Arity: -2, Flags: FlagWrite | FlagFast, Groups: []string{"list"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"RPOP":      {Arity: -2, Flags: FlagWrite | FlagFast, Groups: []string{"list"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"LLEN":      {Arity: 2, Flags: FlagReadOnly | FlagFast, Groups: []string{"list"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"LRANGE":    {Arity: 4, Flags: FlagReadOnly, Groups: []string{"list"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"LINDEX":    {Arity: 3, Flags: FlagReadOnly, Groups: []string{"list"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"LSET":      {Arity: 4, Flags: FlagWrite | FlagDenyOOM, Groups: []string{"list"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"LPUSHX":    {Arity: -3, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"list"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"RPUSHX":    {Arity: -3, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"list"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"LINSERT":   {Arity: 5, Flags: FlagWrite | FlagDenyOOM, Groups: []string{"list"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"LREM":      {Arity: 4, Flags: FlagWrite, Groups: []string{"list"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"LTRIM":     {Arity: 4, Flags: FlagWrite, Groups: []string{"list"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"LPOS":      {Arity: -3, Flags: FlagReadOnly, Groups: []string{"list"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"LMOVE":     {Arity: 5, Flags: FlagWrite | FlagDenyOOM, Groups: []string{"list"}, FirstKey: 1, LastKey: 2, Step: 1},
+	"RPOPLPUSH": {Arity: 3, Flags: FlagWrite | FlagDenyOOM, Groups: []string{"list"}, FirstKey: 1, LastKey: 2, Step: 1},
+	"LMPOP":     {Arity: -4, Flags: FlagWrite, Groups: []string{"list"}},
+
+	// set
+	"SADD":     {Arity: -3, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"set"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"SREM":     {Arity: -3, Flags: FlagWrite | FlagFast, Groups: []string{"set"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"SMEMBERS": {Arity: 2, Flags: FlagReadOnly, Groups: []string{"set"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"SCARD":    {Arity: 2, Flags: FlagReadOnly | FlagFast, Groups: []string{"set"}, FirstKey: 1, LastKey: 1, Step: 1},
+
+	// sorted set
+	"ZADD":   {Arity: -4, Flags: FlagWrite | FlagDenyOOM | FlagFast, Groups: []string{"sortedset"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZSCORE": {Arity: 3, Flags: FlagReadOnly | FlagFast, Groups: []string{"sortedset"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZREM":   {Arity: -3, Flags: FlagWrite | FlagFast, Groups: []string{"sortedset"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZRANGE": {Arity: -4, Flags: FlagReadOnly, Groups: []string{"sortedset"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"ZCARD":  {Arity: 2, Flags: FlagReadOnly | FlagFast, Groups: []string{"sortedset"}, FirstKey: 1, LastKey: 1, Step: 1},
+
+	// keyspace
+	"KEYS":     {Arity: 2, Flags: FlagReadOnly, Groups: []string{"keyspace", "dangerous"}},
+	"DEL":      {Arity: -2, Flags: FlagWrite, Groups: []string{"keyspace"}, FirstKey: 1, LastKey: -1, Step: 1},
+	"EXISTS":   {Arity: -2, Flags: FlagReadOnly | FlagFast, Groups: []string{"keyspace"}, FirstKey: 1, LastKey: -1, Step: 1},
+	"EXPIRE":   {Arity: -3, Flags: FlagWrite | FlagFast, Groups: []string{"keyspace"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"TTL":      {Arity: 2, Flags: FlagReadOnly | FlagFast, Groups: []string{"keyspace"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"TYPE":     {Arity: 2, Flags: FlagReadOnly | FlagFast, Groups: []string{"keyspace"}, FirstKey: 1, LastKey: 1, Step: 1},
+	"OBJECT":   {Arity: -2, Flags: FlagReadOnly, Groups: []string{"keyspace"}, FirstKey: 2, LastKey: 2, Step: 1},
+	"FLUSHALL": {Arity: -1, Flags: FlagWrite, Groups: []string{"keyspace", "dangerous"}},
+	"FLUSHDB":  {Arity: -1, Flags: FlagWrite, Groups: []string{"keyspace", "dangerous"}},
+
+	// server / connection
+	"SELECT":  {Arity: 2, Flags: FlagFast, Groups: []string{"connection"}},
+	"AUTH":    {Arity: -2, Flags: FlagNoAuth | FlagFast, Groups: []string{"connection"}},
+	"ACL":     {Arity: -2, Flags: FlagAdmin},
+	"CLUSTER": {Arity: -2, Flags: FlagAdmin},
+	"CONFIG":  {Arity: -2, Flags: FlagAdmin},
+	"INFO":    {Arity: -1, Groups: []string{"dangerous"}},
+	"SLOWLOG": {Arity: -2, Flags: FlagAdmin},
+	"MONITOR": {Arity: 1, Flags: FlagAdmin},
+	"LATENCY": {Arity: -2, Flags: FlagAdmin},
+
+	"PING":   {Arity: -1, Flags: FlagFast, Groups: []string{"connection"}},
+	"QUIT":   {Arity: -1, Flags: FlagNoAuth | FlagFast, Groups: []string{"connection"}},
+	"RESET":  {Arity: 1, Flags: FlagNoAuth | FlagFast, Groups: []string{"connection"}},
+	"HELLO":  {Arity: -1, Flags: FlagNoAuth | FlagFast, Groups: []string{"connection"}},
+	"CLIENT": {Arity: -2, Groups: []string{"connection"}},
+
+	// pubsub
+	"SUBSCRIBE":    {Arity: -2, Flags: FlagPubSub},
+	"PSUBSCRIBE":   {Arity: -2, Flags: FlagPubSub},
+	"SSUBSCRIBE":   {Arity: -2, Flags: FlagPubSub},
+	"UNSUBSCRIBE":  {Arity: -1, Flags: FlagPubSub},
+	"PUNSUBSCRIBE": {Arity: -1, Flags: FlagPubSub},
+	"SUNSUBSCRIBE": {Arity: -1, Flags: FlagPubSub},
+	"PUBLISH":      {Arity: 3, Flags: FlagPubSub | FlagFast},
+	"SPUBLISH":     {Arity: 3, Flags: FlagPubSub | FlagFast},
+	"PUBSUB":       {Arity: -2, Flags: FlagPubSub},
+
+	// transaction
+	"MULTI":   {Arity: 1, Flags: FlagFast, Groups: []string{"transaction"}},
+	"EXEC":    {Arity: 1, Groups: []string{"transaction"}},
+	"DISCARD": {Arity: 1, Flags: FlagFast, Groups: []string{"transaction"}},
+	"WATCH":   {Arity: -2, Flags: FlagFast, Groups: []string{"transaction"}, FirstKey: 1, LastKey: -1, Step: 1},
+	"UNWATCH": {Arity: 1, Flags: FlagFast, Groups: []string{"transaction"}},
+
+	// scripting，键由 numkeys 决定，在 redis.call 时检查
+	"EVAL":       {Arity: -3, Groups: []string{"scripting"}},
+	"EVALSHA":    {Arity: -3, Groups: []string{"scripting"}},
+	"EVAL_RO":    {Arity: -3, Flags: FlagReadOnly, Groups: []string{"scripting"}},
+	"EVALSHA_RO": {Arity: -3, Flags: FlagReadOnly, Groups: []string{"scripting"}},
+	"SCRIPT":     {Arity: -2, Groups: []string{"scripting"}},
+	"FCALL":      {Arity: -3, Groups: []string{"scripting"}},
+	"FCALL_RO":   {Arity: -3, Flags: FlagReadOnly, Groups: []string{"scripting"}},
+	"FUNCTION":   {Arity: -2, Groups: []string{"scripting"}},
+
+	// blocking list
+	"BLPOP":      {Arity: -3, Flags: FlagWrite, Groups: []string{"list", "blocking"}, FirstKey: 1, LastKey: -2, Step: 1},
+	"BRPOP":      {Arity: -3, Flags: FlagWrite, Groups: []string{"list", "blocking"}, FirstKey: 1, LastKey: -2, Step: 1},
+	"BLMOVE":     {Arity: 6, Flags: FlagWrite | FlagDenyOOM, Groups: []string{"list", "blocking"}, FirstKey: 1, LastKey: 2, Step: 1},
+	"BRPOPLPUSH": {Arity: 4, Flags: FlagWrite | FlagDenyOOM, Groups: []string{"list", "blocking"}, FirstKey: 1, LastKey: 2, Step: 1},
+	"BLMPOP":     {Arity: -5, Flags: FlagWrite, Groups: []string{"list", "blocking"}},
+}
+
+// LookupSpec 返回命令的元数据，name 不区分大小写
+func LookupSpec(name string) (CommandSpec, bool) {
+	spec, ok := commandSpecs[strings.ToUpper(name)]
+	return spec, ok
+}
+
+// CommandNames 返回所有已知命令的名称（大写，已排序）
+func CommandNames() []string {
+	names := make([]string, 0, len(commandSpecs))
+	for name := range commandSpecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckArity 检查参数个数（argc 包含命令名）
+func (s CommandSpec) CheckArity(argc int) bool {
+	if s.Arity > 0 {
+		return argc == s.Arity
+	}
+	return argc >= -s.Arity
+}
+
+// Categories 返回命令所属的全部 ACL 分类
+func (s CommandSpec) Categories() []string {
+	var cats []string
+	if s.Flags&FlagWrite != 0 {
+		cats = append(cats, "write")
+	}
+	if s.Flags&FlagReadOnly != 0 {
+		cats = append(cats, "read")
+	}
+	if s.Flags&FlagAdmin != 0 {
+		cats = append(cats, "admin", "dangerous")
+	}
+	if s.Flags&FlagPubSub != 0 {
+		cats = append(cats, "pubsub")
+	}
+	if s.Flags&FlagFast != 0 {
+		cats = append(cats, "fast")
+	} else {
+		cats = append(cats, "slow")
+	}
+	for _, g := range s.Groups {
+		if !containsString(cats, g) {
+			cats = append(cats, g)
+		}
+	}
+	return cats
+}
+
+// InCategory 判断命令是否属于某个 ACL 分类，"all" 匹配所有命令
+func (s CommandSpec) InCategory(category string) bool {
+	if category == "all" {
+		return true
+	}
+	return containsString(s.Categories(), category)
+}
+
+// Keys 按照键位置从参数中提取键名，args 不包含命令名
+func (s CommandSpec) Keys(args []string) []string {
+	if s.FirstKey <= 0 {
+		return nil
+	}
+	last := s.LastKey
+	if last < 0 {
+		last = len(args) + 1 + last
+	}
+	step := s.Step
+	if step <= 0 {
+		step = 1
+	}
+	var keys []string
+	for i := s.FirstKey; i <= last && i <= len(args); i += step {
+		keys = append(keys, args[i-1])
+	}
+	return keys
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEveryCommandHasSpec(t *testing.T) {
+	for _, cmd := range CommandList {
+		if _, ok := LookupSpec(cmd.Name); !ok {
+			t.Errorf("command %s has no spec", cmd.Name)
+		}
+	}
+}
+
+func TestCommandSpecKeys(t *testing.T) {
+	del, _ := LookupSpec("del")
+	if got := del.Keys([]string{"a", "b", "c"}); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("DEL keys = %v", got)
+	}
+	get, _ := LookupSpec("GET")
+	if got := get.Keys([]string{"a"}); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("GET keys = %v", got)
+	}
+	keys, _ := LookupSpec("KEYS")
+	if got := keys.Keys([]string{"*"}); got != nil {
+		t.Errorf("KEYS keys = %v", got)
+	}
+}
+
+func TestCommandSpecCategories(t *testing.T) {
+	get, _ := LookupSpec("GET")
+	for _, cat := range []string{"read", "fast", "string", "all"} {
+		if !get.InCategory(cat) {
+			t.Errorf("GET should be in @%s", cat)
+		}
+	}
+	if get.InCategory("write") || get.InCategory("slow") {
+		t.Errorf("GET categories = %v", get.Categories())
+	}
+
+	flushall, _ := LookupSpec("FLUSHALL")
+	if !flushall.InCategory("dangerous") || !flushall.InCategory("write") {
+		t.Errorf("FLUSHALL categories = %v", flushall.Categories())
+	}
+}
+
+func TestCommandSpecArity(t *testing.T) {
+	get, _ := LookupSpec("GET")
+	if !get.CheckArity(2) || get.CheckArity(1) || get.CheckArity(3) {
+		t.Error("GET arity should be exactly 2")
+	}
+	set, _ := LookupSpec("SET")
+	if !set.CheckArity(3) || !set.CheckArity(5) || set.CheckArity(2) {
+		t.Error("SET arity should be at least 3")
+	}
+}
@@ -3,8 +3,11 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"literedis/internal/consts"
+	"literedis/internal/datastruct/dsstring"
 	"literedis/internal/storage"
 	"literedis/pkg/protocol"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -16,45 +19,97 @@ func registerStringCommands() {
 	RegisterCommand("APPEND", handleAppend)
 	RegisterCommand("GETRANGE", handleGetRange)
 	RegisterCommand("SETRANGE", handleSetRange)
+	RegisterCommand("SUBSTR", handleGetRange)
+	RegisterCommand("STRLEN", handleStrLen)
+	RegisterCommand("INCR", handleIncr)
+	RegisterCommand("DECR", handleDecr)
+	RegisterCommand("INCRBY", handleIncrBy)
+	RegisterCommand("DECRBY", handleDecrBy)
+	RegisterCommand("INCRBYFLOAT", handleIncrByFloat)
+	RegisterCommand("MSET", handleMSet)
+	RegisterCommand("MSETNX", handleMSetNX)
+	RegisterCommand("MGET", handleMGet)
+	RegisterCommand("SETNX", handleSetNX)
+	RegisterCommand("SETEX", handleSetEX)
+	RegisterCommand("PSETEX", handlePSetEX)
+	RegisterCommand("GETSET", handleGetSet)
+	RegisterCommand("GETDEL", handleGetDel)
+	RegisterCommand("GETEX", handleGetEx)
+	RegisterCommand("LCS", handleLCS)
 }
 
+// handleSet SET key value [NX|XX] [GET] [EX seconds|PX milliseconds|EXAT timestamp|PXAT timestamp|KEEPTTL]
 func handleSet(s storage.Storage, args []string) (*protocol.Message, error) {
 	if len(args) < 2 {
 		return nil, errors.New("SET command requires at least two arguments")
 	}
 
 	key, value := args[0], []byte(args[1])
-	var expiration time.Duration = 0
-
-	if len(args) > 2 {
-		if strings.ToUpper(args[2]) == "EX" && len(args) > 3 {
-			seconds, err := strconv.Atoi(args[3])
-			if err != nil {
-				return nil, fmt.Errorf("invalid expiration time: %v", err)
-			}
-			expiration = time.Duration(seconds) * time.Second
-		} else if strings.ToUpper(args[2]) == "PX" && len(args) > 3 {
-			milliseconds, err := strconv.Atoi(args[3])
-			if err != nil {
-				return nil, fmt.Errorf("invalid expiration time: %v", err)
-			}
-			expiration = time.Duration(milliseconds) * time.Millisecond
-		}
+	opts, err := parseSetOptions(args[2:])
+	if err != nil {
+		return nil, err
 	}
 
-	err := s.Set(key, value)
+	old, ok, err := s.SetWithOptions(key, value, opts)
 	if err != nil {
 		return nil, err
 	}
+	if opts.Get && old != nil {
+		return &protocol.Message{Type: "BulkString", Content: old}, nil
+	}
+	if opts.Get || !ok {
+		return &protocol.Message{Type: "BulkString", Content: nil}, nil
+	}
+	return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+}
 
-	if expiration > 0 {
-		_, err = s.Expire(key, expiration)
-		if err != nil {
-			return nil, err
+// parseSetOptions 解析 SET 的选项，顺序任意；NX 与 XX、多个过期选项之间互斥
+func parseSetOptions(args []string) (storage.SetOptions, error) {
+	var opts storage.SetOptions
+	// 过期选项的参数在所有选项检查通过之后再解析
+	var expireOpt, expireArg string
+	for i := 0; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); opt {
+		case "NX":
+			if opts.XX {
+				return opts, consts.ErrSyntaxError
+			}
+			opts.NX = true
+		case "XX":
+			if opts.NX {
+				return opts, consts.ErrSyntaxError
+			}
+			opts.XX = true
+		case "GET":
+			opts.Get = true
+		case "KEEPTTL":
+			if expireOpt != "" {
+				return opts, consts.ErrSyntaxError
+			}
+			opts.KeepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if expireOpt != "" || opts.KeepTTL || i+1 >= len(args) {
+				return opts, consts.ErrSyntaxError
+			}
+			expireOpt, expireArg = opt, args[i+1]
+			i++
+		default:
+			return opts, consts.ErrSyntaxError
 		}
 	}
 
-	return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+	if expireOpt != "" {
+		unit := time.Second
+		if expireOpt[0] == 'P' {
+			unit = time.Millisecond
+		}
+		expireAt, err := parseExpireTime("set", expireArg, unit, strings.HasSuffix(expireOpt, "AT"))
+		if err != nil {
+			return opts, err
+		}
+		opts.ExpireAt = expireAt
+	}
+	return opts, nil
 }
 
 func handleGet(s storage.Storage, args []string) (*protocol.Message, error) {
@@ -99,6 +154,9 @@ func handleGetRange(s storage.Storage, args []string) (*protocol.Message, error)
 		return nil, fmt.Errorf("invalid end index: %v", err)
 	}
 	value, err := s.GetRange(key, start, end)
+	if errors.Is(err, consts.ErrKeyNotFound) {
+		return &protocol.Message{Type: "BulkString", Content: []byte{}}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -121,3 +179,329 @@ func handleSetRange(s storage.Storage, args []string) (*protocol.Message, error)
 	}
 	return &protocol.Message{Type: "Integer", Content: int64(newLength)}, nil
 }
+
+func handleStrLen(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 1 {
+		return nil, consts.ErrInvalidArgument
+	}
+	length, err := s.StrLen(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "Integer", Content: int64(length)}, nil
+}
+
+func handleIncr(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 1 {
+		return nil, consts.ErrInvalidArgument
+	}
+	return incrBy(s, args[0], 1)
+}
+
+func handleDecr(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 1 {
+		return nil, consts.ErrInvalidArgument
+	}
+	return incrBy(s, args[0], -1)
+}
+
+func handleIncrBy(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 2 {
+		return nil, consts.ErrInvalidArgument
+	}
+	delta, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, consts.ErrNotInteger
+	}
+	return incrBy(s, args[0], delta)
+}
+
+func handleDecrBy(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 2 {
+		return nil, consts.ErrInvalidArgument
+	}
+	delta, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, consts.ErrNotInteger
+	}
+	if delta == math.MinInt64 {
+		return nil, errors.New("decrement would overflow")
+	}
+	return incrBy(s, args[0], -delta)
+}
+
+func incrBy(s storage.Storage, key string, delta int64) (*protocol.Message, error) {
+	value, err := s.IncrBy(key, delta)
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "Integer", Content: value}, nil
+}
+
+func handleIncrByFloat(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 2 {
+		return nil, consts.ErrInvalidArgument
+	}
+	delta, err := storage.ParseFloat([]byte(args[1]))
+	if err != nil {
+		return nil, err
+	}
+	value, err := s.IncrByFloat(args[0], delta)
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "BulkString", Content: value}, nil
+}
+
+// splitPairs 拆分 MSET、MSETNX 的键值对
+func splitPairs(cmd string, args []string) ([]string, [][]byte, error) {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return nil, nil, fmt.Errorf("wrong number of arguments for '%s' command", cmd)
+	}
+	keys := make([]string, 0, len(args)/2)
+	values := make([][]byte, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		keys = append(keys, args[i])
+		values = append(values, []byte(args[i+1]))
+	}
+	return keys, values, nil
+}
+
+func handleMSet(s storage.Storage, args []string) (*protocol.Message, error) {
+	keys, values, err := splitPairs("mset", args)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.MSet(keys, values); err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+}
+
+func handleMSetNX(s storage.Storage, args []string) (*protocol.Message, error) {
+	keys, values, err := splitPairs("msetnx", args)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.MSetNX(keys, values)
+	if err != nil {
+		return nil, err
+	}
+	return boolReply(ok), nil
+}
+
+func handleMGet(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) == 0 {
+		return nil, consts.ErrInvalidArgument
+	}
+	values, err := s.MGet(args...)
+	if err != nil {
+		return nil, err
+	}
+	reply := make([]*protocol.Message, len(values))
+	for i, v := range values {
+		reply[i] = &protocol.Message{Type: "BulkString", Content: v}
+	}
+	return &protocol.Message{Type: "Array", Content: reply}, nil
+}
+
+func handleSetNX(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 2 {
+		return nil, consts.ErrInvalidArgument
+	}
+	ok, err := s.SetNX(args[0], []byte(args[1]))
+	if err != nil {
+		return nil, err
+	}
+	return boolReply(ok), nil
+}
+
+func handleSetEX(s storage.Storage, args []string) (*protocol.Message, error) {
+	return setWithExpire(s, args, "setex", time.Second)
+}
+
+func handlePSetEX(s storage.Storage, args []string) (*protocol.Message, error) {
+	return setWithExpire(s, args, "psetex", time.Millisecond)
+}
+
+// setWithExpire SETEX key seconds value 和 PSETEX key milliseconds value
+func setWithExpire(s storage.Storage, args []string, cmd string, unit time.Duration) (*protocol.Message, error) {
+	if len(args) != 3 {
+		return nil, consts.ErrInvalidArgument
+	}
+	expireAt, err := parseExpireTime(cmd, args[1], unit, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.SetEX(args[0], []byte(args[2]), time.Until(expireAt)); err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "SimpleString", Content: "OK"}, nil
+}
+
+// parseExpireTime 解析以 unit 为单位的过期时间，absolute 为 true 时是 Unix 时间戳。
+// 非正数或者换算成毫秒之后溢出时返回与 Redis 相同的错误
+func parseExpireTime(cmd, arg string, unit time.Duration, absolute bool) (time.Time, error) {
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return time.Time{}, consts.ErrNotInteger
+	}
+	invalid := fmt.Errorf("invalid expire time in '%s' command", cmd)
+	scale := int64(unit / time.Millisecond)
+	if n <= 0 || n > math.MaxInt64/scale {
+		return time.Time{}, invalid
+	}
+	ms := n * scale
+	if !absolute {
+		now := time.Now().UnixMilli()
+		if ms > math.MaxInt64-now {
+			return time.Time{}, invalid
+		}
+		ms += now
+	}
+	return time.UnixMilli(ms), nil
+}
+
+func handleGetSet(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 2 {
+		return nil, consts.ErrInvalidArgument
+	}
+	return bulkOrNil(s.GetSet(args[0], []byte(args[1])))
+}
+
+func handleGetDel(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) != 1 {
+		return nil, consts.ErrInvalidArgument
+	}
+	return bulkOrNil(s.GetDel(args[0]))
+}
+
+// handleGetEx GETEX key [EX seconds|PX milliseconds|EXAT timestamp|PXAT timestamp|PERSIST]
+func handleGetEx(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) == 0 {
+		return nil, consts.ErrInvalidArgument
+	}
+	var expireAt time.Time
+	persist := false
+	switch opts := args[1:]; {
+	case len(opts) == 0:
+	case len(opts) == 1 && strings.ToUpper(opts[0]) == "PERSIST":
+		persist = true
+	case len(opts) == 2:
+		var err error
+		switch strings.ToUpper(opts[0]) {
+		case "EX":
+			expireAt, err = parseExpireTime("getex", opts[1], time.Second, false)
+		case "PX":
+			expireAt, err = parseExpireTime("getex", opts[1], time.Millisecond, false)
+		case "EXAT":
+			expireAt, err = parseExpireTime("getex", opts[1], time.Second, true)
+		case "PXAT":
+			expireAt, err = parseExpireTime("getex", opts[1], time.Millisecond, true)
+		default:
+			return nil, consts.ErrSyntaxError
+		}
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, consts.ErrSyntaxError
+	}
+	return bulkOrNil(s.GetEx(args[0], expireAt, persist))
+}
+
+// bulkOrNil 键不存在时回复 nil
+func bulkOrNil(value []byte, err error) (*protocol.Message, error) {
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return &protocol.Message{Type: "BulkString", Content: nil}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Message{Type: "BulkString", Content: value}, nil
+}
+
+func boolReply(ok bool) *protocol.Message {
+	if ok {
+		return &protocol.Message{Type: "Integer", Content: int64(1)}
+	}
+	return &protocol.Message{Type: "Integer", Content: int64(0)}
+}
+
+// handleLCS LCS key1 key2 [LEN] [IDX] [MINMATCHLEN len] [WITHMATCHLEN]
+func handleLCS(s storage.Storage, args []string) (*protocol.Message, error) {
+	if len(args) < 2 {
+		return nil, consts.ErrInvalidArgument
+	}
+	var getLen, getIdx, withMatchLen bool
+	var minMatchLen int64
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "LEN":
+			getLen = true
+		case "IDX":
+			getIdx = true
+		case "WITHMATCHLEN":
+			withMatchLen = true
+		case "MINMATCHLEN":
+			if i+1 >= len(args) {
+				return nil, consts.ErrSyntaxError
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return nil, consts.ErrNotInteger
+			}
+			minMatchLen = max(n, 0)
+			i++
+		default:
+			return nil, consts.ErrSyntaxError
+		}
+	}
+	if getLen && getIdx {
+		return nil, errors.New("If you want both the length and indexes, please just use IDX.")
+	}
+
+	var values [2][]byte
+	for i, key := range args[:2] {
+		value, err := s.GetString(key)
+		switch {
+		case errors.Is(err, storage.ErrWrongType):
+			return nil, errors.New("The specified keys must contain string values")
+		case err != nil && !errors.Is(err, storage.ErrKeyNotFound):
+			return nil, err
+		}
+		values[i] = value
+	}
+	seq, matches := dsstring.LCS(values[0], values[1])
+	switch {
+	case getLen:
+		return &protocol.Message{Type: "Integer", Content: int64(len(seq))}, nil
+	case !getIdx:
+		return &protocol.Message{Type: "BulkString", Content: seq}, nil
+	}
+
+	reply := []*protocol.Message{}
+	for _, m := range matches {
+		if int64(m.Len) < minMatchLen {
+			continue
+		}
+		match := []*protocol.Message{rangeReply(m.A), rangeReply(m.B)}
+		if withMatchLen {
+			match = append(match, &protocol.Message{Type: "Integer", Content: int64(m.Len)})
+		}
+		reply = append(reply, &protocol.Message{Type: "Array", Content: match})
+	}
+	return &protocol.Message{Type: "Array", Content: []*protocol.Message{
+		{Type: "BulkString", Content: "matches"},
+		{Type: "Array", Content: reply},
+		{Type: "BulkString", Content: "len"},
+		{Type: "Integer", Content: int64(len(seq))},
+	}}, nil
+}
+
+func rangeReply(r [2]int) *protocol.Message {
+	return &protocol.Message{Type: "Array", Content: []*protocol.Message{
+		{Type: "Integer", Content: int64(r[0])},
+		{Type: "Integer", Content: int64(r[1])},
+	}}
+}
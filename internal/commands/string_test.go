@@ -0,0 +1,327 @@
+package commands
+
+import (
+	"literedis/internal/storage"
+	"literedis/pkg/protocol"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHandleIncrDecr(t *testing.T) {
+	s := storage.NewMemoryStorage()
+
+	msg, _ := handleIncr(s, []string{"n"})
+	if msg.Content.(int64) != 1 {
+		t.Errorf("INCR on missing key = %v", msg.Content)
+	}
+	handleIncrBy(s, []string{"n", "41"})
+	handleDecrBy(s, []string{"n", "2"})
+	msg, _ = handleDecr(s, []string{"n"})
+	if msg.Content.(int64) != 39 {
+		t.Errorf("after INCRBY/DECRBY/DECR = %v", msg.Content)
+	}
+	if enc, _ := s.ObjectEncoding("n"); enc != "int" {
+		t.Errorf("OBJECT ENCODING = %s, want int", enc)
+	}
+
+	s.Set("max", []byte(strconv.FormatInt(math.MaxInt64, 10)))
+	if _, err := handleIncr(s, []string{"max"}); err == nil || err.Error() != "increment or decrement would overflow" {
+		t.Errorf("INCR at MaxInt64: %v", err)
+	}
+	if _, err := handleDecrBy(s, []string{"n", "-9223372036854775808"}); err == nil || err.Error() != "decrement would overflow" {
+		t.Errorf("DECRBY MinInt64: %v", err)
+	}
+	s.Set("s", []byte("abc"))
+	for _, args := range [][]string{{"s", "1"}, {"n", "1.5"}, {"n", "x"}} {
+		if _, err := handleIncrBy(s, args); err == nil || err.Error() != "value is not an integer or out of range" {
+			t.Errorf("INCRBY %v: %v", args, err)
+		}
+	}
+	s.LPush("list", []byte("a"))
+	if _, err := handleIncr(s, []string{"list"}); err != storage.ErrWrongType {
+		t.Errorf("INCR on a list: %v", err)
+	}
+}
+
+func TestHandleIncrByFloat(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	s.Set("f", []byte("10.50"))
+
+	msg, err := handleIncrByFloat(s, []string{"f", "0.1"})
+	if err != nil || string(msg.Content.([]byte)) != "10.6" {
+		t.Errorf("INCRBYFLOAT = %v, %v", msg, err)
+	}
+	s.Set("e", []byte("5.0e3"))
+	msg, _ = handleIncrByFloat(s, []string{"e", "2.0e2"})
+	if string(msg.Content.([]byte)) != "5200" {
+		t.Errorf("INCRBYFLOAT with exponents = %s", msg.Content)
+	}
+	if _, err := handleIncrByFloat(s, []string{"f", "inf"}); err == nil || err.Error() != "increment would produce NaN or Infinity" {
+		t.Errorf("INCRBYFLOAT inf: %v", err)
+	}
+	if _, err := handleIncrByFloat(s, []string{"f", "abc"}); err == nil || err.Error() != "value is not a valid float" {
+		t.Errorf("INCRBYFLOAT abc: %v", err)
+	}
+}
+
+func TestHandleMSetMGet(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	if _, err := handleMSet(s, []string{"a", "1", "b"}); err == nil {
+		t.Error("MSET with an odd number of arguments should fail")
+	}
+	handleMSet(s, []string{"a", "1", "b", "2"})
+	s.LPush("l", []byte("x"))
+
+	msg, _ := handleMGet(s, []string{"a", "missing", "b", "l"})
+	reply := msg.Content.([]*protocol.Message)
+	want := []string{"1", "", "2", ""}
+	for i, m := range reply {
+		got, _ := m.Content.([]byte)
+		if string(got) != want[i] || (want[i] == "" && got != nil) {
+			t.Errorf("MGET[%d] = %v", i, m.Content)
+		}
+	}
+
+	msg, _ = handleMSetNX(s, []string{"c", "3", "a", "9"})
+	if msg.Content.(int64) != 0 || s.Exists("c") {
+		t.Error("MSETNX should not set anything when a key exists")
+	}
+	msg, _ = handleMSetNX(s, []string{"c", "3", "d", "4"})
+	if msg.Content.(int64) != 1 || !s.Exists("d") {
+		t.Error("MSETNX should set all keys")
+	}
+	msg, _ = handleSetNX(s, []string{"c", "x"})
+	if msg.Content.(int64) != 0 {
+		t.Error("SETNX on an existing key")
+	}
+}
+
+func TestHandleExpiringSets(t *testing.T) {
+	s := storage.NewMemoryStorage()
+
+	if _, err := handleSetEX(s, []string{"k", "100", "v"}); err != nil {
+		t.Fatalf("SETEX failed: %v", err)
+	}
+	if ttl, _ := s.TTL("k"); ttl <= 99*time.Second || ttl > 100*time.Second {
+		t.Errorf("TTL after SETEX = %v", ttl)
+	}
+	for _, args := range [][]string{{"k", "0", "v"}, {"k", "-1", "v"}, {"k", "9223372036854775807", "v"}} {
+		if _, err := handleSetEX(s, args); err == nil || err.Error() != "invalid expire time in 'setex' command" {
+			t.Errorf("SETEX %v: %v", args, err)
+		}
+	}
+	handlePSetEX(s, []string{"p", "1500", "v"})
+	if ttl, _ := s.TTL("p"); ttl <= time.Second || ttl > 1500*time.Millisecond {
+		t.Errorf("TTL after PSETEX = %v", ttl)
+	}
+
+	msg, _ := handleGetSet(s, []string{"k", "new"})
+	if string(msg.Content.([]byte)) != "v" {
+		t.Errorf("GETSET = %v", msg.Content)
+	}
+	if ttl, _ := s.TTL("k"); ttl != -time.Second {
+		t.Errorf("GETSET should clear the TTL, got %v", ttl)
+	}
+	msg, _ = handleGetSet(s, []string{"missing", "x"})
+	if msg.Content != nil {
+		t.Errorf("GETSET on missing key = %v", msg.Content)
+	}
+
+	msg, _ = handleGetDel(s, []string{"k"})
+	if string(msg.Content.([]byte)) != "new" || s.Exists("k") {
+		t.Errorf("GETDEL = %v", msg.Content)
+	}
+	msg, _ = handleGetDel(s, []string{"k"})
+	if msg.Content != nil {
+		t.Errorf("GETDEL on missing key = %v", msg.Content)
+	}
+}
+
+func TestHandleGetEx(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	s.Set("k", []byte("v"))
+
+	msg, _ := handleGetEx(s, []string{"k", "EX", "100"})
+	if string(msg.Content.([]byte)) != "v" {
+		t.Errorf("GETEX = %v", msg.Content)
+	}
+	if ttl, _ := s.TTL("k"); ttl <= 99*time.Second {
+		t.Errorf("TTL after GETEX EX = %v", ttl)
+	}
+	handleGetEx(s, []string{"k", "persist"})
+	if ttl, _ := s.TTL("k"); ttl != -time.Second {
+		t.Errorf("TTL after GETEX PERSIST = %v", ttl)
+	}
+	at := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	handleGetEx(s, []string{"k", "EXAT", at})
+	if ttl, _ := s.TTL("k"); ttl < 59*time.Minute {
+		t.Errorf("TTL after GETEX EXAT = %v", ttl)
+	}
+	msg, _ = handleGetEx(s, []string{"k", "PXAT", "1"})
+	if string(msg.Content.([]byte)) != "v" || s.Exists("k") {
+		t.Error("GETEX with a past time should return the value and delete the key")
+	}
+
+	for _, args := range [][]string{
+		{"k", "EX"},
+		{"k", "EX", "1", "PERSIST"},
+		{"k", "FOO", "1"},
+		{"k", "PERSIST", "1"},
+	} {
+		if _, err := handleGetEx(s, args); err == nil || err.Error() != "syntax error" {
+			t.Errorf("GETEX %v: %v", args, err)
+		}
+	}
+	if _, err := handleGetEx(s, []string{"k", "PX", "0"}); err == nil || err.Error() != "invalid expire time in 'getex' command" {
+		t.Errorf("GETEX PX 0: %v", err)
+	}
+}
+
+func TestHandleStrLenSubstr(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	s.Set("k", []byte("hello world"))
+	s.Set("n", []byte("-123"))
+
+	for key, want := range map[string]int64{"k": 11, "n": 4, "missing": 0} {
+		msg, _ := handleStrLen(s, []string{key})
+		if msg.Content.(int64) != want {
+			t.Errorf("STRLEN %s = %v, want %d", key, msg.Content, want)
+		}
+	}
+	msg, _ := handleGetRange(s, []string{"k", "-5", "-1"})
+	if string(msg.Content.([]byte)) != "world" {
+		t.Errorf("SUBSTR = %s", msg.Content)
+	}
+	msg, _ = handleGetRange(s, []string{"n", "1", "2"})
+	if string(msg.Content.([]byte)) != "12" {
+		t.Errorf("SUBSTR on an integer = %s", msg.Content)
+	}
+	msg, _ = handleGetRange(s, []string{"missing", "0", "-1"})
+	if got := msg.Content.([]byte); got == nil || len(got) != 0 {
+		t.Errorf("SUBSTR on missing key = %v", msg.Content)
+	}
+}
+
+func TestHandleLCS(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	handleMSet(s, []string{"key1", "ohmytext", "key2", "mynewtext"})
+
+	msg, _ := handleLCS(s, []string{"key1", "key2"})
+	if string(msg.Content.([]byte)) != "mytext" {
+		t.Errorf("LCS = %s", msg.Content)
+	}
+	msg, _ = handleLCS(s, []string{"key1", "key2", "LEN"})
+	if msg.Content.(int64) != 6 {
+		t.Errorf("LCS LEN = %v", msg.Content)
+	}
+
+	msg, err := handleLCS(s, []string{"key1", "key2", "IDX", "MINMATCHLEN", "4", "WITHMATCHLEN"})
+	if err != nil {
+		t.Fatalf("LCS IDX failed: %v", err)
+	}
+	reply := msg.Content.([]*protocol.Message)
+	matches := reply[1].Content.([]*protocol.Message)
+	if len(matches) != 1 || reply[3].Content.(int64) != 6 {
+		t.Fatalf("LCS IDX = %d matches, len %v", len(matches), reply[3].Content)
+	}
+	match := matches[0].Content.([]*protocol.Message)
+	a := match[0].Content.([]*protocol.Message)
+	if a[0].Content.(int64) != 4 || a[1].Content.(int64) != 7 || match[2].Content.(int64) != 4 {
+		t.Errorf("LCS IDX match = %v %v %v", a[0].Content, a[1].Content, match[2].Content)
+	}
+
+	if _, err := handleLCS(s, []string{"key1", "key2", "LEN", "IDX"}); err == nil {
+		t.Error("LCS with LEN and IDX should fail")
+	}
+	s.LPush("l", []byte("a"))
+	if _, err := handleLCS(s, []string{"key1", "l"}); err == nil || err.Error() != "The specified keys must contain string values" {
+		t.Errorf("LCS on a list = %v", err)
+	}
+	if msg, err := handleLCS(s, []string{"key1", "missing", "LEN"}); err != nil || msg.Content.(int64) != 0 {
+		t.Errorf("LCS with a missing key = %v, %v", msg, err)
+	}
+}
+
+func TestHandleSetOptions(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	reply := func(args ...string) interface{} {
+		t.Helper()
+		msg, err := handleSet(s, args)
+		if err != nil {
+			t.Fatalf("SET %v failed: %v", args, err)
+		}
+		if b, ok := msg.Content.([]byte); ok {
+			return string(b)
+		}
+		return msg.Content
+	}
+
+	if got := reply("lock", "a", "NX", "PX", "30000"); got != "OK" {
+		t.Errorf("SET NX PX = %v", got)
+	}
+	if ttl, _ := s.TTL("lock"); ttl <= 29*time.Second {
+		t.Errorf("TTL after SET NX PX = %v", ttl)
+	}
+	if got := reply("lock", "b", "px", "30000", "nx"); got != nil {
+		t.Errorf("SET NX on a held lock = %v", got)
+	}
+	if got := reply("lock", "c", "GET", "NX"); got != "a" {
+		t.Errorf("SET NX GET = %v", got)
+	}
+
+	if got := reply("lock", "d", "XX", "KEEPTTL", "GET"); got != "a" {
+		t.Errorf("SET XX KEEPTTL GET = %v", got)
+	}
+	if ttl, _ := s.TTL("lock"); ttl <= 29*time.Second {
+		t.Errorf("KEEPTTL lost the TTL: %v", ttl)
+	}
+	reply("lock", "e")
+	if ttl, _ := s.TTL("lock"); ttl != -time.Second {
+		t.Errorf("plain SET should clear the TTL, got %v", ttl)
+	}
+
+	if got := reply("missing", "v", "XX"); got != nil {
+		t.Errorf("SET XX on missing key = %v", got)
+	}
+	if got := reply("missing", "v", "GET"); got != nil {
+		t.Errorf("SET GET on missing key = %v", got)
+	}
+	at := strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10)
+	reply("k", "v", "PXAT", at)
+	if ttl, _ := s.TTL("k"); ttl < 59*time.Minute {
+		t.Errorf("TTL after SET PXAT = %v", ttl)
+	}
+
+	for _, args := range [][]string{
+		{"k", "v", "NX", "XX"},
+		{"k", "v", "EX", "10", "PX", "100"},
+		{"k", "v", "EX", "10", "KEEPTTL"},
+		{"k", "v", "KEEPTTL", "EXAT", "10"},
+		{"k", "v", "EX"},
+		{"k", "v", "FOO"},
+		{"k", "v", "EX", "abc", "NX", "XX"},
+	} {
+		if _, err := handleSet(s, args); err == nil || err.Error() != "syntax error" {
+			t.Errorf("SET %v: %v", args, err)
+		}
+	}
+	for args, want := range map[[4]string]string{
+		{"k", "v", "EX", "abc"}: "value is not an integer or out of range",
+		{"k", "v", "EX", "0"}:   "invalid expire time in 'set' command",
+		{"k", "v", "PX", "-5"}:  "invalid expire time in 'set' command",
+	} {
+		if _, err := handleSet(s, args[:]); err == nil || err.Error() != want {
+			t.Errorf("SET %v: %v, want %s", args, err, want)
+		}
+	}
+
+	s.LPush("list", []byte("x"))
+	if _, err := handleSet(s, []string{"list", "v", "GET"}); err != storage.ErrWrongType {
+		t.Errorf("SET GET on a list: %v", err)
+	}
+	if n, _ := s.LLen("list"); n != 1 {
+		t.Error("SET GET on a list should not overwrite it")
+	}
+}
@@ -37,7 +37,7 @@ func handleZAdd(storage storage.Storage, args []string) (*protocol.Message, erro
 		added += n
 	}
 
-	return &protocol.Message{Type: "Integer", Content: added}, nil
+	return &protocol.Message{Type: "Integer", Content: int64(added)}, nil
 }
 
 func handleZScore(storage storage.Storage, args []string) (*protocol.Message, error) {
@@ -73,7 +73,7 @@ func handleZRem(storage storage.Storage, args []string) (*protocol.Message, erro
 		removed += n
 	}
 
-	return &protocol.Message{Type: "Integer", Content: removed}, nil
+	return &protocol.Message{Type: "Integer", Content: int64(removed)}, nil
 }
 
 func handleZRange(storage storage.Storage, args []string) (*protocol.Message, error) {
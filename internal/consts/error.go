@@ -17,8 +17,10 @@ var (
 	// Data structure related errors
 	ErrIndexOutOfRange = errors.New("index out of range")
 	ErrNoSuchField     = errors.New("field does not exist")
-	ErrNotInteger      = errors.New("value is not an integer")
+	ErrNotInteger      = errors.New("value is not an integer or out of range")
 	ErrNotFloat        = errors.New("value is not a valid float")
+	ErrOverflow        = errors.New("increment or decrement would overflow")
+	ErrNaNOrInfinity   = errors.New("increment would produce NaN or Infinity")
 
 	// Cluster related errors
 	ErrClusterNotEnabled = errors.New("cluster mode is not enabled")
@@ -26,11 +28,11 @@ var (
 
 	// Storage related errors
 	ErrDBIndexOutOfRange = errors.New("database index is out of range")
-	ErrMaxMemoryReached  = errors.New("maximum memory limit reached")
+	ErrMaxMemoryReached  = errors.New("OOM command not allowed when used memory > 'maxmemory'.")
 
 	// Other errors
 	ErrOperationAborted = errors.New("operation aborted")
 	ErrBackgroundSaving = errors.New("background save already in progress")
-	ErrAuthRequired     = errors.New("authentication required")
-	ErrAuthFailed       = errors.New("authentication failed")
+	ErrAuthRequired     = errors.New("NOAUTH Authentication required.")
+	ErrAuthFailed       = errors.New("WRONGPASS invalid username-password pair or user is disabled.")
 )
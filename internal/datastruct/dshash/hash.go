@@ -1,8 +1,20 @@
 package dshash
 
 import (
+	"bytes"
+	"encoding/gob"
+	"hash/fnv"
 	"literedis/internal/datastruct/base"
+	"literedis/internal/datastruct/dslistpack"
+	"math/bits"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultMaxListpackEntries = 128
+	DefaultMaxListpackValue   = 64
 )
 
 type Hash interface {
@@ -14,31 +26,163 @@ type Hash interface {
 	HKeys() []string
 	HVals() []string
 	HGetAll() []string
+	Encoding() string
+	Scan(cursor uint64, count int) (uint64, []string)
+
+	// 字段过期
+	FieldExpire(field string) (time.Time, bool)
+	SetFieldExpire(field string, at time.Time) bool
+	PersistField(field string) bool
+	DeleteExpired(now time.Time, fields ...string) []string
+
+	GobEncode() ([]byte, error)
+	GobDecode(data []byte) error
+}
+
+// Limits 是 hash-max-listpack-entries 和 hash-max-listpack-value，
+// 每次写入时读取，修改之后对已有的哈希也生效
+type Limits struct {
+	MaxEntries atomic.Int64
+	MaxValue   atomic.Int64
+}
+
+// DefaultLimits returns the limits Redis uses by default
+func DefaultLimits() *Limits {
+	l := &Limits{}
+	l.MaxEntries.Store(DefaultMaxListpackEntries)
+	l.MaxValue.Store(DefaultMaxListpackValue)
+	return l
 }
 
+// hashImpl 元素较少并且都较短时使用 listpack 编码，field 和 value 交替存放，
+// 超过 limits 之后转换为 hashtable，不会再转换回来
 type hashImpl struct {
-	data map[string]string
-	mu   sync.RWMutex
+	lp      *dslistpack.ListPack // 转换为 hashtable 之后为 nil
+	data    map[string]string
+	buckets [][]string           // hashtable 编码时按 field 的哈希值分桶，供 Scan 使用
+	expires map[string]time.Time // 设置了过期时间的 field，没有时为 nil
+	limits  *Limits
+	mu      sync.RWMutex
 	base.DataStructure
 }
 
-// NewHash creates and returns a new Hash
+// NewHash creates and returns a new Hash with the default limits
 func NewHash() Hash {
-	return &hashImpl{
-		data: make(map[string]string),
+	return NewHashWithLimits(DefaultLimits())
+}
+
+// NewHashWithLimits creates a hash that converts to a hashtable once it
+// outgrows limits
+func NewHashWithLimits(limits *Limits) Hash {
+	return &hashImpl{lp: dslistpack.New(), limits: limits}
+}
+
+// find 返回 field 在 listpack 中的下标，不存在时返回 -1
+func (h *hashImpl) find(field string) int {
+	index := -1
+	h.lp.Each(func(i int, v []byte) bool {
+		if i%2 == 0 && string(v) == field {
+			index = i
+			return false
+		}
+		return true
+	})
+	return index
+}
+
+// convert 把 listpack 编码转换为 hashtable
+func (h *hashImpl) convert() {
+	h.data = make(map[string]string, h.lp.Len()/2)
+	var field string
+	h.lp.Each(func(i int, v []byte) bool {
+		if i%2 == 0 {
+			field = string(v)
+		} else {
+			h.data[field] = string(v)
+		}
+		return true
+	})
+	h.lp = nil
+	h.rehash(len(h.data))
+}
+
+// 桶的数量是 2 的幂，元素多于桶时扩容，少于八分之一时缩容
+const minBuckets = 4
+
+// rehash 按 n 个元素重新分桶
+func (h *hashImpl) rehash(n int) {
+	size := minBuckets
+	for size < n {
+		size *= 2
+	}
+	h.buckets = make([][]string, size)
+	for field := range h.data {
+		i := h.bucketOf(field)
+		h.buckets[i] = append(h.buckets[i], field)
+	}
+}
+
+func (h *hashImpl) bucketOf(field string) uint64 {
+	return fieldHash(field) & uint64(len(h.buckets)-1)
+}
+
+// addToBucket 在 field 加入 data 之后调用
+func (h *hashImpl) addToBucket(field string) {
+	if len(h.data) > len(h.buckets) {
+		h.rehash(len(h.data))
+		return
+	}
+	i := h.bucketOf(field)
+	h.buckets[i] = append(h.buckets[i], field)
+}
+
+// removeFromBucket 在 field 从 data 删除之后调用
+func (h *hashImpl) removeFromBucket(field string) {
+	if len(h.buckets) > minBuckets && len(h.data) < len(h.buckets)/8 {
+		h.rehash(len(h.data))
+		return
+	}
+	i := h.bucketOf(field)
+	bucket := h.buckets[i]
+	for j, f := range bucket {
+		if f == field {
+			bucket[j] = bucket[len(bucket)-1]
+			h.buckets[i] = bucket[:len(bucket)-1]
+			return
+		}
 	}
 }
 
+// HSet 设置 field 的值，同时清除它的过期时间
 func (h *hashImpl) HSet(field string, value string) int {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	delete(h.expires, field)
+
+	if h.lp != nil {
+		maxValue := int(h.limits.MaxValue.Load())
+		if len(field) > maxValue || len(value) > maxValue {
+			h.convert()
+		} else if i := h.find(field); i >= 0 {
+			h.lp.Replace(i+1, []byte(value))
+			return 0
+		} else if h.lp.Len()/2+1 > int(h.limits.MaxEntries.Load()) {
+			h.convert()
+		} else {
+			h.lp.Insert(h.lp.Len(), []byte(field))
+			h.lp.Insert(h.lp.Len(), []byte(value))
+			return 1
+		}
+	}
+
 	_, exists := h.data[field]
 	h.data[field] = value
 
 	if exists {
 		return 0
 	}
+	h.addToBucket(field)
 	return 1
 }
 
@@ -46,6 +190,14 @@ func (h *hashImpl) HGet(field string) (string, bool) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
+	if h.lp != nil {
+		i := h.find(field)
+		if i < 0 {
+			return "", false
+		}
+		value, _ := h.lp.Get(i + 1)
+		return string(value), true
+	}
 	value, exists := h.data[field]
 	return value, exists
 }
@@ -56,11 +208,23 @@ func (h *hashImpl) HDel(fields ...string) int {
 
 	count := 0
 	for _, field := range fields {
+		delete(h.expires, field)
+		if h.lp != nil {
+			if i := h.find(field); i >= 0 {
+				h.lp.DeleteRange(i, 2)
+				count++
+			}
+			continue
+		}
 		if _, exists := h.data[field]; exists {
 			delete(h.data, field)
+			h.removeFromBucket(field)
 			count++
 		}
 	}
+	if len(h.expires) == 0 {
+		h.expires = nil
+	}
 	return count
 }
 
@@ -68,6 +232,9 @@ func (h *hashImpl) HExists(field string) bool {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
+	if h.lp != nil {
+		return h.find(field) >= 0
+	}
 	_, exists := h.data[field]
 	return exists
 }
@@ -76,38 +243,211 @@ func (h *hashImpl) HLen() int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
+	if h.lp != nil {
+		return h.lp.Len() / 2
+	}
 	return len(h.data)
 }
 
 func (h *hashImpl) HKeys() []string {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-
-	keys := make([]string, 0, len(h.data))
-	for k := range h.data {
-		keys = append(keys, k)
+	all := h.HGetAll()
+	keys := make([]string, 0, len(all)/2)
+	for i := 0; i < len(all); i += 2 {
+		keys = append(keys, all[i])
 	}
 	return keys
 }
 
 func (h *hashImpl) HVals() []string {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-
-	vals := make([]string, 0, len(h.data))
-	for _, v := range h.data {
-		vals = append(vals, v)
+	all := h.HGetAll()
+	vals := make([]string, 0, len(all)/2)
+	for i := 1; i < len(all); i += 2 {
+		vals = append(vals, all[i])
 	}
 	return vals
 }
 
+// HGetAll 返回交替排列的 field 和 value，listpack 编码时按插入顺序
 func (h *hashImpl) HGetAll() []string {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
+	if h.lp != nil {
+		result := make([]string, 0, h.lp.Len())
+		h.lp.Each(func(i int, v []byte) bool {
+			result = append(result, string(v))
+			return true
+		})
+		return result
+	}
 	result := make([]string, 0, len(h.data)*2)
 	for k, v := range h.data {
 		result = append(result, k, v)
 	}
 	return result
 }
+
+func (h *hashImpl) Encoding() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	switch {
+	case h.lp != nil && len(h.expires) > 0:
+		return "listpackex"
+	case h.lp != nil:
+		return "listpack"
+	}
+	return "hashtable"
+}
+
+// Scan 从 cursor 开始返回大约 count 个 field 和 value，返回 0 表示遍历结束。
+// listpack 编码一次返回全部元素；hashtable 和 Redis 的 dictScan 一样按桶遍历，
+// cursor 是下一个桶的下标，按高位递增，扩容缩容之后遍历期间一直存在的元素仍至少返回一次
+func (h *hashImpl) Scan(cursor uint64, count int) (uint64, []string) {
+	h.mu.RLock()
+	if h.lp != nil {
+		h.mu.RUnlock()
+		return 0, h.HGetAll()
+	}
+	defer h.mu.RUnlock()
+
+	if len(h.data) == 0 {
+		return 0, nil
+	}
+	count = max(count, 1)
+	mask := uint64(len(h.buckets) - 1)
+	result := make([]string, 0, count*2)
+	// 限制访问的空桶数量，避免稀疏时一次遍历太多
+	for visits := count * 10; ; visits-- {
+		for _, field := range h.buckets[cursor&mask] {
+			result = append(result, field, h.data[field])
+		}
+		cursor |= ^mask
+		cursor = bits.Reverse64(bits.Reverse64(cursor) + 1)
+		if cursor == 0 || len(result) >= count*2 || visits <= 1 {
+			return cursor, result
+		}
+	}
+}
+
+func fieldHash(field string) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(field))
+	return f.Sum64()
+}
+
+// FieldExpire 返回 field 的过期时间，没有设置时返回 false
+func (h *hashImpl) FieldExpire(field string) (time.Time, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	at, ok := h.expires[field]
+	return at, ok
+}
+
+// SetFieldExpire 设置 field 的过期时间，field 不存在时返回 false
+func (h *hashImpl) SetFieldExpire(field string, at time.Time) bool {
+	if !h.HExists(field) {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.expires == nil {
+		h.expires = make(map[string]time.Time)
+	}
+	h.expires[field] = at
+	return true
+}
+
+// PersistField 清除 field 的过期时间，原来没有设置时返回 false
+func (h *hashImpl) PersistField(field string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.expires[field]; !ok {
+		return false
+	}
+	delete(h.expires, field)
+	if len(h.expires) == 0 {
+		h.expires = nil
+	}
+	return true
+}
+
+// DeleteExpired 删除在 now 之前过期的 field 并返回它们，没有给出 fields 时检查所有 field
+func (h *hashImpl) DeleteExpired(now time.Time, fields ...string) []string {
+	h.mu.RLock()
+	var expired []string
+	check := func(field string) {
+		if at, ok := h.expires[field]; ok && !at.After(now) {
+			expired = append(expired, field)
+		}
+	}
+	if len(fields) == 0 {
+		for field := range h.expires {
+			check(field)
+		}
+	} else {
+		for _, field := range fields {
+			check(field)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(expired) > 0 {
+		h.HDel(expired...)
+	}
+	return expired
+}
+
+// snapshot 是哈希在快照中的形式
+type snapshot struct {
+	Pairs     []string
+	Expires   map[string]int64 // field -> 过期时间的 Unix 毫秒数
+	Hashtable bool
+}
+
+// GobEncode 把哈希编码为快照，保留 field 的过期时间和编码方式
+func (h *hashImpl) GobEncode() ([]byte, error) {
+	snap := snapshot{Pairs: h.HGetAll(), Hashtable: h.Encoding() == "hashtable"}
+	h.mu.RLock()
+	if len(h.expires) > 0 {
+		snap.Expires = make(map[string]int64, len(h.expires))
+		for field, at := range h.expires {
+			snap.Expires[field] = at.UnixMilli()
+		}
+	}
+	h.mu.RUnlock()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snap); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode 从快照恢复哈希，h 原有的内容会被替换
+func (h *hashImpl) GobDecode(data []byte) error {
+	var snap snapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return err
+	}
+	if h.limits == nil {
+		h.limits = DefaultLimits()
+	}
+
+	h.mu.Lock()
+	h.lp, h.data, h.buckets, h.expires = dslistpack.New(), nil, nil, nil
+	if snap.Hashtable {
+		h.convert()
+	}
+	h.mu.Unlock()
+	for i := 0; i+1 < len(snap.Pairs); i += 2 {
+		h.HSet(snap.Pairs[i], snap.Pairs[i+1])
+	}
+	for field, ms := range snap.Expires {
+		h.SetFieldExpire(field, time.UnixMilli(ms))
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package dshash
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListpackEncoding(t *testing.T) {
+	h := NewHash()
+	for _, f := range []string{"c", "a", "b"} {
+		if h.HSet(f, "v"+f) != 1 {
+			t.Fatalf("HSet(%s) should add a field", f)
+		}
+	}
+	if h.HSet("a", "x") != 0 {
+		t.Fatal("HSet of an existing field should return 0")
+	}
+	if h.Encoding() != "listpack" {
+		t.Fatalf("Encoding = %s", h.Encoding())
+	}
+	// listpack 编码保持插入顺序
+	if got := strings.Join(h.HGetAll(), ","); got != "c,vc,a,x,b,vb" {
+		t.Fatalf("HGetAll = %s", got)
+	}
+	if h.HDel("a", "missing") != 1 || h.HLen() != 2 || h.HExists("a") {
+		t.Fatal("HDel did not remove the field")
+	}
+	if v, ok := h.HGet("b"); !ok || v != "vb" {
+		t.Fatalf("HGet = %q, %v", v, ok)
+	}
+}
+
+func TestConvertToHashtable(t *testing.T) {
+	limits := DefaultLimits()
+	limits.MaxEntries.Store(3)
+
+	h := NewHashWithLimits(limits)
+	for i := 0; i < 3; i++ {
+		h.HSet(fmt.Sprint(i), "v")
+	}
+	if h.Encoding() != "listpack" {
+		t.Fatal("3 entries should fit in a listpack")
+	}
+	h.HSet("3", "v")
+	if h.Encoding() != "hashtable" || h.HLen() != 4 {
+		t.Fatalf("after 4 entries: %s, len %d", h.Encoding(), h.HLen())
+	}
+	// 不会转换回 listpack
+	h.HDel("0", "1", "2")
+	if h.Encoding() != "hashtable" {
+		t.Fatal("hash converted back to listpack")
+	}
+
+	long := NewHashWithLimits(limits)
+	long.HSet("f", "v")
+	long.HSet("f", strings.Repeat("x", 65))
+	if long.Encoding() != "hashtable" {
+		t.Fatal("a value over hash-max-listpack-value should convert")
+	}
+	if v, _ := long.HGet("f"); len(v) != 65 {
+		t.Fatal("value lost during conversion")
+	}
+}
+
+func TestScan(t *testing.T) {
+	h := NewHash()
+	for i := 0; i < 1000; i++ {
+		h.HSet(fmt.Sprint("field", i), fmt.Sprint(i))
+	}
+	seen := make(map[string]string)
+	var cursor uint64
+	calls := 0
+	for {
+		next, items := h.Scan(cursor, 10)
+		for i := 0; i < len(items); i += 2 {
+			seen[items[i]] = items[i+1]
+		}
+		calls++
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	if len(seen) != 1000 || calls < 50 {
+		t.Fatalf("scan returned %d fields in %d calls", len(seen), calls)
+	}
+	if seen["field42"] != "42" {
+		t.Fatalf("field42 = %q", seen["field42"])
+	}
+
+	small := NewHash()
+	small.HSet("a", "1")
+	if next, items := small.Scan(0, 1); next != 0 || len(items) != 2 {
+		t.Fatalf("listpack scan = %d, %v", next, items)
+	}
+}
+
+// 遍历期间扩容或缩容，一直存在的 field 仍然都要返回
+func TestScanResize(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		change func(h Hash)
+	}{
+		{"grow", func(h Hash) {
+			for i := 0; i < 5000; i++ {
+				h.HSet(fmt.Sprint("new", i), "x")
+			}
+		}},
+		{"shrink", func(h Hash) {
+			for i := 0; i < 5000; i++ {
+				h.HDel(fmt.Sprint("tmp", i))
+			}
+		}},
+	} {
+		h := NewHash()
+		for i := 0; i < 200; i++ {
+			h.HSet(fmt.Sprint("field", i), "v")
+		}
+		if tt.name == "shrink" {
+			for i := 0; i < 5000; i++ {
+				h.HSet(fmt.Sprint("tmp", i), "x")
+			}
+		}
+
+		seen := make(map[string]bool)
+		var cursor uint64
+		for calls := 0; ; calls++ {
+			if calls == 3 {
+				tt.change(h)
+			}
+			next, items := h.Scan(cursor, 10)
+			for i := 0; i < len(items); i += 2 {
+				seen[items[i]] = true
+			}
+			if next == 0 {
+				break
+			}
+			cursor = next
+		}
+		for i := 0; i < 200; i++ {
+			if !seen[fmt.Sprint("field", i)] {
+				t.Fatalf("%s: field%d not returned", tt.name, i)
+			}
+		}
+	}
+}
+
+func TestFieldExpire(t *testing.T) {
+	h := NewHash()
+	h.HSet("a", "1")
+	h.HSet("b", "2")
+	now := time.Now()
+
+	if h.SetFieldExpire("missing", now) {
+		t.Fatal("SetFieldExpire on a missing field should fail")
+	}
+	h.SetFieldExpire("a", now.Add(-time.Second))
+	h.SetFieldExpire("b", now.Add(time.Hour))
+	if h.Encoding() != "listpackex" {
+		t.Fatalf("Encoding with field TTLs = %s", h.Encoding())
+	}
+	if expired := h.DeleteExpired(now, "b"); len(expired) != 0 {
+		t.Fatalf("DeleteExpired(b) = %v", expired)
+	}
+	if expired := h.DeleteExpired(now); len(expired) != 1 || expired[0] != "a" || h.HExists("a") {
+		t.Fatalf("DeleteExpired = %v", expired)
+	}
+
+	// HSET 清除过期时间
+	h.HSet("b", "3")
+	if _, ok := h.FieldExpire("b"); ok {
+		t.Fatal("HSet should clear the field TTL")
+	}
+	h.SetFieldExpire("b", now.Add(time.Hour))
+	if !h.PersistField("b") || h.PersistField("b") {
+		t.Fatal("PersistField")
+	}
+	if h.Encoding() != "listpack" {
+		t.Fatalf("Encoding after PERSIST = %s", h.Encoding())
+	}
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	limits := DefaultLimits()
+	limits.MaxEntries.Store(2)
+	at := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+	for _, n := range []int{2, 3} {
+		h := NewHashWithLimits(limits)
+		for i := 0; i < n; i++ {
+			h.HSet(fmt.Sprint("f", i), fmt.Sprint(i))
+		}
+		h.SetFieldExpire("f1", at)
+		data, err := h.GobEncode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded := NewHashWithLimits(limits)
+		if err := decoded.GobDecode(data); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Encoding() != h.Encoding() || decoded.HLen() != n {
+			t.Fatalf("decoded %s with %d fields, want %s with %d", decoded.Encoding(), decoded.HLen(), h.Encoding(), n)
+		}
+		if got, ok := decoded.FieldExpire("f1"); !ok || !got.Equal(at) {
+			t.Fatalf("decoded TTL = %v, %v", got, ok)
+		}
+		if v, _ := decoded.HGet("f0"); v != "0" {
+			t.Fatalf("decoded f0 = %q", v)
+		}
+	}
+}
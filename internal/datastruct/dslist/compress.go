@@ -0,0 +1,77 @@
+package dslist
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"sync"
+
+	"literedis/internal/datastruct/dslistpack"
+)
+
+const (
+	minCompressBytes   = 48 // 小于该大小的节点不压缩
+	minCompressImprove = 8  // 压缩至少要节省的字节数
+)
+
+// flate.Writer 的初始化开销较大，复用
+var (
+	writers = sync.Pool{New: func() any {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	}}
+	readers = sync.Pool{New: func() any { return flate.NewReader(nil) }}
+)
+
+// compress DEFLATE 压缩节点的 listpack，压缩之后节省不到 minCompressImprove 字节时保持原样
+func (n *ListNode) compress() {
+	if n.lp == nil || n.lp.Size() < minCompressBytes {
+		return
+	}
+	raw := n.lp.Bytes()
+	var buf bytes.Buffer
+	w := writers.Get().(*flate.Writer)
+	w.Reset(&buf)
+	w.Write(raw)
+	w.Close()
+	writers.Put(w)
+	if buf.Len()+minCompressImprove >= len(raw) {
+		return
+	}
+	n.zipped = append([]byte(nil), buf.Bytes()...)
+	n.count, n.size = n.lp.Len(), len(raw)
+	n.lp = nil
+}
+
+// decompress 解压节点，之后节点保持解压状态直到再次调用 compress
+func (n *ListNode) decompress() {
+	if n.lp == nil {
+		n.lp = n.inflate()
+		n.zipped = nil
+	}
+}
+
+// view 返回节点的 listpack，压缩的节点解压到临时的 listpack 中，节点本身不变
+func (n *ListNode) view() *dslistpack.ListPack {
+	if n.lp != nil {
+		return n.lp
+	}
+	return n.inflate()
+}
+
+func (n *ListNode) inflate() *dslistpack.ListPack {
+	r := readers.Get().(io.ReadCloser)
+	r.(flate.Resetter).Reset(bytes.NewReader(n.zipped), nil)
+	raw := make([]byte, n.size)
+	_, err := io.ReadFull(r, raw)
+	readers.Put(r)
+	if err != nil {
+		panic("dslist: corrupt compressed node: " + err.Error())
+	}
+	return dslistpack.FromBytes(raw)
+}
+
+// compressed reports whether the node is stored compressed
+func (n *ListNode) compressed() bool {
+	return n.lp == nil
+}
@@ -1,35 +1,70 @@
 package dslist
 
 import (
-	"literedis/internal/datastruct/dsziplist"
+	"bytes"
+	"encoding/gob"
+	"literedis/internal/datastruct/dslistpack"
 	"time"
 )
 
 const (
-	defaultListNodeSize = 64 // Default number of elements in each node
+	// DefaultFill limits each node to 8KB, the same as list-max-listpack-size -2
+	DefaultFill = -2
+
+	maxFill         = 1 << 15
+	sizeSafetyLimit = 8192 // Node size limit when fill is a number of elements
 )
 
-// ListNode represents a node in the quicklist
+// fillBytes maps fill -1 .. -5 to the maximum node size in bytes
+var fillBytes = [...]int{4096, 8192, 16384, 32768, 65536}
+
+// ListNode represents a node in the quicklist. Interior nodes may be stored
+// DEFLATE compressed, in which case lp is nil.
 type ListNode struct {
-	ziplist *dsziplist.ZipList
-	prev    *ListNode
-	next    *ListNode
+	lp     *dslistpack.ListPack
+	zipped []byte
+	count  int // Number of entries while compressed
+	size   int // Size of the listpack while compressed
+	prev   *ListNode
+	next   *ListNode
+}
+
+func (n *ListNode) len() int {
+	if n.lp != nil {
+		return n.lp.Len()
+	}
+	return n.count
 }
 
-// QuickList is our main list structure
+func (n *ListNode) bytes() int {
+	if n.lp != nil {
+		return n.lp.Size()
+	}
+	return n.size
+}
+
+// QuickList is our main list structure: a doubly linked list of listpacks
 type QuickList struct {
-	head     *ListNode
-	tail     *ListNode
-	len      int
-	expireAt time.Time
+	head          *ListNode
+	tail          *ListNode
+	len           int
+	fill          int
+	compressDepth int
+	expireAt      time.Time
 }
 
-// New creates a new QuickList
+// New creates a new QuickList with the default node size and no compression
 func New() *QuickList {
-	return &QuickList{
-		head: &ListNode{ziplist: dsziplist.NewZipList()},
-		tail: &ListNode{ziplist: dsziplist.NewZipList()},
-	}
+	return NewWithConfig(DefaultFill, 0)
+}
+
+// NewWithConfig creates a new QuickList. A positive fill is the maximum number
+// of elements per node, -1 to -5 limit each node to 4KB to 64KB. compressDepth
+// is the number of nodes at each end that are never compressed, 0 disables
+// compression.
+func NewWithConfig(fill, compressDepth int) *QuickList {
+	fill = max(min(fill, maxFill), -len(fillBytes))
+	return &QuickList{fill: fill, compressDepth: max(compressDepth, 0)}
 }
 
 // Len returns the number of elements in the list
@@ -37,6 +72,15 @@ func (ql *QuickList) Len() int64 {
 	return int64(ql.len)
 }
 
+// Encoding returns "listpack" while the list fits into a single node and
+// "quicklist" otherwise
+func (ql *QuickList) Encoding() string {
+	if ql.head == ql.tail {
+		return "listpack"
+	}
+	return "quicklist"
+}
+
 // IsExpired checks if the list has expired
 func (ql *QuickList) IsExpired() bool {
 	return !ql.expireAt.IsZero() && time.Now().After(ql.expireAt)
@@ -55,21 +99,12 @@ func (ql *QuickList) Expire() time.Time {
 // LPush inserts all the specified values at the head of the list
 func (ql *QuickList) LPush(values ...[]byte) int64 {
 	for _, value := range values {
-		if ql.head == nil || ql.head.ziplist.Len() >= defaultListNodeSize {
-			newNode := &ListNode{ziplist: dsziplist.NewZipList()}
-			newNode.next = ql.head
-			if ql.head != nil {
-				ql.head.prev = newNode
-			}
-			ql.head = newNode
-			if ql.tail == nil {
-				ql.tail = newNode
-			}
-		}
-		err := ql.head.ziplist.Insert(value)
-		if err != nil {
-			// 处理错误,可能需要创建新的节点
-			continue
+		if ql.head != nil && ql.allowInsert(ql.head, value) {
+			ql.head.decompress()
+			ql.head.lp.Insert(0, value)
+		} else {
+			ql.linkAfter(nil, newNode(value))
+			ql.compress(nil)
 		}
 		ql.len++
 	}
@@ -79,21 +114,12 @@ func (ql *QuickList) LPush(values ...[]byte) int64 {
 // RPush inserts all the specified values at the tail of the list
 func (ql *QuickList) RPush(values ...[]byte) int64 {
 	for _, value := range values {
-		if ql.tail == nil || ql.tail.ziplist.Len() >= defaultListNodeSize {
-			newNode := &ListNode{ziplist: dsziplist.NewZipList()}
-			newNode.prev = ql.tail
-			if ql.tail != nil {
-				ql.tail.next = newNode
-			}
-			ql.tail = newNode
-			if ql.head == nil {
-				ql.head = newNode
-			}
-		}
-		err := ql.tail.ziplist.Insert(value)
-		if err != nil {
-			// 处理错误,可能需要创建新的节点
-			continue
+		if ql.tail != nil && ql.allowInsert(ql.tail, value) {
+			ql.tail.decompress()
+			ql.tail.lp.Insert(ql.tail.lp.Len(), value)
+		} else {
+			ql.linkAfter(ql.tail, newNode(value))
+			ql.compress(nil)
 		}
 		ql.len++
 	}
@@ -102,51 +128,70 @@ func (ql *QuickList) RPush(values ...[]byte) int64 {
 
 // LPop removes and returns the first element of the list
 func (ql *QuickList) LPop() ([]byte, bool) {
-	if ql.len == 0 {
+	values := ql.LPopN(1)
+	if len(values) == 0 {
 		return nil, false
 	}
-	value, ok := ql.head.ziplist.Get(0)
-	if !ok {
-		return nil, false
-	}
-	if ql.head.ziplist.Delete(0) {
-		ql.len--
-		if ql.head.ziplist.Len() == 0 {
-			ql.head = ql.head.next
-			if ql.head == nil {
-				ql.tail = nil
-			} else {
-				ql.head.prev = nil
-			}
-		}
-		return value, true
-	}
-	return nil, false
+	return values[0], true
 }
 
 // RPop removes and returns the last element of the list
 func (ql *QuickList) RPop() ([]byte, bool) {
-	if ql.len == 0 {
+	values := ql.RPopN(1)
+	if len(values) == 0 {
 		return nil, false
 	}
-	lastIndex := int(ql.tail.ziplist.Len() - 1)
-	value, ok := ql.tail.ziplist.Get(lastIndex)
-	if !ok {
-		return nil, false
+	return values[0], true
+}
+
+// LPopN removes and returns up to n elements from the head of the list
+func (ql *QuickList) LPopN(n int64) [][]byte {
+	n = min(n, int64(ql.len))
+	result := make([][]byte, 0, max(n, 0))
+	for int64(len(result)) < n {
+		node := ql.head
+		node.decompress()
+		take := int(min(n-int64(len(result)), int64(node.len())))
+		node.lp.Each(func(i int, value []byte) bool {
+			if i >= take {
+				return false
+			}
+			result = append(result, append([]byte(nil), value...))
+			return true
+		})
+		ql.deleteFromNode(node, 0, take)
 	}
-	if ql.tail.ziplist.Delete(lastIndex) {
-		ql.len--
-		if ql.tail.ziplist.Len() == 0 {
-			ql.tail = ql.tail.prev
-			if ql.tail == nil {
-				ql.head = nil
-			} else {
-				ql.tail.next = nil
+	if n > 0 && ql.head != nil {
+		ql.mergeNext(ql.head)
+		ql.compress(nil)
+	}
+	return result
+}
+
+// RPopN removes and returns up to n elements from the tail of the list,
+// in the order they are popped
+func (ql *QuickList) RPopN(n int64) [][]byte {
+	n = min(n, int64(ql.len))
+	result := make([][]byte, 0, max(n, 0))
+	for int64(len(result)) < n {
+		node := ql.tail
+		node.decompress()
+		take := int(min(n-int64(len(result)), int64(node.len())))
+		from := node.len() - take
+		node.lp.EachReverse(func(i int, value []byte) bool {
+			if i < from {
+				return false
 			}
-		}
-		return value, true
+			result = append(result, append([]byte(nil), value...))
+			return true
+		})
+		ql.deleteFromNode(node, from, take)
+	}
+	if n > 0 && ql.tail != nil && ql.tail.prev != nil {
+		ql.mergeNext(ql.tail.prev)
+		ql.compress(nil)
 	}
-	return nil, false
+	return result
 }
 
 // LRange returns the specified elements of the list
@@ -171,20 +216,18 @@ func (ql *QuickList) LRange(start, stop int64) [][]byte {
 	node := ql.head
 	var index int64 = 0
 	for node != nil && index <= stop {
-		nodeLen := node.ziplist.Len()
+		nodeLen := int64(node.len())
 		if index+nodeLen > start {
-			startIndex := 0
-			if index < start {
-				startIndex = int(start - index)
-			}
-			endIndex := int(nodeLen)
-			if index+nodeLen > stop+1 {
-				endIndex = int(stop - index + 1)
-			}
-			for i := startIndex; i < endIndex; i++ {
-				value, _ := node.ziplist.Get(i)
-				result = append(result, value)
-			}
+			node.view().Each(func(i int, value []byte) bool {
+				pos := index + int64(i)
+				if pos > stop {
+					return false
+				}
+				if pos >= start {
+					result = append(result, append([]byte(nil), value...))
+				}
+				return true
+			})
 		}
 		index += nodeLen
 		node = node.next
@@ -194,24 +237,7 @@ func (ql *QuickList) LRange(start, stop int64) [][]byte {
 
 // LIndex returns the element at index in the list
 func (ql *QuickList) LIndex(index int64) ([]byte, bool) {
-	if index < 0 {
-		index = int64(ql.len) + index
-	}
-	if index < 0 || index >= int64(ql.len) {
-		return nil, false
-	}
-
-	node := ql.head
-	var currentIndex int64 = 0
-	for node != nil {
-		nodeLen := node.ziplist.Len()
-		if currentIndex+nodeLen > index {
-			return node.ziplist.Get(int(index - currentIndex))
-		}
-		currentIndex += nodeLen
-		node = node.next
-	}
-	return nil, false
+	return ql.LGet(index)
 }
 
 // LSet sets the list element at index to value
@@ -222,18 +248,17 @@ func (ql *QuickList) LSet(index int64, value []byte) bool {
 	if index < 0 || index >= int64(ql.len) {
 		return false
 	}
-
-	node := ql.head
-	var currentIndex int64 = 0
-	for node != nil {
-		nodeLen := node.ziplist.Len()
-		if currentIndex+nodeLen > index {
-			return node.ziplist.Set(int(index-currentIndex), value)
-		}
-		currentIndex += nodeLen
-		node = node.next
+	node, i := ql.nodeAt(index)
+	node.decompress()
+	node.lp.Replace(i, value)
+	// A larger value may push the node over its limit, split it in half
+	if node.len() > 1 && !ql.fits(node.len(), node.bytes()) {
+		right := &ListNode{lp: node.lp.Split(node.len() / 2)}
+		ql.linkAfter(node, right)
+		ql.compress(right)
 	}
-	return false
+	ql.compress(node)
+	return true
 }
 
 // LGet returns the element at index in the list
@@ -244,16 +269,375 @@ func (ql *QuickList) LGet(index int64) ([]byte, bool) {
 	if index < 0 || index >= int64(ql.len) {
 		return nil, false
 	}
+	node, i := ql.nodeAt(index)
+	return node.view().Get(i)
+}
+
+// LInsert inserts value before or after the first element equal to pivot.
+// It returns the new length, or -1 when pivot is not found.
+func (ql *QuickList) LInsert(pivot, value []byte, before bool) int64 {
+	for node := ql.head; node != nil; node = node.next {
+		index := -1
+		node.view().Each(func(i int, v []byte) bool {
+			if bytes.Equal(v, pivot) {
+				index = i
+				return false
+			}
+			return true
+		})
+		if index < 0 {
+			continue
+		}
+		if !before {
+			index++
+		}
+		ql.insertAt(node, index, value)
+		return int64(ql.len)
+	}
+	return -1
+}
 
+// LRem removes the first count elements equal to value. A negative count
+// removes from the tail and zero removes all of them. It returns the number
+// of removed elements.
+func (ql *QuickList) LRem(count int64, value []byte) int64 {
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
 	node := ql.head
-	var currentIndex int64 = 0
-	for node != nil {
-		nodeLen := node.ziplist.Len()
-		if currentIndex+nodeLen > index {
-			return node.ziplist.Get(int(index - currentIndex))
+	if count < 0 {
+		node = ql.tail
+	}
+	var removed int64
+	for node != nil && (limit == 0 || removed < limit) {
+		next := node.next
+		if count < 0 {
+			next = node.prev
 		}
-		currentIndex += nodeLen
-		node = node.next
+		var matches []int
+		node.view().Each(func(i int, v []byte) bool {
+			if bytes.Equal(v, value) {
+				matches = append(matches, i)
+			}
+			return true
+		})
+		if limit > 0 && int64(len(matches)) > limit-removed {
+			n := int(limit - removed)
+			if count > 0 {
+				matches = matches[:n]
+			} else {
+				matches = matches[len(matches)-n:]
+			}
+		}
+		if len(matches) > 0 {
+			node.decompress()
+			for i := len(matches) - 1; i >= 0; i-- {
+				node.lp.Delete(matches[i])
+			}
+			ql.len -= len(matches)
+			removed += int64(len(matches))
+			// Only merge with the neighbour that has already been visited
+			switch {
+			case node.len() == 0:
+				ql.unlink(node)
+				ql.compress(nil)
+			case count >= 0 && node.prev != nil && ql.mergeNext(node.prev):
+				ql.compress(node.prev)
+			case count < 0:
+				ql.mergeNext(node)
+				ql.compress(node)
+			default:
+				ql.compress(node)
+			}
+		}
+		node = next
+	}
+	return removed
+}
+
+// LTrim keeps only the elements between start and stop, both inclusive
+func (ql *QuickList) LTrim(start, stop int64) {
+	length := int64(ql.len)
+	if start < 0 {
+		start = length + start
+	}
+	if stop < 0 {
+		stop = length + stop
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop {
+		ql.head, ql.tail, ql.len = nil, nil, 0
+		return
+	}
+	ql.deleteRange(stop+1, length-stop-1)
+	ql.deleteRange(0, start)
+}
+
+// LPos returns the indexes of the elements equal to value. rank selects the
+// first match to return and a negative rank searches from the tail; count
+// limits the number of matches and maxLen the number of compared elements,
+// zero meaning no limit for both.
+func (ql *QuickList) LPos(value []byte, rank, count, maxLen int64) []int64 {
+	result := []int64{}
+	skip := rank - 1
+	if rank < 0 {
+		skip = -rank - 1
+	}
+	var compared int64
+	done := false
+	// match returns false once the search is done
+	match := func(index int64, v []byte) bool {
+		if maxLen > 0 && compared >= maxLen {
+			done = true
+			return false
+		}
+		compared++
+		if !bytes.Equal(v, value) {
+			return true
+		}
+		if skip > 0 {
+			skip--
+			return true
+		}
+		result = append(result, index)
+		done = count > 0 && int64(len(result)) >= count
+		return !done
+	}
+
+	if rank > 0 {
+		var base int64
+		for node := ql.head; node != nil && !done; node = node.next {
+			node.view().Each(func(i int, v []byte) bool {
+				return match(base+int64(i), v)
+			})
+			base += int64(node.len())
+		}
+		return result
+	}
+	base := int64(ql.len)
+	for node := ql.tail; node != nil && !done; node = node.prev {
+		base -= int64(node.len())
+		node.view().EachReverse(func(i int, v []byte) bool {
+			return match(base+int64(i), v)
+		})
+	}
+	return result
+}
+
+// nodeAt returns the node holding index and the offset inside it, walking
+// from the nearer end. index must be within the list.
+func (ql *QuickList) nodeAt(index int64) (*ListNode, int) {
+	if index < int64(ql.len)/2 {
+		for node := ql.head; node != nil; node = node.next {
+			n := int64(node.len())
+			if index < n {
+				return node, int(index)
+			}
+			index -= n
+		}
+		return nil, 0
+	}
+	index = int64(ql.len) - 1 - index
+	for node := ql.tail; node != nil; node = node.prev {
+		n := int64(node.len())
+		if index < n {
+			return node, int(n - 1 - index)
+		}
+		index -= n
+	}
+	return nil, 0
+}
+
+// allowInsert reports whether node stays within the size limit after
+// inserting value
+func (ql *QuickList) allowInsert(node *ListNode, value []byte) bool {
+	return ql.fits(node.len()+1, node.bytes()+dslistpack.EntrySize(value))
+}
+
+// fits reports whether a node of count entries and size bytes is within the limit
+func (ql *QuickList) fits(count, size int) bool {
+	if ql.fill >= 0 {
+		return count <= max(ql.fill, 1) && size <= sizeSafetyLimit
+	}
+	return size <= fillBytes[-ql.fill-1]
+}
+
+// insertAt inserts value at offset i of node. A full node first spills into a
+// neighbour with free space, otherwise it is split at the insertion point.
+func (ql *QuickList) insertAt(node *ListNode, i int, value []byte) {
+	node.decompress()
+	size := node.len()
+	switch {
+	case ql.allowInsert(node, value):
+		node.lp.Insert(i, value)
+	case i == 0 && node.prev != nil && ql.allowInsert(node.prev, value):
+		prev := node.prev
+		prev.decompress()
+		prev.lp.Insert(prev.lp.Len(), value)
+		ql.compress(prev)
+	case i == size && node.next != nil && ql.allowInsert(node.next, value):
+		next := node.next
+		next.decompress()
+		next.lp.Insert(0, value)
+		ql.compress(next)
+	case i == 0:
+		ql.linkAfter(node.prev, newNode(value))
+	case i == size:
+		ql.linkAfter(node, newNode(value))
+	default:
+		right := &ListNode{lp: node.lp.Split(i)}
+		ql.linkAfter(node, right)
+		node.lp.Insert(i, value)
+		ql.compress(right)
+	}
+	ql.len++
+	ql.compress(node)
+}
+
+// deleteRange removes n elements starting at index, dropping whole nodes and
+// trimming the partial nodes at both ends, which are merged when they fit
+func (ql *QuickList) deleteRange(index, n int64) {
+	if n <= 0 || index >= int64(ql.len) {
+		return
+	}
+	node, i := ql.nodeAt(index)
+	left := node.prev
+	if i > 0 {
+		left = node
+	}
+	for node != nil && n > 0 {
+		next := node.next
+		take := int(min(n, int64(node.len()-i)))
+		ql.deleteFromNode(node, i, take)
+		n -= int64(take)
+		node, i = next, 0
+	}
+	if left == nil {
+		left = ql.head
+	}
+	if left != nil {
+		ql.mergeNext(left)
+		ql.compress(left)
+	}
+}
+
+// deleteFromNode removes n entries of node starting at offset i and unlinks
+// the node when it becomes empty
+func (ql *QuickList) deleteFromNode(node *ListNode, i, n int) {
+	if i == 0 && n >= node.len() {
+		ql.len -= node.len()
+		ql.unlink(node)
+		return
+	}
+	node.decompress()
+	ql.len -= node.lp.DeleteRange(i, n)
+}
+
+// mergeNext merges the next node into node when both fit into one node
+func (ql *QuickList) mergeNext(node *ListNode) bool {
+	next := node.next
+	if next == nil || !ql.fits(node.len()+next.len(), node.bytes()+next.bytes()-dslistpack.HeaderSize-1) {
+		return false
+	}
+	node.decompress()
+	next.decompress()
+	node.lp.Merge(next.lp)
+	ql.unlink(next)
+	return true
+}
+
+// compress keeps compressDepth nodes at each end uncompressed and compresses
+// the nodes right inside them, as well as node when it is an interior node.
+// Every operation that changes the list calls it for the nodes it touched.
+func (ql *QuickList) compress(node *ListNode) {
+	if ql.compressDepth == 0 || ql.head == nil {
+		return
+	}
+	f, r := ql.head, ql.tail
+	for i := 0; i < ql.compressDepth; i++ {
+		f.decompress()
+		r.decompress()
+		if f == node || r == node {
+			node = nil
+		}
+		if f == r || f.next == r {
+			return
+		}
+		f, r = f.next, r.prev
+	}
+	f.compress()
+	r.compress()
+	if node != nil {
+		node.compress()
+	}
+}
+
+// linkAfter links node after prev, or at the head when prev is nil
+func (ql *QuickList) linkAfter(prev, node *ListNode) {
+	node.prev = prev
+	if prev == nil {
+		node.next = ql.head
+		ql.head = node
+	} else {
+		node.next = prev.next
+		prev.next = node
+	}
+	if node.next != nil {
+		node.next.prev = node
+	} else {
+		ql.tail = node
+	}
+}
+
+func (ql *QuickList) unlink(node *ListNode) {
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		ql.head = node.next
+	}
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		ql.tail = node.prev
+	}
+	node.prev, node.next = nil, nil
+}
+
+func newNode(value []byte) *ListNode {
+	lp := dslistpack.New()
+	lp.Insert(0, value)
+	return &ListNode{lp: lp}
+}
+
+// snapshot 是列表在快照中的形式
+type snapshot struct {
+	Fill          int
+	CompressDepth int
+	Values        [][]byte
+}
+
+// GobEncode 把列表编码为元素和节点配置，节点在加载时重新构建
+func (ql *QuickList) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	snap := snapshot{Fill: ql.fill, CompressDepth: ql.compressDepth, Values: ql.LRange(0, -1)}
+	if err := gob.NewEncoder(&buf).Encode(snap); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (ql *QuickList) GobDecode(data []byte) error {
+	var snap snapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return err
 	}
-	return nil, false
+	*ql = *NewWithConfig(snap.Fill, snap.CompressDepth)
+	ql.RPush(snap.Values...)
+	return nil
 }
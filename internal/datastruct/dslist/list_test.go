@@ -0,0 +1,356 @@
+package dslist
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// build creates a list of n elements "0".."n-1" in nodes of 64 elements
+func build(n int) (*QuickList, []string) {
+	ql := NewWithConfig(64, 0)
+	model := make([]string, n)
+	for i := range model {
+		model[i] = fmt.Sprint(i)
+		ql.RPush([]byte(model[i]))
+	}
+	return ql, model
+}
+
+// check verifies the list against the model and the node invariants
+func check(t *testing.T, ql *QuickList, model []string) {
+	t.Helper()
+	got := []string{}
+	for _, v := range ql.LRange(0, -1) {
+		got = append(got, string(v))
+	}
+	if !reflect.DeepEqual(got, append([]string{}, model...)) {
+		t.Fatalf("list = %v, want %v", got, model)
+	}
+	if ql.Len() != int64(len(model)) {
+		t.Fatalf("Len = %d, want %d", ql.Len(), len(model))
+	}
+	var total int64
+	var prev *ListNode
+	for node := ql.head; node != nil; node = node.next {
+		if node.prev != prev {
+			t.Fatalf("broken prev link")
+		}
+		if n := node.len(); n == 0 || (n > 1 && !ql.fits(n, node.bytes())) {
+			t.Fatalf("node of %d elements and %d bytes out of bounds", n, node.bytes())
+		}
+		total += int64(node.len())
+		prev = node
+	}
+	// 两端 compressDepth 个节点不压缩
+	f, r := ql.head, ql.tail
+	for i := 0; i < ql.compressDepth && f != nil; i++ {
+		if f.compressed() || r.compressed() {
+			t.Fatalf("node %d from the ends is compressed", i)
+		}
+		f, r = f.next, r.prev
+	}
+	if ql.tail != prev || total != ql.Len() {
+		t.Fatalf("tail or length mismatch: total %d, len %d", total, ql.Len())
+	}
+}
+
+func TestLInsert(t *testing.T) {
+	ql, model := build(200)
+	// 插入到满节点的中间会分裂节点
+	if n := ql.LInsert([]byte("100"), []byte("x"), true); n != 201 {
+		t.Fatalf("LInsert = %d", n)
+	}
+	model = append(model[:100], append([]string{"x"}, model[100:]...)...)
+	check(t, ql, model)
+
+	// 节点边界：第一个、最后一个以及节点的首尾元素
+	for _, pivot := range []string{"0", "199", "63", "64", "127", "128"} {
+		for _, before := range []bool{true, false} {
+			ql.LInsert([]byte(pivot), []byte("y"), before)
+			i := indexOf(model, pivot)
+			if !before {
+				i++
+			}
+			model = append(model[:i], append([]string{"y"}, model[i:]...)...)
+			check(t, ql, model)
+		}
+	}
+	if n := ql.LInsert([]byte("missing"), []byte("z"), true); n != -1 {
+		t.Fatalf("LInsert missing pivot = %d, want -1", n)
+	}
+}
+
+func TestLRem(t *testing.T) {
+	ql := NewWithConfig(64, 0)
+	var model []string
+	for i := 0; i < 300; i++ {
+		v := "a"
+		if i%3 == 0 {
+			v = "b"
+		}
+		ql.RPush([]byte(v))
+		model = append(model, v)
+	}
+	remove := func(count int64, value string) {
+		t.Helper()
+		n := ql.LRem(count, []byte(value))
+		var want int64
+		limit := count
+		if limit < 0 {
+			limit = -limit
+		}
+		if count >= 0 {
+			for i := 0; i < len(model); i++ {
+				if model[i] == value && (limit == 0 || want < limit) {
+					model = append(model[:i], model[i+1:]...)
+					i--
+					want++
+				}
+			}
+		} else {
+			for i := len(model) - 1; i >= 0; i-- {
+				if model[i] == value && want < limit {
+					model = append(model[:i], model[i+1:]...)
+					want++
+				}
+			}
+		}
+		if n != want {
+			t.Fatalf("LRem(%d, %s) = %d, want %d", count, value, n, want)
+		}
+		check(t, ql, model)
+	}
+	remove(5, "b")
+	remove(-7, "b")
+	remove(70, "a")
+	remove(-70, "a")
+	remove(0, "b")
+	remove(0, "missing")
+	remove(0, "a")
+	if ql.head != nil || ql.tail != nil {
+		t.Fatal("empty list still has nodes")
+	}
+}
+
+func TestLTrim(t *testing.T) {
+	cases := []struct{ start, stop int64 }{
+		{0, -1}, {1, -2}, {64, 127}, {63, 128}, {0, 0}, {-1, -1},
+		{-100, 1000}, {150, 10}, {200, 300}, {-300, -250}, {10, 63},
+	}
+	for _, c := range cases {
+		ql, model := build(200)
+		ql.LTrim(c.start, c.stop)
+		start, stop := c.start, c.stop
+		if start < 0 {
+			start += 200
+		}
+		if stop < 0 {
+			stop += 200
+		}
+		start, stop = max(start, 0), min(stop, 199)
+		if start > stop {
+			model = nil
+		} else {
+			model = model[start : stop+1]
+		}
+		check(t, ql, model)
+	}
+}
+
+func TestLPos(t *testing.T) {
+	ql := NewWithConfig(64, 1)
+	for i := 0; i < 200; i++ {
+		ql.RPush([]byte(fmt.Sprint(i % 50)))
+	}
+	cases := []struct {
+		rank, count, maxLen int64
+		want                []int64
+	}{
+		{1, 1, 0, []int64{7}},
+		{2, 1, 0, []int64{57}},
+		{1, 0, 0, []int64{7, 57, 107, 157}},
+		{-1, 0, 0, []int64{157, 107, 57, 7}},
+		{-2, 2, 0, []int64{107, 57}},
+		{1, 0, 100, []int64{7, 57}},
+		{-1, 0, 43, []int64{157}},
+		{-1, 0, 42, []int64{}},
+		{5, 0, 0, []int64{}},
+	}
+	for _, c := range cases {
+		got := ql.LPos([]byte("7"), c.rank, c.count, c.maxLen)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("LPos(rank %d, count %d, maxlen %d) = %v, want %v", c.rank, c.count, c.maxLen, got, c.want)
+		}
+	}
+}
+
+func TestPopN(t *testing.T) {
+	ql, model := build(200)
+	got := ql.LPopN(70)
+	if len(got) != 70 || string(got[0]) != "0" || string(got[69]) != "69" {
+		t.Fatalf("LPopN = %d elements", len(got))
+	}
+	model = model[70:]
+	check(t, ql, model)
+
+	got = ql.RPopN(65)
+	if len(got) != 65 || string(got[0]) != "199" || string(got[64]) != "135" {
+		t.Fatalf("RPopN = %q..%q", got[0], got[len(got)-1])
+	}
+	model = model[:len(model)-65]
+	check(t, ql, model)
+
+	if got = ql.LPopN(1000); int64(len(got)) != 65 || ql.Len() != 0 {
+		t.Fatalf("LPopN past the end = %d elements", len(got))
+	}
+	check(t, ql, nil)
+	if got = ql.RPopN(3); len(got) != 0 {
+		t.Fatalf("RPopN on empty list = %v", got)
+	}
+}
+
+// TestRandomOperations 随机操作与切片模型比对，覆盖按个数、按字节限制节点以及压缩的配置
+func TestRandomOperations(t *testing.T) {
+	configs := []struct{ fill, depth int }{{64, 0}, {5, 1}, {-1, 2}, {0, 3}}
+	for _, cfg := range configs {
+		r := rand.New(rand.NewSource(1))
+		ql := NewWithConfig(cfg.fill, cfg.depth)
+		var model []string
+		for step := 0; step < 3000; step++ {
+			v := fmt.Sprint(r.Intn(20))
+			if r.Intn(4) == 0 {
+				v = strings.Repeat(v, r.Intn(100)) + "x"
+			}
+			switch r.Intn(8) {
+			case 0:
+				ql.RPush([]byte(v))
+				model = append(model, v)
+			case 1:
+				ql.LPush([]byte(v))
+				model = append([]string{v}, model...)
+			case 2:
+				pivot := fmt.Sprint(r.Intn(20))
+				if ql.LInsert([]byte(pivot), []byte(v), false) >= 0 {
+					i := indexOf(model, pivot) + 1
+					model = append(model[:i], append([]string{v}, model[i:]...)...)
+				}
+			case 3:
+				count := int64(r.Intn(5) - 2)
+				ql.LRem(count, []byte(v))
+				model = remModel(model, count, v)
+			case 4:
+				if len(model) > 100 {
+					start, stop := int64(r.Intn(20)), int64(-1-r.Intn(20))
+					ql.LTrim(start, stop)
+					model = model[start : int64(len(model))+stop+1]
+				}
+			case 5:
+				n := r.Intn(10)
+				ql.LPopN(int64(n))
+				model = model[min(n, len(model)):]
+			case 6:
+				n := r.Intn(10)
+				ql.RPopN(int64(n))
+				model = model[:len(model)-min(n, len(model))]
+			case 7:
+				if len(model) > 0 {
+					i := r.Intn(len(model))
+					ql.LSet(int64(i), []byte(v))
+					model[i] = v
+				}
+			}
+			check(t, ql, model)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	ql := NewWithConfig(16, 1)
+	var model []string
+	for i := 0; i < 1000; i++ {
+		v := fmt.Sprintf("element-%03d", i%100)
+		ql.RPush([]byte(v))
+		model = append(model, v)
+	}
+	check(t, ql, model)
+	compressed := 0
+	for node := ql.head; node != nil; node = node.next {
+		if node.compressed() {
+			compressed++
+		}
+	}
+	if compressed == 0 {
+		t.Fatal("no interior node is compressed")
+	}
+	if ql.head.compressed() || ql.tail.compressed() {
+		t.Fatal("end nodes must stay uncompressed")
+	}
+	// 读取内部节点不会改变节点的压缩状态
+	if v, _ := ql.LIndex(500); string(v) != model[500] {
+		t.Fatalf("LIndex(500) = %s", v)
+	}
+	if n, _ := ql.nodeAt(500); !n.compressed() {
+		t.Fatal("reading an interior node decompressed it")
+	}
+	ql.LSet(500, []byte("changed"))
+	model[500] = "changed"
+	check(t, ql, model)
+	if n, _ := ql.nodeAt(500); !n.compressed() {
+		t.Fatal("interior node is not compressed again after LSet")
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	ql := New()
+	ql.RPush([]byte("a"), []byte("b"))
+	if got := ql.Encoding(); got != "listpack" {
+		t.Fatalf("Encoding = %s, want listpack", got)
+	}
+	big := []byte(strings.Repeat("x", 1000))
+	for i := 0; i < 10; i++ {
+		ql.RPush(big)
+	}
+	if got := ql.Encoding(); got != "quicklist" {
+		t.Fatalf("Encoding = %s, want quicklist", got)
+	}
+	ql.RPopN(10)
+	if got := ql.Encoding(); got != "listpack" {
+		t.Fatalf("Encoding after shrinking = %s, want listpack", got)
+	}
+}
+
+func indexOf(model []string, v string) int {
+	for i, m := range model {
+		if m == v {
+			return i
+		}
+	}
+	return -1
+}
+
+func remModel(model []string, count int64, v string) []string {
+	var out []string
+	if count >= 0 {
+		removed := int64(0)
+		for _, m := range model {
+			if m == v && (count == 0 || removed < count) {
+				removed++
+				continue
+			}
+			out = append(out, m)
+		}
+		return out
+	}
+	removed := int64(0)
+	for i := len(model) - 1; i >= 0; i-- {
+		if model[i] == v && removed < -count {
+			removed++
+			continue
+		}
+		out = append([]string{model[i]}, out...)
+	}
+	return out
+}
@@ -0,0 +1,401 @@
+// Package dslistpack implements the listpack encoding: a compact byte buffer
+// of entries that can be walked in both directions.
+//
+// The layout follows Redis: a 6 byte header holding the total size and the
+// number of entries, the entries, and a terminating 0xFF byte. Every entry is
+// an encoding byte, the data, and a backlen holding the size of the first two
+// so that the previous entry can be found from the end of the current one.
+// Values that look like canonical integers are stored as integers.
+package dslistpack
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+)
+
+const (
+	HeaderSize = 6
+	eof        = 0xFF
+
+	// 条目数超过 uint16 时头部记录为 unknownCount，以 count 字段为准
+	unknownCount = math.MaxUint16
+
+	encInt16 = 0xF1
+	encInt24 = 0xF2
+	encInt32 = 0xF3
+	encInt64 = 0xF4
+	encStr32 = 0xF0
+)
+
+// ListPack is a listpack encoded list of byte strings
+type ListPack struct {
+	buf   []byte
+	count int
+}
+
+// New creates an empty listpack
+func New() *ListPack {
+	lp := &ListPack{buf: make([]byte, HeaderSize+1)}
+	lp.buf[HeaderSize] = eof
+	lp.writeHeader()
+	return lp
+}
+
+// FromBytes wraps an encoded listpack, such as one returned by Bytes
+func FromBytes(b []byte) *ListPack {
+	lp := &ListPack{buf: b, count: int(binary.LittleEndian.Uint16(b[4:6]))}
+	if lp.count == unknownCount {
+		lp.count = 0
+		for p := HeaderSize; b[p] != eof; p += entrySize(b[p:]) {
+			lp.count++
+		}
+	}
+	return lp
+}
+
+// Bytes returns the encoded listpack
+func (lp *ListPack) Bytes() []byte {
+	return lp.buf
+}
+
+// Len returns the number of entries
+func (lp *ListPack) Len() int {
+	return lp.count
+}
+
+// Size returns the number of bytes used by the encoding
+func (lp *ListPack) Size() int {
+	return len(lp.buf)
+}
+
+// EntrySize returns the number of bytes value takes once encoded
+func EntrySize(value []byte) int {
+	n := encodedLen(value)
+	return n + backlenSize(n)
+}
+
+// Get returns a copy of the entry at index
+func (lp *ListPack) Get(index int) ([]byte, bool) {
+	if index < 0 || index >= lp.count {
+		return nil, false
+	}
+	value := decode(lp.buf[lp.seek(index):])
+	return append([]byte(nil), value...), true
+}
+
+// Insert inserts value before the entry at index. An index equal to Len
+// appends the value.
+func (lp *ListPack) Insert(index int, value []byte) bool {
+	if index < 0 || index > lp.count {
+		return false
+	}
+	p := len(lp.buf) - 1
+	if index < lp.count {
+		p = lp.seek(index)
+	}
+	entry := encode(nil, value)
+	lp.buf = append(lp.buf, entry...)
+	copy(lp.buf[p+len(entry):], lp.buf[p:len(lp.buf)-len(entry)])
+	copy(lp.buf[p:], entry)
+	lp.count++
+	lp.writeHeader()
+	return true
+}
+
+// Replace sets the entry at index to value
+func (lp *ListPack) Replace(index int, value []byte) bool {
+	if index < 0 || index >= lp.count {
+		return false
+	}
+	p := lp.seek(index)
+	old := entrySize(lp.buf[p:])
+	entry := encode(nil, value)
+	tail := lp.buf[p+old:]
+	buf := make([]byte, 0, p+len(entry)+len(tail))
+	buf = append(buf, lp.buf[:p]...)
+	buf = append(buf, entry...)
+	lp.buf = append(buf, tail...)
+	lp.writeHeader()
+	return true
+}
+
+// Delete removes the entry at index
+func (lp *ListPack) Delete(index int) bool {
+	return lp.DeleteRange(index, 1) == 1
+}
+
+// DeleteRange removes n entries starting at index and returns the number removed
+func (lp *ListPack) DeleteRange(index, n int) int {
+	if index < 0 || index >= lp.count || n <= 0 {
+		return 0
+	}
+	n = min(n, lp.count-index)
+	start := lp.seek(index)
+	end := start
+	for i := 0; i < n; i++ {
+		end += entrySize(lp.buf[end:])
+	}
+	lp.buf = append(lp.buf[:start], lp.buf[end:]...)
+	lp.count -= n
+	lp.writeHeader()
+	return n
+}
+
+// Each calls fn for every entry from the first to the last until fn returns
+// false. The value is only valid until the listpack is modified.
+func (lp *ListPack) Each(fn func(index int, value []byte) bool) {
+	p := HeaderSize
+	for i := 0; i < lp.count; i++ {
+		if !fn(i, decode(lp.buf[p:])) {
+			return
+		}
+		p += entrySize(lp.buf[p:])
+	}
+}
+
+// EachReverse calls fn for every entry from the last to the first until fn
+// returns false
+func (lp *ListPack) EachReverse(fn func(index int, value []byte) bool) {
+	p := len(lp.buf) - 1
+	for i := lp.count - 1; i >= 0; i-- {
+		p = prev(lp.buf, p)
+		if !fn(i, decode(lp.buf[p:])) {
+			return
+		}
+	}
+}
+
+// Split moves the entries from index to the end into a new listpack
+func (lp *ListPack) Split(index int) *ListPack {
+	other := New()
+	if index < 0 || index >= lp.count {
+		return other
+	}
+	p := lp.seek(index)
+	other.buf = append(other.buf[:HeaderSize], lp.buf[p:]...)
+	other.count = lp.count - index
+	other.writeHeader()
+
+	lp.buf = append(lp.buf[:p:p], eof)
+	lp.count = index
+	lp.writeHeader()
+	return other
+}
+
+// Merge appends all entries of other
+func (lp *ListPack) Merge(other *ListPack) {
+	lp.buf = append(lp.buf[:len(lp.buf)-1], other.buf[HeaderSize:]...)
+	lp.count += other.count
+	lp.writeHeader()
+}
+
+// seek returns the offset of the entry at index, walking from the nearer end
+func (lp *ListPack) seek(index int) int {
+	if index <= lp.count/2 {
+		p := HeaderSize
+		for i := 0; i < index; i++ {
+			p += entrySize(lp.buf[p:])
+		}
+		return p
+	}
+	p := len(lp.buf) - 1
+	for i := lp.count; i > index; i-- {
+		p = prev(lp.buf, p)
+	}
+	return p
+}
+
+func (lp *ListPack) writeHeader() {
+	binary.LittleEndian.PutUint32(lp.buf[0:4], uint32(len(lp.buf)))
+	count := uint16(unknownCount)
+	if lp.count < unknownCount {
+		count = uint16(lp.count)
+	}
+	binary.LittleEndian.PutUint16(lp.buf[4:6], count)
+}
+
+// prev returns the offset of the entry that ends right before offset p
+func prev(buf []byte, p int) int {
+	p--
+	var n, shift int
+	for {
+		b := buf[p]
+		n |= int(b&127) << shift
+		if b&128 == 0 {
+			break
+		}
+		shift += 7
+		p--
+	}
+	return p - n
+}
+
+// entrySize returns the size of the entry at the start of b, backlen included
+func entrySize(b []byte) int {
+	n := elementLen(b)
+	return n + backlenSize(n)
+}
+
+// elementLen returns the size of the encoding byte and the data
+func elementLen(b []byte) int {
+	switch c := b[0]; {
+	case c&0x80 == 0:
+		return 1
+	case c&0xC0 == 0x80:
+		return 1 + int(c&0x3F)
+	case c&0xE0 == 0xC0:
+		return 2
+	case c&0xF0 == 0xE0:
+		return 2 + (int(c&0x0F)<<8 | int(b[1]))
+	case c == encStr32:
+		return 5 + int(binary.LittleEndian.Uint32(b[1:5]))
+	case c == encInt16:
+		return 3
+	case c == encInt24:
+		return 4
+	case c == encInt32:
+		return 5
+	default:
+		return 9
+	}
+}
+
+func backlenSize(n int) int {
+	switch {
+	case n <= 127:
+		return 1
+	case n < 16383:
+		return 2
+	case n < 2097151:
+		return 3
+	case n < 268435455:
+		return 4
+	}
+	return 5
+}
+
+// appendBacklen appends n so that it can be read from right to left, seven
+// bits per byte with the high bit marking that more bytes follow on the left
+func appendBacklen(dst []byte, n int) []byte {
+	size := backlenSize(n)
+	for i := size - 1; i >= 0; i-- {
+		b := byte(n>>(7*i)) & 127
+		if i != size-1 {
+			b |= 128
+		}
+		dst = append(dst, b)
+	}
+	return dst
+}
+
+// parseInt reports whether value is an integer in canonical form, so that it
+// can be stored as an integer and formatted back to the same bytes
+func parseInt(value []byte) (int64, bool) {
+	if len(value) == 0 || len(value) > 20 {
+		return 0, false
+	}
+	if c := value[0]; c != '-' && (c < '0' || c > '9') {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	var buf [20]byte
+	if string(strconv.AppendInt(buf[:0], v, 10)) != string(value) {
+		return 0, false
+	}
+	return v, true
+}
+
+func encodedLen(value []byte) int {
+	if v, ok := parseInt(value); ok {
+		switch {
+		case v >= 0 && v <= 127:
+			return 1
+		case v >= -4096 && v <= 4095:
+			return 2
+		case v >= math.MinInt16 && v <= math.MaxInt16:
+			return 3
+		case v >= -1<<23 && v <= 1<<23-1:
+			return 4
+		case v >= math.MinInt32 && v <= math.MaxInt32:
+			return 5
+		}
+		return 9
+	}
+	switch n := len(value); {
+	case n < 64:
+		return 1 + n
+	case n < 4096:
+		return 2 + n
+	default:
+		return 5 + n
+	}
+}
+
+// encode appends the encoded entry for value to dst
+func encode(dst []byte, value []byte) []byte {
+	start := len(dst)
+	if v, ok := parseInt(value); ok {
+		switch {
+		case v >= 0 && v <= 127:
+			dst = append(dst, byte(v))
+		case v >= -4096 && v <= 4095:
+			u := uint16(v) & 0x1FFF
+			dst = append(dst, 0xC0|byte(u>>8), byte(u))
+		case v >= math.MinInt16 && v <= math.MaxInt16:
+			dst = binary.LittleEndian.AppendUint16(append(dst, encInt16), uint16(v))
+		case v >= -1<<23 && v <= 1<<23-1:
+			u := uint32(v)
+			dst = append(dst, encInt24, byte(u), byte(u>>8), byte(u>>16))
+		case v >= math.MinInt32 && v <= math.MaxInt32:
+			dst = binary.LittleEndian.AppendUint32(append(dst, encInt32), uint32(v))
+		default:
+			dst = binary.LittleEndian.AppendUint64(append(dst, encInt64), uint64(v))
+		}
+	} else {
+		switch n := len(value); {
+		case n < 64:
+			dst = append(dst, 0x80|byte(n))
+		case n < 4096:
+			dst = append(dst, 0xE0|byte(n>>8), byte(n))
+		default:
+			dst = binary.LittleEndian.AppendUint32(append(dst, encStr32), uint32(n))
+		}
+		dst = append(dst, value...)
+	}
+	return appendBacklen(dst, len(dst)-start)
+}
+
+// decode returns the value of the entry at the start of b. Strings alias b,
+// integers are formatted into a new slice.
+func decode(b []byte) []byte {
+	var v int64
+	switch c := b[0]; {
+	case c&0x80 == 0:
+		v = int64(c)
+	case c&0xC0 == 0x80:
+		return b[1 : 1+int(c&0x3F)]
+	case c&0xE0 == 0xC0:
+		u := uint16(c&0x1F)<<8 | uint16(b[1])
+		v = int64(int16(u<<3) >> 3)
+	case c&0xF0 == 0xE0:
+		n := int(c&0x0F)<<8 | int(b[1])
+		return b[2 : 2+n]
+	case c == encStr32:
+		n := int(binary.LittleEndian.Uint32(b[1:5]))
+		return b[5 : 5+n]
+	case c == encInt16:
+		v = int64(int16(binary.LittleEndian.Uint16(b[1:3])))
+	case c == encInt24:
+		u := uint32(b[1]) | uint32(b[2])<<8 | uint32(b[3])<<16
+		v = int64(int32(u<<8) >> 8)
+	case c == encInt32:
+		v = int64(int32(binary.LittleEndian.Uint32(b[1:5])))
+	default:
+		v = int64(binary.LittleEndian.Uint64(b[1:9]))
+	}
+	return strconv.AppendInt(nil, v, 10)
+}
@@ -0,0 +1,146 @@
+package dslistpack
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// values 覆盖所有的整数和字符串编码，以及看起来像整数但不是规范形式的字符串
+var values = []string{
+	"0", "127", "128", "-1", "4095", "-4096", "4096", "32767", "-32768",
+	"8388607", "-8388608", "2147483647", "-2147483648",
+	fmt.Sprint(int64(math.MaxInt64)), fmt.Sprint(int64(math.MinInt64)),
+	"9223372036854775808", "007", "-0", "+1", "1 ", "", "a",
+	strings.Repeat("s", 63), strings.Repeat("m", 64), strings.Repeat("m", 4095),
+	strings.Repeat("l", 4096), strings.Repeat("l", 20000),
+}
+
+func contents(lp *ListPack) []string {
+	var out []string
+	lp.Each(func(i int, v []byte) bool {
+		out = append(out, string(v))
+		return true
+	})
+	return out
+}
+
+func TestRoundTrip(t *testing.T) {
+	lp := New()
+	for _, v := range values {
+		lp.Insert(lp.Len(), []byte(v))
+	}
+	if lp.Len() != len(values) {
+		t.Fatalf("Len = %d, want %d", lp.Len(), len(values))
+	}
+	size := HeaderSize + 1
+	for i, v := range values {
+		size += EntrySize([]byte(v))
+		if got, _ := lp.Get(i); string(got) != v {
+			t.Errorf("Get(%d) = %q, want %q", i, trim(got), trim([]byte(v)))
+		}
+	}
+	if lp.Size() != size {
+		t.Errorf("Size = %d, want %d", lp.Size(), size)
+	}
+
+	i := len(values) - 1
+	lp.EachReverse(func(index int, v []byte) bool {
+		if index != i || string(v) != values[i] {
+			t.Errorf("EachReverse at %d = %q, want %q", index, trim(v), trim([]byte(values[i])))
+		}
+		i--
+		return true
+	})
+
+	copied := FromBytes(append([]byte(nil), lp.Bytes()...))
+	if strings.Join(contents(copied), ",") != strings.Join(values, ",") {
+		t.Error("FromBytes does not decode the same entries")
+	}
+}
+
+func TestInsertDeleteReplace(t *testing.T) {
+	lp := New()
+	for _, v := range []string{"b", "d"} {
+		lp.Insert(lp.Len(), []byte(v))
+	}
+	lp.Insert(0, []byte("a"))
+	lp.Insert(2, []byte("300"))
+	lp.Insert(4, []byte("e"))
+	if got := strings.Join(contents(lp), ","); got != "a,b,300,d,e" {
+		t.Fatalf("after inserts: %s", got)
+	}
+	if lp.Insert(6, []byte("x")) || lp.Insert(-1, []byte("x")) {
+		t.Fatal("Insert out of range should fail")
+	}
+
+	lp.Replace(2, []byte(strings.Repeat("z", 100)))
+	lp.Replace(0, []byte("1"))
+	if got, _ := lp.Get(2); len(got) != 100 {
+		t.Fatalf("Replace with a longer value: %q", got)
+	}
+	lp.Replace(2, []byte("c"))
+	if got := strings.Join(contents(lp), ","); got != "1,b,c,d,e" {
+		t.Fatalf("after replaces: %s", got)
+	}
+
+	if n := lp.DeleteRange(1, 2); n != 2 {
+		t.Fatalf("DeleteRange = %d", n)
+	}
+	if n := lp.DeleteRange(2, 10); n != 1 {
+		t.Fatalf("DeleteRange past the end = %d", n)
+	}
+	if got := strings.Join(contents(lp), ","); got != "1,d" {
+		t.Fatalf("after deletes: %s", got)
+	}
+	lp.Delete(0)
+	lp.Delete(0)
+	if lp.Len() != 0 || lp.Size() != HeaderSize+1 {
+		t.Fatalf("empty listpack: len %d, size %d", lp.Len(), lp.Size())
+	}
+}
+
+func TestSplitMerge(t *testing.T) {
+	lp := New()
+	for i := 0; i < 100; i++ {
+		lp.Insert(lp.Len(), []byte(fmt.Sprint(i*1000)))
+	}
+	right := lp.Split(40)
+	if lp.Len() != 40 || right.Len() != 60 {
+		t.Fatalf("Split: %d + %d", lp.Len(), right.Len())
+	}
+	if v, _ := right.Get(0); string(v) != "40000" {
+		t.Fatalf("right half starts with %s", v)
+	}
+	if v, _ := lp.Get(39); string(v) != "39000" {
+		t.Fatalf("left half ends with %s", v)
+	}
+	lp.Merge(right)
+	if lp.Len() != 100 {
+		t.Fatalf("Merge: len %d", lp.Len())
+	}
+	for i := 0; i < 100; i++ {
+		if v, _ := lp.Get(i); string(v) != fmt.Sprint(i*1000) {
+			t.Fatalf("Get(%d) = %s after merge", i, v)
+		}
+	}
+}
+
+func TestManyEntries(t *testing.T) {
+	lp := New()
+	for i := 0; i < 70000; i++ {
+		lp.Insert(lp.Len(), []byte("x"))
+	}
+	copied := FromBytes(lp.Bytes())
+	if copied.Len() != 70000 {
+		t.Fatalf("FromBytes with more than 65535 entries: len %d", copied.Len())
+	}
+}
+
+func trim(b []byte) string {
+	if len(b) > 20 {
+		return fmt.Sprintf("%s...(%d bytes)", b[:20], len(b))
+	}
+	return string(b)
+}
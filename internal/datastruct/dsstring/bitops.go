@@ -0,0 +1,327 @@
+package dsstring
+
+import (
+	"math"
+	"math/bits"
+)
+
+// 位图操作。第 0 位是第 0 个字节的最高位，超出字符串长度的位视为 0，
+// 写入时用 0 字节扩展字符串
+
+// GetBit 返回 offset 位的值
+func (s *SDS) GetBit(offset uint64) int {
+	return getBit(s.Get(), offset)
+}
+
+// SetBit 设置 offset 位并返回原来的值
+func (s *SDS) SetBit(offset uint64, bit int) int {
+	s.grow0(int(offset>>3) + 1)
+	old := getBit(s.buf, offset)
+	mask := byte(1) << (7 - offset&7)
+	if bit != 0 {
+		s.buf[offset>>3] |= mask
+	} else {
+		s.buf[offset>>3] &^= mask
+	}
+	return old
+}
+
+// grow0 用 0 字节把字符串扩展到至少 n 字节
+func (s *SDS) grow0(n int) {
+	s.toRaw()
+	if n > s.len {
+		s.Append(make([]byte, n-s.len))
+	}
+}
+
+func getBit(b []byte, offset uint64) int {
+	if offset>>3 >= uint64(len(b)) {
+		return 0
+	}
+	return int(b[offset>>3]>>(7-offset&7)) & 1
+}
+
+// normalizeRange 按 GETRANGE 的规则处理负数下标，范围为空时返回 false
+func normalizeRange(start, end, total int64) (int64, int64, bool) {
+	if start < 0 {
+		start += total
+	}
+	if end < 0 {
+		end += total
+	}
+	start, end = max(start, 0), max(end, 0)
+	end = min(end, total-1)
+	return start, end, start <= end
+}
+
+// BitCount 统计 [start, end] 范围内为 1 的位数，bitMode 为 true 时范围以位为单位，
+// 否则以字节为单位，负数从末尾开始计算
+func BitCount(b []byte, start, end int64, bitMode bool) int64 {
+	total := int64(len(b))
+	if bitMode {
+		total *= 8
+	}
+	start, end, ok := normalizeRange(start, end, total)
+	if !ok {
+		return 0
+	}
+	if !bitMode {
+		start, end = start*8, end*8+7
+	}
+
+	var count int64
+	first, last := start>>3, end>>3
+	for _, c := range b[first : last+1] {
+		count += int64(bits.OnesCount8(c))
+	}
+	// 去掉首字节中 start 之前和尾字节中 end 之后的位
+	count -= int64(bits.OnesCount8(b[first] >> (8 - start&7)))
+	count -= int64(bits.OnesCount8(b[last] << (1 + end&7)))
+	return count
+}
+
+// BitPos 返回 [start, end] 范围内第一个值为 bit 的位置，没有找到时返回 -1。
+// 查找 0 且没有指定 end 时，字符串视为右侧补 0，返回范围之后的第一个位置
+func BitPos(b []byte, bit int, start, end int64, endGiven, bitMode bool) int64 {
+	total := int64(len(b))
+	if bitMode {
+		total *= 8
+	}
+	if !endGiven {
+		end = total - 1
+	}
+	start, end, ok := normalizeRange(start, end, total)
+	if !ok {
+		return -1
+	}
+	if !bitMode {
+		start, end = start*8, end*8+7
+	}
+
+	// 整个字节都不是要找的值时一次跳过 8 位
+	skip := byte(0)
+	if bit == 0 {
+		skip = 0xFF
+	}
+	for p := start; p <= end; {
+		if p&7 == 0 && p+7 <= end && b[p>>3] == skip {
+			p += 8
+			continue
+		}
+		if getBit(b, uint64(p)) == bit {
+			return p
+		}
+		p++
+	}
+	if bit == 0 && !endGiven {
+		return end + 1
+	}
+	return -1
+}
+
+// BitOp 是 BITOP 的运算
+type BitOp int
+
+const (
+	BitAnd BitOp = iota
+	BitOr
+	BitXor
+	BitNot
+	BitDiff // 第一个源中有、其余源中都没有的位
+	BitOne  // 只在一个源中出现的位
+)
+
+// Apply 对 srcs 按位运算，较短的源视为右侧补 0，结果的长度与最长的源相同
+func (op BitOp) Apply(srcs [][]byte) []byte {
+	n := 0
+	for _, src := range srcs {
+		n = max(n, len(src))
+	}
+	res := make([]byte, n)
+	at := func(src []byte, i int) byte {
+		if i < len(src) {
+			return src[i]
+		}
+		return 0
+	}
+	for i := range res {
+		var acc byte
+		switch op {
+		case BitAnd:
+			acc = 0xFF
+			for _, src := range srcs {
+				acc &= at(src, i)
+			}
+		case BitOr:
+			for _, src := range srcs {
+				acc |= at(src, i)
+			}
+		case BitXor:
+			for _, src := range srcs {
+				acc ^= at(src, i)
+			}
+		case BitNot:
+			acc = ^at(srcs[0], i)
+		case BitDiff:
+			var others byte
+			for _, src := range srcs[1:] {
+				others |= at(src, i)
+			}
+			acc = at(srcs[0], i) &^ others
+		case BitOne:
+			// once 记录出现过的位，more 记录出现过不止一次的位
+			var once, more byte
+			for _, src := range srcs {
+				c := at(src, i)
+				more |= once & c
+				once |= c
+			}
+			acc = once &^ more
+		}
+		res[i] = acc
+	}
+	return res
+}
+
+// Overflow 是 BITFIELD 的溢出处理方式
+type Overflow int
+
+const (
+	OverflowWrap Overflow = iota
+	OverflowSat
+	OverflowFail
+)
+
+// BitFieldOpcode 是 BITFIELD 的子命令
+type BitFieldOpcode int
+
+const (
+	BitFieldGet BitFieldOpcode = iota
+	BitFieldSet
+	BitFieldIncrBy
+)
+
+// BitFieldOp 是 BITFIELD 的一个操作，Value 为 SET 的值或者 INCRBY 的增量
+type BitFieldOp struct {
+	Opcode   BitFieldOpcode
+	Signed   bool
+	Bits     int
+	Offset   uint64
+	Value    int64
+	Overflow Overflow
+}
+
+// BitFieldResult 是一个操作的结果，Failed 表示 OVERFLOW FAIL 时发生了溢出
+type BitFieldResult struct {
+	Value  int64
+	Failed bool
+}
+
+// BitField 依次执行 ops，有写操作时字符串会扩展到能容纳所有写入的位
+func (s *SDS) BitField(ops []BitFieldOp) []BitFieldResult {
+	results := make([]BitFieldResult, len(ops))
+	for i, op := range ops {
+		if op.Opcode == BitFieldGet {
+			results[i].Value = s.getField(op)
+			continue
+		}
+
+		old := s.getField(op)
+		value, incr := op.Value, int64(0)
+		if op.Opcode == BitFieldIncrBy {
+			value, incr = old, op.Value
+		}
+		var res int64
+		var overflow bool
+		if op.Signed {
+			res, overflow = signedOverflow(value, incr, op.Bits, op.Overflow)
+		} else {
+			res, overflow = unsignedOverflow(uint64(value), incr, op.Bits, op.Overflow)
+		}
+		if overflow && op.Overflow == OverflowFail {
+			results[i].Failed = true
+			// 和 Redis 一样，失败的写操作也会扩展字符串
+			s.grow0(int((op.Offset+uint64(op.Bits)-1)>>3 + 1))
+			continue
+		}
+		s.setBits(op.Offset, op.Bits, uint64(res))
+		if op.Opcode == BitFieldSet {
+			results[i].Value = old
+		} else {
+			results[i].Value = res
+		}
+	}
+	return results
+}
+
+func (s *SDS) getField(op BitFieldOp) int64 {
+	v := getBits(s.Get(), op.Offset, op.Bits)
+	if op.Signed && op.Bits < 64 && v&(1<<(op.Bits-1)) != 0 {
+		// 符号扩展
+		v |= math.MaxUint64 << op.Bits
+	}
+	return int64(v)
+}
+
+// getBits 读取从 offset 开始的 n 位无符号整数，高位在前
+func getBits(b []byte, offset uint64, n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		v = v<<1 | uint64(getBit(b, offset+uint64(i)))
+	}
+	return v
+}
+
+func (s *SDS) setBits(offset uint64, n int, v uint64) {
+	s.grow0(int((offset+uint64(n)-1)>>3 + 1))
+	for i := 0; i < n; i++ {
+		s.SetBit(offset+uint64(i), int(v>>(n-1-i))&1)
+	}
+}
+
+// unsignedOverflow 计算 n 位无符号整数 value 加 incr 的结果，返回是否溢出。
+// 溢出时 WRAP 取低 n 位，SAT 取最大值或最小值 0
+func unsignedOverflow(value uint64, incr int64, n int, ow Overflow) (int64, bool) {
+	maxValue := uint64(1)<<n - 1
+	overflow := value > maxValue || (incr > 0 && uint64(incr) > maxValue-value)
+	// incr 为 MinInt64 时 uint64(-incr) 仍然是它的绝对值
+	underflow := !overflow && incr < 0 && uint64(-incr) > value
+	res := value + uint64(incr)
+	switch {
+	case overflow && ow == OverflowSat:
+		res = maxValue
+	case underflow && ow == OverflowSat:
+		res = 0
+	default:
+		res &= maxValue
+	}
+	return int64(res), overflow || underflow
+}
+
+// signedOverflow 计算 n 位有符号整数 value 加 incr 的结果，返回是否溢出。
+// 溢出时 WRAP 取低 n 位再做符号扩展，SAT 取最大值或最小值
+func signedOverflow(value, incr int64, n int, ow Overflow) (int64, bool) {
+	maxValue := int64(math.MaxInt64)
+	if n < 64 {
+		maxValue = 1<<(n-1) - 1
+	}
+	minValue := -maxValue - 1
+	// 按无符号计算，避免 int64 溢出
+	res := uint64(value) + uint64(incr)
+	if n < 64 {
+		if res&(1<<(n-1)) != 0 {
+			res |= math.MaxUint64 << n
+		} else {
+			res &^= math.MaxUint64 << n
+		}
+	}
+	overflow := value > maxValue || (incr > 0 && value > maxValue-incr)
+	underflow := !overflow && (value < minValue || (incr < 0 && value < minValue-incr))
+	switch {
+	case overflow && ow == OverflowSat:
+		return maxValue, true
+	case underflow && ow == OverflowSat:
+		return minValue, true
+	}
+	return int64(res), overflow || underflow
+}
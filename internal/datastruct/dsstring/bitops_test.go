@@ -0,0 +1,143 @@
+package dsstring
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSetBitGrows(t *testing.T) {
+	s := NewSDS("")
+	if old := s.SetBit(7, 1); old != 0 {
+		t.Fatalf("SetBit on an empty string returned %d", old)
+	}
+	if s.SetBit(100, 1) != 0 || s.Len() != 13 {
+		t.Fatalf("SetBit past the end: len %d", s.Len())
+	}
+	if s.Get()[0] != 0x01 || s.GetBit(7) != 1 || s.GetBit(6) != 0 || s.GetBit(1000) != 0 {
+		t.Errorf("bits = %08b", s.Get()[0])
+	}
+	if s.SetBit(7, 0) != 1 || s.Get()[0] != 0 {
+		t.Error("SetBit 0 did not clear the bit")
+	}
+}
+
+func TestBitCountAndPos(t *testing.T) {
+	b := []byte("foobar")
+	cases := []struct {
+		start, end int64
+		bitMode    bool
+		want       int64
+	}{
+		{0, -1, false, 26},
+		{0, 0, false, 4},
+		{1, 1, false, 6},
+		{1, 1, true, 1},
+		{5, 30, true, 17},
+		{-2, -1, false, 7},
+		{3, 1, false, 0},
+		{100, 200, false, 0},
+	}
+	for _, c := range cases {
+		if got := BitCount(b, c.start, c.end, c.bitMode); got != c.want {
+			t.Errorf("BitCount(%d, %d, %v) = %d, want %d", c.start, c.end, c.bitMode, got, c.want)
+		}
+	}
+
+	ff := []byte{0xff, 0xf0, 0x00}
+	posCases := []struct {
+		bit             int
+		start, end      int64
+		endGiven, bitMd bool
+		want            int64
+	}{
+		{0, 0, 0, false, false, 12},
+		{1, 2, 0, false, false, -1},
+		{1, 0, 0, false, false, 0},
+		{0, 0, 0, true, false, -1},
+		{1, 7, 15, true, true, 7},
+		{0, 7, 15, true, true, 12},
+		{1, 2, -1, true, true, 2},
+	}
+	for _, c := range posCases {
+		if got := BitPos(ff, c.bit, c.start, c.end, c.endGiven, c.bitMd); got != c.want {
+			t.Errorf("BitPos(%d, %d, %d, %v, %v) = %d, want %d", c.bit, c.start, c.end, c.endGiven, c.bitMd, got, c.want)
+		}
+	}
+	if got := BitPos([]byte{0xff, 0xff}, 0, 0, 0, false, false); got != 16 {
+		t.Errorf("BitPos for 0 in all-ones without end = %d, want 16", got)
+	}
+	if got := BitPos([]byte{0xff, 0xff}, 0, 0, -1, true, false); got != -1 {
+		t.Errorf("BitPos for 0 in all-ones with end = %d, want -1", got)
+	}
+}
+
+func TestBitOp(t *testing.T) {
+	a, b, c := []byte{0b1100}, []byte{0b1010, 0xff}, []byte{0b0110}
+	cases := []struct {
+		op   BitOp
+		srcs [][]byte
+		want []byte
+	}{
+		{BitAnd, [][]byte{a, b}, []byte{0b1000, 0}},
+		{BitOr, [][]byte{a, b}, []byte{0b1110, 0xff}},
+		{BitXor, [][]byte{a, b, c}, []byte{0b0000, 0xff}},
+		{BitNot, [][]byte{a}, []byte{0b11110011}},
+		{BitDiff, [][]byte{a, b}, []byte{0b0100, 0}},
+		{BitOne, [][]byte{a, b, c}, []byte{0b0000, 0xff}},
+		{BitOne, [][]byte{a, []byte{0b0001}}, []byte{0b1101}},
+		{BitOr, [][]byte{nil, nil}, []byte{}},
+	}
+	for _, tc := range cases {
+		if got := tc.op.Apply(tc.srcs); string(got) != string(tc.want) {
+			t.Errorf("op %d = %08b, want %08b", tc.op, got, tc.want)
+		}
+	}
+}
+
+func TestBitField(t *testing.T) {
+	s := NewSDS("")
+	res := s.BitField([]BitFieldOp{
+		{Opcode: BitFieldSet, Bits: 8, Offset: 0, Value: 255},
+		{Opcode: BitFieldGet, Signed: true, Bits: 8, Offset: 0},
+		{Opcode: BitFieldGet, Bits: 4, Offset: 4},
+		{Opcode: BitFieldIncrBy, Signed: true, Bits: 5, Offset: 100, Value: 1},
+	})
+	want := []int64{0, -1, 15, 1}
+	for i, r := range res {
+		if r.Value != want[i] || r.Failed {
+			t.Errorf("op %d = %+v, want %d", i, r, want[i])
+		}
+	}
+	if s.Len() != 14 {
+		t.Errorf("len after writing bit 104 = %d", s.Len())
+	}
+
+	// 每个用例都在上一个用例的结果上执行
+	overflow := []struct {
+		op   BitFieldOp
+		want BitFieldResult
+	}{
+		{BitFieldOp{Opcode: BitFieldIncrBy, Bits: 2, Value: 5}, BitFieldResult{Value: 1}},
+		{BitFieldOp{Opcode: BitFieldIncrBy, Bits: 2, Value: 5, Overflow: OverflowSat}, BitFieldResult{Value: 3}},
+		{BitFieldOp{Opcode: BitFieldIncrBy, Bits: 2, Value: 1, Overflow: OverflowFail}, BitFieldResult{Failed: true}},
+		{BitFieldOp{Opcode: BitFieldIncrBy, Bits: 2, Value: -10, Overflow: OverflowSat}, BitFieldResult{Value: 0}},
+		{BitFieldOp{Opcode: BitFieldIncrBy, Signed: true, Bits: 4, Value: 9}, BitFieldResult{Value: -7}},
+		{BitFieldOp{Opcode: BitFieldIncrBy, Signed: true, Bits: 4, Value: 20, Overflow: OverflowSat}, BitFieldResult{Value: 7}},
+		{BitFieldOp{Opcode: BitFieldIncrBy, Signed: true, Bits: 4, Value: -20, Overflow: OverflowSat}, BitFieldResult{Value: -8}},
+		{BitFieldOp{Opcode: BitFieldSet, Signed: true, Bits: 4, Value: 8, Overflow: OverflowFail}, BitFieldResult{Failed: true}},
+		{BitFieldOp{Opcode: BitFieldSet, Signed: true, Bits: 64, Value: math.MinInt64}, BitFieldResult{Value: math.MinInt64}},
+		{BitFieldOp{Opcode: BitFieldIncrBy, Signed: true, Bits: 64, Value: -1, Overflow: OverflowFail}, BitFieldResult{Failed: true}},
+		{BitFieldOp{Opcode: BitFieldIncrBy, Signed: true, Bits: 64, Value: -1}, BitFieldResult{Value: math.MaxInt64}},
+	}
+	s = NewSDS("")
+	for i, c := range overflow {
+		got := s.BitField([]BitFieldOp{c.op})[0]
+		if got != c.want {
+			t.Errorf("case %d: %+v, want %+v", i, got, c.want)
+		}
+		// 无符号的用例结束之后从空字符串开始有符号的用例
+		if i == 3 {
+			s = NewSDS("")
+		}
+	}
+}
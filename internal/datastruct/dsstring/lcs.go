@@ -0,0 +1,72 @@
+package dsstring
+
+// Match 是公共子序列中连续的一段，A 和 B 为两个字符串中的闭区间
+type Match struct {
+	A, B [2]int
+	Len  int
+}
+
+// LCS 返回 a 和 b 的最长公共子序列，以及组成它的连续片段。
+// 片段从字符串的末尾开始排列，与 Redis 的 LCS IDX 相同
+func LCS(a, b []byte) ([]byte, []Match) {
+	// table[i*(len(b)+1)+j] 是 a[:i] 和 b[:j] 的最长公共子序列的长度
+	width := len(b) + 1
+	table := make([]uint32, (len(a)+1)*width)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				table[i*width+j] = table[(i-1)*width+j-1] + 1
+			} else {
+				table[i*width+j] = max(table[(i-1)*width+j], table[i*width+j-1])
+			}
+		}
+	}
+
+	idx := table[len(a)*width+len(b)]
+	seq := make([]byte, idx)
+	var matches []Match
+	// start 为 -1 表示当前没有正在记录的片段
+	start := [2]int{-1, -1}
+	end := [2]int{}
+	i, j := len(a), len(b)
+	for i > 0 && j > 0 {
+		emit := false
+		if a[i-1] == b[j-1] {
+			seq[idx-1] = a[i-1]
+			switch {
+			case start[0] == -1:
+				start = [2]int{i - 1, j - 1}
+				end = start
+			case start[0] == i && start[1] == j:
+				// 与当前片段连续，向前扩展
+				start[0]--
+				start[1]--
+			default:
+				emit = true
+			}
+			// 匹配到了某个字符串的第一个字节，循环马上结束
+			if start[0] == 0 || start[1] == 0 {
+				emit = true
+			}
+			idx--
+			i--
+			j--
+		} else {
+			if table[(i-1)*width+j] > table[i*width+j-1] {
+				i--
+			} else {
+				j--
+			}
+			emit = start[0] != -1
+		}
+		if emit {
+			matches = append(matches, Match{
+				A:   [2]int{start[0], end[0]},
+				B:   [2]int{start[1], end[1]},
+				Len: end[0] - start[0] + 1,
+			})
+			start = [2]int{-1, -1}
+		}
+	}
+	return seq, matches
+}
@@ -1,20 +1,55 @@
 package dsstring
 
 import (
+	"strconv"
 	"time"
 )
 
 const (
 	SDS_MAX_PREALLOC = 1024 * 1024 // 1MB
+
+	// SharedIntegers 0 到 SharedIntegers-1 之间的整数共用同一个对象
+	SharedIntegers = 10000
+
+	// EmbstrSizeLimit 不超过该长度的未修改字符串编码为 embstr
+	EmbstrSizeLimit = 44
 )
 
 type SDS struct {
 	buf      []byte
 	len      int
 	free     int
+	intVal   int64
+	isInt    bool // 整数编码，不占用 buf
+	raw      bool // 被 APPEND、SETRANGE 修改过
 	expireAt time.Time
 }
 
+var shared [SharedIntegers]*SDS
+
+func init() {
+	for i := range shared {
+		shared[i] = &SDS{intVal: int64(i), isInt: true}
+	}
+}
+
+// New 根据内容选择编码，规范形式的整数使用整数编码
+func New(value []byte) *SDS {
+	if v, ok := parseInt(value); ok {
+		return NewInt(v)
+	}
+	return NewSDS(string(value))
+}
+
+// NewInt 返回整数编码的字符串，小整数返回共享对象。共享对象不能被修改，
+// 修改之前先用 Unshare 复制
+func NewInt(v int64) *SDS {
+	if v >= 0 && v < SharedIntegers {
+		return shared[v]
+	}
+	return &SDS{intVal: v, isInt: true}
+}
+
 func NewSDS(init string) *SDS {
 	buf := make([]byte, len(init), len(init)*2)
 	copy(buf, init)
@@ -30,9 +65,45 @@ func (s *SDS) Type() string {
 }
 
 func (s *SDS) Len() int64 {
+	if s.isInt {
+		var buf [20]byte
+		return int64(len(strconv.AppendInt(buf[:0], s.intVal, 10)))
+	}
 	return int64(s.len)
 }
 
+// Int 返回字符串表示的整数，只接受规范形式，与 Redis 的 string2ll 相同
+func (s *SDS) Int() (int64, bool) {
+	if s.isInt {
+		return s.intVal, true
+	}
+	return parseInt(s.buf[:s.len])
+}
+
+// Shared reports whether s is one of the shared integer objects
+func (s *SDS) Shared() bool {
+	return s.isInt && s.intVal >= 0 && s.intVal < SharedIntegers && shared[s.intVal] == s
+}
+
+// Unshare 返回可以原地修改的字符串，整数编码转换为普通字符串
+func (s *SDS) Unshare() *SDS {
+	if !s.isInt {
+		return s
+	}
+	return NewSDS(strconv.FormatInt(s.intVal, 10))
+}
+
+// Encoding 返回 OBJECT ENCODING 报告的编码：int、embstr 或 raw
+func (s *SDS) Encoding() string {
+	switch {
+	case s.isInt:
+		return "int"
+	case !s.raw && s.len <= EmbstrSizeLimit:
+		return "embstr"
+	}
+	return "raw"
+}
+
 func (s *SDS) Expire() time.Time {
 	return s.expireAt
 }
@@ -46,10 +117,27 @@ func (s *SDS) IsExpired() bool {
 }
 
 func (s *SDS) Get() []byte {
+	if s.isInt {
+		return strconv.AppendInt(nil, s.intVal, 10)
+	}
 	return s.buf[:s.len]
 }
 
+// GobEncode 快照中只保存字符串的内容，编码在加载时重新选择
+func (s *SDS) GobEncode() ([]byte, error) {
+	return append([]byte(nil), s.Get()...), nil
+}
+
+func (s *SDS) GobDecode(data []byte) error {
+	*s = *New(data)
+	return nil
+}
+
 func (s *SDS) Set(value []byte) {
+	if s.Shared() {
+		panic("dsstring: modifying a shared integer")
+	}
+	s.isInt, s.raw = false, false
 	s.buf = make([]byte, len(value), len(value)*2)
 	copy(s.buf, value)
 	s.len = len(value)
@@ -57,9 +145,11 @@ func (s *SDS) Set(value []byte) {
 }
 
 func (s *SDS) Append(value []byte) int {
+	s.toRaw()
 	if s.free < len(value) {
 		s.grow(len(value))
 	}
+	s.buf = s.buf[:s.len+len(value)]
 	copy(s.buf[s.len:], value)
 	s.len += len(value)
 	s.free -= len(value)
@@ -73,13 +163,16 @@ func (s *SDS) grow(addLen int) {
 	} else {
 		newLen += SDS_MAX_PREALLOC
 	}
-	newBuf := make([]byte, newLen)
-	copy(newBuf, s.buf)
+	newBuf := make([]byte, s.len, newLen)
+	copy(newBuf, s.buf[:s.len])
 	s.buf = newBuf
 	s.free = newLen - s.len
 }
 
 func (s *SDS) GetRange(start, end int) []byte {
+	if s.isInt {
+		return NewSDS(string(s.Get())).GetRange(start, end)
+	}
 	if start < 0 {
 		start = s.len + start
 	}
@@ -95,13 +188,14 @@ func (s *SDS) GetRange(start, end int) []byte {
 	if start > end {
 		return []byte{}
 	}
-	return s.buf[start : end+1]
+	return append([]byte(nil), s.buf[start:end+1]...)
 }
 
 func (s *SDS) SetRange(offset int, value []byte) int {
 	if offset < 0 {
-		return s.len
+		return int(s.Len())
 	}
+	s.toRaw()
 	if offset >= s.len {
 		s.Append(make([]byte, offset-s.len))
 		s.Append(value)
@@ -115,6 +209,41 @@ func (s *SDS) SetRange(offset int, value []byte) int {
 	return s.len
 }
 
+// Bytes returns a copy of the string content
 func (s *SDS) Bytes() []byte {
-	return s.buf
+	if s.isInt {
+		return s.Get()
+	}
+	return append([]byte(nil), s.buf[:s.len]...)
+}
+
+// toRaw 原地修改之前把整数编码转换为普通字符串
+func (s *SDS) toRaw() {
+	if s.isInt {
+		if s.Shared() {
+			panic("dsstring: modifying a shared integer")
+		}
+		v := strconv.AppendInt(nil, s.intVal, 10)
+		s.buf, s.len, s.free, s.isInt = v, len(v), cap(v)-len(v), false
+	}
+	s.raw = true
+}
+
+// parseInt 解析规范形式的整数，不接受前导零、正号和空白
+func parseInt(value []byte) (int64, bool) {
+	if len(value) == 0 || len(value) > 20 {
+		return 0, false
+	}
+	if c := value[0]; c != '-' && (c < '0' || c > '9') {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	var buf [20]byte
+	if string(strconv.AppendInt(buf[:0], v, 10)) != string(value) {
+		return 0, false
+	}
+	return v, true
 }
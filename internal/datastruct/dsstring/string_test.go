@@ -0,0 +1,80 @@
+package dsstring
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncoding(t *testing.T) {
+	cases := []struct {
+		value, encoding string
+	}{
+		{"0", "int"},
+		{"9999", "int"},
+		{"-9223372036854775808", "int"},
+		{"007", "embstr"},
+		{"+1", "embstr"},
+		{"9223372036854775808", "embstr"},
+		{"hello", "embstr"},
+		{string(make([]byte, 45)), "raw"},
+	}
+	for _, c := range cases {
+		s := New([]byte(c.value))
+		if got := s.Encoding(); got != c.encoding {
+			t.Errorf("New(%q).Encoding() = %s, want %s", c.value, got, c.encoding)
+		}
+		if string(s.Get()) != c.value || s.Len() != int64(len(c.value)) {
+			t.Errorf("New(%q) = %q (len %d)", c.value, s.Get(), s.Len())
+		}
+	}
+}
+
+func TestSharedIntegers(t *testing.T) {
+	a, b := New([]byte("42")), NewInt(42)
+	if a != b || !a.Shared() {
+		t.Fatal("small integers should share one object")
+	}
+	if NewInt(SharedIntegers).Shared() || NewInt(-1).Shared() {
+		t.Fatal("integers outside the shared range should not be shared")
+	}
+
+	c := a.Unshare()
+	if c == a || c.Append([]byte("0")) != 3 || string(c.Get()) != "420" {
+		t.Fatalf("Unshare then Append = %q", c.Get())
+	}
+	if string(a.Get()) != "42" {
+		t.Fatalf("shared object modified: %q", a.Get())
+	}
+	if c.Encoding() != "raw" {
+		t.Errorf("appended string encoding = %s, want raw", c.Encoding())
+	}
+
+	big := NewInt(123456)
+	if big.SetRange(1, []byte("x")) != 6 || string(big.Get()) != "1x3456" {
+		t.Errorf("SetRange on an integer = %q", big.Get())
+	}
+	if v, ok := New([]byte("-17")).Int(); !ok || v != -17 {
+		t.Errorf("Int() = %d, %v", v, ok)
+	}
+	if _, ok := NewSDS("12a").Int(); ok {
+		t.Error("Int() should reject non-integers")
+	}
+}
+
+func TestLCS(t *testing.T) {
+	seq, matches := LCS([]byte("ohmytext"), []byte("mynewtext"))
+	if string(seq) != "mytext" {
+		t.Fatalf("LCS = %q, want mytext", seq)
+	}
+	want := []Match{
+		{A: [2]int{4, 7}, B: [2]int{5, 8}, Len: 4},
+		{A: [2]int{2, 3}, B: [2]int{0, 1}, Len: 2},
+	}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("matches = %v, want %v", matches, want)
+	}
+
+	if seq, matches := LCS(nil, []byte("abc")); len(seq) != 0 || matches != nil {
+		t.Errorf("LCS with an empty string = %q, %v", seq, matches)
+	}
+}
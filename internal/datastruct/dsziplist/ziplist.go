@@ -100,6 +100,44 @@ func (zl *ZipList) Insert(value []byte) error {
 	return nil
 }
 
+// InsertAt adds a new entry before the entry at the specified index.
+// An index equal to Len appends the entry.
+func (zl *ZipList) InsertAt(index int, value []byte) error {
+	if index < 0 || index > int(zl.length) {
+		return errors.New("index out of range")
+	}
+	if index == int(zl.length) {
+		return zl.Insert(value)
+	}
+
+	encodedValue, err := encodeEntry(value)
+	if err != nil {
+		return err
+	}
+
+	offset := uint32(10)
+	for i := 0; i < index; i++ {
+		_, entryLen := decodeEntry(zl.bytes[offset:])
+		offset += uint32(entryLen)
+	}
+
+	newBytes := make([]byte, 0, len(zl.bytes)+len(encodedValue))
+	newBytes = append(newBytes, zl.bytes[:offset]...)
+	newBytes = append(newBytes, encodedValue...)
+	newBytes = append(newBytes, zl.bytes[offset:]...)
+	zl.bytes = newBytes
+
+	zl.length++
+	zl.tailOffset += uint32(len(encodedValue))
+
+	// Update the ziplist header
+	binary.LittleEndian.PutUint32(zl.bytes[0:4], uint32(len(zl.bytes)))
+	binary.LittleEndian.PutUint16(zl.bytes[4:6], zl.length)
+	binary.LittleEndian.PutUint32(zl.bytes[6:10], zl.tailOffset)
+
+	return nil
+}
+
 // Delete removes an entry from the ziplist at the specified index
 func (zl *ZipList) Delete(index int) bool {
 	if index < 0 || index >= int(zl.length) {
@@ -237,7 +275,8 @@ func (zl *ZipList) Get(index int) ([]byte, bool) {
 	}
 
 	value, _ := decodeEntry(zl.bytes[offset:])
-	return value, true
+	// Return a copy so that later mutations of the ziplist don't alter it
+	return append([]byte(nil), value...), true
 }
 
 // Set updates the value at the specified index
@@ -264,6 +303,9 @@ func (zl *ZipList) Set(index int, value []byte) bool {
 			zl.bytes = append(zl.bytes, make([]byte, lenDiff)...)
 		}
 		copy(zl.bytes[offset+uint32(len(newValue)):], zl.bytes[offset+uint32(oldEntryLen):])
+		if lenDiff < 0 {
+			zl.bytes = zl.bytes[:len(zl.bytes)+lenDiff]
+		}
 	}
 	copy(zl.bytes[offset:], newValue)
 
@@ -273,3 +315,81 @@ func (zl *ZipList) Set(index int, value []byte) bool {
 
 	return true
 }
+
+// Each calls fn for every entry in order until fn returns false.
+// The value aliases the ziplist and is only valid until it is modified.
+func (zl *ZipList) Each(fn func(index int, value []byte) bool) {
+	offset := uint32(10)
+	for i := 0; i < int(zl.length); i++ {
+		value, entryLen := decodeEntry(zl.bytes[offset:])
+		if !fn(i, value) {
+			return
+		}
+		offset += uint32(entryLen)
+	}
+}
+
+// DeleteRange removes n entries starting at index and returns the number removed
+func (zl *ZipList) DeleteRange(index, n int) int {
+	if index < 0 || index >= int(zl.length) || n <= 0 {
+		return 0
+	}
+	if n > int(zl.length)-index {
+		n = int(zl.length) - index
+	}
+	start := zl.offsetOf(index)
+	end := start
+	for i := 0; i < n; i++ {
+		_, entryLen := decodeEntry(zl.bytes[end:])
+		end += uint32(entryLen)
+	}
+	copy(zl.bytes[start:], zl.bytes[end:])
+	zl.bytes = zl.bytes[:len(zl.bytes)-int(end-start)]
+	zl.length -= uint16(n)
+	zl.tailOffset -= end - start
+	zl.writeHeader()
+	return n
+}
+
+// Split moves the entries from index to the end into a new ziplist
+func (zl *ZipList) Split(index int) *ZipList {
+	other := NewZipList()
+	if index < 0 || index >= int(zl.length) {
+		return other
+	}
+	offset := zl.offsetOf(index)
+	other.bytes = append(other.bytes, zl.bytes[offset:]...)
+	other.length = zl.length - uint16(index)
+	other.tailOffset = uint32(len(other.bytes))
+	other.writeHeader()
+
+	zl.bytes = zl.bytes[:offset:offset]
+	zl.length = uint16(index)
+	zl.tailOffset = offset
+	zl.writeHeader()
+	return other
+}
+
+// Merge appends all entries of other to the end of the ziplist
+func (zl *ZipList) Merge(other *ZipList) {
+	zl.bytes = append(zl.bytes, other.bytes[10:]...)
+	zl.length += other.length
+	zl.tailOffset = uint32(len(zl.bytes))
+	zl.writeHeader()
+}
+
+// offsetOf returns the byte offset of the entry at index
+func (zl *ZipList) offsetOf(index int) uint32 {
+	offset := uint32(10)
+	for i := 0; i < index; i++ {
+		_, entryLen := decodeEntry(zl.bytes[offset:])
+		offset += uint32(entryLen)
+	}
+	return offset
+}
+
+func (zl *ZipList) writeHeader() {
+	binary.LittleEndian.PutUint32(zl.bytes[0:4], uint32(len(zl.bytes)))
+	binary.LittleEndian.PutUint16(zl.bytes[4:6], zl.length)
+	binary.LittleEndian.PutUint32(zl.bytes[6:10], zl.tailOffset)
+}
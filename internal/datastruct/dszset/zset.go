@@ -10,6 +10,9 @@ type ZSet interface {
 	RangeByScore(min, max float64) []string
 	Len() int64
 	IncrBy(increment float64, member string) float64
+
+	GobEncode() ([]byte, error)
+	GobDecode(data []byte) error
 }
 
 // NewZSet returns a new ZSet implementation.
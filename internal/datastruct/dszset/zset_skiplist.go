@@ -1,5 +1,10 @@
 package dszset
 
+import (
+	"bytes"
+	"encoding/gob"
+)
+
 // SkipListZSet implements the ZSet interface using a skip list data structure.
 // Skip lists provide O(log N) time complexity for add, remove, and search operations.
 type SkipListZSet struct {
@@ -52,3 +57,35 @@ func (z *SkipListZSet) IncrBy(increment float64, member string) float64 {
 	z.Add(increment, member)
 	return increment
 }
+
+// snapshot 是有序集合在快照中的形式，按分数从小到大排列
+type snapshot struct {
+	Members []string
+	Scores  []float64
+}
+
+func (z *SkipListZSet) GobEncode() ([]byte, error) {
+	var snap snapshot
+	snap.Members = z.Range(0, -1)
+	snap.Scores = make([]float64, len(snap.Members))
+	for i, member := range snap.Members {
+		snap.Scores[i], _ = z.Score(member)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snap); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (z *SkipListZSet) GobDecode(data []byte) error {
+	var snap snapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return err
+	}
+	z.sl = NewSkipList()
+	for i, member := range snap.Members {
+		z.Add(snap.Scores[i], member)
+	}
+	return nil
+}
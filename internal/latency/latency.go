@@ -0,0 +1,205 @@
+// Package latency 记录内部事件的延迟尖峰，实现 LATENCY LATEST/HISTORY/RESET/DOCTOR
+package latency
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 事件名称与 Redis 保持一致
+const (
+	EventCommand     = "command"      // 非 fast 命令的执行
+	EventFastCommand = "fast-command" // fast 命令的执行
+	EventExpireCycle = "expire-cycle" // 主动过期清理
+	EventRDBFsync    = "rdb-fsync"    // RDB 文件落盘
+	EventSnapshot    = "fork"         // 保存之前复制脏键集合，相当于 Redis 的 fork
+)
+
+// historyLen 每个事件保留的样本数，同一秒内的多个尖峰只保留最大值
+const historyLen = 160
+
+// Sample 一次延迟尖峰
+type Sample struct {
+	Time    time.Time
+	Latency time.Duration
+}
+
+// Latest LATENCY LATEST 中的一个事件
+type Latest struct {
+	Event string
+	Sample
+	Max time.Duration // 有记录以来的最大延迟
+}
+
+type series struct {
+	samples []Sample // 环形缓冲区
+	next    int
+	max     time.Duration
+}
+
+func (s *series) add(now time.Time, d time.Duration) {
+	if d > s.max {
+		s.max = d
+	}
+	if len(s.samples) > 0 {
+		last := &s.samples[(s.next-1+len(s.samples))%len(s.samples)]
+		if last.Time.Unix() == now.Unix() {
+			last.Latency = max(last.Latency, d)
+			return
+		}
+	}
+	if len(s.samples) < historyLen {
+		s.samples = append(s.samples, Sample{now, d})
+		s.next = len(s.samples) % historyLen
+		return
+	}
+	s.samples[s.next] = Sample{now, d}
+	s.next = (s.next + 1) % historyLen
+}
+
+// history 按时间从旧到新返回样本
+func (s *series) history() []Sample {
+	out := make([]Sample, 0, len(s.samples))
+	if len(s.samples) == historyLen {
+		out = append(out, s.samples[s.next:]...)
+		return append(out, s.samples[:s.next]...)
+	}
+	return append(out, s.samples...)
+}
+
+// Monitor 记录超过阈值的事件延迟。nil Monitor 可以安全调用，不记录任何事件
+type Monitor struct {
+	threshold atomic.Int64 // 毫秒，0 表示关闭
+
+	mu     sync.Mutex
+	events map[string]*series
+}
+
+func New(threshold time.Duration) *Monitor {
+	m := &Monitor{events: make(map[string]*series)}
+	m.SetThreshold(threshold)
+	return m
+}
+
+func (m *Monitor) SetThreshold(threshold time.Duration) {
+	m.threshold.Store(threshold.Milliseconds())
+}
+
+func (m *Monitor) Threshold() time.Duration {
+	return time.Duration(m.threshold.Load()) * time.Millisecond
+}
+
+// Add 延迟不低于阈值时记录一次尖峰
+func (m *Monitor) Add(event string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	threshold := m.Threshold()
+	if threshold <= 0 || d < threshold {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.events[event]
+	if !ok {
+		s = &series{}
+		m.events[event] = s
+	}
+	s.add(time.Now(), d)
+}
+
+// Latest 返回每个事件最近一次的尖峰，按事件名排序
+func (m *Monitor) Latest() []Latest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	latest := make([]Latest, 0, len(m.events))
+	for event, s := range m.events {
+		h := s.history()
+		latest = append(latest, Latest{Event: event, Sample: h[len(h)-1], Max: s.max})
+	}
+	sort.Slice(latest, func(i, j int) bool { return latest[i].Event < latest[j].Event })
+	return latest
+}
+
+// History 返回事件的所有样本，从旧到新
+func (m *Monitor) History(event string) []Sample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.events[event]; ok {
+		return s.history()
+	}
+	return nil
+}
+
+// Reset 清空指定事件的记录，没有指定时清空所有事件，返回被清空的事件数
+func (m *Monitor) Reset(events ...string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(events) == 0 {
+		n := len(m.events)
+		m.events = make(map[string]*series)
+		return n
+	}
+	n := 0
+	for _, event := range events {
+		if _, ok := m.events[event]; ok {
+			delete(m.events, event)
+			n++
+		}
+	}
+	return n
+}
+
+// advice 针对各个事件的建议
+var advice = map[string]string{
+	EventCommand:     "Check your SLOWLOG for commands with high time complexity, such as KEYS or range queries over large collections, and split them into smaller operations.",
+	EventFastCommand: "Fast commands should never be slow: the server is probably starved of CPU or the host is swapping. Check GOMAXPROCS, CPU steal and memory pressure.",
+	EventExpireCycle: "Many keys are expiring at the same time. Consider spreading expire times with some randomness.",
+	EventRDBFsync:    "The disk is slow to persist the RDB file. Use faster storage or a longer rdb-save-interval.",
+	EventSnapshot:    "Collecting changed keys before a save is slow because too many keys changed. Consider a lower rdb-auto-save-changes so that saves are smaller.",
+}
+
+// Doctor 生成可读的延迟分析报告
+func (m *Monitor) Doctor() string {
+	if m.Threshold() <= 0 {
+		return "I'm sorry, Dave, I can't do that. Latency monitoring is disabled in this instance. " +
+			"You may use \"CONFIG SET latency-monitor-threshold <milliseconds>.\" in order to enable it.\n"
+	}
+	latest := m.Latest()
+	if len(latest) == 0 {
+		return "Dave, no latency spike was observed during the lifetime of this instance, not in the slightest bit. " +
+			"I honestly think you ought to sleep tonight.\n"
+	}
+
+	var b strings.Builder
+	b.WriteString("Dave, I have observed latency spikes in this instance. You don't mind talking about it, do you Dave?\n\n")
+	for i, l := range latest {
+		h := m.History(l.Event)
+		var sum time.Duration
+		for _, s := range h {
+			sum += s.Latency
+		}
+		avg := sum / time.Duration(len(h))
+		var dev time.Duration
+		for _, s := range h {
+			dev += (s.Latency - avg).Abs()
+		}
+		dev /= time.Duration(len(h))
+		period := h[len(h)-1].Time.Sub(h[0].Time) / time.Duration(len(h))
+
+		fmt.Fprintf(&b, "%d. %s: %d latency spikes (average %dms, mean deviation %dms, period %.2f sec). Worst all time event %dms.\n",
+			i+1, l.Event, len(h), avg.Milliseconds(), dev.Milliseconds(), period.Seconds(), l.Max.Milliseconds())
+	}
+
+	b.WriteString("\nI have a few advices for you:\n\n")
+	for _, l := range latest {
+		if a, ok := advice[l.Event]; ok {
+			fmt.Fprintf(&b, "- %s: %s\n", l.Event, a)
+		}
+	}
+	return b.String()
+}
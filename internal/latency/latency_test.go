@@ -0,0 +1,94 @@
+package latency
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThreshold(t *testing.T) {
+	m := New(0)
+	m.Add(EventCommand, time.Second)
+	if got := m.Latest(); len(got) != 0 {
+		t.Fatalf("disabled monitor recorded %v", got)
+	}
+
+	m.SetThreshold(10 * time.Millisecond)
+	m.Add(EventCommand, 9*time.Millisecond)
+	m.Add(EventCommand, 10*time.Millisecond)
+	m.Add(EventCommand, 30*time.Millisecond)
+	m.Add(EventCommand, 20*time.Millisecond)
+	m.Add(EventExpireCycle, 15*time.Millisecond)
+
+	latest := m.Latest()
+	if len(latest) != 2 || latest[0].Event != EventCommand || latest[1].Event != EventExpireCycle {
+		t.Fatalf("latest = %+v", latest)
+	}
+	// 同一秒内的尖峰合并为最大值
+	if l := latest[0]; l.Latency != 30*time.Millisecond || l.Max != 30*time.Millisecond {
+		t.Errorf("command latest = %+v", l)
+	}
+	if h := m.History(EventCommand); len(h) != 1 {
+		t.Errorf("history = %+v", h)
+	}
+
+	var nilMonitor *Monitor
+	nilMonitor.Add(EventCommand, time.Second)
+}
+
+func TestHistoryWrap(t *testing.T) {
+	s := &series{}
+	start := time.Unix(1000, 0)
+	for i := 0; i < historyLen+5; i++ {
+		s.add(start.Add(time.Duration(i)*time.Second), time.Duration(i)*time.Millisecond)
+	}
+	h := s.history()
+	if len(h) != historyLen {
+		t.Fatalf("len = %d", len(h))
+	}
+	if h[0].Latency != 5*time.Millisecond || h[len(h)-1].Latency != time.Duration(historyLen+4)*time.Millisecond {
+		t.Errorf("history = %v ... %v", h[0], h[len(h)-1])
+	}
+	for i := 1; i < len(h); i++ {
+		if !h[i].Time.After(h[i-1].Time) {
+			t.Fatalf("history not ordered at %d", i)
+		}
+	}
+	if s.max != time.Duration(historyLen+4)*time.Millisecond {
+		t.Errorf("max = %v", s.max)
+	}
+}
+
+func TestResetAndDoctor(t *testing.T) {
+	m := New(0)
+	if d := m.Doctor(); !strings.Contains(d, "Latency monitoring is disabled") {
+		t.Errorf("doctor = %q", d)
+	}
+	m.SetThreshold(time.Millisecond)
+	if d := m.Doctor(); !strings.Contains(d, "no latency spike was observed") {
+		t.Errorf("doctor = %q", d)
+	}
+
+	m.Add(EventCommand, 5*time.Millisecond)
+	m.Add(EventRDBFsync, 8*time.Millisecond)
+	d := m.Doctor()
+	for _, want := range []string{
+		"1. command: 1 latency spikes (average 5ms, mean deviation 0ms, period 0.00 sec). Worst all time event 5ms.",
+		"2. rdb-fsync: 1 latency spikes",
+		"- rdb-fsync: The disk is slow",
+	} {
+		if !strings.Contains(d, want) {
+			t.Errorf("doctor missing %q:\n%s", want, d)
+		}
+	}
+
+	if n := m.Reset(EventCommand, "nosuch"); n != 1 {
+		t.Errorf("Reset(command) = %d", n)
+	}
+	if n := m.Reset(); n != 1 {
+		t.Errorf("Reset() = %d", n)
+	}
+	if got := m.Latest(); len(got) != 0 {
+		t.Errorf("latest after reset = %v", got)
+	}
+}
@@ -1,18 +1,29 @@
 package storage
 
 import (
+	"literedis/internal/consts"
 	"literedis/internal/datastruct/dshash"
+	"literedis/pkg/glob"
+	"math"
+	"math/rand"
+	"strconv"
 	"sync"
 )
 
 type MemoryHashStorage struct {
-	data map[string]dshash.Hash
-	mu   sync.RWMutex
+	data   map[string]dshash.Hash
+	limits *dshash.Limits // 新建哈希的 listpack 编码上限
+	mu     sync.RWMutex
 }
 
 func NewMemoryHashStorage() *MemoryHashStorage {
+	return newMemoryHashStorage(dshash.DefaultLimits())
+}
+
+func newMemoryHashStorage(limits *dshash.Limits) *MemoryHashStorage {
 	return &MemoryHashStorage{
-		data: make(map[string]dshash.Hash),
+		data:   make(map[string]dshash.Hash),
+		limits: limits,
 	}
 }
 
@@ -22,7 +33,7 @@ func (m *MemoryHashStorage) HSet(key string, fields map[string][]byte) (int, err
 
 	hash, ok := m.data[key]
 	if !ok {
-		hash = dshash.NewHash()
+		hash = dshash.NewHashWithLimits(m.limits)
 		m.data[key] = hash
 	}
 
@@ -115,29 +126,205 @@ func (m *MemoryHashStorage) HVals(key string) ([][]byte, error) {
 		return nil, nil
 	}
 
-	values := hash.HVals()
-	byteValues := make([][]byte, len(values))
-	for i, v := range values {
-		byteValues[i] = []byte(v)
+	return toBytes(hash.HVals()), nil
+}
+
+// HGetAll 返回交替排列的 field 和 value
+func (m *MemoryHashStorage) HGetAll(key string) ([][]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hash, ok := m.data[key]
+	if !ok {
+		return nil, nil
 	}
 
-	return byteValues, nil
+	return toBytes(hash.HGetAll()), nil
 }
 
-func (m *MemoryHashStorage) HGetAll(key string) (map[string][]byte, error) {
+// HMGet 返回 fields 的值，不存在的 field 为 nil
+func (m *MemoryHashStorage) HMGet(key string, fields ...string) ([][]byte, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	values := make([][]byte, len(fields))
 	hash, ok := m.data[key]
 	if !ok {
-		return nil, ErrKeyNotFound
+		return values, nil
+	}
+	for i, field := range fields {
+		if value, exists := hash.HGet(field); exists {
+			values[i] = []byte(value)
+		}
+	}
+	return values, nil
+}
+
+// HSetNX 只在 field 不存在时设置，返回是否设置成功
+func (m *MemoryHashStorage) HSetNX(key, field string, value []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hash, ok := m.data[key]
+	if !ok {
+		hash = dshash.NewHashWithLimits(m.limits)
+		m.data[key] = hash
 	}
+	if hash.HExists(field) {
+		return false, nil
+	}
+	hash.HSet(field, string(value))
+	return true, nil
+}
+
+// HIncrBy 将 field 的整数值加上 delta，field 不存在时从 0 开始
+func (m *MemoryHashStorage) HIncrBy(key, field string, delta int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
+	var current int64
+	hash, ok := m.data[key]
+	if ok {
+		if value, exists := hash.HGet(field); exists {
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || strconv.FormatInt(v, 10) != value {
+				return 0, ErrHashNotInteger
+			}
+			current = v
+		}
+	}
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, consts.ErrOverflow
+	}
+	current += delta
+	if !ok {
+		hash = dshash.NewHashWithLimits(m.limits)
+		m.data[key] = hash
+	}
+	setKeepTTL(hash, field, strconv.FormatInt(current, 10))
+	return current, nil
+}
+
+// HIncrByFloat 将 field 的浮点数值加上 delta，返回保存的新值
+func (m *MemoryHashStorage) HIncrByFloat(key, field string, delta float64) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var current float64
+	hash, ok := m.data[key]
+	if ok {
+		if value, exists := hash.HGet(field); exists {
+			v, err := ParseFloat([]byte(value))
+			if err != nil {
+				return nil, ErrHashNotFloat
+			}
+			current = v
+		}
+	}
+	current += delta
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return nil, consts.ErrNaNOrInfinity
+	}
+	value := FormatFloat(current)
+	if !ok {
+		hash = dshash.NewHashWithLimits(m.limits)
+		m.data[key] = hash
+	}
+	setKeepTTL(hash, field, string(value))
+	return value, nil
+}
+
+// HStrLen 返回 field 的值的长度，不存在时返回 0
+func (m *MemoryHashStorage) HStrLen(key, field string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hash, ok := m.data[key]
+	if !ok {
+		return 0, nil
+	}
+	value, _ := hash.HGet(field)
+	return len(value), nil
+}
+
+// maxRandFieldPrealloc 是 HRandField 按 count 预分配的元素个数上限
+const maxRandFieldPrealloc = 1024
+
+// HRandField 随机返回 field，count 为正数时不重复，最多返回全部 field；
+// 为负数时可以重复，返回 -count 个。withValues 为 true 时每个 field 后面跟着它的值
+func (m *MemoryHashStorage) HRandField(key string, count int64, withValues bool) ([][]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hash, ok := m.data[key]
+	if !ok || count == 0 {
+		return nil, nil
+	}
 	all := hash.HGetAll()
-	result := make(map[string][]byte, len(all))
-	for i := 0; i < len(all); i += 2 {
-		result[all[i]] = []byte(all[i+1])
+	n := len(all) / 2
+
+	pick := func(result [][]byte, p int) [][]byte {
+		result = append(result, []byte(all[2*p]))
+		if withValues {
+			result = append(result, []byte(all[2*p+1]))
+		}
+		return result
+	}
+	if count > 0 {
+		picks := rand.Perm(n)[:min(int(count), n)]
+		result := make([][]byte, 0, len(picks)*2)
+		for _, p := range picks {
+			result = pick(result, p)
+		}
+		return result, nil
 	}
 
+	// count 为负数时 field 可以重复，预分配的空间有上限，很大的 count 不会一次分配过多内存
+	prealloc := int64(maxRandFieldPrealloc)
+	if count > -prealloc {
+		prealloc = -count
+	}
+	result := make([][]byte, 0, prealloc*2)
+	for i := int64(0); i > count; i-- {
+		result = pick(result, rand.Intn(n))
+	}
 	return result, nil
 }
+
+// HScan 从 cursor 开始遍历哈希，返回下一个 cursor 和交替排列的 field 和 value，
+// match 不为空时只返回匹配的 field
+func (m *MemoryHashStorage) HScan(key string, cursor uint64, match string, count int) (uint64, [][]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hash, ok := m.data[key]
+	if !ok {
+		return 0, nil, nil
+	}
+	next, all := hash.Scan(cursor, count)
+	result := make([][]byte, 0, len(all))
+	for i := 0; i < len(all); i += 2 {
+		if match != "" && !glob.Match(match, all[i]) {
+			continue
+		}
+		result = append(result, []byte(all[i]), []byte(all[i+1]))
+	}
+	return next, result, nil
+}
+
+// setKeepTTL 修改 field 的值并保留它的过期时间，用于 HINCRBY 和 HINCRBYFLOAT
+func setKeepTTL(hash dshash.Hash, field, value string) {
+	at, ok := hash.FieldExpire(field)
+	hash.HSet(field, value)
+	if ok {
+		hash.SetFieldExpire(field, at)
+	}
+}
+
+func toBytes(values []string) [][]byte {
+	result := make([][]byte, len(values))
+	for i, v := range values {
+		result[i] = []byte(v)
+	}
+	return result
+}
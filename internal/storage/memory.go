@@ -2,12 +2,17 @@ package storage
 
 import (
 	"compress/gzip"
+	"errors"
 	"literedis/config"
 	"literedis/internal/cluster"
 	"literedis/internal/consts"
+	"literedis/internal/datastruct/dshash"
 	"literedis/internal/datastruct/dslist"
+	"literedis/internal/datastruct/dsstring"
+	"literedis/internal/latency"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,6 +21,8 @@ var _ Storage = (*MemoryStorage)(nil)
 
 const DefaultDBCount = 16
 
+// Database is a single numbered keyspace. Its mutex stripes locking by
+// database: every keyed operation holds the lock of the database it touches.
 type Database struct {
 	stringStorage *MemoryStringStorage
 	hashStorage   *MemoryHashStorage
@@ -24,38 +31,56 @@ type Database struct {
 	zsetStorage   *MemoryZSetStorage
 	expiry        map[string]time.Time
 	mu            sync.RWMutex
+	expiredKeys   atomic.Int64 // 因过期被删除的键数量
+	index         int
+	ks            *keyspace              // 所属的键空间，用于发布键空间通知
+	watched       map[string]*watchedKey // 被 WATCH 的键，FLUSHDB 之后仍然保留
 }
 
+// watchedKey 被 WATCH 的键的版本号，只在有连接 WATCH 时保存
+type watchedKey struct {
+	refs    int
+	version uint64
+}
+
+// keyspace holds the state shared by every view of a MemoryStorage.
+type keyspace struct {
+	databases    []*Database
+	mu           sync.RWMutex
+	cluster      *cluster.Cluster
+	RDB          *RDBStorage
+	lastSaveTime time.Time
+	dirtyKeys    map[int]map[string]struct{} // 数据库索引 -> 脏键集合
+	dirtyMu      sync.Mutex
+	latency      *latency.Monitor
+	publish      Publisher
+	notifyFlags  atomic.Int64 // NotifyClass
+	listFill     atomic.Int64 // list-max-listpack-size，新建列表的节点大小
+	listDepth    atomic.Int64 // list-compress-depth，新建列表两端不压缩的节点数
+	hashLimits   *dshash.Limits
+}
+
+// MemoryStorage is a view over the shared keyspace. Each view keeps its own
+// selected database so that SELECT only affects the connection that issued it.
 type MemoryStorage struct {
-	databases      []*Database
+	*keyspace
 	currentDBIndex int
-	mu             sync.RWMutex
-	cluster        *cluster.Cluster
-	RDB            *RDBStorage
-	lastSaveTime   time.Time
-	dirtyKeys      map[int]map[string]struct{} // 数据库索引 -> 脏键集合
-	data           map[string]interface{}
-	mutex          sync.RWMutex
 }
 
 func NewMemoryStorage(rdbConfig ...config.RDBConfig) Storage {
 	ms := &MemoryStorage{
-		databases:      make([]*Database, DefaultDBCount),
+		keyspace: &keyspace{
+			databases:    make([]*Database, DefaultDBCount),
+			lastSaveTime: time.Now(),
+			dirtyKeys:    make(map[int]map[string]struct{}),
+			hashLimits:   dshash.DefaultLimits(),
+		},
 		currentDBIndex: 0,
-		lastSaveTime:   time.Now(),
-		dirtyKeys:      make(map[int]map[string]struct{}),
-		data:           make(map[string]interface{}),
 	}
 	for i := 0; i < DefaultDBCount; i++ {
-		ms.databases[i] = &Database{
-			stringStorage: NewMemoryStringStorage(),
-			hashStorage:   NewMemoryHashStorage(),
-			listStorage:   make(map[string]*dslist.QuickList),
-			setStorage:    NewMemorySetStorage(),
-			zsetStorage:   NewMemoryZSetStorage(),
-			expiry:        make(map[string]time.Time),
-		}
+		ms.databases[i] = newDatabase(ms.keyspace, i)
 	}
+	ms.listFill.Store(dslist.DefaultFill)
 
 	var cfg config.RDBConfig
 	if len(rdbConfig) > 0 {
@@ -74,6 +99,124 @@ func NewMemoryStorage(rdbConfig ...config.RDBConfig) Storage {
 	return ms
 }
 
+func newDatabase(ks *keyspace, index int) *Database {
+	db := &Database{ks: ks, index: index, watched: make(map[string]*watchedKey)}
+	db.reset()
+	return db
+}
+
+// reset drops every key of the database. The caller must hold db.mu.
+func (db *Database) reset() {
+	db.stringStorage = NewMemoryStringStorage()
+	db.hashStorage = newMemoryHashStorage(db.ks.hashLimits)
+	db.listStorage = make(map[string]*dslist.QuickList)
+	db.setStorage = NewMemorySetStorage()
+	db.zsetStorage = NewMemoryZSetStorage()
+	db.expiry = make(map[string]time.Time)
+}
+
+// isExpired reports whether key has a deadline in the past
+func (db *Database) isExpired(key string) bool {
+	expireTime, exists := db.expiry[key]
+	if !exists {
+		return false
+	}
+	return time.Now().After(expireTime)
+}
+
+// expireIfNeeded lazily deletes key if its deadline has passed
+func (db *Database) expireIfNeeded(key string) bool {
+	if !db.isExpired(key) {
+		return false
+	}
+	db.deleteKey(key)
+	db.expiredKeys.Add(1)
+	db.touch(key)
+	db.notify(NotifyExpired, "expired", key)
+	return true
+}
+
+// touch 增加被 WATCH 的键的版本号，键的每次修改（包括过期和 FLUSHDB）之后调用
+func (db *Database) touch(key string) {
+	if w, ok := db.watched[key]; ok {
+		w.version++
+	}
+}
+
+// touchAll 在清空数据库之前调用，只修改当前存在的键的版本号
+func (db *Database) touchAll() {
+	for key, w := range db.watched {
+		if db.exists(key) {
+			w.version++
+		}
+	}
+}
+
+// expireIfNeededRead 用于读命令，键不存在时额外发布 keymiss 事件
+func (db *Database) expireIfNeededRead(key string) bool {
+	expired := db.expireIfNeeded(key)
+	db.notifyKeyMiss(key)
+	return expired
+}
+
+// deleteKey removes key whatever its type and reports whether it existed
+func (db *Database) deleteKey(key string) bool {
+	existed := db.exists(key)
+	delete(db.stringStorage.data, key)
+	delete(db.hashStorage.data, key)
+	delete(db.listStorage, key)
+	delete(db.setStorage.data, key)
+	delete(db.zsetStorage.data, key)
+	delete(db.expiry, key)
+	return existed
+}
+
+// notifyNew 键由本次写入创建时发布 new 事件
+func (db *Database) notifyNew(key string, existed bool) {
+	if !existed {
+		db.notify(NotifyNew, "new", key)
+	}
+}
+
+// checkType 键存在且不是 typ 类型时返回 ErrWrongType
+func (db *Database) checkType(key, typ string) error {
+	if t := db.typeOf(key); t != "" && t != typ {
+		return ErrWrongType
+	}
+	return nil
+}
+
+// exists reports whether key holds a value of any type
+func (db *Database) exists(key string) bool {
+	return db.typeOf(key) != ""
+}
+
+// typeOf returns the type name of key, or "" when it does not exist
+func (db *Database) typeOf(key string) string {
+	if _, ok := db.stringStorage.data[key]; ok {
+		return "string"
+	}
+	if _, ok := db.hashStorage.data[key]; ok {
+		return "hash"
+	}
+	if _, ok := db.listStorage[key]; ok {
+		return "list"
+	}
+	if _, ok := db.setStorage.data[key]; ok {
+		return "set"
+	}
+	if _, ok := db.zsetStorage.data[key]; ok {
+		return "zset"
+	}
+	return ""
+}
+
+// NewView returns a Storage that shares all data with m but keeps its own
+// selected database, starting at database 0.
+func (m *MemoryStorage) NewView() Storage {
+	return &MemoryStorage{keyspace: m.keyspace}
+}
+
 func (m *MemoryStorage) GetCluster() *cluster.Cluster {
 	return m.cluster
 }
@@ -82,270 +225,1069 @@ func (m *MemoryStorage) SetCluster(c *cluster.Cluster) {
 	m.cluster = c
 }
 
+// SetLatencyMonitor 设置记录过期清理、RDB 保存等事件延迟的监控器
+func (m *MemoryStorage) SetLatencyMonitor(l *latency.Monitor) {
+	m.latency = l
+}
+
 func (m *MemoryStorage) getCurrentDB() *Database {
 	return m.databases[m.currentDBIndex]
 }
 
+// lockDB locks the selected database and returns it
+func (m *MemoryStorage) lockDB() *Database {
+	db := m.getCurrentDB()
+	db.mu.Lock()
+	return db
+}
+
 func (m *MemoryStorage) Select(index int) error {
 	if index < 0 || index >= len(m.databases) {
 		return ErrInvalidDBIndex
 	}
-	m.mu.Lock()
-	defer m.mu.Unlock()
 	m.currentDBIndex = index
 	return nil
 }
 
+// SelectedDB returns the index of the database this view operates on.
+func (m *MemoryStorage) SelectedDB() int {
+	return m.currentDBIndex
+}
+
+// signalModified 在修改键之后调用：标记脏键、累计变更次数并发布键空间通知
+func (m *MemoryStorage) signalModified(db *Database, class NotifyClass, event, key string) {
+	m.markDirty(db.index, key)
+	m.IncrementRDBChanges()
+	db.touch(key)
+	db.notify(class, event, key)
+}
+
+// notifyIfEmptied 集合类型删除元素之后变为空键时，键已被删除，发布 del 事件
+func (m *MemoryStorage) notifyIfEmptied(db *Database, key string) {
+	if !db.exists(key) {
+		db.notify(NotifyGeneric, "del", key)
+	}
+}
+
 // ########################## String operations ##########################
 
 func (m *MemoryStorage) Set(key string, value []byte) error {
-	db := m.getCurrentDB()
-	db.mu.Lock()
+	db := m.lockDB()
 	defer db.mu.Unlock()
 
-	err := db.stringStorage.Set(key, value)
-	if err != nil {
-		return err
-	}
+	m.setString(db, key, value)
+	return nil
+}
 
-	// 标记键为脏
-	m.markDirty(m.currentDBIndex, key)
-	m.IncrementRDBChanges()
+// SetWithOptions 在一次加锁中完成条件检查、设置和过期时间，返回旧值（只在 opts.Get 时读取）
+// 和是否设置了新值
+func (m *MemoryStorage) SetWithOptions(key string, value []byte, opts SetOptions) ([]byte, bool, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	db.expireIfNeeded(key)
 
-	// 如果键已经存在过期时间，我们应该删除它
-	delete(db.expiry, key)
+	var old []byte
+	if opts.Get {
+		if err := db.checkType(key, "string"); err != nil {
+			return nil, false, err
+		}
+		if sds, ok := db.stringStorage.data[key]; ok {
+			old = sds.Bytes()
+		}
+	}
+	exists := db.exists(key)
+	if (opts.NX && exists) || (opts.XX && !exists) {
+		return old, false, nil
+	}
 
-	return nil
+	ttl, hasTTL := db.expiry[key]
+	m.setString(db, key, value)
+	switch {
+	case !opts.ExpireAt.IsZero():
+		db.expiry[key] = opts.ExpireAt
+		m.signalModified(db, NotifyGeneric, "expire", key)
+	case opts.KeepTTL && hasTTL:
+		db.expiry[key] = ttl
+	}
+	return old, true, nil
 }
 
 func (m *MemoryStorage) Get(key string) ([]byte, error) {
-	db := m.getCurrentDB()
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	db := m.lockDB()
+	defer db.mu.Unlock()
 
-	if m.isExpired(key) {
-		m.deleteKey(key)
+	if db.expireIfNeededRead(key) {
 		return nil, ErrKeyNotFound
 	}
 
 	return db.stringStorage.Get(key)
 }
 
-func (m *MemoryStorage) isExpired(key string) bool {
-	db := m.getCurrentDB()
-	expireTime, exists := db.expiry[key]
-	if !exists {
-		return false
-	}
-	return time.Now().After(expireTime)
-}
-
-func (m *MemoryStorage) deleteKey(key string) {
-	db := m.getCurrentDB()
-	delete(db.stringStorage.data, key)
-	delete(db.hashStorage.data, key)
-	delete(db.listStorage, key)
+// GetString 在一次加锁中检查类型并读取字符串，键不存在时返回 ErrKeyNotFound，
+// 空字符串返回非 nil 的空切片
+func (m *MemoryStorage) GetString(key string) ([]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
 
-	if members, err := db.setStorage.SMembers(key); err == nil {
-		_, _ = db.setStorage.SRem(key, members...)
+	if db.expireIfNeededRead(key) {
+		return nil, ErrKeyNotFound
 	}
-	if db.zsetStorage != nil {
-		_, _ = db.zsetStorage.ZRem(key, "")
+	if err := db.checkType(key, "string"); err != nil {
+		return nil, err
 	}
+	value, err := db.stringStorage.Get(key)
+	if err == nil && value == nil {
+		value = []byte{}
+	}
+	return value, err
+}
 
-	delete(db.expiry, key)
+func (m *MemoryStorage) isExpired(key string) bool {
+	return m.getCurrentDB().isExpired(key)
+}
+
+func (m *MemoryStorage) deleteKey(key string) {
+	m.getCurrentDB().deleteKey(key)
 }
 
 func (m *MemoryStorage) Append(key string, value []byte) (int, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	db := m.getCurrentDB()
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	db.expireIfNeeded(key)
+	existed := db.exists(key)
 	length, err := db.stringStorage.Append(key, value)
 	if err == nil {
-		m.markDirty(m.currentDBIndex, key)
-		m.IncrementRDBChanges()
+		db.notifyNew(key, existed)
+		m.signalModified(db, NotifyString, "append", key)
 	}
 	return length, err
 }
 
 func (m *MemoryStorage) GetRange(key string, start, end int) ([]byte, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	db := m.getCurrentDB()
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeededRead(key) {
+		return nil, consts.ErrKeyNotFound
+	}
 	return db.stringStorage.GetRange(key, start, end)
 }
 
 func (m *MemoryStorage) SetRange(key string, offset int, value []byte) (int, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	db := m.getCurrentDB()
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	db.expireIfNeeded(key)
+	existed := db.exists(key)
 	length, err := db.stringStorage.SetRange(key, offset, value)
 	if err == nil {
-		m.markDirty(m.currentDBIndex, key)
-		m.IncrementRDBChanges()
+		db.notifyNew(key, existed)
+		m.signalModified(db, NotifyString, "setrange", key)
 	}
 	return length, err
 }
 
 func (m *MemoryStorage) StrLen(key string) (int, error) {
-	//TODO implement me
-	panic("implement me")
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeededRead(key) {
+		return 0, nil
+	}
+	if err := db.checkType(key, "string"); err != nil {
+		return 0, err
+	}
+	length, err := db.stringStorage.StrLen(key)
+	if errors.Is(err, consts.ErrKeyNotFound) {
+		return 0, nil
+	}
+	return length, err
+}
+
+// IncrBy 供 INCR、DECR、INCRBY、DECRBY 使用
+func (m *MemoryStorage) IncrBy(key string, delta int64) (int64, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	db.expireIfNeeded(key)
+	if err := db.checkType(key, "string"); err != nil {
+		return 0, err
+	}
+	existed := db.exists(key)
+	value, err := db.stringStorage.IncrBy(key, delta)
+	if err != nil {
+		return 0, err
+	}
+	db.notifyNew(key, existed)
+	m.signalModified(db, NotifyString, "incrby", key)
+	return value, nil
+}
+
+func (m *MemoryStorage) IncrByFloat(key string, delta float64) ([]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	db.expireIfNeeded(key)
+	if err := db.checkType(key, "string"); err != nil {
+		return nil, err
+	}
+	existed := db.exists(key)
+	value, err := db.stringStorage.IncrByFloat(key, delta)
+	if err != nil {
+		return nil, err
+	}
+	db.notifyNew(key, existed)
+	m.signalModified(db, NotifyString, "incrbyfloat", key)
+	return value, nil
+}
+
+// MSet 依次设置 keys[i] 为 values[i]，与 SET 一样会清除过期时间
+func (m *MemoryStorage) MSet(keys []string, values [][]byte) error {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	for i, key := range keys {
+		m.setString(db, key, values[i])
+	}
+	return nil
+}
+
+// MSetNX 所有键都不存在时才设置，返回是否设置
+func (m *MemoryStorage) MSetNX(keys []string, values [][]byte) (bool, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	for _, key := range keys {
+		if !db.expireIfNeeded(key) && db.exists(key) {
+			return false, nil
+		}
+	}
+	for i, key := range keys {
+		m.setString(db, key, values[i])
+	}
+	return true, nil
+}
+
+// MGet 返回每个键的值，不存在或者不是字符串的键为 nil
+func (m *MemoryStorage) MGet(keys ...string) ([][]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		if db.expireIfNeededRead(key) {
+			continue
+		}
+		if sds, ok := db.stringStorage.data[key]; ok {
+			values[i] = sds.Bytes()
+		}
+	}
+	return values, nil
+}
+
+// SetNX 键不存在时才设置，返回是否设置
+func (m *MemoryStorage) SetNX(key string, value []byte) (bool, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if !db.expireIfNeeded(key) && db.exists(key) {
+		return false, nil
+	}
+	m.setString(db, key, value)
+	return true, nil
+}
+
+// SetEX 设置值和过期时间，供 SETEX、PSETEX 使用
+func (m *MemoryStorage) SetEX(key string, value []byte, expiration time.Duration) error {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	m.setString(db, key, value)
+	db.expiry[key] = time.Now().Add(expiration)
+	m.signalModified(db, NotifyGeneric, "expire", key)
+	return nil
+}
+
+// GetSet 设置新值并返回旧值，键不存在时返回 ErrKeyNotFound
+func (m *MemoryStorage) GetSet(key string, value []byte) ([]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	db.expireIfNeeded(key)
+	if err := db.checkType(key, "string"); err != nil {
+		return nil, err
+	}
+	old, err := db.stringStorage.Get(key)
+	m.setString(db, key, value)
+	return old, err
+}
+
+// GetDel 返回值并删除键
+func (m *MemoryStorage) GetDel(key string) ([]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeededRead(key) {
+		return nil, ErrKeyNotFound
+	}
+	if err := db.checkType(key, "string"); err != nil {
+		return nil, err
+	}
+	value, err := db.stringStorage.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	db.deleteKey(key)
+	m.signalModified(db, NotifyGeneric, "del", key)
+	return value, nil
+}
+
+// GetEx 返回值并修改过期时间：expireAt 非零时设置过期时间，已经过去的时间删除键；
+// persist 为 true 时移除过期时间
+func (m *MemoryStorage) GetEx(key string, expireAt time.Time, persist bool) ([]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeededRead(key) {
+		return nil, ErrKeyNotFound
+	}
+	if err := db.checkType(key, "string"); err != nil {
+		return nil, err
+	}
+	value, err := db.stringStorage.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !expireAt.IsZero() && !expireAt.After(time.Now()):
+		db.deleteKey(key)
+		m.signalModified(db, NotifyGeneric, "del", key)
+	case !expireAt.IsZero():
+		db.expiry[key] = expireAt
+		m.signalModified(db, NotifyGeneric, "expire", key)
+	case persist:
+		if _, ok := db.expiry[key]; ok {
+			delete(db.expiry, key)
+			m.signalModified(db, NotifyGeneric, "persist", key)
+		}
+	}
+	return value, nil
+}
+
+// ########################## Bitmap operations ##########################
+
+// SetBit 设置 offset 位并返回原来的值，字符串按需用 0 字节扩展
+func (m *MemoryStorage) SetBit(key string, offset uint64, bit int) (int, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	sds, existed, err := db.writableString(key)
+	if err != nil {
+		return 0, err
+	}
+	old := sds.SetBit(offset, bit)
+	db.notifyNew(key, existed)
+	m.signalModified(db, NotifyString, "setbit", key)
+	return old, nil
+}
+
+func (m *MemoryStorage) GetBit(key string, offset uint64) (int, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeededRead(key) {
+		return 0, nil
+	}
+	if err := db.checkType(key, "string"); err != nil {
+		return 0, err
+	}
+	sds, ok := db.stringStorage.data[key]
+	if !ok {
+		return 0, nil
+	}
+	return sds.GetBit(offset), nil
+}
+
+// BitOp 对 keys 的值按位运算并保存到 dest，返回结果的长度。结果为空时删除 dest
+func (m *MemoryStorage) BitOp(op dsstring.BitOp, dest string, keys ...string) (int64, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	srcs := make([][]byte, len(keys))
+	for i, key := range keys {
+		if db.expireIfNeeded(key) {
+			continue
+		}
+		if err := db.checkType(key, "string"); err != nil {
+			return 0, err
+		}
+		if sds, ok := db.stringStorage.data[key]; ok {
+			srcs[i] = sds.Get()
+		}
+	}
+	res := op.Apply(srcs)
+
+	db.expireIfNeeded(dest)
+	if len(res) == 0 {
+		if db.deleteKey(dest) {
+			m.signalModified(db, NotifyGeneric, "del", dest)
+		}
+		return 0, nil
+	}
+	existed := db.deleteKey(dest)
+	db.stringStorage.data[dest] = dsstring.NewSDS(string(res))
+	db.notifyNew(dest, existed)
+	m.signalModified(db, NotifyString, "set", dest)
+	return int64(len(res)), nil
+}
+
+// BitField 执行 BITFIELD 的操作。只有 GET 时不会创建键
+func (m *MemoryStorage) BitField(key string, ops []dsstring.BitFieldOp) ([]dsstring.BitFieldResult, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	write := false
+	for _, op := range ops {
+		write = write || op.Opcode != dsstring.BitFieldGet
+	}
+	if !write {
+		if db.expireIfNeededRead(key) {
+			return dsstring.NewSDS("").BitField(ops), nil
+		}
+		if err := db.checkType(key, "string"); err != nil {
+			return nil, err
+		}
+		sds, ok := db.stringStorage.data[key]
+		if !ok {
+			sds = dsstring.NewSDS("")
+		}
+		return sds.BitField(ops), nil
+	}
+
+	sds, existed, err := db.writableString(key)
+	if err != nil {
+		return nil, err
+	}
+	results := sds.BitField(ops)
+	db.notifyNew(key, existed)
+	m.signalModified(db, NotifyString, "setbit", key)
+	return results, nil
+}
+
+// writableString 返回 key 上可以原地修改的字符串，键不存在时创建空字符串，
+// 共享的整数先复制一份
+func (db *Database) writableString(key string) (*dsstring.SDS, bool, error) {
+	db.expireIfNeeded(key)
+	if err := db.checkType(key, "string"); err != nil {
+		return nil, false, err
+	}
+	sds, existed := db.stringStorage.data[key]
+	if !existed {
+		sds = dsstring.NewSDS("")
+	}
+	sds = sds.Unshare()
+	db.stringStorage.data[key] = sds
+	return sds, existed, nil
+}
+
+// setString 覆盖键为字符串值，清除原有的值和过期时间
+func (m *MemoryStorage) setString(db *Database, key string, value []byte) {
+	existed := db.deleteKey(key)
+	db.stringStorage.Set(key, value)
+	db.notifyNew(key, existed)
+	m.signalModified(db, NotifyString, "set", key)
 }
 
 // ########################## Hash operations ##########################
 
 func (m *MemoryStorage) HSet(key string, fields map[string][]byte) (int, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	db := m.getCurrentDB()
-	if m.isExpired(key) {
-		m.deleteKey(key)
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	db.expireIfNeeded(key)
+	for field := range fields {
+		m.reapFields(db, key, field)
 	}
+	existed := db.exists(key)
 	count, err := db.hashStorage.HSet(key, fields)
 	if err == nil {
-		m.markDirty(m.currentDBIndex, key)
-		m.IncrementRDBChanges()
+		db.notifyNew(key, existed)
+		m.signalModified(db, NotifyHash, "hset", key)
 	}
 	return count, err
 }
 
 func (m *MemoryStorage) HGet(key, field string) ([]byte, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	db := m.getCurrentDB()
-	if m.isExpired(key) {
-		m.deleteKey(key)
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeededRead(key) {
 		return nil, ErrKeyNotFound
 	}
+	m.reapFields(db, key, field)
 	return db.hashStorage.HGet(key, field)
 }
 
 func (m *MemoryStorage) HDel(key string, fields ...string) (int, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	db := m.getCurrentDB()
-	if m.isExpired(key) {
-		m.deleteKey(key)
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeeded(key) {
 		return 0, nil
 	}
+	m.reapFields(db, key, fields...)
 	count, err := db.hashStorage.HDel(key, fields...)
-	if err == nil {
-		m.markDirty(m.currentDBIndex, key)
-		m.IncrementRDBChanges()
+	if err == nil && count > 0 {
+		m.signalModified(db, NotifyHash, "hdel", key)
+		m.notifyIfEmptied(db, key)
 	}
 	return count, err
 }
 
 func (m *MemoryStorage) HLen(key string) (int, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	db := m.getCurrentDB()
-	if m.isExpired(key) {
-		m.deleteKey(key)
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeededRead(key) {
 		return 0, nil
 	}
+	m.reapFields(db, key)
 	return db.hashStorage.HLen(key)
 }
 
+// readHash 读操作之前处理键和 field 的过期以及类型检查，键不存在时返回 false。
+// 没有给出 fields 时检查所有 field 是否过期
+func (m *MemoryStorage) readHash(db *Database, key string, fields ...string) (bool, error) {
+	if db.expireIfNeededRead(key) {
+		return false, nil
+	}
+	if err := db.checkType(key, "hash"); err != nil {
+		return false, err
+	}
+	m.reapFields(db, key, fields...)
+	_, ok := db.hashStorage.data[key]
+	return ok, nil
+}
+
+// writeHash 写操作之前处理键和 fields 的过期以及类型检查，返回键是否存在
+func (m *MemoryStorage) writeHash(db *Database, key string, fields ...string) (bool, error) {
+	db.expireIfNeeded(key)
+	if err := db.checkType(key, "hash"); err != nil {
+		return false, err
+	}
+	m.reapFields(db, key, fields...)
+	return db.exists(key), nil
+}
+
+// reapFields 删除已经过期的 field，没有给出 fields 时检查所有 field，
+// 哈希因此变为空时删除整个键
+func (m *MemoryStorage) reapFields(db *Database, key string, fields ...string) {
+	hash, ok := db.hashStorage.data[key]
+	if !ok {
+		return
+	}
+	if expired := hash.DeleteExpired(time.Now(), fields...); len(expired) > 0 {
+		m.fieldsExpired(db, key)
+	}
+}
+
+// fieldsExpired 在过期的 field 被删除之后调用，哈希变为空时删除整个键
+func (m *MemoryStorage) fieldsExpired(db *Database, key string) {
+	if db.hashStorage.data[key].HLen() == 0 {
+		db.deleteKey(key)
+	}
+	m.signalModified(db, NotifyHash, "hexpired", key)
+	m.notifyIfEmptied(db, key)
+}
+
+func (m *MemoryStorage) HExists(key, field string) (bool, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if ok, err := m.readHash(db, key, field); !ok {
+		return false, err
+	}
+	return db.hashStorage.HExists(key, field)
+}
+
+func (m *MemoryStorage) HKeys(key string) ([]string, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if ok, err := m.readHash(db, key); !ok {
+		return nil, err
+	}
+	return db.hashStorage.HKeys(key)
+}
+
+func (m *MemoryStorage) HVals(key string) ([][]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if ok, err := m.readHash(db, key); !ok {
+		return nil, err
+	}
+	return db.hashStorage.HVals(key)
+}
+
+// HGetAll 返回交替排列的 field 和 value，键不存在时返回空
+func (m *MemoryStorage) HGetAll(key string) ([][]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if ok, err := m.readHash(db, key); !ok {
+		return nil, err
+	}
+	return db.hashStorage.HGetAll(key)
+}
+
+func (m *MemoryStorage) HMGet(key string, fields ...string) ([][]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if _, err := m.readHash(db, key, fields...); err != nil {
+		return nil, err
+	}
+	return db.hashStorage.HMGet(key, fields...)
+}
+
+func (m *MemoryStorage) HSetNX(key, field string, value []byte) (bool, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	existed, err := m.writeHash(db, key, field)
+	if err != nil {
+		return false, err
+	}
+	ok, err := db.hashStorage.HSetNX(key, field, value)
+	if err == nil && ok {
+		db.notifyNew(key, existed)
+		m.signalModified(db, NotifyHash, "hset", key)
+	}
+	return ok, err
+}
+
+func (m *MemoryStorage) HIncrBy(key, field string, delta int64) (int64, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	existed, err := m.writeHash(db, key, field)
+	if err != nil {
+		return 0, err
+	}
+	value, err := db.hashStorage.HIncrBy(key, field, delta)
+	if err != nil {
+		return 0, err
+	}
+	db.notifyNew(key, existed)
+	m.signalModified(db, NotifyHash, "hincrby", key)
+	return value, nil
+}
+
+func (m *MemoryStorage) HIncrByFloat(key, field string, delta float64) ([]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	existed, err := m.writeHash(db, key, field)
+	if err != nil {
+		return nil, err
+	}
+	value, err := db.hashStorage.HIncrByFloat(key, field, delta)
+	if err != nil {
+		return nil, err
+	}
+	db.notifyNew(key, existed)
+	m.signalModified(db, NotifyHash, "hincrbyfloat", key)
+	return value, nil
+}
+
+func (m *MemoryStorage) HStrLen(key, field string) (int, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if ok, err := m.readHash(db, key, field); !ok {
+		return 0, err
+	}
+	return db.hashStorage.HStrLen(key, field)
+}
+
+func (m *MemoryStorage) HRandField(key string, count int64, withValues bool) ([][]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if ok, err := m.readHash(db, key); !ok {
+		return nil, err
+	}
+	return db.hashStorage.HRandField(key, count, withValues)
+}
+
+func (m *MemoryStorage) HScan(key string, cursor uint64, match string, count int) (uint64, [][]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if ok, err := m.readHash(db, key); !ok {
+		return 0, nil, err
+	}
+	return db.hashStorage.HScan(key, cursor, match, count)
+}
+
+// HExpire 设置 fields 的过期时间，返回每个 field 的结果，见 FieldNotFound 等常量。
+// at 不晚于当前时间时直接删除 field
+func (m *MemoryStorage) HExpire(key string, at time.Time, cond ExpireCondition, fields ...string) ([]int64, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if _, err := m.writeHash(db, key, fields...); err != nil {
+		return nil, err
+	}
+	results := make([]int64, len(fields))
+	hash, ok := db.hashStorage.data[key]
+	if !ok {
+		for i := range results {
+			results[i] = FieldNotFound
+		}
+		return results, nil
+	}
+
+	now := time.Now()
+	var updated, deleted bool
+	for i, field := range fields {
+		if !hash.HExists(field) {
+			results[i] = FieldNotFound
+			continue
+		}
+		current, hasTTL := hash.FieldExpire(field)
+		skip := false
+		switch cond {
+		case ExpireNX:
+			skip = hasTTL
+		case ExpireXX:
+			skip = !hasTTL
+		case ExpireGT:
+			skip = !hasTTL || !at.After(current)
+		case ExpireLT:
+			skip = hasTTL && !at.Before(current)
+		}
+		switch {
+		case skip:
+			results[i] = FieldSkipped
+		case !at.After(now):
+			hash.HDel(field)
+			results[i] = FieldDeleted
+			deleted = true
+		default:
+			hash.SetFieldExpire(field, at)
+			results[i] = FieldUpdated
+			updated = true
+		}
+	}
+	if updated {
+		m.signalModified(db, NotifyHash, "hexpire", key)
+	}
+	if deleted {
+		m.fieldsExpired(db, key)
+	}
+	return results, nil
+}
+
+// HPExpireTime 返回 fields 过期时间的 Unix 毫秒数，field 不存在或没有过期时间时
+// 分别为 FieldNotFound 和 FieldNoTTL
+func (m *MemoryStorage) HPExpireTime(key string, fields ...string) ([]int64, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if _, err := m.readHash(db, key, fields...); err != nil {
+		return nil, err
+	}
+	hash := db.hashStorage.data[key]
+	results := make([]int64, len(fields))
+	for i, field := range fields {
+		if hash == nil || !hash.HExists(field) {
+			results[i] = FieldNotFound
+		} else if at, ok := hash.FieldExpire(field); ok {
+			results[i] = at.UnixMilli()
+		} else {
+			results[i] = FieldNoTTL
+		}
+	}
+	return results, nil
+}
+
+// HPersist 清除 fields 的过期时间
+func (m *MemoryStorage) HPersist(key string, fields ...string) ([]int64, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if _, err := m.writeHash(db, key, fields...); err != nil {
+		return nil, err
+	}
+	hash := db.hashStorage.data[key]
+	results := make([]int64, len(fields))
+	persisted := false
+	for i, field := range fields {
+		switch {
+		case hash == nil || !hash.HExists(field):
+			results[i] = FieldNotFound
+		case hash.PersistField(field):
+			results[i] = FieldUpdated
+			persisted = true
+		default:
+			results[i] = FieldNoTTL
+		}
+	}
+	if persisted {
+		m.signalModified(db, NotifyHash, "hpersist", key)
+	}
+	return results, nil
+}
+
+// HGetEx 返回 fields 的值，并设置（at 非零）或清除（persist）已有 field 的过期时间
+func (m *MemoryStorage) HGetEx(key string, at time.Time, persist bool, fields ...string) ([][]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if _, err := m.writeHash(db, key, fields...); err != nil {
+		return nil, err
+	}
+	values := make([][]byte, len(fields))
+	hash, ok := db.hashStorage.data[key]
+	if !ok {
+		return values, nil
+	}
+
+	var found []string
+	for i, field := range fields {
+		if value, exists := hash.HGet(field); exists {
+			values[i] = []byte(value)
+			found = append(found, field)
+		}
+	}
+	if len(found) == 0 {
+		return values, nil
+	}
+	switch {
+	case persist:
+		persisted := false
+		for _, field := range found {
+			persisted = hash.PersistField(field) || persisted
+		}
+		if persisted {
+			m.signalModified(db, NotifyHash, "hpersist", key)
+		}
+	case at.IsZero():
+	case !at.After(time.Now()):
+		hash.HDel(found...)
+		m.fieldsExpired(db, key)
+	default:
+		for _, field := range found {
+			hash.SetFieldExpire(field, at)
+		}
+		m.signalModified(db, NotifyHash, "hexpire", key)
+	}
+	return values, nil
+}
+
+// HSetEx 设置多个 field 的值和过期时间，FNX、FXX 条件不满足时不做修改并返回 false
+func (m *MemoryStorage) HSetEx(key string, fields []string, values [][]byte, opts HSetExOptions) (bool, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	existed, err := m.writeHash(db, key, fields...)
+	if err != nil {
+		return false, err
+	}
+	hash, ok := db.hashStorage.data[key]
+	// FNX 时任一 field 已存在、FXX 时任一 field 不存在都不满足条件
+	if opts.FNX || opts.FXX {
+		for _, field := range fields {
+			if exists := ok && hash.HExists(field); exists == opts.FNX {
+				return false, nil
+			}
+		}
+	}
+	if !ok {
+		hash = dshash.NewHashWithLimits(db.hashStorage.limits)
+		db.hashStorage.data[key] = hash
+	}
+
+	for i, field := range fields {
+		if opts.KeepTTL {
+			setKeepTTL(hash, field, string(values[i]))
+		} else {
+			hash.HSet(field, string(values[i]))
+		}
+	}
+	db.notifyNew(key, existed)
+	m.signalModified(db, NotifyHash, "hset", key)
+
+	switch {
+	case opts.ExpireAt.IsZero():
+	case !opts.ExpireAt.After(time.Now()):
+		hash.HDel(fields...)
+		m.fieldsExpired(db, key)
+	default:
+		for _, field := range fields {
+			hash.SetFieldExpire(field, opts.ExpireAt)
+		}
+		m.signalModified(db, NotifyHash, "hexpire", key)
+	}
+	return true, nil
+}
+
+// SetHashConfig 设置哈希使用 listpack 编码的上限，已有的哈希在下次写入时按新的上限转换
+func (m *MemoryStorage) SetHashConfig(maxEntries, maxValue int) {
+	m.hashLimits.MaxEntries.Store(int64(maxEntries))
+	m.hashLimits.MaxValue.Store(int64(maxValue))
+}
+
+func (m *MemoryStorage) HashConfig() (maxEntries, maxValue int) {
+	return int(m.hashLimits.MaxEntries.Load()), int(m.hashLimits.MaxValue.Load())
+}
+
 // ########################## List operations ##########################
 
-func (m *MemoryStorage) LPush(key string, values ...[]byte) (int, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// SetListConfig 设置新建列表的节点大小和压缩深度，已有的列表保持创建时的设置
+func (m *MemoryStorage) SetListConfig(fill, compressDepth int) {
+	m.listFill.Store(int64(fill))
+	m.listDepth.Store(int64(compressDepth))
+}
+
+func (m *MemoryStorage) ListConfig() (fill, compressDepth int) {
+	return int(m.listFill.Load()), int(m.listDepth.Load())
+}
+
+func (m *MemoryStorage) newList() *dslist.QuickList {
+	return dslist.NewWithConfig(m.ListConfig())
+}
+
+// getList returns the live list stored at key, dropping it if it has expired.
+// It returns ErrWrongType when key holds a value of another type
+func (m *MemoryStorage) getList(key string) (*dslist.QuickList, bool, error) {
 	db := m.getCurrentDB()
+	if db.expireIfNeeded(key) {
+		return nil, false, nil
+	}
+	if err := db.checkType(key, "list"); err != nil {
+		return nil, false, err
+	}
 	list, ok := db.listStorage[key]
 	if !ok {
-		list = dslist.New()
-		db.listStorage[key] = list
-	} else if list.IsExpired() {
-		list = dslist.New()
+		return nil, false, nil
+	}
+	if list.IsExpired() {
+		db.deleteKey(key)
+		db.expiredKeys.Add(1)
+		db.touch(key)
+		db.notify(NotifyExpired, "expired", key)
+		return nil, false, nil
+	}
+	return list, true, nil
+}
+
+func (m *MemoryStorage) LPush(key string, values ...[]byte) (int64, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	list, ok, err := m.getList(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		list = m.newList()
 		db.listStorage[key] = list
-		delete(db.expiry, key)
 	}
 	length := list.LPush(values...)
-	m.markDirty(m.currentDBIndex, key)
-	m.IncrementRDBChanges()
-	return int(length), nil
+	db.notifyNew(key, ok)
+	m.signalModified(db, NotifyList, "lpush", key)
+	return length, nil
 }
 
-func (m *MemoryStorage) RPush(key string, values ...[]byte) (int, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	db := m.getCurrentDB()
-	list, ok := db.listStorage[key]
+func (m *MemoryStorage) RPush(key string, values ...[]byte) (int64, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	list, ok, err := m.getList(key)
+	if err != nil {
+		return 0, err
+	}
 	if !ok {
-		list = dslist.New()
-		db.listStorage[key] = list
-	} else if list.IsExpired() {
-		list = dslist.New()
+		list = m.newList()
 		db.listStorage[key] = list
 	}
 	length := list.RPush(values...)
-	m.markDirty(m.currentDBIndex, key)
-	m.IncrementRDBChanges()
-	return int(length), nil
+	db.notifyNew(key, ok)
+	m.signalModified(db, NotifyList, "rpush", key)
+	return length, nil
 }
 
 func (m *MemoryStorage) LPop(key string) ([]byte, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	db := m.getCurrentDB()
-	list, ok := db.listStorage[key]
-	if !ok {
-		return nil, consts.ErrKeyNotFound
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	list, ok, err := m.getList(key)
+	if err != nil {
+		return nil, err
 	}
-	if list.IsExpired() {
-		delete(db.listStorage, key)
+	if !ok {
 		return nil, consts.ErrKeyNotFound
 	}
 	value, ok := list.LPop()
 	if !ok {
-		delete(db.listStorage, key)
+		db.deleteKey(key)
 		return nil, consts.ErrKeyNotFound
 	}
 	if list.Len() == 0 {
-		delete(db.listStorage, key)
+		db.deleteKey(key)
 	}
-	m.markDirty(m.currentDBIndex, key)
-	m.IncrementRDBChanges()
+	m.signalModified(db, NotifyList, "lpop", key)
+	m.notifyIfEmptied(db, key)
 	return value, nil
 }
 
 func (m *MemoryStorage) RPop(key string) ([]byte, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	db := m.getCurrentDB()
-	list, ok := db.listStorage[key]
-	if !ok {
-		return nil, consts.ErrKeyNotFound
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	list, ok, err := m.getList(key)
+	if err != nil {
+		return nil, err
 	}
-	if list.IsExpired() {
-		delete(db.listStorage, key)
+	if !ok {
 		return nil, consts.ErrKeyNotFound
 	}
 	value, ok := list.RPop()
 	if !ok {
-		delete(db.listStorage, key)
+		db.deleteKey(key)
 		return nil, consts.ErrKeyNotFound
 	}
 	if list.Len() == 0 {
-		delete(db.listStorage, key)
+		db.deleteKey(key)
 	}
-	m.markDirty(m.currentDBIndex, key)
-	m.IncrementRDBChanges()
+	m.signalModified(db, NotifyList, "rpop", key)
+	m.notifyIfEmptied(db, key)
 	return value, nil
 }
 
-func (m *MemoryStorage) LRange(key string, start, stop int) ([][]byte, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	db := m.getCurrentDB()
-	list, ok := db.listStorage[key]
+// LMove 原子地从 src 的一端弹出元素并插入 dst 的一端，src 不存在时返回 ErrKeyNotFound。
+// src 或 dst 不是列表时返回 ErrWrongType，两个键都不会被修改
+func (m *MemoryStorage) LMove(src, dst string, srcLeft, dstLeft bool) ([]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	list, ok, err := m.getList(src)
+	if err != nil {
+		return nil, err
+	}
 	if !ok {
 		return nil, consts.ErrKeyNotFound
 	}
-	if list.IsExpired() {
-		delete(db.listStorage, key)
+	target, existed, err := m.getList(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	var value []byte
+	event := "rpop"
+	if srcLeft {
+		value, ok = list.LPop()
+		event = "lpop"
+	} else {
+		value, ok = list.RPop()
+	}
+	if !ok {
+		db.deleteKey(src)
+		return nil, consts.ErrKeyNotFound
+	}
+	// src 和 dst 相同时是原地旋转，列表不会变空
+	if list.Len() == 0 && src != dst {
+		db.deleteKey(src)
+	}
+	m.signalModified(db, NotifyList, event, src)
+	m.notifyIfEmptied(db, src)
+
+	if !existed {
+		target = m.newList()
+		db.listStorage[dst] = target
+	}
+	event = "rpush"
+	if dstLeft {
+		target.LPush(value)
+		event = "lpush"
+	} else {
+		target.RPush(value)
+	}
+	db.notifyNew(dst, existed)
+	m.signalModified(db, NotifyList, event, dst)
+	return value, nil
+}
+
+func (m *MemoryStorage) LRange(key string, start, stop int) ([][]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	list, ok, err := m.getList(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		db.notifyKeyMiss(key)
 		return nil, consts.ErrKeyNotFound
 	}
 	return list.LRange(int64(start), int64(stop)), nil
@@ -353,31 +1295,28 @@ func (m *MemoryStorage) LRange(key string, start, stop int) ([][]byte, error) {
 
 // LLen returns the length of the list stored at key
 func (m *MemoryStorage) LLen(key string) (int, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	db := m.getCurrentDB()
-	list, ok := db.listStorage[key]
-	if !ok {
-		return 0, nil
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	list, ok, err := m.getList(key)
+	if err != nil {
+		return 0, err
 	}
-	if m.isExpired(key) {
-		delete(db.listStorage, key)
-		delete(db.expiry, key)
+	if !ok {
+		db.notifyKeyMiss(key)
 		return 0, nil
 	}
 	return int(list.Len()), nil
 }
 
 func (m *MemoryStorage) LIndex(key string, index int64) ([]byte, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	db := m.getCurrentDB()
-	list, ok := db.listStorage[key]
-	if !ok {
-		return nil, consts.ErrKeyNotFound
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	list, ok, err := m.getList(key)
+	if err != nil {
+		return nil, err
 	}
-	if list.IsExpired() {
-		delete(db.listStorage, key)
+	if !ok {
+		db.notifyKeyMiss(key)
 		return nil, consts.ErrKeyNotFound
 	}
 	value, ok := list.LIndex(index)
@@ -388,75 +1327,206 @@ func (m *MemoryStorage) LIndex(key string, index int64) ([]byte, error) {
 }
 
 func (m *MemoryStorage) LSet(key string, index int64, value []byte) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	db := m.getCurrentDB()
-	list, ok := db.listStorage[key]
-	if !ok {
-		return consts.ErrKeyNotFound
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	list, ok, err := m.getList(key)
+	if err != nil {
+		return err
 	}
-	if list.IsExpired() {
-		delete(db.listStorage, key)
+	if !ok {
 		return consts.ErrKeyNotFound
 	}
 	if !list.LSet(index, value) {
 		return consts.ErrIndexOutOfRange
 	}
-	m.markDirty(m.currentDBIndex, key)
-	m.IncrementRDBChanges()
+	m.signalModified(db, NotifyList, "lset", key)
 	return nil
 }
 
+// LPushX 只在列表已经存在时插入，列表不存在时返回 0
+func (m *MemoryStorage) LPushX(key string, values ...[]byte) (int64, error) {
+	return m.pushExisting(key, values, true)
+}
+
+func (m *MemoryStorage) RPushX(key string, values ...[]byte) (int64, error) {
+	return m.pushExisting(key, values, false)
+}
+
+func (m *MemoryStorage) pushExisting(key string, values [][]byte, left bool) (int64, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	list, ok, err := m.getList(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, nil
+	}
+	if left {
+		list.LPush(values...)
+		m.signalModified(db, NotifyList, "lpush", key)
+	} else {
+		list.RPush(values...)
+		m.signalModified(db, NotifyList, "rpush", key)
+	}
+	return list.Len(), nil
+}
+
+// LPopCount 从列表头部弹出至多 count 个元素，列表不存在时返回 ErrKeyNotFound
+func (m *MemoryStorage) LPopCount(key string, count int64) ([][]byte, error) {
+	return m.popCount(key, count, true)
+}
+
+func (m *MemoryStorage) RPopCount(key string, count int64) ([][]byte, error) {
+	return m.popCount(key, count, false)
+}
+
+func (m *MemoryStorage) popCount(key string, count int64, left bool) ([][]byte, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	list, ok, err := m.getList(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, consts.ErrKeyNotFound
+	}
+	if count <= 0 {
+		return [][]byte{}, nil
+	}
+	var values [][]byte
+	event := "rpop"
+	if left {
+		values, event = list.LPopN(count), "lpop"
+	} else {
+		values = list.RPopN(count)
+	}
+	if list.Len() == 0 {
+		db.deleteKey(key)
+	}
+	m.signalModified(db, NotifyList, event, key)
+	m.notifyIfEmptied(db, key)
+	return values, nil
+}
+
+// LInsert 在第一个等于 pivot 的元素之前或之后插入 value，返回插入之后的长度。
+// 列表不存在时返回 0，找不到 pivot 时返回 -1
+func (m *MemoryStorage) LInsert(key string, before bool, pivot, value []byte) (int64, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	list, ok, err := m.getList(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, nil
+	}
+	length := list.LInsert(pivot, value, before)
+	if length > 0 {
+		m.signalModified(db, NotifyList, "linsert", key)
+	}
+	return length, nil
+}
+
+// LRem 删除 count 个等于 value 的元素，count 为负数时从尾部开始，为 0 时删除全部
+func (m *MemoryStorage) LRem(key string, count int64, value []byte) (int64, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	list, ok, err := m.getList(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, nil
+	}
+	removed := list.LRem(count, value)
+	if removed > 0 {
+		if list.Len() == 0 {
+			db.deleteKey(key)
+		}
+		m.signalModified(db, NotifyList, "lrem", key)
+		m.notifyIfEmptied(db, key)
+	}
+	return removed, nil
+}
+
+// LTrim 只保留 start 到 stop 之间的元素，列表变空时删除键
+func (m *MemoryStorage) LTrim(key string, start, stop int64) error {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	list, ok, err := m.getList(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	list.LTrim(start, stop)
+	if list.Len() == 0 {
+		db.deleteKey(key)
+	}
+	m.signalModified(db, NotifyList, "ltrim", key)
+	m.notifyIfEmptied(db, key)
+	return nil
+}
+
+// LPos 返回等于 value 的元素的下标，参数的含义见 QuickList.LPos
+func (m *MemoryStorage) LPos(key string, value []byte, rank, count, maxLen int64) ([]int64, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	list, ok, err := m.getList(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		db.notifyKeyMiss(key)
+		return nil, nil
+	}
+	return list.LPos(value, rank, count, maxLen), nil
+}
+
 // ########################## Set operations ##########################
 
 func (m *MemoryStorage) SAdd(key string, members ...string) (int, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	db := m.getCurrentDB()
-	if m.isExpired(key) {
-		m.deleteKey(key)
-	}
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	db.expireIfNeeded(key)
+	existed := db.exists(key)
 	count, err := db.setStorage.SAdd(key, members...)
 	if err == nil {
-		m.markDirty(m.currentDBIndex, key)
-		m.IncrementRDBChanges()
+		db.notifyNew(key, existed)
+		m.signalModified(db, NotifySet, "sadd", key)
 	}
 	return count, err
 }
 
 func (m *MemoryStorage) SMembers(key string) ([]string, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	db := m.getCurrentDB()
-	if m.isExpired(key) {
-		m.deleteKey(key)
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeededRead(key) {
 		return []string{}, nil
 	}
 	return db.setStorage.SMembers(key)
 }
 
 func (m *MemoryStorage) SRem(key string, members ...string) (int, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	db := m.getCurrentDB()
-	if m.isExpired(key) {
-		m.deleteKey(key)
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeeded(key) {
 		return 0, nil
 	}
 	count, err := db.setStorage.SRem(key, members...)
-	if err == nil {
-		m.markDirty(m.currentDBIndex, key)
-		m.IncrementRDBChanges()
+	if err == nil && count > 0 {
+		m.signalModified(db, NotifySet, "srem", key)
+		m.notifyIfEmptied(db, key)
 	}
 	return count, err
 }
 
 func (m *MemoryStorage) SCard(key string) (int, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	db := m.getCurrentDB()
-	if m.isExpired(key) {
-		m.deleteKey(key)
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeededRead(key) {
 		return 0, nil
 	}
 	return db.setStorage.SCard(key)
@@ -465,65 +1535,77 @@ func (m *MemoryStorage) SCard(key string) (int, error) {
 // ########################## ZSet operations ##########################
 
 func (m *MemoryStorage) ZAdd(key string, score float64, member string) (int, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	db := m.getCurrentDB()
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	db.expireIfNeeded(key)
+	existed := db.exists(key)
 	count, err := db.zsetStorage.ZAdd(key, score, member)
 	if err == nil {
-		m.markDirty(m.currentDBIndex, key)
-		m.IncrementRDBChanges()
+		db.notifyNew(key, existed)
+		m.signalModified(db, NotifyZSet, "zadd", key)
 	}
 	return count, err
 }
 
 func (m *MemoryStorage) ZScore(key, member string) (float64, bool) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	db := m.getCurrentDB()
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeededRead(key) {
+		return 0, false
+	}
 	return db.zsetStorage.ZScore(key, member)
 }
 
 func (m *MemoryStorage) ZRem(key string, member string) (int, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	db := m.getCurrentDB()
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeeded(key) {
+		return 0, nil
+	}
 	count, err := db.zsetStorage.ZRem(key, member)
-	if err == nil {
-		m.markDirty(m.currentDBIndex, key)
-		m.IncrementRDBChanges()
+	if err == nil && count > 0 {
+		m.signalModified(db, NotifyZSet, "zrem", key)
+		m.notifyIfEmptied(db, key)
 	}
 	return count, err
 }
 
 func (m *MemoryStorage) ZRange(key string, start, stop int64) ([]string, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	db := m.getCurrentDB()
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeededRead(key) {
+		return []string{}, nil
+	}
 	return db.zsetStorage.ZRange(key, start, stop)
 }
 
 func (m *MemoryStorage) ZRangeByScore(key string, min, max float64) ([]string, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	db := m.getCurrentDB()
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeededRead(key) {
+		return []string{}, nil
+	}
 	return db.zsetStorage.ZRangeByScore(key, min, max)
 }
 
 func (m *MemoryStorage) ZCard(key string) (int64, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	db := m.getCurrentDB()
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeededRead(key) {
+		return 0, nil
+	}
 	return db.zsetStorage.ZCard(key)
 }
 
 func (m *MemoryStorage) ZIncrBy(key string, increment float64, member string) (float64, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	db := m.getCurrentDB()
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	db.expireIfNeeded(key)
+	existed := db.exists(key)
 	score, err := db.zsetStorage.ZIncrBy(key, increment, member)
 	if err == nil {
-		m.markDirty(m.currentDBIndex, key)
-		m.IncrementRDBChanges()
+		db.notifyNew(key, existed)
+		m.signalModified(db, NotifyZSet, "zincr", key)
 	}
 	return score, err
 }
@@ -531,149 +1613,145 @@ func (m *MemoryStorage) ZIncrBy(key string, increment float64, member string) (f
 // ########################## Generic operations ##########################
 
 func (m *MemoryStorage) Del(key string) (bool, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	db := m.getCurrentDB()
-	deleted := false
-	if _, ok := db.stringStorage.data[key]; ok {
-		delete(db.stringStorage.data, key)
-		deleted = true
-	}
-	if _, ok := db.hashStorage.data[key]; ok {
-		delete(db.hashStorage.data, key)
-		deleted = true
-	}
-	if _, ok := db.listStorage[key]; ok {
-		delete(db.listStorage, key)
-		deleted = true
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeeded(key) {
+		return false, nil
 	}
-	delete(db.expiry, key)
+	deleted := db.deleteKey(key)
 	if deleted {
-		m.markDirty(m.currentDBIndex, key)
-		m.IncrementRDBChanges()
+		m.signalModified(db, NotifyGeneric, "del", key)
 	}
 	return deleted, nil
 }
 
 func (m *MemoryStorage) Exists(key string) bool {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	db := m.getCurrentDB()
-	if m.isExpired(key) {
-		m.deleteKey(key)
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeeded(key) {
 		return false
 	}
-	_, existsString := db.stringStorage.data[key]
-	_, existsHash := db.hashStorage.data[key]
-	_, existsList := db.listStorage[key]
-	return existsString || existsHash || existsList
+	return db.exists(key)
 }
 
 func (m *MemoryStorage) Expire(key string, expiration time.Duration) (bool, error) {
-	db := m.getCurrentDB()
-	db.mu.Lock()
+	db := m.lockDB()
 	defer db.mu.Unlock()
 
-	if _, exists := m.getAnyKey(key); !exists {
+	if db.expireIfNeeded(key) || !db.exists(key) {
 		return false, nil
 	}
 
+	event := "expire"
 	if expiration > 0 {
 		db.expiry[key] = time.Now().Add(expiration)
 	} else {
 		delete(db.expiry, key)
+		event = "persist"
 	}
-	m.markDirty(m.currentDBIndex, key)
-	m.IncrementRDBChanges()
+	m.signalModified(db, NotifyGeneric, event, key)
 	return true, nil
 }
 
 func (m *MemoryStorage) TTL(key string) (time.Duration, error) {
-	db := m.getCurrentDB()
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	db := m.lockDB()
+	defer db.mu.Unlock()
+
+	if db.expireIfNeeded(key) {
+		return -2 * time.Second, nil
+	}
 
 	expireTime, exists := db.expiry[key]
 	if !exists {
 		// 键存在，但没有设置过期时间
-		if _, keyExists := m.getAnyKey(key); keyExists {
+		if db.exists(key) {
 			return -1 * time.Second, nil
 		}
 		// 键不存在
 		return -2 * time.Second, nil
 	}
 
-	ttl := time.Until(expireTime)
-	if ttl <= 0 {
-		m.deleteKey(key)
-		return -2 * time.Second, nil
-	}
-
-	return ttl, nil
+	return time.Until(expireTime), nil
 }
 
 func (m *MemoryStorage) Type(key string) (string, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	db := m.getCurrentDB()
-	if m.isExpired(key) {
-		m.deleteKey(key)
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeeded(key) {
 		return "", ErrKeyNotFound
 	}
-	if _, ok := db.stringStorage.data[key]; ok {
-		return "string", nil
+	if keyType := db.typeOf(key); keyType != "" {
+		return keyType, nil
 	}
-	if _, ok := db.hashStorage.data[key]; ok {
-		return "hash", nil
+	return "", ErrKeyNotFound
+}
+
+// ObjectEncoding 返回键的内部编码，与 Redis 的 OBJECT ENCODING 相同
+func (m *MemoryStorage) ObjectEncoding(key string) (string, error) {
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	if db.expireIfNeeded(key) {
+		return "", ErrKeyNotFound
 	}
-	if _, ok := db.listStorage[key]; ok {
-		return "list", nil
+	switch db.typeOf(key) {
+	case "string":
+		return db.stringStorage.data[key].Encoding(), nil
+	case "hash":
+		return db.hashStorage.data[key].Encoding(), nil
+	case "list":
+		return db.listStorage[key].Encoding(), nil
+	case "set":
+		if db.setStorage.data[key].encoding == useIntSet {
+			return "intset", nil
+		}
+		return "hashtable", nil
+	case "zset":
+		return "skiplist", nil
 	}
 	return "", ErrKeyNotFound
 }
 
 func (m *MemoryStorage) Flush() error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	for i := range m.databases {
-		m.databases[i] = &Database{
-			stringStorage: NewMemoryStringStorage(),
-			hashStorage:   NewMemoryHashStorage(),
-			listStorage:   make(map[string]*dslist.QuickList),
-			setStorage:    NewMemorySetStorage(),
-			zsetStorage:   NewMemoryZSetStorage(),
-			expiry:        make(map[string]time.Time),
-		}
-	}
-	m.dirtyKeys = make(map[int]map[string]struct{})
+	for i, db := range m.databases {
+		db.mu.Lock()
+		db.touchAll()
+		db.reset()
+		db.mu.Unlock()
+		m.dirtyMu.Lock()
+		m.dirtyKeys[i] = make(map[string]struct{})
+		m.dirtyMu.Unlock()
+	}
 	return nil
 }
 
 func (m *MemoryStorage) FlushDB() error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.databases[m.currentDBIndex] = &Database{
-		stringStorage: NewMemoryStringStorage(),
-		hashStorage:   NewMemoryHashStorage(),
-		listStorage:   make(map[string]*dslist.QuickList),
-		setStorage:    NewMemorySetStorage(),
-		zsetStorage:   NewMemoryZSetStorage(),
-		expiry:        make(map[string]time.Time),
-	}
+	db := m.lockDB()
+	defer db.mu.Unlock()
+	db.touchAll()
+	db.reset()
+	m.dirtyMu.Lock()
 	m.dirtyKeys[m.currentDBIndex] = make(map[string]struct{})
+	m.dirtyMu.Unlock()
 	return nil
 }
 
 func (m *MemoryStorage) cleanExpired() {
+	start := time.Now()
+	defer func() { m.latency.Add(latency.EventExpireCycle, time.Since(start)) }()
 	for _, db := range m.databases {
 		db.mu.Lock()
 		now := time.Now()
 		for key, expireTime := range db.expiry {
 			if now.After(expireTime) {
-				m.deleteKey(key)
-				delete(db.expiry, key)
+				db.deleteKey(key)
+				db.expiredKeys.Add(1)
+				db.touch(key)
+				db.notify(NotifyExpired, "expired", key)
 			}
 		}
+		for key := range db.hashStorage.data {
+			m.reapFields(db, key)
+		}
 		db.mu.Unlock()
 	}
 }
@@ -687,28 +1765,6 @@ func (m *MemoryStorage) StartExpirationChecker(interval time.Duration) {
 	}()
 }
 
-func (m *MemoryStorage) getAnyKey(key string) (interface{}, bool) {
-	db := m.getCurrentDB()
-	if _, ok := db.stringStorage.data[key]; ok {
-		return nil, true
-	}
-	if _, ok := db.hashStorage.data[key]; ok {
-		return nil, true
-	}
-	if _, ok := db.listStorage[key]; ok {
-		return nil, true
-	}
-	// 使用 SCard 来检查集合是否存在
-	if count, err := db.setStorage.SCard(key); err == nil && count > 0 {
-		return nil, true
-	}
-	// 假设 zsetStorage 有一个 ZCard 方法
-	if count, err := db.zsetStorage.ZCard(key); err == nil && count > 0 {
-		return nil, true
-	}
-	return nil, false
-}
-
 // SaveRDB 保存 RDB 文件
 func (m *MemoryStorage) SaveRDB() error {
 	return m.RDB.SaveIncremental()
@@ -721,8 +1777,8 @@ func (m *MemoryStorage) LoadRDB() error {
 
 // 在每次修改操作后调用此方法
 func (m *MemoryStorage) markDirty(dbIndex int, key string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.dirtyMu.Lock()
+	defer m.dirtyMu.Unlock()
 	if _, ok := m.dirtyKeys[dbIndex]; !ok {
 		m.dirtyKeys[dbIndex] = make(map[string]struct{})
 	}
@@ -746,20 +1802,123 @@ func (m *MemoryStorage) SetRDBConfig(config config.RDBConfig) {
 	if m.RDB == nil {
 		m.RDB = NewRDBStorage(config, m)
 	} else {
-		m.RDB.Config = config
+		m.RDB.SetConfig(config)
+	}
+}
+
+// KeyspaceStats 返回每个非空数据库的键数量、带过期时间的键数量和平均剩余 TTL
+func (m *MemoryStorage) KeyspaceStats() []KeyspaceStats {
+	var stats []KeyspaceStats
+	now := time.Now()
+	for i, db := range m.databases {
+		db.mu.RLock()
+		keys := len(db.stringStorage.data) + len(db.hashStorage.data) + len(db.listStorage) +
+			len(db.setStorage.data) + len(db.zsetStorage.data)
+		var ttl time.Duration
+		for _, expireTime := range db.expiry {
+			if d := expireTime.Sub(now); d > 0 {
+				ttl += d
+			}
+		}
+		expires := len(db.expiry)
+		db.mu.RUnlock()
+
+		if keys == 0 {
+			continue
+		}
+		st := KeyspaceStats{DB: i, Keys: keys, Expires: expires}
+		if expires > 0 {
+			st.AvgTTL = ttl / time.Duration(expires)
+		}
+		stats = append(stats, st)
 	}
+	return stats
+}
+
+// Watch 开始跟踪键的版本号，返回当前版本。每次 Watch 都要有对应的 Unwatch
+func (m *MemoryStorage) Watch(dbIndex int, key string) uint64 {
+	db := m.databases[dbIndex]
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.expireIfNeeded(key)
+	w, ok := db.watched[key]
+	if !ok {
+		w = &watchedKey{}
+		db.watched[key] = w
+	}
+	w.refs++
+	return w.version
+}
+
+// Unwatch 停止跟踪键的版本号，没有连接跟踪时释放
+func (m *MemoryStorage) Unwatch(dbIndex int, key string) {
+	db := m.databases[dbIndex]
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if w, ok := db.watched[key]; ok {
+		if w.refs--; w.refs <= 0 {
+			delete(db.watched, key)
+		}
+	}
+}
+
+// KeyVersion 返回被跟踪的键的当前版本号，已过期的键会先被删除
+func (m *MemoryStorage) KeyVersion(dbIndex int, key string) uint64 {
+	db := m.databases[dbIndex]
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.expireIfNeeded(key)
+	if w, ok := db.watched[key]; ok {
+		return w.version
+	}
+	return 0
+}
+
+// ExpiredKeys 返回启动以来因过期被删除的键数量
+func (m *MemoryStorage) ExpiredKeys() int64 {
+	var n int64
+	for _, db := range m.databases {
+		n += db.expiredKeys.Load()
+	}
+	return n
+}
+
+func (m *MemoryStorage) GetRDBConfig() config.RDBConfig {
+	if m.RDB == nil {
+		return config.RDBConfig{}
+	}
+	return m.RDB.currentConfig()
 }
 
 func (m *MemoryStorage) Keys(pattern string) []string {
-	m.mutex.RLock()
-	defer m.mutex.RUnlock()
+	db := m.lockDB()
+	defer db.mu.Unlock()
 
-	var keys []string
-	for key := range m.data {
-		matched, err := filepath.Match(pattern, key)
-		if err == nil && matched {
+	keys := []string{}
+	seen := make(map[string]struct{})
+	match := func(key string) {
+		if _, ok := seen[key]; ok || db.isExpired(key) {
+			return
+		}
+		seen[key] = struct{}{}
+		if matched, err := filepath.Match(pattern, key); err == nil && matched {
 			keys = append(keys, key)
 		}
 	}
+	for key := range db.stringStorage.data {
+		match(key)
+	}
+	for key := range db.hashStorage.data {
+		match(key)
+	}
+	for key := range db.listStorage {
+		match(key)
+	}
+	for key := range db.setStorage.data {
+		match(key)
+	}
+	for key := range db.zsetStorage.data {
+		match(key)
+	}
 	return keys
 }
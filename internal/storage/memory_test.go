@@ -2,6 +2,7 @@ package storage
 
 import (
 	"testing"
+	"time"
 )
 
 func TestMemoryStorage_HSet(t *testing.T) {
@@ -76,3 +77,47 @@ func TestMemoryStorage_HLen(t *testing.T) {
 		t.Errorf("Expected length 2, got %d", length)
 	}
 }
+
+func TestHashFieldExpiration(t *testing.T) {
+	s := NewMemoryStorage().(*MemoryStorage)
+	s.HSet("h", map[string][]byte{"a": []byte("1"), "b": []byte("2")})
+	s.HSet("gone", map[string][]byte{"a": []byte("1")})
+	soon := time.Now().Add(20 * time.Millisecond)
+	s.HExpire("h", soon, ExpireAlways, "a")
+	s.HExpire("gone", soon, ExpireAlways, "a")
+	time.Sleep(30 * time.Millisecond)
+
+	// 访问时惰性删除
+	if _, err := s.HGet("h", "a"); err != ErrKeyNotFound {
+		t.Errorf("HGet of an expired field: %v", err)
+	}
+	if n, _ := s.HLen("h"); n != 1 {
+		t.Errorf("HLen = %d, want 1", n)
+	}
+
+	// 定期删除，哈希变为空时删除整个键
+	s.cleanExpired()
+	if _, err := s.Type("gone"); err != ErrKeyNotFound {
+		t.Errorf("hash with only expired fields still exists: %v", err)
+	}
+}
+
+func TestMemoryStorage_GetString(t *testing.T) {
+	s := NewMemoryStorage()
+	s.Set("str", []byte("v"))
+	s.Set("empty", []byte{})
+	s.HSet("hash", map[string][]byte{"f": []byte("v")})
+
+	if value, err := s.GetString("str"); err != nil || string(value) != "v" {
+		t.Errorf("GetString(str) = %q, %v", value, err)
+	}
+	if value, err := s.GetString("empty"); err != nil || value == nil || len(value) != 0 {
+		t.Errorf("GetString(empty) = %#v, %v", value, err)
+	}
+	if _, err := s.GetString("missing"); err != ErrKeyNotFound {
+		t.Errorf("GetString(missing) error = %v, want ErrKeyNotFound", err)
+	}
+	if _, err := s.GetString("hash"); err != ErrWrongType {
+		t.Errorf("GetString(hash) error = %v, want ErrWrongType", err)
+	}
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// NotifyClass 键空间通知的类别，对应 notify-keyspace-events 中的字符
+type NotifyClass int
+
+const (
+	NotifyKeyspace NotifyClass = 1 << iota // K：发布到 __keyspace@<db>__:<key>
+	NotifyKeyevent                         // E：发布到 __keyevent@<db>__:<event>
+	NotifyGeneric                          // g：DEL、EXPIRE 等与类型无关的命令
+	NotifyString                           // $
+	NotifyList                             // l
+	NotifySet                              // s
+	NotifyHash                             // h
+	NotifyZSet                             // z
+	NotifyExpired                          // x：键过期被删除
+	NotifyEvicted                          // e：键因 maxmemory 被淘汰
+	NotifyStream                           // t
+	NotifyKeyMiss                          // m：读取不存在的键，不包含在 A 中
+	NotifyNew                              // n：创建新键，不包含在 A 中
+
+	// NotifyAll 即 A，等价于 g$lshzxet
+	NotifyAll = NotifyGeneric | NotifyString | NotifyList | NotifySet | NotifyHash |
+		NotifyZSet | NotifyExpired | NotifyEvicted | NotifyStream
+)
+
+var notifyFlagChars = []struct {
+	ch    byte
+	class NotifyClass
+}{
+	{'g', NotifyGeneric}, {'$', NotifyString}, {'l', NotifyList}, {'s', NotifySet},
+	{'h', NotifyHash}, {'z', NotifyZSet}, {'x', NotifyExpired}, {'e', NotifyEvicted},
+	{'t', NotifyStream}, {'K', NotifyKeyspace}, {'E', NotifyKeyevent},
+	{'m', NotifyKeyMiss}, {'n', NotifyNew},
+}
+
+// ParseNotifyFlags 解析 notify-keyspace-events 的取值，如 "KEA"、"Ex"
+func ParseNotifyFlags(s string) (NotifyClass, error) {
+	var flags NotifyClass
+next:
+	for i := 0; i < len(s); i++ {
+		if s[i] == 'A' {
+			flags |= NotifyAll
+			continue
+		}
+		for _, f := range notifyFlagChars {
+			if f.ch == s[i] {
+				flags |= f.class
+				continue next
+			}
+		}
+		return 0, errors.New("invalid event class character. Use 'Ag$lshzxeKEtmn'")
+	}
+	return flags, nil
+}
+
+// String 返回 CONFIG GET 显示的取值，与 Redis 的顺序一致
+func (c NotifyClass) String() string {
+	var b strings.Builder
+	for _, f := range notifyFlagChars {
+		if f.class&NotifyAll != 0 && c&NotifyAll == NotifyAll {
+			if f.class == NotifyGeneric {
+				b.WriteByte('A')
+			}
+			continue
+		}
+		if c&f.class != 0 {
+			b.WriteByte(f.ch)
+		}
+	}
+	return b.String()
+}
+
+// Publisher 把消息发布到频道，由发布订阅模块提供。存储在持有数据库锁时调用，
+// 实现不能阻塞，也不能回调存储
+type Publisher func(channel, message string)
+
+// SetNotifier 设置键空间通知的发布函数
+func (m *MemoryStorage) SetNotifier(p Publisher) {
+	m.publish = p
+}
+
+// SetNotifyFlags 设置需要发布的通知类别，未包含 K 或 E 时不发布任何通知
+func (m *MemoryStorage) SetNotifyFlags(flags NotifyClass) {
+	m.notifyFlags.Store(int64(flags))
+}
+
+func (m *MemoryStorage) NotifyFlags() NotifyClass {
+	return NotifyClass(m.notifyFlags.Load())
+}
+
+// notify 发布一个键空间事件，class 是事件的类别，事件名称与 Redis 相同
+func (db *Database) notify(class NotifyClass, event, key string) {
+	ks := db.ks
+	if ks == nil || ks.publish == nil {
+		return
+	}
+	flags := NotifyClass(ks.notifyFlags.Load())
+	if flags&class == 0 || flags&(NotifyKeyspace|NotifyKeyevent) == 0 {
+		return
+	}
+	prefix := "@" + strconv.Itoa(db.index) + "__:"
+	if flags&NotifyKeyspace != 0 {
+		ks.publish("__keyspace"+prefix+key, event)
+	}
+	if flags&NotifyKeyevent != 0 {
+		ks.publish("__keyevent"+prefix+event, key)
+	}
+}
+
+// notifyKeyMiss 读取的键不存在时发布 keymiss 事件
+func (db *Database) notifyKeyMiss(key string) {
+	if db.ks != nil && NotifyClass(db.ks.notifyFlags.Load())&NotifyKeyMiss != 0 && !db.exists(key) {
+		db.notify(NotifyKeyMiss, "keymiss", key)
+	}
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseNotifyFlags(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"", ""},
+		{"KEA", "AKE"},
+		{"Ex", "xE"},
+		{"Kg$lshzxet", "AK"},
+		{"Elm", "lEm"},
+		{"AKEmn", "AKEmn"},
+	}
+	for _, tt := range tests {
+		flags, err := ParseNotifyFlags(tt.in)
+		if err != nil {
+			t.Fatalf("ParseNotifyFlags(%q): %v", tt.in, err)
+		}
+		if got := flags.String(); got != tt.out {
+			t.Errorf("ParseNotifyFlags(%q).String() = %q, want %q", tt.in, got, tt.out)
+		}
+	}
+	if _, err := ParseNotifyFlags("KEQ"); err == nil {
+		t.Error("invalid class character accepted")
+	}
+}
+
+type published struct{ channel, message string }
+
+func newNotifyStorage(t *testing.T, flags string) (*MemoryStorage, *[]published) {
+	t.Helper()
+	ms := NewMemoryStorage().(*MemoryStorage)
+	var events []published
+	ms.SetNotifier(func(channel, message string) {
+		events = append(events, published{channel, message})
+	})
+	f, err := ParseNotifyFlags(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms.SetNotifyFlags(f)
+	return ms, &events
+}
+
+func TestKeyspaceNotifications(t *testing.T) {
+	ms, events := newNotifyStorage(t, "KEA")
+	ms.Select(2)
+
+	ms.Set("k", []byte("v"))
+	ms.RPush("l", []byte("a"))
+	ms.LPop("l")
+	ms.Del("k")
+	ms.Del("missing")
+
+	want := []published{
+		{"__keyspace@2__:k", "set"}, {"__keyevent@2__:set", "k"},
+		{"__keyspace@2__:l", "rpush"}, {"__keyevent@2__:rpush", "l"},
+		{"__keyspace@2__:l", "lpop"}, {"__keyevent@2__:lpop", "l"},
+		{"__keyspace@2__:l", "del"}, {"__keyevent@2__:del", "l"},
+		{"__keyspace@2__:k", "del"}, {"__keyevent@2__:del", "k"},
+	}
+	if !reflect.DeepEqual(*events, want) {
+		t.Errorf("events = %v\nwant %v", *events, want)
+	}
+}
+
+func TestKeyspaceNotificationClasses(t *testing.T) {
+	// 只订阅哈希类和 keyevent 频道
+	ms, events := newNotifyStorage(t, "Eh")
+	ms.Set("s", []byte("v"))
+	ms.HSet("h", map[string][]byte{"f": []byte("v")})
+	ms.HDel("h", "f")
+
+	want := []published{{"__keyevent@0__:hset", "h"}, {"__keyevent@0__:hdel", "h"}}
+	if !reflect.DeepEqual(*events, want) {
+		t.Errorf("events = %v, want %v", *events, want)
+	}
+
+	// 没有 K 和 E 时不发布
+	*events = nil
+	ms.SetNotifyFlags(NotifyAll)
+	ms.Set("s", []byte("v"))
+	if len(*events) != 0 {
+		t.Errorf("published without K or E: %v", *events)
+	}
+}
+
+func TestExpiredNotifications(t *testing.T) {
+	ms, events := newNotifyStorage(t, "Ex")
+	ms.Set("lazy", []byte("v"))
+	ms.Set("active", []byte("v"))
+	ms.Expire("lazy", time.Millisecond)
+	ms.Expire("active", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	ms.Get("lazy")
+	ms.cleanExpired()
+
+	want := []published{{"__keyevent@0__:expired", "lazy"}, {"__keyevent@0__:expired", "active"}}
+	if !reflect.DeepEqual(*events, want) {
+		t.Errorf("events = %v, want %v", *events, want)
+	}
+}
+
+func TestNewAndKeyMissNotifications(t *testing.T) {
+	ms, events := newNotifyStorage(t, "Knm")
+	ms.SAdd("s", "a")
+	ms.SAdd("s", "b")
+	ms.Get("nokey")
+	ms.SCard("s")
+
+	want := []published{{"__keyspace@0__:s", "new"}, {"__keyspace@0__:nokey", "keymiss"}}
+	if !reflect.DeepEqual(*events, want) {
+		t.Errorf("events = %v, want %v", *events, want)
+	}
+}
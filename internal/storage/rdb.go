@@ -6,14 +6,18 @@ import (
 	"encoding/binary"
 	"encoding/gob"
 	"errors"
+	"fmt"
 	"hash/crc32"
 	"io"
 	"literedis/config"
+	"literedis/internal/datastruct/dshash"
+	"literedis/internal/datastruct/dszset"
 	"os"
+	"sync"
 	"sync/atomic"
 	"time"
 
-	"literedis/internal/datastruct/dslist"
+	"literedis/internal/latency"
 	"literedis/pkg/log"
 )
 
@@ -31,12 +35,14 @@ type RDBConfig struct {
 }
 
 type RDBStorage struct {
-	Config               config.RDBConfig
+	Config               config.RDBConfig // 运行时通过 SetConfig 修改
+	configMu             sync.RWMutex
 	Storage              *MemoryStorage
 	savingInProgress     atomic.Bool
-	lastSaveTime         time.Time
-	changesSinceLastSave int
+	lastSaveTime         time.Time // 由 statsMu 保护
+	changesSinceLastSave atomic.Int64
 	stats                RDBStats // 使用 storage 包中定义的 RDBStats
+	statsMu              sync.Mutex
 }
 
 func NewRDBStorage(config config.RDBConfig, storage *MemoryStorage) *RDBStorage {
@@ -44,214 +50,226 @@ func NewRDBStorage(config config.RDBConfig, storage *MemoryStorage) *RDBStorage
 		Config:       config,
 		Storage:      storage,
 		lastSaveTime: time.Now(),
-		stats:        RDBStats{},
+		stats:        RDBStats{LastSaveStatus: "ok"},
 	}
 }
 
+// SetConfig 替换 RDB 配置，下一次保存和自动保存检查时生效
+func (r *RDBStorage) SetConfig(c config.RDBConfig) {
+	r.configMu.Lock()
+	defer r.configMu.Unlock()
+	r.Config = c
+}
+
+func (r *RDBStorage) currentConfig() config.RDBConfig {
+	r.configMu.RLock()
+	defer r.configMu.RUnlock()
+	return r.Config
+}
+
 func (r *RDBStorage) Save() error {
-	log.Infof("Saving RDB to file: %s", r.Config.Filename)
-	file, err := os.Create(r.Config.Filename)
-	if err != nil {
+	log.Infof("Saving RDB to file: %s", r.currentConfig().Filename)
+
+	r.Storage.mu.RLock()
+	defer r.Storage.mu.RUnlock()
+
+	if err := r.writeSnapshot(); err != nil {
 		return err
 	}
-	defer file.Close()
 
-	encoder := gob.NewEncoder(file)
+	log.Infof("RDB save completed")
+	return nil
+}
 
-	r.Storage.mu.RLock()
-	defer r.Storage.mu.RUnlock()
+// writeSnapshot 把所有数据库写入临时文件再替换 RDB 文件。文件由 gzip 压缩的
+// gob 数据和末尾 4 字节的 CRC32 校验和组成。调用方需要持有 Storage.mu 的读锁
+func (r *RDBStorage) writeSnapshot() error {
+	cfg := r.currentConfig()
+	var buf bytes.Buffer
+	gzipWriter, err := gzip.NewWriterLevel(&buf, cfg.CompressionLevel)
+	if err != nil {
+		return err
+	}
+	encoder := gob.NewEncoder(gzipWriter)
 
-	// 编码数据库数量
+	// 写入头部信息和数据库数量
+	if err := encoder.Encode(rdbHeader{Version: currentVersion}); err != nil {
+		return err
+	}
 	if err := encoder.Encode(len(r.Storage.databases)); err != nil {
 		return err
 	}
-
-	// 编码每个数据库
 	for i, db := range r.Storage.databases {
-		if err := r.encodeDatabase(encoder, i, db); err != nil {
+		db.mu.RLock()
+		err := r.encodeDatabase(encoder, i, db)
+		db.mu.RUnlock()
+		if err != nil {
 			return err
 		}
 	}
+	if err := gzipWriter.Close(); err != nil {
+		return err
+	}
 
-	log.Infof("RDB save completed")
-	return nil
-}
-
-func (r *RDBStorage) Load() error {
-	log.Infof("Loading RDB from file: %s", r.Config.Filename)
-	file, err := os.Open(r.Config.Filename)
+	tempFilename := cfg.Filename + ".temp"
+	file, err := os.Create(tempFilename)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	// 获取文件大小
-	fileInfo, err := file.Stat()
-	if err != nil {
+	if _, err := file.Write(buf.Bytes()); err != nil {
 		return err
 	}
-	fileSize := fileInfo.Size()
+	checksum := crc32.ChecksumIEEE(buf.Bytes())
+	if err := binary.Write(file, binary.LittleEndian, checksum); err != nil {
+		return err
+	}
+	fsyncStart := time.Now()
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	r.Storage.latency.Add(latency.EventRDBFsync, time.Since(fsyncStart))
 
-	// 读取除校验和外的所有数据
-	data := make([]byte, fileSize-4) // 4 bytes for checksum
-	if _, err := io.ReadFull(file, data); err != nil {
+	// 原子性地替换旧的RDB文件
+	return os.Rename(tempFilename, cfg.Filename)
+}
+
+func (r *RDBStorage) Load() error {
+	log.Infof("Loading RDB from file: %s", r.currentConfig().Filename)
+	file, err := os.Open(r.currentConfig().Filename)
+	if err != nil {
 		return err
 	}
+	defer file.Close()
 
-	// 读取存储的校和
-	var storedChecksum uint32
-	if err := binary.Read(file, binary.LittleEndian, &storedChecksum); err != nil {
+	data, err := io.ReadAll(file)
+	if err != nil {
 		return err
 	}
 
-	// 计算实际校验和
-	calculatedChecksum := crc32.ChecksumIEEE(data)
+	// 加入版本头部之前的 Save 直接把 gob 写入文件，没有压缩也没有校验和
+	if !bytes.HasPrefix(data, gzipMagic) {
+		log.Infof("RDB file is not compressed, loading legacy format")
+		return r.decodeDatabases(gob.NewDecoder(bytes.NewReader(data)), r.decodeLegacyDatabase)
+	}
 
-	// 验证校验和
-	if storedChecksum != calculatedChecksum {
+	if len(data) < 4 {
+		return errors.New("RDB file is corrupted: too short")
+	}
+	// 最后 4 字节是校验和
+	data, trailer := data[:len(data)-4], data[len(data)-4:]
+	if binary.LittleEndian.Uint32(trailer) != crc32.ChecksumIEEE(data) {
 		return errors.New("RDB file is corrupted: checksum mismatch")
 	}
 
-	// 创建一个gzip读取器
 	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
 	if err != nil {
 		return err
 	}
 	defer gzipReader.Close()
-
 	decoder := gob.NewDecoder(gzipReader)
 
+	var header rdbHeader
+	if err := decoder.Decode(&header); err != nil {
+		return err
+	}
+	if header.Version != currentVersion {
+		return fmt.Errorf("unsupported RDB version %d", header.Version)
+	}
+	return r.decodeDatabases(decoder, r.decodeDatabase)
+}
+
+// gzipMagic 是 gzip 数据开头的两个字节
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decodeDatabases 解码数据库数量和每个数据库，全部解码成功之后才替换当前的数据
+func (r *RDBStorage) decodeDatabases(decoder *gob.Decoder, decodeDatabase func(*gob.Decoder) (int, *Database, error)) error {
 	// 解码数据库数量
 	var dbCount int
 	if err := decoder.Decode(&dbCount); err != nil {
 		return err
 	}
+	if dbCount <= 0 || dbCount > DefaultDBCount {
+		return fmt.Errorf("RDB file is corrupted: %d databases", dbCount)
+	}
 
-	r.Storage.mu.Lock()
-	defer r.Storage.mu.Unlock()
-
-	r.Storage.databases = make([]*Database, dbCount)
-
-	// 解码每个数据库
+	databases := make([]*Database, DefaultDBCount)
 	for i := 0; i < dbCount; i++ {
-		if err := r.decodeDatabase(decoder); err != nil {
+		index, db, err := decodeDatabase(decoder)
+		if err != nil {
 			return err
 		}
+		if index < 0 || index >= dbCount {
+			return fmt.Errorf("RDB file is corrupted: database index %d", index)
+		}
+		databases[index] = db
+	}
+	for i, db := range databases {
+		if db == nil {
+			databases[i] = newDatabase(r.Storage.keyspace, i)
+		}
 	}
 
+	r.Storage.mu.Lock()
+	r.Storage.databases = databases
+	r.Storage.mu.Unlock()
+
 	log.Infof("RDB load completed")
 	return nil
 }
 
 func (r *RDBStorage) SaveIncremental() error {
+	err := r.saveIncremental()
+	r.statsMu.Lock()
+	if err != nil {
+		r.stats.LastSaveStatus = "err"
+	} else {
+		r.stats.LastSaveStatus = "ok"
+	}
+	r.statsMu.Unlock()
+	return err
+}
+
+func (r *RDBStorage) saveIncremental() error {
 	startTime := time.Now()
 	r.Storage.mu.RLock()
 	defer r.Storage.mu.RUnlock()
 
-	if len(r.Storage.dirtyKeys) == 0 {
+	r.Storage.dirtyMu.Lock()
+	dirtyKeys := r.Storage.dirtyKeys
+	r.Storage.dirtyKeys = make(map[int]map[string]struct{})
+	r.Storage.dirtyMu.Unlock()
+	r.Storage.latency.Add(latency.EventSnapshot, time.Since(startTime))
+
+	if len(dirtyKeys) == 0 {
 		log.Info("No changes since last save, skipping RDB save")
 		return nil
 	}
 
-	tempFilename := r.Config.Filename + ".temp"
-	file, err := os.Create(tempFilename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	var buf bytes.Buffer
-	gzipWriter := gzip.NewWriter(&buf)
-	defer gzipWriter.Close()
-
-	encoder := gob.NewEncoder(gzipWriter)
-
-	// 写入头部信
-	header := rdbHeader{Version: currentVersion}
-	if err := encoder.Encode(header); err != nil {
-		return err
-	}
-
-	// 写入增量数据
-	for dbIndex, keys := range r.Storage.dirtyKeys {
-		if err := encoder.Encode(dbIndex); err != nil {
-			return err
-		}
-		if err := encoder.Encode(len(keys)); err != nil {
-			return err
-		}
-		for key := range keys {
-			if err := r.encodeKey(encoder, dbIndex, key); err != nil {
-				return err
-			}
-		}
-	}
-
-	// 写入结束标记
-	if err := encoder.Encode(-1); err != nil {
-		return err
-	}
-
-	// 关闭gzip写入器
-	if err := gzipWriter.Close(); err != nil {
+	// 有修改时保存完整的快照，加载时不需要合并多个文件
+	if err := r.writeSnapshot(); err != nil {
 		return err
 	}
 
-	// 计算校验和
-	checksum := crc32.ChecksumIEEE(buf.Bytes())
-
-	// 写入校验和
-	if err := binary.Write(file, binary.LittleEndian, checksum); err != nil {
-		return err
-	}
-
-	// 将缓冲区的内容写入文件
-	if _, err := file.Write(buf.Bytes()); err != nil {
-		return err
-	}
-
-	// 原子性地替换旧的RDB文件
-	if err := os.Rename(tempFilename, r.Config.Filename); err != nil {
-		return err
-	}
-
-	// 清除脏���记录
-	r.Storage.dirtyKeys = make(map[int]map[string]struct{})
-	r.Storage.lastSaveTime = time.Now()
-
 	// 更新统计信息
+	r.statsMu.Lock()
+	r.Storage.lastSaveTime = time.Now()
+	r.lastSaveTime = time.Now()
 	r.stats.LastSaveTime = startTime
 	r.stats.LastSaveDuration = time.Since(startTime)
 	r.stats.TotalSaves++
-	r.stats.TotalKeysSaved += len(r.Storage.dirtyKeys)
+	for _, keys := range dirtyKeys {
+		r.stats.TotalKeysSaved += len(keys)
+	}
 
-	fileInfo, err := os.Stat(r.Config.Filename)
+	fileInfo, err := os.Stat(r.currentConfig().Filename)
 	if err == nil {
 		r.stats.LastSaveSize = fileInfo.Size()
 	}
+	r.statsMu.Unlock()
 
-	r.changesSinceLastSave = 0
-	r.lastSaveTime = time.Now()
-
-	return nil
-}
-
-func (r *RDBStorage) encodeKey(encoder *gob.Encoder, dbIndex int, key string) error {
-	db := r.Storage.databases[dbIndex]
-	if err := encoder.Encode(key); err != nil {
-		return err
-	}
-
-	// 根据键的类型进行编码
-	// 这里需要根据你的具体实现来编码不同类型的数据
-	// 例如:
-	if value, ok := db.stringStorage.data[key]; ok {
-		if err := encoder.Encode("string"); err != nil {
-			return err
-		}
-		return encoder.Encode(value)
-	}
-	// 对其类型的数据进行类似的处理...
+	r.changesSinceLastSave.Store(0)
 
 	return nil
 }
@@ -267,8 +285,12 @@ func (r *RDBStorage) encodeDatabase(encoder *gob.Encoder, index int, db *Databas
 		return err
 	}
 
-	// 编码哈希数据
-	if err := encoder.Encode(db.hashStorage.data); err != nil {
+	// 编码哈希数据，包括 field 的过期时间
+	hashes, err := encodeValues(db.hashStorage.data)
+	if err != nil {
+		return err
+	}
+	if err := encoder.Encode(hashes); err != nil {
 		return err
 	}
 
@@ -283,7 +305,11 @@ func (r *RDBStorage) encodeDatabase(encoder *gob.Encoder, index int, db *Databas
 	}
 
 	// 编码有序集合数据
-	if err := encoder.Encode(db.zsetStorage.data); err != nil {
+	zsets, err := encodeValues(db.zsetStorage.data)
+	if err != nil {
+		return err
+	}
+	if err := encoder.Encode(zsets); err != nil {
 		return err
 	}
 
@@ -291,53 +317,98 @@ func (r *RDBStorage) encodeDatabase(encoder *gob.Encoder, index int, db *Databas
 	return encoder.Encode(db.expiry)
 }
 
-func (r *RDBStorage) decodeDatabase(decoder *gob.Decoder) error {
+func (r *RDBStorage) decodeDatabase(decoder *gob.Decoder) (int, *Database, error) {
 	var dbIndex int
 	if err := decoder.Decode(&dbIndex); err != nil {
-		return err
+		return 0, nil, err
 	}
 
-	db := &Database{
-		stringStorage: NewMemoryStringStorage(),
-		hashStorage:   NewMemoryHashStorage(),
-		listStorage:   make(map[string]*dslist.QuickList),
-		setStorage:    NewMemorySetStorage(),
-		zsetStorage:   NewMemoryZSetStorage(),
-		expiry:        make(map[string]time.Time),
-	}
+	db := newDatabase(r.Storage.keyspace, dbIndex)
 
 	// 解码字串数据
 	if err := decoder.Decode(&db.stringStorage.data); err != nil {
-		return err
+		return 0, nil, err
 	}
 
 	// 解码哈希数据
-	if err := decoder.Decode(&db.hashStorage.data); err != nil {
-		return err
+	var hashes map[string][]byte
+	if err := decoder.Decode(&hashes); err != nil {
+		return 0, nil, err
+	}
+	for key, data := range hashes {
+		hash := dshash.NewHashWithLimits(db.ks.hashLimits)
+		if err := hash.GobDecode(data); err != nil {
+			return 0, nil, err
+		}
+		db.hashStorage.data[key] = hash
 	}
 
 	// 解码列表数据
 	if err := decoder.Decode(&db.listStorage); err != nil {
-		return err
+		return 0, nil, err
 	}
 
 	// 解码集合数据
 	if err := decoder.Decode(&db.setStorage.data); err != nil {
-		return err
+		return 0, nil, err
 	}
 
 	// 解码有序集合数据
-	if err := decoder.Decode(&db.zsetStorage.data); err != nil {
-		return err
+	var zsets map[string][]byte
+	if err := decoder.Decode(&zsets); err != nil {
+		return 0, nil, err
+	}
+	for key, data := range zsets {
+		zset := dszset.NewZSet()
+		if err := zset.GobDecode(data); err != nil {
+			return 0, nil, err
+		}
+		db.zsetStorage.data[key] = zset
 	}
 
 	// 解码过期时间数据
 	if err := decoder.Decode(&db.expiry); err != nil {
-		return err
+		return 0, nil, err
 	}
 
-	r.Storage.databases[dbIndex] = db
-	return nil
+	return dbIndex, db, nil
+}
+
+// decodeLegacyDatabase 解码没有版本头部的旧格式，其中哈希和有序集合直接以接口类型编码
+func (r *RDBStorage) decodeLegacyDatabase(decoder *gob.Decoder) (int, *Database, error) {
+	var dbIndex int
+	if err := decoder.Decode(&dbIndex); err != nil {
+		return 0, nil, err
+	}
+
+	db := newDatabase(r.Storage.keyspace, dbIndex)
+	for _, v := range []any{
+		&db.stringStorage.data,
+		&db.hashStorage.data,
+		&db.listStorage,
+		&db.setStorage.data,
+		&db.zsetStorage.data,
+		&db.expiry,
+	} {
+		if err := decoder.Decode(v); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return dbIndex, db, nil
+}
+
+// encodeValues 编码接口类型的值，解码时由调用方创建具体的类型
+func encodeValues[V interface{ GobEncode() ([]byte, error) }](m map[string]V) (map[string][]byte, error) {
+	result := make(map[string][]byte, len(m))
+	for key, v := range m {
+		data, err := v.GobEncode()
+		if err != nil {
+			return nil, err
+		}
+		result[key] = data
+	}
+	return result, nil
 }
 
 func (r *RDBStorage) BackgroundSave() error {
@@ -359,18 +430,25 @@ func (r *RDBStorage) BackgroundSave() error {
 }
 
 func (r *RDBStorage) shouldAutoSave() bool {
+	r.statsMu.Lock()
 	timeSinceLastSave := time.Since(r.lastSaveTime)
-	return timeSinceLastSave >= r.Config.SaveInterval ||
-		r.changesSinceLastSave >= r.Config.AutoSaveChanges
+	r.statsMu.Unlock()
+	return timeSinceLastSave >= r.currentConfig().SaveInterval ||
+		r.changesSinceLastSave.Load() >= int64(r.currentConfig().AutoSaveChanges)
 }
 
 func (r *RDBStorage) incrementChanges() {
-	r.changesSinceLastSave++
+	r.changesSinceLastSave.Add(1)
 	if r.shouldAutoSave() {
 		go r.BackgroundSave()
 	}
 }
 
 func (r *RDBStorage) GetStats() RDBStats {
-	return r.stats
+	r.statsMu.Lock()
+	stats := r.stats
+	r.statsMu.Unlock()
+	stats.ChangesSinceLastSave = r.changesSinceLastSave.Load()
+	stats.SaveInProgress = r.savingInProgress.Load()
+	return stats
 }
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"bytes"
+	"encoding/gob"
 	"strconv"
 	"sync"
 )
@@ -65,6 +67,48 @@ type Set struct {
 	dict     map[string]struct{}
 }
 
+// setSnapshot 是集合在快照中的形式
+type setSnapshot struct {
+	IntSet  bool
+	Ints    []int64
+	Members []string
+}
+
+func (s *Set) GobEncode() ([]byte, error) {
+	snap := setSnapshot{IntSet: s.encoding == useIntSet}
+	if snap.IntSet {
+		snap.Ints = s.intset.contents
+	} else {
+		for member := range s.dict {
+			snap.Members = append(snap.Members, member)
+		}
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snap); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Set) GobDecode(data []byte) error {
+	var snap setSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return err
+	}
+	if snap.IntSet {
+		*s = Set{encoding: useIntSet, intset: &IntSet{contents: snap.Ints}}
+		if s.intset.contents == nil {
+			s.intset.contents = make([]int64, 0)
+		}
+		return nil
+	}
+	*s = Set{encoding: useHashTable, dict: make(map[string]struct{}, len(snap.Members))}
+	for _, member := range snap.Members {
+		s.dict[member] = struct{}{}
+	}
+	return nil
+}
+
 type MemorySetStorage struct {
 	data map[string]*Set
 	mu   sync.RWMutex
@@ -3,11 +3,15 @@ package storage
 import (
 	"errors"
 	"literedis/config"
+	"literedis/internal/datastruct/dsstring"
 	"time"
 )
 
 var ErrKeyNotFound = errors.New("key not found")
 var ErrInvalidDBIndex = errors.New("invalid database index")
+var ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+var ErrHashNotInteger = errors.New("hash value is not an integer")
+var ErrHashNotFloat = errors.New("hash value is not a float")
 
 type Storage interface {
 	StringStorage
@@ -17,24 +21,92 @@ type Storage interface {
 	ZSetStorage
 	KeyStorage
 	ServerStorage
+	WatchStorage
 }
 
 type RDBStats struct {
-	LastSaveTime     time.Time
-	LastSaveDuration time.Duration
-	TotalSaves       int
-	TotalKeysSaved   int
-	LastSaveSize     int64
+	LastSaveTime         time.Time
+	LastSaveDuration     time.Duration
+	LastSaveStatus       string // ok 或 err
+	TotalSaves           int
+	TotalKeysSaved       int
+	LastSaveSize         int64
+	ChangesSinceLastSave int64
+	SaveInProgress       bool
+}
+
+// KeyspaceStats 单个数据库的统计信息，用于 INFO keyspace
+type KeyspaceStats struct {
+	DB      int
+	Keys    int
+	Expires int
+	AvgTTL  time.Duration
+}
+
+// SetOptions SET 命令的选项
+type SetOptions struct {
+	NX       bool      // 只在键不存在时设置
+	XX       bool      // 只在键存在时设置
+	Get      bool      // 返回旧值，旧值不是字符串时报错
+	ExpireAt time.Time // 非零时设置过期时间
+	KeepTTL  bool      // 保留原有的过期时间
+}
+
+// ExpireCondition HEXPIRE 等命令的 NX、XX、GT、LT 选项
+type ExpireCondition int
+
+const (
+	ExpireAlways ExpireCondition = iota
+	ExpireNX                     // 只在没有过期时间时设置
+	ExpireXX                     // 只在已有过期时间时设置
+	ExpireGT                     // 只在新的过期时间更晚时设置，没有过期时间视为永不过期
+	ExpireLT                     // 只在新的过期时间更早时设置
+)
+
+// HEXPIRE、HPERSIST 等命令对每个 field 的返回值
+const (
+	FieldNotFound = -2 // 键或 field 不存在
+	FieldNoTTL    = -1 // field 没有过期时间
+	FieldSkipped  = 0  // 不满足 NX、XX、GT、LT 条件
+	FieldUpdated  = 1  // 设置或清除了过期时间
+	FieldDeleted  = 2  // 过期时间已过，field 被删除
+)
+
+// HSetExOptions HSETEX 命令的选项
+type HSetExOptions struct {
+	FNX      bool      // 只在所有 field 都不存在时设置
+	FXX      bool      // 只在所有 field 都存在时设置
+	ExpireAt time.Time // 非零时设置 field 的过期时间
+	KeepTTL  bool      // 保留 field 原有的过期时间
 }
 
 // StringStorage 接口定义了字符串类型的操作
 type StringStorage interface {
 	Set(key string, value []byte) error
 	Get(key string) ([]byte, error)
+	// GetString 和 Get 相同，但键不是字符串时返回 ErrWrongType
+	GetString(key string) ([]byte, error)
+	SetWithOptions(key string, value []byte, opts SetOptions) (old []byte, ok bool, err error)
 	Append(key string, value []byte) (int, error)
 	GetRange(key string, start, end int) ([]byte, error)
 	SetRange(key string, offset int, value []byte) (int, error)
 	StrLen(key string) (int, error)
+	IncrBy(key string, delta int64) (int64, error)
+	IncrByFloat(key string, delta float64) ([]byte, error)
+	MSet(keys []string, values [][]byte) error
+	MSetNX(keys []string, values [][]byte) (bool, error)
+	MGet(keys ...string) ([][]byte, error)
+	SetNX(key string, value []byte) (bool, error)
+	SetEX(key string, value []byte, expiration time.Duration) error
+	GetSet(key string, value []byte) ([]byte, error)
+	GetDel(key string) ([]byte, error)
+	GetEx(key string, expireAt time.Time, persist bool) ([]byte, error)
+
+	// 位图
+	SetBit(key string, offset uint64, bit int) (int, error)
+	GetBit(key string, offset uint64) (int, error)
+	BitOp(op dsstring.BitOp, dest string, keys ...string) (int64, error)
+	BitField(key string, ops []dsstring.BitFieldOp) ([]dsstring.BitFieldResult, error)
 }
 
 // HashStorage 接口定义了哈希类型的操作
@@ -43,18 +115,45 @@ type HashStorage interface {
 	HGet(key, field string) ([]byte, error)
 	HDel(key string, fields ...string) (int, error)
 	HLen(key string) (int, error)
+	HExists(key, field string) (bool, error)
+	HKeys(key string) ([]string, error)
+	HVals(key string) ([][]byte, error)
+	HGetAll(key string) ([][]byte, error)
+	HMGet(key string, fields ...string) ([][]byte, error)
+	HSetNX(key, field string, value []byte) (bool, error)
+	HIncrBy(key, field string, delta int64) (int64, error)
+	HIncrByFloat(key, field string, delta float64) ([]byte, error)
+	HStrLen(key, field string) (int, error)
+	HRandField(key string, count int64, withValues bool) ([][]byte, error)
+	HScan(key string, cursor uint64, match string, count int) (uint64, [][]byte, error)
+
+	// field 过期
+	HExpire(key string, at time.Time, cond ExpireCondition, fields ...string) ([]int64, error)
+	HPExpireTime(key string, fields ...string) ([]int64, error)
+	HPersist(key string, fields ...string) ([]int64, error)
+	HGetEx(key string, at time.Time, persist bool, fields ...string) ([][]byte, error)
+	HSetEx(key string, fields []string, values [][]byte, opts HSetExOptions) (bool, error)
 }
 
 // ListStorage 接口定义了列表类型的操作
 type ListStorage interface {
-	LPush(key string, values ...[]byte) (int, error)
-	RPush(key string, values ...[]byte) (int, error)
+	LPush(key string, values ...[]byte) (int64, error)
+	RPush(key string, values ...[]byte) (int64, error)
 	LPop(key string) ([]byte, error)
 	RPop(key string) ([]byte, error)
+	LMove(src, dst string, srcLeft, dstLeft bool) ([]byte, error)
 	LLen(key string) (int, error)
 	LRange(key string, start, stop int) ([][]byte, error)
 	LIndex(key string, index int64) ([]byte, error)
 	LSet(key string, index int64, value []byte) error
+	LPushX(key string, values ...[]byte) (int64, error)
+	RPushX(key string, values ...[]byte) (int64, error)
+	LPopCount(key string, count int64) ([][]byte, error)
+	RPopCount(key string, count int64) ([][]byte, error)
+	LInsert(key string, before bool, pivot, value []byte) (int64, error)
+	LRem(key string, count int64, value []byte) (int64, error)
+	LTrim(key string, start, stop int64) error
+	LPos(key string, value []byte, rank, count, maxLen int64) ([]int64, error)
 }
 
 // SetStorage 接口定义了集合类型的操作
@@ -82,6 +181,14 @@ type KeyStorage interface {
 	Expire(key string, expiration time.Duration) (bool, error)
 	TTL(key string) (time.Duration, error)
 	Type(key string) (string, error)
+	ObjectEncoding(key string) (string, error)
+}
+
+// WatchStorage 为 WATCH 提供键的版本号，键的每次修改（包括过期和 FLUSHDB）都会增加版本号
+type WatchStorage interface {
+	Watch(db int, key string) uint64
+	Unwatch(db int, key string)
+	KeyVersion(db int, key string) uint64
 }
 
 // ServerStorage 接口定义了服务器级别的操作
@@ -89,10 +196,17 @@ type ServerStorage interface {
 	Flush() error
 	FlushDB() error
 	Select(index int) error
+	SelectedDB() int
+	// NewView 返回共享数据但拥有独立 SELECT 状态的存储视图
+	NewView() Storage
 
 	// RDB 相关的方法
 	SaveRDB() error
 	LoadRDB() error
 	GetRDBStats() RDBStats
 	SetRDBConfig(config config.RDBConfig)
+	GetRDBConfig() config.RDBConfig
+
+	KeyspaceStats() []KeyspaceStats
+	ExpiredKeys() int64
 }
@@ -3,6 +3,8 @@ package storage
 import (
 	"literedis/internal/consts"
 	"literedis/internal/datastruct/dsstring"
+	"math"
+	"strconv"
 	"sync"
 )
 
@@ -20,7 +22,7 @@ func NewMemoryStringStorage() *MemoryStringStorage {
 func (s *MemoryStringStorage) Set(key string, value []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.data[key] = dsstring.NewSDS(string(value))
+	s.data[key] = dsstring.New(value)
 	return nil
 }
 
@@ -60,8 +62,9 @@ func (m *MemoryStringStorage) Append(key string, value []byte) (int, error) {
 	sds, exists := m.data[key]
 	if !exists {
 		sds = dsstring.NewSDS("")
-		m.data[key] = sds
 	}
+	sds = sds.Unshare()
+	m.data[key] = sds
 
 	return sds.Append(value), nil
 }
@@ -85,8 +88,9 @@ func (m *MemoryStringStorage) SetRange(key string, offset int, value []byte) (in
 	sds, exists := m.data[key]
 	if !exists {
 		sds = dsstring.NewSDS("")
-		m.data[key] = sds
 	}
+	sds = sds.Unshare()
+	m.data[key] = sds
 
 	return sds.SetRange(offset, value), nil
 }
@@ -102,3 +106,63 @@ func (m *MemoryStringStorage) StrLen(key string) (int, error) {
 
 	return int(sds.Len()), nil
 }
+
+// IncrBy 将整数值加上 delta，键不存在时从 0 开始，结果使用整数编码
+func (m *MemoryStringStorage) IncrBy(key string, delta int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var current int64
+	if sds, exists := m.data[key]; exists {
+		v, ok := sds.Int()
+		if !ok {
+			return 0, consts.ErrNotInteger
+		}
+		current = v
+	}
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, consts.ErrOverflow
+	}
+	current += delta
+	m.data[key] = dsstring.NewInt(current)
+	return current, nil
+}
+
+// IncrByFloat 将浮点数值加上 delta，返回保存的新值
+func (m *MemoryStringStorage) IncrByFloat(key string, delta float64) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var current float64
+	if sds, exists := m.data[key]; exists {
+		v, err := ParseFloat(sds.Get())
+		if err != nil {
+			return nil, err
+		}
+		current = v
+	}
+	current += delta
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return nil, consts.ErrNaNOrInfinity
+	}
+	value := FormatFloat(current)
+	m.data[key] = dsstring.NewSDS(string(value))
+	return value, nil
+}
+
+// ParseFloat 解析字符串表示的浮点数，不接受空白和 NaN
+func ParseFloat(b []byte) (float64, error) {
+	if len(b) == 0 {
+		return 0, consts.ErrNotFloat
+	}
+	v, err := strconv.ParseFloat(string(b), 64)
+	if err != nil || math.IsNaN(v) {
+		return 0, consts.ErrNotFloat
+	}
+	return v, nil
+}
+
+// FormatFloat 格式化 INCRBYFLOAT 的结果，不使用指数形式，去掉多余的零
+func FormatFloat(v float64) []byte {
+	return strconv.AppendFloat(nil, v, 'f', -1, 64)
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyVersion(t *testing.T) {
+	ms := NewMemoryStorage().(*MemoryStorage)
+	ms.Set("k", []byte("v"))
+	v := ms.Watch(0, "k")
+	ms.Watch(0, "k")
+	vm := ms.Watch(0, "missing")
+
+	// 读取不改变版本号
+	ms.Get("k")
+	if got := ms.KeyVersion(0, "k"); got != v {
+		t.Errorf("version changed after GET: %d -> %d", v, got)
+	}
+	ms.Set("k", []byte("v2"))
+	if got := ms.KeyVersion(0, "k"); got == v {
+		t.Error("version unchanged after SET")
+	}
+
+	// FLUSHDB 只修改存在的键
+	v = ms.KeyVersion(0, "k")
+	ms.FlushDB()
+	if got := ms.KeyVersion(0, "k"); got == v {
+		t.Error("version unchanged after FLUSHDB")
+	}
+	if got := ms.KeyVersion(0, "missing"); got != vm {
+		t.Error("FLUSHDB changed the version of a missing key")
+	}
+
+	// 过期
+	ms.Set("e", []byte("v"))
+	ms.Expire("e", time.Millisecond)
+	ve := ms.Watch(0, "e")
+	time.Sleep(5 * time.Millisecond)
+	if got := ms.KeyVersion(0, "e"); got == ve {
+		t.Error("version unchanged after expiry")
+	}
+
+	// 引用计数归零之后释放
+	ms.Unwatch(0, "k")
+	if _, ok := ms.databases[0].watched["k"]; !ok {
+		t.Error("released while still watched")
+	}
+	ms.Unwatch(0, "k")
+	ms.Unwatch(0, "missing")
+	ms.Unwatch(0, "e")
+	if n := len(ms.databases[0].watched); n != 0 {
+		t.Errorf("%d watched keys left", n)
+	}
+}
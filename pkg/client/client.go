@@ -2,10 +2,12 @@ package client
 
 import (
 	"bufio"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"literedis/pkg/protocol"
 	"net"
+	"strings"
 	"time"
 )
 
@@ -15,8 +17,26 @@ type Client struct {
 	protocol protocol.Protocol
 }
 
-func NewClient(address string) (*Client, error) {
-	conn, err := net.Dial("tcp", address)
+func NewClient(address string, opts ...Option) (*Client, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// unix:///path/to/socket 表示通过 unix domain socket 连接
+	network := "tcp"
+	if path, ok := strings.CutPrefix(address, "unix://"); ok {
+		network, address = "unix", path
+	}
+
+	dialer := &net.Dialer{Timeout: o.dialTimeout}
+	var conn net.Conn
+	var err error
+	if o.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, network, address, o.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(network, address)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -51,7 +71,11 @@ func (c *Client) Do(cmd string, args ...interface{}) (interface{}, error) {
 		return nil, err
 	}
 
-	// Read the response
+	return c.Receive()
+}
+
+// Receive 读取服务端的下一条回复，用于 MONITOR、订阅等服务端主动推送的场景
+func (c *Client) Receive() (interface{}, error) {
 	resp, err := c.protocol.Unpack(c.reader)
 	if err != nil {
 		return nil, err
@@ -63,11 +87,20 @@ func (c *Client) Do(cmd string, args ...interface{}) (interface{}, error) {
 func (c *Client) parseResponse(resp *protocol.Message) (interface{}, error) {
 	switch resp.Type {
 	case "SimpleString", "BulkString":
-		return string(resp.Content.([]byte)), nil
+		switch v := resp.Content.(type) {
+		case []byte:
+			return string(v), nil
+		case string:
+			return v, nil
+		}
+		return nil, nil
 	case "Integer":
 		return resp.Content.(int64), nil
 	case "Array":
 		array := resp.Content.([]*protocol.Message)
+		if array == nil {
+			return nil, nil
+		}
 		result := make([]interface{}, len(array))
 		for i, item := range array {
 			parsed, err := c.parseResponse(item)
@@ -78,7 +111,7 @@ func (c *Client) parseResponse(resp *protocol.Message) (interface{}, error) {
 		}
 		return result, nil
 	case "Error":
-		return nil, errors.New(string(resp.Content.([]byte)))
+		return nil, errors.New(fmt.Sprint(resp.Content))
 	default:
 		return nil, fmt.Errorf("unknown response type: %s", resp.Type)
 	}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+)
+
+type options struct {
+	dialTimeout time.Duration
+	tlsConfig   *tls.Config
+}
+
+type Option func(o *options)
+
+func defaultOptions() *options {
+	return &options{
+		dialTimeout: 5 * time.Second,
+	}
+}
+
+func WithDialTimeout(d time.Duration) Option {
+	return func(o *options) { o.dialTimeout = d }
+}
+
+// WithTLS 使用 TLS 连接服务器
+func WithTLS(conf *tls.Config) Option {
+	return func(o *options) { o.tlsConfig = conf }
+}
+
+// TLSFiles 客户端 TLS 文件配置
+type TLSFiles struct {
+	CACertFile string // 用于校验服务端证书，为空时使用系统根证书
+	CertFile   string // 客户端证书，服务端要求双向认证时需要
+	KeyFile    string
+	ServerName string // SNI，为空时使用连接地址中的主机名
+	Insecure   bool   // 跳过服务端证书校验
+}
+
+// LoadTLSConfig 根据文件生成客户端 TLS 配置
+func LoadTLSConfig(files TLSFiles) (*tls.Config, error) {
+	conf := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         files.ServerName,
+		InsecureSkipVerify: files.Insecure,
+	}
+	if files.CACertFile != "" {
+		pem, err := os.ReadFile(files.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", files.CACertFile)
+		}
+		conf.RootCAs = pool
+	}
+	if files.CertFile != "" || files.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
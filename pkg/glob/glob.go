@@ -0,0 +1,104 @@
+// Package glob 实现 Redis 的 glob 风格匹配（stringmatchlen），
+// 用于频道和键的模式匹配。
+// 与 filepath.Match 不同，'*' 和 '?' 可以匹配 '/'，模式本身不会出错：
+// 未闭合的 '[' 一直延伸到模式结尾，末尾的 '\' 按普通字符处理。
+package glob
+
+// maxNesting 限制 '*' 的递归深度，避免病态模式耗尽栈
+const maxNesting = 1000
+
+// Match 判断 s 是否匹配 pattern
+func Match(pattern, s string) bool {
+	skipLonger := false
+	return match(pattern, s, 0, &skipLonger)
+}
+
+func match(p, s string, nesting int, skipLonger *bool) bool {
+	if nesting > maxNesting {
+		return false
+	}
+	pi, si := 0, 0
+	for pi < len(p) && si < len(s) {
+		switch p[pi] {
+		case '*':
+			for pi+1 < len(p) && p[pi+1] == '*' {
+				pi++
+			}
+			if pi+1 == len(p) {
+				return true
+			}
+			for si < len(s) {
+				if match(p[pi+1:], s[si:], nesting+1, skipLonger) {
+					return true
+				}
+				// 后面的模式在更短的后缀上已经失败，更长的位置也不会成功
+				if *skipLonger {
+					return false
+				}
+				si++
+			}
+			*skipLonger = true
+			return false
+		case '?':
+			si++
+		case '[':
+			pi++
+			not := pi < len(p) && p[pi] == '^'
+			if not {
+				pi++
+			}
+			matched := false
+			for {
+				if pi+1 < len(p) && p[pi] == '\\' {
+					pi++
+					if p[pi] == s[si] {
+						matched = true
+					}
+				} else if pi < len(p) && p[pi] == ']' {
+					break
+				} else if pi >= len(p) {
+					// 未闭合的 '['，退回一步让下面的 pi++ 走到结尾
+					pi--
+					break
+				} else if pi+2 < len(p) && p[pi+1] == '-' {
+					start, end := p[pi], p[pi+2]
+					if start > end {
+						start, end = end, start
+					}
+					pi += 2
+					if s[si] >= start && s[si] <= end {
+						matched = true
+					}
+				} else if p[pi] == s[si] {
+					matched = true
+				}
+				pi++
+			}
+			if not {
+				matched = !matched
+			}
+			if !matched {
+				return false
+			}
+			si++
+		case '\\':
+			if pi+1 < len(p) {
+				pi++
+			}
+			fallthrough
+		default:
+			if p[pi] != s[si] {
+				return false
+			}
+			si++
+		}
+		pi++
+		if si == len(s) {
+			for pi < len(p) && p[pi] == '*' {
+				pi++
+			}
+			break
+		}
+	}
+	return pi == len(p) && si == len(s)
+}
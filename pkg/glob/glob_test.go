@@ -0,0 +1,55 @@
+package glob
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "", false}, // 与 stringmatchlen 一致，空串只匹配空模式
+		{"*", "a/b", true},
+		{"", "", true},
+		{"", "a", false},
+		{"a", "", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "hllo", true},
+		{"h*llo", "heeeello", true},
+		{"h*llo", "hello!", false},
+		{"a**b", "axxb", true},
+		{"a*", "a", true},
+		{"news.*", "news.a/b", true},
+		{"user:?", "user:/", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hallo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{`h[\]]llo`, "h]llo", true},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{`a\`, `a\`, true},
+		{"a[b", "ab", true},
+		{"a[", "a", false},
+		{"[a-", "a", true},
+		{"[^", "a", true},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPathological(t *testing.T) {
+	pattern := strings.Repeat("a*", 50) + "b"
+	if Match(pattern, strings.Repeat("a", 100)) {
+		t.Error("pathological pattern matched")
+	}
+}
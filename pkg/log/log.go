@@ -2,11 +2,14 @@ package log
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/pprof"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -26,9 +29,23 @@ var levelMap = map[string]zapcore.Level{
 	"panic": zapcore.PanicLevel,
 }
 
+// 每秒内相同级别和消息的日志只输出前 sampleFirst 条，之后每 sampleThereafter 条输出一条，
+// 避免命令执行等热点路径刷屏
+const (
+	sampleTick       = time.Second
+	sampleFirst      = 100
+	sampleThereafter = 100
+)
+
 var (
-	srv    *http.Server
-	logger *zap.SugaredLogger
+	srv *http.Server
+	// atomicLevel 当前日志级别，可以在运行时通过 SetLevel 修改
+	atomicLevel = zap.NewAtomicLevel()
+	// current 当前的日志输出，可以在运行时通过 SetFile 切换
+	current atomic.Pointer[output]
+	// logger discards everything until Init is called, so packages can log
+	// safely from tests and tools that never initialise logging.
+	logger = zap.NewNop().Sugar()
 )
 
 func toZapLevel(l string) zapcore.Level {
@@ -38,8 +55,27 @@ func toZapLevel(l string) zapcore.Level {
 	return zapcore.InfoLevel
 }
 
+// SetLevel 修改日志级别，立即生效
+func SetLevel(level string) error {
+	l, ok := levelMap[level]
+	if !ok {
+		return fmt.Errorf("invalid log level: %q", level)
+	}
+	atomicLevel.SetLevel(l)
+	return nil
+}
+
+// DebugEnabled 返回是否输出 debug 日志，热点路径上可以据此跳过构造日志字段
+func DebugEnabled() bool {
+	return atomicLevel.Enabled(zapcore.DebugLevel)
+}
+
+// Level 返回当前日志级别
+func Level() string {
+	return atomicLevel.Level().String()
+}
+
 func Init(serviceName, level string, logPaths ...string) {
-	var atomicLevel = zap.NewAtomicLevel()
 	atomicLevel.SetLevel(toZapLevel(level))
 
 	var logPath string
@@ -81,9 +117,15 @@ func Init(serviceName, level string, logPaths ...string) {
 	//highCore := newCore(filePath, highLevel, "error.log")
 	//lowCore := newCore(filePath, lowLever, "info.log")
 
-	core := newCore(logPath, atomicLevel)
+	filename := filepath.Join(logPath, time.Now().Format("2006-01-02")+".log")
+	current.Store(newOutput(filename, true))
+	logger = newLogger(serviceName)
+}
+
+func newLogger(serviceName string) *zap.SugaredLogger {
+	core := zapcore.NewSamplerWithOptions(&switchCore{}, sampleTick, sampleFirst, sampleThereafter)
 	log := zap.New(
-		zapcore.NewTee(core),
+		core,
 		zap.AddCaller(),
 		zap.AddCallerSkip(1),
 		zap.Development(),
@@ -91,7 +133,7 @@ func Init(serviceName, level string, logPaths ...string) {
 		//zap.Fields(zap.String("func", funcName())),
 		zap.Fields(zap.String("usecase", serviceName)),
 	)
-	logger = log.Sugar()
+	return log.Sugar()
 }
 
 func funcName() string {
@@ -102,17 +144,15 @@ func funcName() string {
 	return filepath.Base(runtime.FuncForPC(pc).Name())
 }
 
-func newCore(logPath string, atomicLevel zap.AtomicLevel) zapcore.Core {
-	filename := time.Now().Format("2006-01-02") + ".log"
-	logPath = filepath.Join(logPath, filename)
-	fileWriteSyncer := &lumberjack.Logger{
-		Filename:   logPath, // 日志文件存放目录
-		MaxSize:    100,     // 文件大小限制,单位MB
-		MaxBackups: 30,      // 最大保留日志文件数量
-		MaxAge:     7,       // 日志文件保留天数
-		Compress:   true,    // 是否压缩
-	}
+// output 日志输出的目标
+type output struct {
+	core zapcore.Core
+	file string
+	lj   *lumberjack.Logger
+}
 
+// newOutput 创建写到 file 的输出，file 为空时只写标准输出
+func newOutput(file string, stdout bool) *output {
 	encoderConfig := zapcore.EncoderConfig{
 		TimeKey:        "ts",
 		LevelKey:       "level",
@@ -127,14 +167,124 @@ func newCore(logPath string, atomicLevel zap.AtomicLevel) zapcore.Core {
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 		EncodeName:     zapcore.FullNameEncoder,
 	}
-	return zapcore.NewCore(
+	o := &output{file: file}
+	var syncers []zapcore.WriteSyncer
+	if stdout || file == "" {
+		syncers = append(syncers, zapcore.AddSync(os.Stdout))
+	}
+	if file != "" {
+		o.lj = &lumberjack.Logger{
+			Filename:   file, // 日志文件路径
+			MaxSize:    100,  // 文件大小限制,单位MB
+			MaxBackups: 30,   // 最大保留日志文件数量
+			MaxAge:     7,    // 日志文件保留天数
+			Compress:   true, // 是否压缩
+		}
+		syncers = append(syncers, zapcore.AddSync(o.lj))
+	}
+	o.core = zapcore.NewCore(
 		zapcore.NewJSONEncoder(encoderConfig),
-		zapcore.NewMultiWriteSyncer(
-			zapcore.AddSync(os.Stdout),
-			zapcore.AddSync(fileWriteSyncer),
-		),
+		zapcore.NewMultiWriteSyncer(syncers...),
 		atomicLevel,
 	)
+	return o
+}
+
+// switchCore 把日志转发到当前的输出。SetFile 切换输出之后，之前通过 With 派生的
+// logger（如每个连接的 logger）也会写到新的输出
+type switchCore struct {
+	fields []zapcore.Field
+}
+
+func (c *switchCore) Enabled(l zapcore.Level) bool {
+	return atomicLevel.Enabled(l)
+}
+
+func (c *switchCore) With(fields []zapcore.Field) zapcore.Core {
+	return &switchCore{fields: append(slices.Clip(c.fields), fields...)}
+}
+
+func (c *switchCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+	return ce
+}
+
+func (c *switchCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	o := current.Load()
+	if o == nil {
+		return nil
+	}
+	if len(c.fields) > 0 {
+		fields = append(slices.Clip(c.fields), fields...)
+	}
+	return o.core.Write(e, fields)
+}
+
+func (c *switchCore) Sync() error {
+	if o := current.Load(); o != nil {
+		return o.core.Sync()
+	}
+	return nil
+}
+
+// SetFile 把日志切换到 file，立即生效，file 为空表示写到标准输出
+func SetFile(file string) error {
+	if file != "" {
+		if dir := filepath.Dir(file); dir != "" {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+		}
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+	old := current.Swap(newOutput(file, false))
+	if old != nil {
+		old.core.Sync()
+		if old.lj != nil {
+			old.lj.Close()
+		}
+	}
+	return nil
+}
+
+// File 返回当前的日志文件，空字符串表示标准输出
+func File() string {
+	if o := current.Load(); o != nil {
+		return o.file
+	}
+	return ""
+}
+
+// Logger 带有固定字段的日志记录器，如连接的 cid、地址
+type Logger struct {
+	s *zap.SugaredLogger
+}
+
+// With 返回带有 kvs 字段的 Logger，每一行日志都会带上这些字段
+func With(kvs ...DefaultPair) *Logger {
+	return &Logger{s: logger.With(spread(kvs...)...)}
+}
+
+func (l *Logger) Debugw(msg string, kvs ...DefaultPair) {
+	l.s.Debugw(msg, spread(kvs...)...)
+}
+
+func (l *Logger) Infow(msg string, kvs ...DefaultPair) {
+	l.s.Infow(msg, spread(kvs...)...)
+}
+
+func (l *Logger) Warnw(msg string, kvs ...DefaultPair) {
+	l.s.Warnw(msg, spread(kvs...)...)
+}
+
+func (l *Logger) Errorw(msg string, kvs ...DefaultPair) {
+	l.s.Errorw(msg, spread(kvs...)...)
 }
 
 type DefaultPair struct {
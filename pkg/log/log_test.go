@@ -0,0 +1,111 @@
+package log
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readLines 读取日志文件中的 JSON 行
+func readLines(t *testing.T, file string) []map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("invalid json line %q: %v", line, err)
+		}
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func TestSetFileAndWith(t *testing.T) {
+	old := logger
+	defer func() {
+		logger = old
+		current.Store(nil)
+		SetLevel("info")
+	}()
+	logger = newLogger("test")
+	SetLevel("debug")
+
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.log")
+	if err := SetFile(first); err != nil {
+		t.Fatal(err)
+	}
+	if File() != first {
+		t.Errorf("File() = %q", File())
+	}
+
+	conn := With(Pair("cid", 7), Pair("user", "alice"))
+	conn.Infow("client connected", Pair("db", 0))
+
+	// 切换文件之后，之前派生的 logger 也写到新文件
+	second := filepath.Join(dir, "sub", "second.log")
+	if err := SetFile(second); err != nil {
+		t.Fatal(err)
+	}
+	conn.Warnw("slow client")
+	Infof("global %d", 1)
+
+	if err := SetLevel("warn"); err != nil {
+		t.Fatal(err)
+	}
+	conn.Infow("filtered")
+	conn.Debugw("filtered")
+	Sync()
+
+	lines := readLines(t, first)
+	if len(lines) != 1 || lines[0]["msg"] != "client connected" || lines[0]["cid"] != 7.0 ||
+		lines[0]["user"] != "alice" || lines[0]["db"] != 0.0 || lines[0]["usecase"] != "test" {
+		t.Errorf("first file = %v", lines)
+	}
+	lines = readLines(t, second)
+	if len(lines) != 2 || lines[0]["msg"] != "slow client" || lines[0]["cid"] != 7.0 || lines[1]["msg"] != "global 1" {
+		t.Errorf("second file = %v", lines)
+	}
+	if _, ok := lines[1]["cid"]; ok {
+		t.Error("global logger must not carry connection fields")
+	}
+
+	if err := SetFile(filepath.Join(first, "not-a-dir", "x.log")); err == nil {
+		t.Error("SetFile under a regular file should fail")
+	}
+	if File() != second {
+		t.Errorf("failed SetFile changed the output to %q", File())
+	}
+}
+
+func TestSampling(t *testing.T) {
+	old := logger
+	defer func() {
+		logger = old
+		current.Store(nil)
+	}()
+	logger = newLogger("test")
+
+	file := filepath.Join(t.TempDir(), "sample.log")
+	if err := SetFile(file); err != nil {
+		t.Fatal(err)
+	}
+	conn := With(Pair("cid", 1))
+	for i := 0; i < sampleFirst+2*sampleThereafter; i++ {
+		conn.Warnw("hot path")
+	}
+	Sync()
+	// 跨过采样周期时计数会重置，只检查被采样掉了大部分
+	if n := len(readLines(t, file)); n < sampleFirst+2 || n >= sampleFirst+sampleThereafter {
+		t.Errorf("sampled lines = %d, want about %d", n, sampleFirst+2)
+	}
+}
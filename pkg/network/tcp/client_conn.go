@@ -3,6 +3,7 @@ package tcp
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"literedis/pkg/network"
@@ -57,7 +58,7 @@ func (c *clientConn) readLoop() {
 		default:
 			buf, err := reader.ReadBytes('\n')
 			if err != nil {
-				if err != io.EOF && err != io.ErrUnexpectedEOF {
+				if err != io.EOF && err != io.ErrUnexpectedEOF && !errors.Is(err, net.ErrClosed) {
 					fmt.Println("read err: ", err)
 				}
 				return
@@ -113,8 +114,12 @@ func (c *clientConn) Push(msg []byte) error {
 	if err := c.checkState(); err != nil {
 		return err
 	}
-	c.sendCh <- msg
-	return nil
+	select {
+	case c.sendCh <- msg:
+		return nil
+	case <-c.done:
+		return network.ErrConnectionClosed
+	}
 }
 
 func (c *clientConn) State() network.ConnState {
@@ -122,12 +127,16 @@ func (c *clientConn) State() network.ConnState {
 }
 
 func (c *clientConn) Close() error {
-	if err := c.checkState(); err != nil {
-		return err
+	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnOpened), int32(network.ConnClosed)) {
+		return network.ErrConnectionClosed
 	}
 
-	close(c.sendCh)
-	return c.conn.Close()
+	close(c.done)
+	err := c.conn.Close()
+	if c.client.disconnectHandler != nil {
+		c.client.disconnectHandler(c, err)
+	}
+	return err
 }
 
 func (c *clientConn) LocalIP() string {
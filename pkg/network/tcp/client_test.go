@@ -1,35 +1,56 @@
 package tcp
 
 import (
-	"fmt"
-	"literedis/pkg/network"
 	"testing"
+	"time"
+
+	"literedis/pkg/network"
 )
 
 func TestClient(t *testing.T) {
-	c := NewClient("127.0.0.1:8899")
+	s := NewServer("127.0.0.1:8898")
+	s.OnReceive(func(conn network.Conn, msg []byte) {
+		conn.Send([]byte("+OK\r\n"))
+	})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	c := NewClient("127.0.0.1:8898")
+
+	connected := make(chan struct{}, 1)
+	received := make(chan string, 3)
 
 	c.OnConnect(func(conn network.Conn) {
-		fmt.Println("connect")
+		connected <- struct{}{}
 	})
 
 	c.OnReceive(func(conn network.Conn, msg []byte) {
-		fmt.Println("msg: ", string(msg))
-	})
-
-	c.OnDisconnect(func(conn network.Conn, err error) {
-		fmt.Println("disconnect: ", err)
+		received <- string(msg)
 	})
 
 	conn, err := c.Dial()
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	<-connected
+
+	for i := 0; i < 3; i++ {
+		if err = conn.Send([]byte("test\n")); err != nil {
+			t.Fatalf("send err: %v", err)
+		}
 	}
 
 	for i := 0; i < 3; i++ {
-		b := []byte("test\n")
-		if err = conn.Send(b); err != nil {
-			fmt.Println("send err:", err)
+		select {
+		case msg := <-received:
+			if msg != "+OK\r" {
+				t.Fatalf("unexpected reply: %q", msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for reply")
 		}
 	}
 }
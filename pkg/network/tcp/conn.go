@@ -2,12 +2,11 @@ package tcp
 
 import (
 	"bufio"
-	"bytes"
 	"context"
-	"fmt"
 	"io"
 	"literedis/pkg/log"
 	"literedis/pkg/network"
+	"literedis/pkg/protocol"
 	"net"
 	"net/url"
 	"sync"
@@ -65,15 +64,13 @@ func (c *Conn) State() network.ConnState {
 }
 
 func (c *Conn) Close() error {
-	if err := c.checkState(); err != nil {
-		return err
+	if !atomic.CompareAndSwapInt32(&c.state, int32(network.ConnOpened), int32(network.ConnClosed)) {
+		return c.checkState()
 	}
 
-	atomic.StoreInt32(&c.state, int32(network.ConnClosed))
-	delete(c.srv.conns, c.conn)
+	c.srv.removeConn(c.conn)
+	close(c.done)
 	err := c.conn.Close()
-	c.conn = nil
-	c.srv.pool.Put(c)
 
 	if c.srv.disconnectHandler != nil {
 		c.srv.disconnectHandler(c, err)
@@ -133,6 +130,8 @@ func (c *Conn) process(ctx context.Context) {
 		select {
 		case <-c.srv.exitCh:
 			return
+		case <-c.done:
+			return
 		case msg := <-c.msgCh:
 			c.srv.receiveHandler(c, msg)
 		}
@@ -150,15 +149,18 @@ func (c *Conn) readLoop(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		default:
-			buf, err := reader.ReadBytes('\n')
+			buf, err := protocol.ReadFrame(reader)
 			if err != nil {
-				fmt.Println("read err: ", err)
-				if err == io.EOF || err == io.ErrUnexpectedEOF {
-					err = ErrClientClosed
+				if err != io.EOF && err != io.ErrUnexpectedEOF {
+					log.Debugf("read err: %v", err)
 				}
 				return
 			}
-			c.msgCh <- bytes.Trim(buf, "\r\n")
+			select {
+			case c.msgCh <- buf:
+			case <-c.done:
+				return
+			}
 
 			v, ok := c.srv.sessions.Load(c.sessId)
 			if !ok {
@@ -179,6 +181,8 @@ func (c *Conn) writeLoop(ctx context.Context) {
 			return
 		case <-ctx.Done():
 			return
+		case <-c.done:
+			return
 		case msg := <-c.sendCh:
 			_, err := c.conn.Write(msg)
 			if err != nil {
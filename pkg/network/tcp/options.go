@@ -2,19 +2,24 @@ package tcp
 
 import (
 	"crypto/tls"
+	"os"
 	"time"
 )
 
 type Options struct {
-	addr      string
-	tlsConf   *tls.Config
-	heartbeat time.Duration
+	addr       string
+	network    string // tcp 或 unix
+	socketPerm os.FileMode
+	tlsConf    *tls.Config
+	heartbeat  time.Duration
+	keepAlive  func() time.Duration
 }
 
 type OptionFunc func(o *Options)
 
 func defaultOptions() *Options {
 	return &Options{
+		network:   "tcp",
 		tlsConf:   nil,
 		heartbeat: 0,
 	}
@@ -31,3 +36,25 @@ func WithHeartbeat(t time.Duration) OptionFunc {
 		o.heartbeat = t
 	}
 }
+
+// WithNetwork 设置监听的网络类型：tcp 或 unix
+func WithNetwork(network string) OptionFunc {
+	return func(o *Options) {
+		o.network = network
+	}
+}
+
+// WithSocketPerm 设置 unix socket 文件的权限，0 表示不修改
+func WithSocketPerm(perm os.FileMode) OptionFunc {
+	return func(o *Options) {
+		o.socketPerm = perm
+	}
+}
+
+// WithKeepAlive 设置新连接的 TCP keepalive 间隔，每次 accept 时调用 period，
+// 因此修改后对之后建立的连接生效
+func WithKeepAlive(period func() time.Duration) OptionFunc {
+	return func(o *Options) {
+		o.keepAlive = period
+	}
+}
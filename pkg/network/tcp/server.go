@@ -2,12 +2,15 @@ package tcp
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"literedis/pkg/log"
 	"literedis/pkg/network"
 	"literedis/pkg/protocol"
 	"net"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,20 +19,20 @@ var (
 	ErrClientClosed = errors.New("client closed")
 )
 
-const (
-	IdleTime   = 60
-	MaxConnNum = 1024
-)
+// nextConnID 所有服务器共享的连接 ID，保证多个监听之间不重复
+var nextConnID atomic.Int64
 
 type server struct {
-	cid      int64
 	opts     *Options
 	listener net.Listener
 	sessions *sync.Map
 	pool     sync.Pool
 	conns    map[net.Conn]*Conn
+	connsMu  sync.Mutex
 	protocol protocol.Protocol
 	exitCh   chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
 
 	startHandler      network.StartHandler
 	stopHandler       network.CloseHandler
@@ -44,8 +47,11 @@ func NewServer(addr string, opts ...OptionFunc) network.Server {
 		opt(o)
 	}
 	o.addr = addr
+	ctx, cancel := context.WithCancel(context.Background())
 	return &server{
 		opts:     o,
+		ctx:      ctx,
+		cancel:   cancel,
 		sessions: &sync.Map{},
 		exitCh:   make(chan struct{}),
 		conns:    make(map[net.Conn]*Conn),
@@ -56,10 +62,31 @@ func NewServer(addr string, opts ...OptionFunc) network.Server {
 	}
 }
 
+// NewUnixServer 创建监听 unix domain socket 的服务器，perm 为 socket 文件权限
+func NewUnixServer(path string, perm os.FileMode, opts ...OptionFunc) network.Server {
+	opts = append(opts, WithNetwork("unix"), WithSocketPerm(perm))
+	return NewServer(path, opts...)
+}
+
 func (s *server) Start() error {
-	listener, err := net.Listen("tcp", s.opts.addr)
+	if s.opts.network == "unix" {
+		// 清理上次异常退出留下的 socket 文件
+		if fi, err := os.Stat(s.opts.addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(s.opts.addr)
+		}
+	}
+	listener, err := net.Listen(s.opts.network, s.opts.addr)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	if s.opts.network == "unix" && s.opts.socketPerm != 0 {
+		if err := os.Chmod(s.opts.addr, s.opts.socketPerm); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+	if s.opts.tlsConf != nil {
+		listener = tls.NewListener(listener, s.opts.tlsConf)
 	}
 	s.listener = listener
 	if s.startHandler != nil {
@@ -71,12 +98,9 @@ func (s *server) Start() error {
 	return nil
 }
 
+// serve 接收新连接，监听关闭后已建立的连接继续工作，直到 Stop
 func (s *server) serve() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	go s.heartbeat()
-	s.accept(ctx)
+	s.accept(s.ctx)
 }
 
 func (s *server) accept(ctx context.Context) {
@@ -103,67 +127,81 @@ func (s *server) accept(ctx context.Context) {
 				time.Sleep(tempDelay)
 				continue
 			}
-			log.Errorf("accept connection err: %v", err)
+			if !errors.Is(err, net.ErrClosed) {
+				log.Errorf("accept connection err: %v", err)
+			}
 			return
 		}
 		tempDelay = 0
 
-		if len(s.conns) > MaxConnNum {
-			conn.Close()
-			log.Warn("too many connections")
-			continue
+		if s.opts.keepAlive != nil {
+			setKeepAlive(conn, s.opts.keepAlive())
 		}
 
 		//conn.SetDeadline(time.Now().Add(time.Second))
 		//conn.SetReadDeadline(time.Now().Add(time.Second))
 		//conn.SetWriteDeadline(time.Now().Add(time.Second))
 
-		s.cid++
 		cc := s.pool.Get().(*Conn)
-		cc.cid = s.cid
+		cc.cid = nextConnID.Add(1)
 		cc.conn = conn
 		cc.timer = time.NewTimer(2 * time.Second)
 		cc.msgCh = make(chan []byte, 1024)
 		cc.sendCh = make(chan []byte, 1024)
 		cc.extraMap = make(map[string]interface{})
+		cc.done = make(chan struct{})
+		cc.state = int32(network.ConnOpened)
 		cc.srv = s
 
-		if s.connectHandler != nil {
-			s.connectHandler(cc)
-		}
+		s.connsMu.Lock()
 		s.conns[conn] = cc
+		s.connsMu.Unlock()
 		go cc.process(ctx)
 	}
 }
 
-func (s *server) heartbeat() {
-	tick := time.NewTicker(time.Second)
-	for {
-		select {
-		case <-tick.C:
-			s.sessions.Range(func(key, value interface{}) bool {
-				sess, ok := value.(*Session)
-				if !ok {
-					return true
-				}
-				if time.Now().Unix()-sess.lastTime > IdleTime {
-					sess.GetConn().Close()
-					s.sessions.Delete(key)
-				}
-				return true
-			})
-		}
+// setKeepAlive 设置 TCP keepalive 探测间隔，0 表示关闭，unix socket 忽略
+func setKeepAlive(conn net.Conn, period time.Duration) {
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	if period <= 0 {
+		tc.SetKeepAlive(false)
+		return
 	}
+	tc.SetKeepAlive(true)
+	tc.SetKeepAlivePeriod(period)
+}
+
+func (s *server) removeConn(conn net.Conn) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	delete(s.conns, conn)
+}
+
+// CloseListener 只关闭监听，已建立的连接不受影响，用于重新绑定地址
+func (s *server) CloseListener() error {
+	return s.listener.Close()
 }
 
 func (s *server) Stop() error {
-	if err := s.listener.Close(); err != nil {
+	if err := s.listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
 		return err
 	}
+	s.connsMu.Lock()
+	conns := make([]*Conn, 0, len(s.conns))
 	for _, conn := range s.conns {
+		conns = append(conns, conn)
+	}
+	s.connsMu.Unlock()
+	for _, conn := range conns {
 		conn.Close()
 	}
-	s.conns = nil
+	s.cancel()
 	if s.stopHandler != nil {
 		s.stopHandler()
 	}
@@ -172,7 +210,10 @@ func (s *server) Stop() error {
 }
 
 func (s *server) Protocol() string {
-	return "tcp"
+	if s.opts.tlsConf != nil {
+		return "tls"
+	}
+	return s.opts.network
 }
 
 func (s *server) OnStart(handler network.StartHandler) {
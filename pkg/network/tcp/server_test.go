@@ -1,54 +1,111 @@
 package tcp
 
 import (
-	"fmt"
-	"literedis/pkg/log"
-	"literedis/pkg/network"
+	"bufio"
+	"net"
 	"os"
-	"os/signal"
-	"syscall"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"literedis/pkg/network"
 )
 
 func TestServer(t *testing.T) {
-	s := NewServer(":8899")
+	s := NewServer("127.0.0.1:8899")
 
-	s.OnStart(func() {
-		fmt.Println("start")
-	})
+	started := make(chan struct{})
+	received := make(chan string, 1)
+	disconnected := make(chan struct{}, 1)
 
-	s.OnConnect(func(conn network.Conn) {
-		fmt.Println("connect")
+	s.OnStart(func() {
+		close(started)
 	})
 
 	s.OnReceive(func(conn network.Conn, msg []byte) {
-		fmt.Println("msg: ", string(msg))
+		received <- string(msg)
+		conn.Send([]byte("+PONG\r\n"))
 	})
 
 	s.OnDisconnect(func(conn network.Conn, err error) {
-		fmt.Println("disconnect: ", err)
-	})
-
-	s.OnStop(func() {
-		fmt.Println("stop")
+		disconnected <- struct{}{}
 	})
 
 	if err := s.Start(); err != nil {
-		panic(err)
-	}
-
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
-	for {
-		sig := <-ch
-		switch sig {
-		case syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT:
-			log.Sync()
-			s.Stop()
-			return
-		case syscall.SIGHUP:
-		default:
-			return
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	<-started
+
+	conn, err := net.Dial("tcp", "127.0.0.1:8899")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 内联命令会被转换为 RESP 数组
+	if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-received:
+		if msg != "*1\r\n$4\r\nPING\r\n" {
+			t.Fatalf("unexpected frame: %q", msg)
 		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "+PONG\r\n" {
+		t.Fatalf("unexpected reply: %q", line)
+	}
+
+	conn.Close()
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for disconnect")
+	}
+}
+
+func TestUnixServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "literedis.sock")
+	s := NewUnixServer(path, 0700)
+	s.OnReceive(func(conn network.Conn, msg []byte) {
+		conn.Send([]byte("+PONG\r\n"))
+	})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Protocol() != "unix" {
+		t.Errorf("Protocol() = %s", s.Protocol())
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0700 {
+		t.Errorf("socket perm = %o, want 700", fi.Mode().Perm())
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("PING\r\n"))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "+PONG\r\n" {
+		t.Fatalf("reply = %q, %v", line, err)
+	}
+
+	s.Stop()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket file not removed after Stop: %v", err)
 	}
 }
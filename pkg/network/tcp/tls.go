@@ -0,0 +1,205 @@
+package tcp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"literedis/pkg/log"
+)
+
+// 证书文件在短时间内的多次变化合并为一次重新加载
+const tlsReloadDelay = 100 * time.Millisecond
+
+// TLSFiles TLS 证书相关文件
+type TLSFiles struct {
+	CertFile   string
+	KeyFile    string
+	CAFile     string // 用于校验客户端证书，为空时不校验
+	ClientAuth tls.ClientAuthType
+}
+
+// ParseClientAuth 解析 tls-auth-clients 配置：yes、no 或 optional
+func ParseClientAuth(s string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(s) {
+	case "", "yes":
+		return tls.RequireAndVerifyClientCert, nil
+	case "no":
+		return tls.NoClientCert, nil
+	case "optional":
+		return tls.VerifyClientCertIfGiven, nil
+	}
+	return tls.NoClientCert, fmt.Errorf("invalid tls-auth-clients value: %q", s)
+}
+
+// TLSReloader 持有当前生效的 TLS 配置，证书可以在不重启监听的情况下重新加载，
+// 已建立的连接不受影响
+type TLSReloader struct {
+	files   TLSFiles
+	current atomic.Pointer[tls.Config]
+
+	mu      sync.Mutex
+	watcher *fsnotify.Watcher
+	timer   *time.Timer
+}
+
+// NewTLSReloader 加载证书并返回 reloader
+func NewTLSReloader(files TLSFiles) (*TLSReloader, error) {
+	r := &TLSReloader{files: files}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Config 返回传给 WithTLS 的配置，每次握手都会使用最新加载的证书
+func (r *TLSReloader) Config() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load(), nil
+		},
+	}
+}
+
+// Reload 重新读取证书文件，失败时继续使用旧的证书
+func (r *TLSReloader) Reload() error {
+	conf, err := loadTLSConfig(r.files)
+	if err != nil {
+		return err
+	}
+	r.current.Store(conf)
+	return nil
+}
+
+func loadTLSConfig(files TLSFiles) (*tls.Config, error) {
+	if files.CertFile == "" || files.KeyFile == "" {
+		return nil, errors.New("tls-cert-file and tls-key-file are required")
+	}
+	cert, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate: %w", err)
+	}
+
+	conf := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   files.ClientAuth,
+	}
+	if files.CAFile != "" {
+		pem, err := os.ReadFile(files.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("load CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", files.CAFile)
+		}
+		conf.ClientCAs = pool
+	} else if files.ClientAuth == tls.RequireAndVerifyClientCert || files.ClientAuth == tls.VerifyClientCertIfGiven {
+		return nil, errors.New("tls-ca-cert-file is required to verify client certificates")
+	}
+	return conf, nil
+}
+
+// Watch 监听证书文件所在目录，文件变化后自动重新加载
+func (r *TLSReloader) Watch() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.watcher != nil {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	watched := make(map[string]bool)
+	for _, file := range r.watchedFiles() {
+		// 监听目录而不是文件，这样原子替换（rename）也能被感知
+		dir := filepath.Dir(file)
+		if watched[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+		watched[dir] = true
+	}
+	r.watcher = watcher
+
+	go r.watchLoop(watcher)
+	return nil
+}
+
+func (r *TLSReloader) watchedFiles() []string {
+	var files []string
+	for _, f := range []string{r.files.CertFile, r.files.KeyFile, r.files.CAFile} {
+		if f != "" {
+			files = append(files, filepath.Clean(f))
+		}
+	}
+	return files
+}
+
+func (r *TLSReloader) watchLoop(watcher *fsnotify.Watcher) {
+	files := r.watchedFiles()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			for _, f := range files {
+				if filepath.Clean(event.Name) == f {
+					r.scheduleReload()
+					break
+				}
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Errorf("tls certificate watcher error: %v", err)
+		}
+	}
+}
+
+func (r *TLSReloader) scheduleReload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.timer = time.AfterFunc(tlsReloadDelay, func() {
+		if err := r.Reload(); err != nil {
+			log.Errorf("reload tls certificates failed: %v", err)
+			return
+		}
+		log.Info("tls certificates reloaded")
+	})
+}
+
+// Close 停止监听证书文件
+func (r *TLSReloader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	if r.watcher == nil {
+		return nil
+	}
+	err := r.watcher.Close()
+	r.watcher = nil
+	return err
+}
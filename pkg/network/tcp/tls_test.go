@@ -0,0 +1,205 @@
+package tcp
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"literedis/pkg/network"
+)
+
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// newTestCert 生成证书，parent 为 nil 时生成自签名的 CA
+func newTestCert(t *testing.T, dir, name string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{"localhost"},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	c := &testCert{
+		cert:     cert,
+		key:      key,
+		certFile: filepath.Join(dir, name+".crt"),
+		keyFile:  filepath.Join(dir, name+".key"),
+	}
+	writePEM(t, c.certFile, "CERTIFICATE", der)
+	writePEM(t, c.keyFile, "EC PRIVATE KEY", keyDER)
+	return c
+}
+
+func writePEM(t *testing.T, file, typ string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func startTLSServer(t *testing.T, addr string, reloader *TLSReloader) {
+	t.Helper()
+	s := NewServer(addr, WithTLS(reloader.Config()))
+	s.OnReceive(func(conn network.Conn, msg []byte) {
+		conn.Send([]byte("+PONG\r\n"))
+	})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Stop() })
+}
+
+func ping(addr string, conf *tls.Config) (string, error) {
+	conn, err := tls.Dial("tcp", addr, conf)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+		return "", err
+	}
+	return bufio.NewReader(conn).ReadString('\n')
+}
+
+func TestTLSServer(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, dir, "ca", nil)
+	srvCert := newTestCert(t, dir, "server", ca)
+	cliCert := newTestCert(t, dir, "client", ca)
+
+	reloader, err := NewTLSReloader(TLSFiles{
+		CertFile:   srvCert.certFile,
+		KeyFile:    srvCert.keyFile,
+		CAFile:     ca.certFile,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	startTLSServer(t, "127.0.0.1:8897", reloader)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientPair, err := tls.LoadX509KeyPair(cliCert.certFile, cliCert.keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := ping("127.0.0.1:8897", &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientPair}})
+	if err != nil || reply != "+PONG\r\n" {
+		t.Fatalf("mutual TLS ping = %q, %v", reply, err)
+	}
+
+	// 未提供客户端证书时握手失败
+	if reply, err := ping("127.0.0.1:8897", &tls.Config{RootCAs: roots}); err == nil {
+		t.Fatalf("expected handshake failure without client certificate, got %q", reply)
+	}
+}
+
+func TestTLSReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, dir, "ca", nil)
+	first := newTestCert(t, dir, "server", ca)
+
+	reloader, err := NewTLSReloader(TLSFiles{
+		CertFile:   first.certFile,
+		KeyFile:    first.keyFile,
+		ClientAuth: tls.NoClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reloader.Watch(); err != nil {
+		t.Fatal(err)
+	}
+	defer reloader.Close()
+	startTLSServer(t, "127.0.0.1:8896", reloader)
+
+	servedSerial := func() *big.Int {
+		conn, err := tls.Dial("tcp", "127.0.0.1:8896", &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber
+	}
+	if servedSerial().Cmp(first.cert.SerialNumber) != 0 {
+		t.Fatal("server is not using the initial certificate")
+	}
+
+	// 覆盖证书文件后由文件监听触发重新加载
+	second := newTestCert(t, dir, "server", ca)
+	deadline := time.Now().Add(3 * time.Second)
+	for servedSerial().Cmp(second.cert.SerialNumber) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("certificate was not reloaded after file change")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// 证书文件损坏时继续使用旧证书
+	os.WriteFile(second.certFile, []byte("garbage"), 0600)
+	if err := reloader.Reload(); err == nil {
+		t.Fatal("expected reload error")
+	}
+	if servedSerial().Cmp(second.cert.SerialNumber) != 0 {
+		t.Fatal("failed reload must keep the previous certificate")
+	}
+}
+
+func TestParseClientAuth(t *testing.T) {
+	tests := map[string]tls.ClientAuthType{
+		"yes":      tls.RequireAndVerifyClientCert,
+		"no":       tls.NoClientCert,
+		"optional": tls.VerifyClientCertIfGiven,
+	}
+	for in, want := range tests {
+		if got, err := ParseClientAuth(in); err != nil || got != want {
+			t.Errorf("ParseClientAuth(%q) = %v, %v", in, got, err)
+		}
+	}
+	if _, err := ParseClientAuth("maybe"); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -3,6 +3,7 @@ package protocol
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -23,70 +24,115 @@ const (
 	IntegerPrefix      = ':'
 	BulkStringPrefix   = '$'
 	ArrayPrefix        = '*'
+	MapPrefix          = '%' // RESP3，Content 为依次排列的键和值
+	PushPrefix         = '>' // RESP3 带外推送，如发布订阅的消息
 	CRLF               = "\r\n"
 )
 
+// ErrProtocol is returned when a frame does not follow the RESP grammar
+var ErrProtocol = errors.New("protocol error")
+
 // Pack packs a Message into a RESP packet
 func (p *RESPProtocol) Pack(msg *Message) ([]byte, error) {
 	var buf bytes.Buffer
-	writer := bufio.NewWriter(&buf)
+	if err := p.write(&buf, msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
+func (p *RESPProtocol) write(buf *bytes.Buffer, msg *Message) error {
 	switch msg.Type {
 	case "SimpleString":
-		_, err := writer.WriteString(fmt.Sprintf("%c%s%s", SimpleStringPrefix, msg.Content, CRLF))
-		if err != nil {
-			return nil, err
-		}
+		fmt.Fprintf(buf, "%c%s%s", SimpleStringPrefix, msg.Content, CRLF)
 	case "Error":
-		_, err := writer.WriteString(fmt.Sprintf("%c%s%s", ErrorPrefix, msg.Content, CRLF))
-		if err != nil {
-			return nil, err
-		}
+		fmt.Fprintf(buf, "%c%s%s", ErrorPrefix, msg.Content, CRLF)
 	case "Integer":
-		_, err := writer.WriteString(fmt.Sprintf("%c%d%s", IntegerPrefix, msg.Content, CRLF))
-		if err != nil {
-			return nil, err
-		}
+		fmt.Fprintf(buf, "%c%d%s", IntegerPrefix, msg.Content, CRLF)
+	case "Null":
+		fmt.Fprintf(buf, "%c-1%s", BulkStringPrefix, CRLF)
 	case "BulkString":
-		content := msg.Content.([]byte)
+		var content []byte
+		switch v := msg.Content.(type) {
+		case []byte:
+			content = v
+		case string:
+			content = []byte(v)
+		case nil:
+		default:
+			return fmt.Errorf("invalid bulk string content: %T", msg.Content)
+		}
 		if content == nil {
-			_, err := writer.WriteString(fmt.Sprintf("%c-1%s", BulkStringPrefix, CRLF))
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			_, err := writer.WriteString(fmt.Sprintf("%c%d%s%s%s", BulkStringPrefix, len(content), CRLF, content, CRLF))
-			if err != nil {
-				return nil, err
-			}
+			fmt.Fprintf(buf, "%c-1%s", BulkStringPrefix, CRLF)
+			return nil
 		}
-	case "Array":
-		content := msg.Content.([]*Message)
-		_, err := writer.WriteString(fmt.Sprintf("%c%d%s", ArrayPrefix, len(content), CRLF))
+		fmt.Fprintf(buf, "%c%d%s", BulkStringPrefix, len(content), CRLF)
+		buf.Write(content)
+		buf.WriteString(CRLF)
+	case "Array", "Push", "Map":
+		elems, err := arrayElements(msg.Content)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		for _, elem := range content {
-			elemBytes, err := p.Pack(elem)
-			if err != nil {
-				return nil, err
+		prefix, n := byte(ArrayPrefix), len(elems)
+		switch msg.Type {
+		case "Push":
+			prefix = PushPrefix
+		case "Map":
+			if n%2 != 0 {
+				return errors.New("map with odd number of elements")
 			}
-			_, err = writer.Write(elemBytes)
-			if err != nil {
-				return nil, err
+			prefix, n = MapPrefix, n/2
+		}
+		if elems == nil {
+			fmt.Fprintf(buf, "%c-1%s", prefix, CRLF)
+			return nil
+		}
+		fmt.Fprintf(buf, "%c%d%s", prefix, n, CRLF)
+		for _, elem := range elems {
+			if err := p.write(buf, elem); err != nil {
+				return err
 			}
 		}
 	default:
-		return nil, fmt.Errorf("unknown message type: %s", msg.Type)
+		return fmt.Errorf("unknown message type: %s", msg.Type)
 	}
+	return nil
+}
 
-	writer.Flush()
-	return buf.Bytes(), nil
+// arrayElements normalizes the content types handlers use for arrays.
+// A nil []*Message encodes the RESP null array.
+func arrayElements(content interface{}) ([]*Message, error) {
+	switch v := content.(type) {
+	case []*Message:
+		return v, nil
+	case []string:
+		elems := make([]*Message, len(v))
+		for i, s := range v {
+			elems[i] = &Message{Type: "BulkString", Content: []byte(s)}
+		}
+		return elems, nil
+	case [][]byte:
+		elems := make([]*Message, len(v))
+		for i, b := range v {
+			elems[i] = &Message{Type: "BulkString", Content: b}
+		}
+		return elems, nil
+	case nil:
+		return []*Message{}, nil
+	default:
+		return nil, fmt.Errorf("invalid array content: %T", content)
+	}
 }
 
-// Unpack unpacks a RESP packet into a Message
+// Unpack unpacks a RESP packet into a Message. When reader is a
+// *bufio.Reader it is used directly so that buffered data isn't lost
+// between consecutive frames.
 func (p *RESPProtocol) Unpack(reader io.Reader) (*Message, error) {
-	bufReader := bufio.NewReader(reader)
+	bufReader, ok := reader.(*bufio.Reader)
+	if !ok {
+		bufReader = bufio.NewReader(reader)
+	}
 	prefix, err := bufReader.ReadByte()
 	if err != nil {
 		return nil, err
@@ -94,54 +140,72 @@ func (p *RESPProtocol) Unpack(reader io.Reader) (*Message, error) {
 
 	switch prefix {
 	case SimpleStringPrefix:
-		line, err := bufReader.ReadString('\n')
+		line, err := readLine(bufReader)
 		if err != nil {
 			return nil, err
 		}
-		return &Message{Type: "SimpleString", Content: strings.TrimSuffix(line, CRLF)}, nil
+		return &Message{Type: "SimpleString", Content: line}, nil
 	case ErrorPrefix:
-		line, err := bufReader.ReadString('\n')
+		line, err := readLine(bufReader)
 		if err != nil {
 			return nil, err
 		}
-		return &Message{Type: "Error", Content: strings.TrimSuffix(line, CRLF)}, nil
+		return &Message{Type: "Error", Content: line}, nil
 	case IntegerPrefix:
-		line, err := bufReader.ReadString('\n')
+		line, err := readLine(bufReader)
 		if err != nil {
 			return nil, err
 		}
-		value, err := strconv.ParseInt(strings.TrimSuffix(line, CRLF), 10, 64)
+		value, err := strconv.ParseInt(line, 10, 64)
 		if err != nil {
 			return nil, err
 		}
 		return &Message{Type: "Integer", Content: value}, nil
 	case BulkStringPrefix:
-		line, err := bufReader.ReadString('\n')
+		line, err := readLine(bufReader)
 		if err != nil {
 			return nil, err
 		}
-		length, err := strconv.Atoi(strings.TrimSuffix(line, CRLF))
+		length, err := strconv.Atoi(line)
 		if err != nil {
 			return nil, err
 		}
 		if length == -1 {
 			return &Message{Type: "BulkString", Content: nil}, nil
 		}
+		if length < 0 {
+			return nil, ErrProtocol
+		}
 		data := make([]byte, length+2)
-		_, err = bufReader.Read(data)
-		if err != nil {
+		if _, err = io.ReadFull(bufReader, data); err != nil {
 			return nil, err
 		}
 		return &Message{Type: "BulkString", Content: data[:length]}, nil
-	case ArrayPrefix:
-		line, err := bufReader.ReadString('\n')
+	case ArrayPrefix, PushPrefix, MapPrefix:
+		line, err := readLine(bufReader)
 		if err != nil {
 			return nil, err
 		}
-		length, err := strconv.Atoi(strings.TrimSuffix(line, CRLF))
+		length, err := strconv.Atoi(line)
 		if err != nil {
 			return nil, err
 		}
+		typ := "Array"
+		switch prefix {
+		case PushPrefix:
+			typ = "Push"
+		case MapPrefix:
+			typ = "Map"
+		}
+		if length == -1 {
+			return &Message{Type: typ, Content: []*Message(nil)}, nil
+		}
+		if length < 0 {
+			return nil, ErrProtocol
+		}
+		if prefix == MapPrefix {
+			length *= 2
+		}
 		array := make([]*Message, length)
 		for i := 0; i < length; i++ {
 			element, err := p.Unpack(bufReader)
@@ -150,8 +214,96 @@ func (p *RESPProtocol) Unpack(reader io.Reader) (*Message, error) {
 			}
 			array[i] = element
 		}
-		return &Message{Type: "Array", Content: array}, nil
+		return &Message{Type: typ, Content: array}, nil
 	default:
 		return nil, fmt.Errorf("unknown prefix: %c", prefix)
 	}
 }
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, CRLF), nil
+}
+
+// ReadFrame reads exactly one complete RESP value from reader and returns
+// its raw bytes. Inline commands (a plain line such as "PING\r\n") are
+// converted into the equivalent RESP array so callers only ever see RESP.
+func ReadFrame(reader *bufio.Reader) ([]byte, error) {
+	prefix, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if !isRESPPrefix(prefix[0]) {
+		return readInline(reader)
+	}
+
+	var buf bytes.Buffer
+	if err := copyFrame(reader, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func isRESPPrefix(b byte) bool {
+	switch b {
+	case SimpleStringPrefix, ErrorPrefix, IntegerPrefix, BulkStringPrefix, ArrayPrefix:
+		return true
+	}
+	return false
+}
+
+func copyFrame(reader *bufio.Reader, buf *bytes.Buffer) error {
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+	buf.Write(line)
+	if len(line) < 3 {
+		return ErrProtocol
+	}
+
+	switch line[0] {
+	case SimpleStringPrefix, ErrorPrefix, IntegerPrefix:
+		return nil
+	case BulkStringPrefix:
+		length, err := strconv.Atoi(string(bytes.TrimRight(line[1:], CRLF)))
+		if err != nil {
+			return ErrProtocol
+		}
+		if length < 0 {
+			return nil
+		}
+		_, err = io.CopyN(buf, reader, int64(length)+2)
+		return err
+	case ArrayPrefix:
+		length, err := strconv.Atoi(string(bytes.TrimRight(line[1:], CRLF)))
+		if err != nil {
+			return ErrProtocol
+		}
+		for i := 0; i < length; i++ {
+			if err := copyFrame(reader, buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return ErrProtocol
+	}
+}
+
+func readInline(reader *bufio.Reader) ([]byte, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(line)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%c%d%s", ArrayPrefix, len(fields), CRLF)
+	for _, field := range fields {
+		fmt.Fprintf(&buf, "%c%d%s%s%s", BulkStringPrefix, len(field), CRLF, field, CRLF)
+	}
+	return buf.Bytes(), nil
+}
@@ -1,6 +1,7 @@
 package protocol
 
 import (
+	"bytes"
 	"fmt"
 	"net"
 	"testing"
@@ -99,3 +100,38 @@ func TestResp(t *testing.T) {
 	}
 	fmt.Printf("HGET response: %+v\n", hgetResp)
 }
+
+func TestPackUnpackRESP3(t *testing.T) {
+	p := NewRESPProtocol()
+	tests := []struct {
+		msg  *Message
+		want string
+	}{
+		{&Message{Type: "Push", Content: []string{"message", "ch", "hi"}}, ">3\r\n$7\r\nmessage\r\n$2\r\nch\r\n$2\r\nhi\r\n"},
+		{&Message{Type: "Map", Content: []*Message{
+			{Type: "BulkString", Content: "proto"}, {Type: "Integer", Content: int64(3)},
+		}}, "%1\r\n$5\r\nproto\r\n:3\r\n"},
+	}
+	for _, tt := range tests {
+		data, err := p.Pack(tt.msg)
+		if err != nil {
+			t.Fatalf("Pack(%s): %v", tt.msg.Type, err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("Pack(%s) = %q, want %q", tt.msg.Type, data, tt.want)
+		}
+		msg, err := p.Unpack(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Unpack(%q): %v", data, err)
+		}
+		if msg.Type != tt.msg.Type {
+			t.Errorf("Unpack(%q).Type = %s", data, msg.Type)
+		}
+		if again, _ := p.Pack(msg); string(again) != tt.want {
+			t.Errorf("round trip = %q, want %q", again, tt.want)
+		}
+	}
+	if _, err := p.Pack(&Message{Type: "Map", Content: []string{"odd"}}); err == nil {
+		t.Error("map with odd number of elements packed")
+	}
+}
@@ -2,11 +2,12 @@ package literedis
 
 import (
 	"bufio"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
+	"strings"
 	"strconv"
-	"time"
 )
 
 type Client struct {
@@ -14,8 +15,26 @@ type Client struct {
 	reader *bufio.Reader
 }
 
-func NewClient(address string) (*Client, error) {
-	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+func NewClient(address string, opts ...Option) (*Client, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// unix:///path/to/socket 表示通过 unix domain socket 连接
+	network := "tcp"
+	if path, ok := strings.CutPrefix(address, "unix://"); ok {
+		network, address = "unix", path
+	}
+
+	dialer := &net.Dialer{Timeout: o.dialTimeout}
+	var conn net.Conn
+	var err error
+	if o.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, network, address, o.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(network, address)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to server: %w", err)
 	}
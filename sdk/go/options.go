@@ -0,0 +1,58 @@
+package literedis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+)
+
+type options struct {
+	dialTimeout time.Duration
+	tlsConfig   *tls.Config
+}
+
+// Option 连接选项
+type Option func(o *options)
+
+func defaultOptions() *options {
+	return &options{
+		dialTimeout: 5 * time.Second,
+	}
+}
+
+// WithDialTimeout 设置建立连接的超时时间
+func WithDialTimeout(d time.Duration) Option {
+	return func(o *options) { o.dialTimeout = d }
+}
+
+// WithTLS 使用 TLS 连接服务器
+func WithTLS(conf *tls.Config) Option {
+	return func(o *options) { o.tlsConfig = conf }
+}
+
+// TLSConfigFromFiles 根据 CA 证书和客户端证书生成 TLS 配置。
+// caFile 为空时使用系统根证书，certFile/keyFile 为空时不提供客户端证书
+func TLSConfigFromFiles(caFile, certFile, keyFile string) (*tls.Config, error) {
+	conf := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		conf.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
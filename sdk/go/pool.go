@@ -15,6 +15,7 @@ type Pool struct {
 	mu          sync.Mutex
 	activeConn  int
 	idleClients []*idleClient
+	dialOpts    []Option
 }
 
 type idleClient struct {
@@ -22,8 +23,9 @@ type idleClient struct {
 	lastUsed time.Time
 }
 
-func NewPool(address string, maxSize int, maxIdleSize int, idleTimeout time.Duration) *Pool {
+func NewPool(address string, maxSize int, maxIdleSize int, idleTimeout time.Duration, opts ...Option) *Pool {
 	p := &Pool{
+		dialOpts:    opts,
 		address:     address,
 		maxSize:     maxSize,
 		maxIdleSize: maxIdleSize,
@@ -47,7 +49,7 @@ func (p *Pool) Get() (*Client, error) {
 		p.mu.Lock()
 		defer p.mu.Unlock()
 		if p.activeConn < p.maxSize {
-			client, err := NewClient(p.address)
+			client, err := NewClient(p.address, p.dialOpts...)
 			if err != nil {
 				return nil, err
 			}